@@ -0,0 +1,340 @@
+// Package audit keeps a tamper-evident record of who did what with Callisto
+// data: submissions, deletions, match queries, decryptions by the LOCs and key
+// operations.
+//
+// A Log appends one Record per event to a file. Every record carries the hash
+// of the record before it, so changing, inserting or removing a record breaks
+// the chain, which Verify detects. Removing records from the end leaves a
+// shorter chain that is still valid, so truncation is detected against a
+// Checkpoint: the size and head hash of the log at some point, kept somewhere
+// the log's host cannot rewrite. A log that no longer contains its checkpoint
+// has been truncated or rewritten.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrChainBroken is returned when a record of a log was modified, removed
+	// or inserted
+	ErrChainBroken = errors.New("audit log hash chain broken")
+	// ErrTruncated is returned when a log ends before its checkpoint
+	ErrTruncated = errors.New("audit log truncated")
+)
+
+// Action is the kind of operation an event records
+type Action string
+
+const (
+	Submit                Action = "submit"
+	Delete                Action = "delete"
+	MatchQuery            Action = "match_query"
+	DecryptEntryData      Action = "decrypt_entry_data"
+	DecryptAssignmentData Action = "decrypt_assignment_data"
+	KeyGenerate           Action = "key_generate"
+	KeyRegister           Action = "key_register"
+)
+
+// Event is an operation to be recorded
+type Event struct {
+	Action  Action
+	Actor   string            // Who performed the operation
+	Details map[string]string // What it was performed on
+}
+
+// Recorder records events. Log implements it.
+type Recorder interface {
+	// Record records event. Callers that must not act unaudited stop when
+	// it returns an error.
+	Record(event Event) error
+}
+
+// actorRecorder sets the actor of events that do not name one
+type actorRecorder struct {
+	recorder Recorder
+	actor    string
+}
+
+func (a actorRecorder) Record(event Event) error {
+	if event.Actor == "" {
+		event.Actor = a.actor
+	}
+	return a.recorder.Record(event)
+}
+
+// WithActor returns a Recorder that records events without an actor as
+// performed by actor, e.g. to give a server or a LOC its own identity in a
+// shared log
+func WithActor(recorder Recorder, actor string) Recorder {
+	return actorRecorder{recorder: recorder, actor: actor}
+}
+
+// Digest is a SHA-256 hash, encoded as hex in JSON
+type Digest [sha256.Size]byte
+
+// MarshalText encodes the digest as hex
+func (d Digest) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(d[:])), nil
+}
+
+// UnmarshalText decodes a hex-encoded digest
+func (d *Digest) UnmarshalText(text []byte) error {
+	decoded, err := hex.DecodeString(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid hex: %w", err)
+	}
+	if len(decoded) != len(d) {
+		return fmt.Errorf("digest must be %v bytes, got %v", len(d), len(decoded))
+	}
+	copy(d[:], decoded)
+	return nil
+}
+
+// Record is an event as it is stored in a log, one JSON object per line
+type Record struct {
+	Seq     uint64            `json:"seq"` // Position in the log, from 0
+	Time    time.Time         `json:"time"`
+	Actor   string            `json:"actor"`
+	Action  Action            `json:"action"`
+	Details map[string]string `json:"details,omitempty"`
+	Prev    Digest            `json:"prev"` // Hash of the previous record, zero for the first
+	Hash    Digest            `json:"hash"` // See ComputeHash
+}
+
+// recordLabel separates record hashes from other hashes
+var recordLabel = []byte("gallisto/audit/v1/record")
+
+// ComputeHash returns the hash of every field of the record but Hash: the
+// SHA-256 of a label, the sequence number and time as 8-byte big-endian
+// integers (the time in Unix nanoseconds), the actor, the action, the number
+// of details as a 4-byte big-endian integer followed by each detail's key and
+// value in key order, and finally the previous hash. Strings are each encoded
+// as a 4-byte big-endian length and their bytes.
+func (r Record) ComputeHash() Digest {
+	var b []byte
+	appendString := func(s string) {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+		b = append(b, s...)
+	}
+
+	b = append(b, recordLabel...)
+	b = binary.BigEndian.AppendUint64(b, r.Seq)
+	b = binary.BigEndian.AppendUint64(b, uint64(r.Time.UnixNano()))
+	appendString(r.Actor)
+	appendString(string(r.Action))
+	keys := make([]string, 0, len(r.Details))
+	for key := range r.Details {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(keys)))
+	for _, key := range keys {
+		appendString(key)
+		appendString(r.Details[key])
+	}
+	b = append(b, r.Prev[:]...)
+	return sha256.Sum256(b)
+}
+
+// Checkpoint is the number of records of a log and the hash of its last
+// record. A checkpoint of an empty log has a zero head.
+type Checkpoint struct {
+	Size uint64
+	Head Digest
+}
+
+// String encodes the checkpoint as its size and hex head, separated by a
+// colon
+func (c Checkpoint) String() string {
+	return fmt.Sprintf("%v:%x", c.Size, c.Head[:])
+}
+
+// ParseCheckpoint decodes a checkpoint encoded by Checkpoint.String
+func ParseCheckpoint(s string) (Checkpoint, error) {
+	size, head, ok := strings.Cut(s, ":")
+	if !ok {
+		return Checkpoint{}, fmt.Errorf("invalid checkpoint %q: expected <size>:<head>", s)
+	}
+	var c Checkpoint
+	var err error
+	if c.Size, err = strconv.ParseUint(size, 10, 64); err != nil {
+		return Checkpoint{}, fmt.Errorf("invalid checkpoint size: %w", err)
+	}
+	if err := c.Head.UnmarshalText([]byte(head)); err != nil {
+		return Checkpoint{}, fmt.Errorf("invalid checkpoint head: %w", err)
+	}
+	return c, nil
+}
+
+// Log is an append-only audit log file. It is safe for concurrent use.
+//
+// Record returns once a record is flushed to stable storage, so an operation
+// recorded before it runs cannot go unrecorded in a crash.
+type Log struct {
+	mu   sync.Mutex
+	file *os.File
+	size int64 // End of the last complete record
+	head Checkpoint
+	now  func() time.Time
+}
+
+// LogOption configures optional Log behaviour
+type LogOption func(*Log)
+
+// WithClock makes the log read the time of records from now instead of
+// time.Now
+func WithClock(now func() time.Time) LogOption {
+	return func(l *Log) {
+		l.now = now
+	}
+}
+
+// OpenLog opens the audit log at path, creating it if needed, and verifies its
+// hash chain. A log whose chain is broken is not opened: it must be
+// investigated, not appended to. A record left incomplete by a crash while it
+// was being written is discarded; the operation it recorded did not run.
+func OpenLog(path string, opts ...LogOption) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	l := &Log{file: file, now: time.Now}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	l.head, l.size, err = verify(file, nil, true)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Truncate(l.size); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to repair audit log: %w", err)
+	}
+	if _, err := file.Seek(l.size, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return l, nil
+}
+
+// Record implements Recorder
+func (l *Log) Record(event Event) error {
+	if event.Action == "" {
+		return fmt.Errorf("event has no action")
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record := Record{
+		Seq:     l.head.Size,
+		Time:    l.now().UTC(),
+		Actor:   event.Actor,
+		Action:  event.Action,
+		Details: event.Details,
+		Prev:    l.head.Head,
+	}
+	record.Hash = record.ComputeHash()
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+	line = append(line, '\n')
+
+	if _, err := l.file.Write(line); err != nil {
+		// Drop a partial write so that the next record starts on its own line
+		l.file.Truncate(l.size)
+		l.file.Seek(l.size, io.SeekStart)
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	// The record is written, so the chain continues from it even if it
+	// cannot be flushed
+	l.size += int64(len(line))
+	l.head = Checkpoint{Size: record.Seq + 1, Head: record.Hash}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("failed to flush audit record: %w", err)
+	}
+	return nil
+}
+
+// Checkpoint returns the checkpoint of the records written so far. Copy it
+// out of reach of the log's host to detect later truncation.
+func (l *Log) Checkpoint() Checkpoint {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.head
+}
+
+// Close closes the log's file
+func (l *Log) Close() error {
+	return l.file.Close()
+}
+
+// Verify reads a log written by Log and checks its hash chain. If checkpoint
+// is not nil, the log must also still contain the record checkpoint ends with;
+// otherwise Verify returns an error wrapping ErrTruncated. It returns the
+// checkpoint of the whole log, to be kept for the next verification.
+func Verify(r io.Reader, checkpoint *Checkpoint) (Checkpoint, error) {
+	head, _, err := verify(r, checkpoint, false)
+	return head, err
+}
+
+// verify checks the chain of the records read from r and returns the
+// checkpoint and size in bytes of the records. If repair is set, a last line
+// without a newline is ignored as a record left incomplete by a crash;
+// otherwise it breaks the chain.
+func verify(r io.Reader, checkpoint *Checkpoint, repair bool) (Checkpoint, int64, error) {
+	reader := bufio.NewReader(r)
+	var head Checkpoint
+	var size int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 && !repair {
+				return Checkpoint{}, 0, fmt.Errorf("%w: record %v is incomplete", ErrChainBroken, head.Size)
+			}
+			break
+		}
+		if err != nil {
+			return Checkpoint{}, 0, fmt.Errorf("failed to read audit log: %w", err)
+		}
+
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			return Checkpoint{}, 0, fmt.Errorf("%w: record %v is not valid: %v", ErrChainBroken, head.Size, err)
+		}
+		if record.Seq != head.Size {
+			return Checkpoint{}, 0, fmt.Errorf("%w: record %v has sequence number %v", ErrChainBroken, head.Size, record.Seq)
+		}
+		if record.Prev != head.Head {
+			return Checkpoint{}, 0, fmt.Errorf("%w: record %v does not follow the record before it", ErrChainBroken, record.Seq)
+		}
+		if record.ComputeHash() != record.Hash {
+			return Checkpoint{}, 0, fmt.Errorf("%w: record %v was modified", ErrChainBroken, record.Seq)
+		}
+		head = Checkpoint{Size: record.Seq + 1, Head: record.Hash}
+		size += int64(len(line))
+		if checkpoint != nil && head.Size == checkpoint.Size && head.Head != checkpoint.Head {
+			return Checkpoint{}, 0, fmt.Errorf("%w: record %v differs from the checkpoint", ErrChainBroken, record.Seq)
+		}
+	}
+	if checkpoint != nil && head.Size < checkpoint.Size {
+		return Checkpoint{}, 0, fmt.Errorf("%w: %v records, but the checkpoint has %v", ErrTruncated, head.Size, checkpoint.Size)
+	}
+	return head, size, nil
+}
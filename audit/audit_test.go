@@ -0,0 +1,223 @@
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fixedClock returns a clock that starts at a fixed time and advances by a
+// second on every reading
+func fixedClock() func() time.Time {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	return func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+}
+
+// writeLog records events into a new log at a temporary path and returns the
+// path and the log's final checkpoint
+func writeLog(t *testing.T, events ...Event) (string, Checkpoint) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := OpenLog(path, WithClock(fixedClock()))
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	defer log.Close()
+	for _, event := range events {
+		if err := log.Record(event); err != nil {
+			t.Fatalf("failed to record event: %v", err)
+		}
+	}
+	return path, log.Checkpoint()
+}
+
+func testEvents() []Event {
+	return []Event{
+		{Action: KeyGenerate, Actor: "operator", Details: map[string]string{"key": "loc"}},
+		{Action: Submit, Actor: "server", Details: map[string]string{"tuple": "1", "user": "aa"}},
+		{Action: Submit, Actor: "server", Details: map[string]string{"tuple": "2", "user": "bb"}},
+		{Action: MatchQuery, Actor: "server"},
+		{Action: DecryptEntryData, Actor: "loc", Details: map[string]string{"matches": "1"}},
+	}
+}
+
+func TestLog_Verify(t *testing.T) {
+	path, checkpoint := writeLog(t, testEvents()...)
+	assert.Equal(t, uint64(5), checkpoint.Size)
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+	head, err := Verify(bytes.NewReader(contents), &checkpoint)
+	assert.Nil(t, err)
+	assert.Equal(t, checkpoint, head)
+
+	// An earlier checkpoint is contained in the log
+	lines := strings.SplitAfter(string(contents), "\n")
+	earlier, err := Verify(strings.NewReader(strings.Join(lines[:2], "")), nil)
+	assert.Nil(t, err)
+	_, err = Verify(bytes.NewReader(contents), &earlier)
+	assert.Nil(t, err)
+
+	// An empty log is valid
+	head, err = Verify(strings.NewReader(""), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, Checkpoint{}, head)
+}
+
+func TestVerify_Tampering(t *testing.T) {
+	path, checkpoint := writeLog(t, testEvents()...)
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+	lines := strings.SplitAfter(string(contents), "\n")
+	lines = lines[:len(lines)-1] // After the last newline
+
+	tests := map[string]struct {
+		log         []string
+		expectedErr error
+	}{
+		"modified record": {
+			log:         replace(lines, 1, strings.Replace(lines[1], `"user":"aa"`, `"user":"cc"`, 1)),
+			expectedErr: ErrChainBroken,
+		},
+		"removed record": {
+			log:         append(append([]string(nil), lines[:2]...), lines[3:]...),
+			expectedErr: ErrChainBroken,
+		},
+		"reordered records": {
+			log:         append(append([]string(nil), lines[0], lines[2], lines[1]), lines[3:]...),
+			expectedErr: ErrChainBroken,
+		},
+		"invalid record": {
+			log:         replace(lines, 3, "{\n"),
+			expectedErr: ErrChainBroken,
+		},
+		"incomplete record": {
+			log:         replace(lines, 4, strings.TrimSuffix(lines[4], "\n")),
+			expectedErr: ErrChainBroken,
+		},
+		"truncated": {
+			log:         lines[:3],
+			expectedErr: ErrTruncated,
+		},
+		"emptied": {
+			log:         nil,
+			expectedErr: ErrTruncated,
+		},
+	}
+
+	for testName, tc := range tests {
+		t.Run(testName, func(t *testing.T) {
+			_, err := Verify(strings.NewReader(strings.Join(tc.log, "")), &checkpoint)
+			assert.True(t, errors.Is(err, tc.expectedErr), err)
+		})
+	}
+
+	// A rewritten log that is as long as the checkpoint has another head
+	rewrittenPath, _ := writeLog(t, append(testEvents()[:4], Event{Action: Delete, Actor: "server"})...)
+	rewritten, err := os.ReadFile(rewrittenPath)
+	if err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+	_, err = Verify(bytes.NewReader(rewritten), &checkpoint)
+	assert.True(t, errors.Is(err, ErrChainBroken), err)
+}
+
+func replace(lines []string, i int, line string) []string {
+	replaced := append([]string(nil), lines...)
+	replaced[i] = line
+	return replaced
+}
+
+func TestOpenLog(t *testing.T) {
+	events := testEvents()
+	path, checkpoint := writeLog(t, events[:2]...)
+
+	// Reopening continues the chain
+	log, err := OpenLog(path)
+	if err != nil {
+		t.Fatalf("failed to reopen audit log: %v", err)
+	}
+	assert.Equal(t, checkpoint, log.Checkpoint())
+	assert.Nil(t, log.Record(events[2]))
+	assert.Nil(t, log.Close())
+
+	// A record left incomplete by a crash is discarded
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	if _, err := file.WriteString(`{"seq":3,"ti`); err != nil {
+		t.Fatalf("failed to write audit log: %v", err)
+	}
+	file.Close()
+	log, err = OpenLog(path)
+	if err != nil {
+		t.Fatalf("failed to reopen audit log: %v", err)
+	}
+	assert.Equal(t, uint64(3), log.Checkpoint().Size)
+	assert.Nil(t, log.Record(events[3]))
+	assert.Nil(t, log.Close())
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+	head, err := Verify(bytes.NewReader(contents), &checkpoint)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(4), head.Size)
+
+	// A log with a broken chain is not appended to
+	if err := os.WriteFile(path, bytes.Replace(contents, []byte(`"user":"aa"`), []byte(`"user":"cc"`), 1), 0600); err != nil {
+		t.Fatalf("failed to write audit log: %v", err)
+	}
+	_, err = OpenLog(path)
+	assert.True(t, errors.Is(err, ErrChainBroken), err)
+}
+
+func TestWithActor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := OpenLog(path)
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	defer log.Close()
+
+	loc := WithActor(log, "loc")
+	assert.Nil(t, loc.Record(Event{Action: DecryptEntryData}))
+	assert.Nil(t, loc.Record(Event{Action: DecryptEntryData, Actor: "someone else"}))
+	assert.NotNil(t, loc.Record(Event{}))
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	if assert.Len(t, lines, 2) {
+		assert.Contains(t, lines[0], `"actor":"loc"`)
+		assert.Contains(t, lines[1], `"actor":"someone else"`)
+	}
+}
+
+func TestParseCheckpoint(t *testing.T) {
+	_, checkpoint := writeLog(t, testEvents()...)
+	parsed, err := ParseCheckpoint(checkpoint.String())
+	assert.Nil(t, err)
+	assert.Equal(t, checkpoint, parsed)
+
+	for _, invalid := range []string{"", "5", "x:00", "5:zz", "5:0011"} {
+		_, err := ParseCheckpoint(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
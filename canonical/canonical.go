@@ -0,0 +1,232 @@
+// Package canonical normalizes perpetrator identifiers before they are used to
+// derive p-hat values. Matching in Callisto requires byte-identical perpetrator
+// IDs, so identifiers that refer to the same person must canonicalize to the
+// same bytes.
+package canonical
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Version identifies the set of canonicalization rules implemented by this
+// package. It is embedded in every canonical perpetrator ID so that changing
+// the rules never silently produces IDs that collide with older ones.
+const Version = 1
+
+// DefaultCountryCode is the country calling code assumed for phone numbers
+// that are not written in international format
+const DefaultCountryCode = "1"
+
+// ErrInvalidIdentifier is returned when an identifier cannot be canonicalized
+var ErrInvalidIdentifier = errors.New("invalid perpetrator identifier")
+
+// An enum that represents the kind of a perpetrator identifier
+type IdentifierType int
+
+const (
+	TypeUnknown IdentifierType = iota // Default case
+	TypeName
+	TypeHandle
+	TypeEmail
+	TypePhone
+	TypeNamePhonetic // Derived from a name by Variants
+	TypeNameInitial  // Derived from a name by Variants
+)
+
+var identifierTypeTags = map[IdentifierType]string{
+	TypeName:         "name",
+	TypeHandle:       "handle",
+	TypeEmail:        "email",
+	TypePhone:        "phone",
+	TypeNamePhonetic: "name-phonetic",
+	TypeNameInitial:  "name-initial",
+}
+
+// String returns the tag used for the type in canonical perpetrator IDs
+func (t IdentifierType) String() string {
+	if tag, ok := identifierTypeTags[t]; ok {
+		return tag
+	}
+	return fmt.Sprintf("IdentifierType(%d)", int(t))
+}
+
+// ParseIdentifierType returns the type with the given tag (see String)
+func ParseIdentifierType(tag string) (IdentifierType, error) {
+	for t, typeTag := range identifierTypeTags {
+		if typeTag == tag {
+			return t, nil
+		}
+	}
+	return TypeUnknown, fmt.Errorf("unknown identifier type: %q", tag)
+}
+
+// Identifier is a perpetrator identifier as entered by a user
+type Identifier struct {
+	Type  IdentifierType
+	Value string
+}
+
+// Bytes returns the canonical, version-tagged encoding of the identifier. This
+// is the perpetrator ID handed to the OPRF.
+func (id Identifier) Bytes() ([]byte, error) {
+	canonicalValue, err := id.Canonical()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("gallisto-perpid/v%d/%v:%v", Version, id.Type, canonicalValue)), nil
+}
+
+// Canonical returns the canonical form of the identifier's value. Errors wrap
+// ErrInvalidIdentifier.
+func (id Identifier) Canonical() (string, error) {
+	var canonicalValue string
+	var err error
+	switch id.Type {
+	case TypeName, TypeNameInitial:
+		canonicalValue, err = Name(id.Value)
+	case TypeNamePhonetic:
+		canonicalValue, err = PhoneticCode(id.Value)
+	case TypeHandle:
+		canonicalValue, err = Handle(id.Value)
+	case TypeEmail:
+		canonicalValue, err = Email(id.Value)
+	case TypePhone:
+		canonicalValue, err = Phone(id.Value, DefaultCountryCode)
+	default:
+		err = fmt.Errorf("unknown identifier type: %v", id.Type)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidIdentifier, err)
+	}
+	return canonicalValue, nil
+}
+
+// Name canonicalizes a person's name by applying Unicode NFKC normalization,
+// case folding and collapsing all runs of whitespace into a single space
+func Name(s string) (string, error) {
+	folded := cases.Fold().String(norm.NFKC.String(s))
+	collapsed := strings.Join(strings.Fields(folded), " ")
+	if collapsed == "" {
+		return "", fmt.Errorf("name cannot be empty")
+	}
+	return collapsed, nil
+}
+
+// Handle canonicalizes a social media handle by dropping a leading "@" and
+// lowercasing it
+func Handle(s string) (string, error) {
+	handle := strings.TrimSpace(norm.NFKC.String(s))
+	handle = strings.ToLower(strings.TrimPrefix(handle, "@"))
+	if handle == "" {
+		return "", fmt.Errorf("handle cannot be empty")
+	}
+	if strings.IndexFunc(handle, unicode.IsSpace) >= 0 {
+		return "", fmt.Errorf("handle cannot contain whitespace: %q", s)
+	}
+	return handle, nil
+}
+
+// Email canonicalizes an email address by lowercasing it and converting its
+// domain to its ASCII (punycode) form without a trailing dot
+func Email(s string) (string, error) {
+	email := strings.TrimSpace(norm.NFKC.String(s))
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || at == len(email)-1 {
+		return "", fmt.Errorf("invalid email address: %q", s)
+	}
+
+	localPart := strings.ToLower(email[:at])
+	domain, err := idna.Lookup.ToASCII(strings.TrimSuffix(strings.ToLower(email[at+1:]), "."))
+	if err != nil {
+		return "", fmt.Errorf("invalid email domain %q: %w", email[at+1:], err)
+	}
+	if strings.IndexFunc(localPart, unicode.IsSpace) >= 0 {
+		return "", fmt.Errorf("invalid email address: %q", s)
+	}
+	return localPart + "@" + domain, nil
+}
+
+// nationalNumberLengths holds the shortest and longest national significant
+// number of the country calling codes Phone accepts as a default
+var nationalNumberLengths = map[string]struct{ min, max int }{
+	"1":   {10, 10}, // North American Numbering Plan
+	"7":   {10, 10},
+	"31":  {9, 9},
+	"32":  {8, 9},
+	"33":  {9, 9},
+	"34":  {9, 9},
+	"39":  {6, 11},
+	"41":  {9, 9},
+	"44":  {7, 10},
+	"46":  {7, 13},
+	"49":  {6, 13},
+	"61":  {9, 9},
+	"64":  {8, 10},
+	"81":  {9, 10},
+	"86":  {8, 11},
+	"91":  {10, 10},
+	"972": {8, 9},
+}
+
+// Phone canonicalizes a phone number into E.164 format. Numbers written with a
+// leading "+" or "00" are treated as international; all others are prefixed
+// with defaultCountryCode after dropping a national trunk prefix of "0",
+// unless they are too long to be national numbers and start with
+// defaultCountryCode followed by a national number of valid length. Only the
+// country codes of nationalNumberLengths can be the default.
+func Phone(s, defaultCountryCode string) (string, error) {
+	lengths, ok := nationalNumberLengths[defaultCountryCode]
+	if !ok {
+		return "", fmt.Errorf("unsupported default country code %q", defaultCountryCode)
+	}
+	number := strings.TrimSpace(norm.NFKC.String(s))
+
+	international := false
+	switch {
+	case strings.HasPrefix(number, "+"):
+		international = true
+		number = number[1:]
+	case strings.HasPrefix(number, "00"):
+		international = true
+		number = number[2:]
+	}
+
+	var digits strings.Builder
+	for _, r := range number {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+			// Formatting characters are dropped
+		default:
+			return "", fmt.Errorf("invalid character %q in phone number %q", r, s)
+		}
+	}
+
+	e164 := digits.String()
+	if !international {
+		national := strings.TrimPrefix(e164, "0")
+		// Numbers that already start with the default country code and are
+		// too long to be national numbers are assumed to include it
+		withoutCode := len(national) - len(defaultCountryCode)
+		if len(national) > lengths.max && strings.HasPrefix(national, defaultCountryCode) &&
+			withoutCode >= lengths.min && withoutCode <= lengths.max {
+			e164 = national
+		} else {
+			e164 = defaultCountryCode + national
+		}
+	}
+
+	// E.164 numbers have at most 15 digits
+	if len(e164) < 7 || len(e164) > 15 || e164[0] == '0' {
+		return "", fmt.Errorf("invalid phone number: %q", s)
+	}
+	return "+" + e164, nil
+}
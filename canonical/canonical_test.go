@@ -0,0 +1,205 @@
+package canonical
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestName(t *testing.T) {
+	tests := map[string]string{
+		"john smith":         "john smith",
+		"john smith ":        "john smith",
+		"John  Smith":        "john smith",
+		"JOHN SMITH":         "john smith",
+		"\tJohn\n Smith":     "john smith",
+		"Ｊｏｈｎ Ｓｍｉｔｈ":         "john smith", // full-width characters
+		"Straße":             "strasse",
+		"José Álvarez":       "josé álvarez",
+		"Jose\u0301 Alvarez": "josé alvarez", // combining accent
+	}
+
+	for input, expected := range tests {
+		t.Run(input, func(t *testing.T) {
+			actual, err := Name(input)
+			if assert.NoError(t, err) {
+				assert.Equal(t, expected, actual)
+			}
+		})
+	}
+
+	_, err := Name("  \t ")
+	assert.Error(t, err)
+}
+
+func TestHandle(t *testing.T) {
+	tests := map[string]string{
+		"foo":    "foo",
+		"@Foo":   "foo",
+		" @FOO ": "foo",
+		"Foo_99": "foo_99",
+	}
+
+	for input, expected := range tests {
+		t.Run(input, func(t *testing.T) {
+			actual, err := Handle(input)
+			if assert.NoError(t, err) {
+				assert.Equal(t, expected, actual)
+			}
+		})
+	}
+
+	for _, invalid := range []string{"", "@", "foo bar"} {
+		_, err := Handle(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestEmail(t *testing.T) {
+	tests := map[string]string{
+		"bar@mail.com":        "bar@mail.com",
+		" Bar@Mail.COM ":      "bar@mail.com",
+		"bar@mail.com.":       "bar@mail.com",
+		"bar@bücher.example":  "bar@xn--bcher-kva.example",
+		"\"a@b\"@example.com": "\"a@b\"@example.com",
+	}
+
+	for input, expected := range tests {
+		t.Run(input, func(t *testing.T) {
+			actual, err := Email(input)
+			if assert.NoError(t, err) {
+				assert.Equal(t, expected, actual)
+			}
+		})
+	}
+
+	for _, invalid := range []string{"", "bar", "@mail.com", "bar@", "b ar@mail.com"} {
+		_, err := Email(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestPhone(t *testing.T) {
+	tests := map[string]string{
+		"111-234-5678":      "+11112345678",
+		"(111) 234-5678":    "+11112345678",
+		"1 111 234 5678":    "+11112345678",
+		"+1 111.234.5678":   "+11112345678",
+		"+44 20 7946 0958":  "+442079460958",
+		"0044 20 7946 0958": "+442079460958",
+	}
+
+	for input, expected := range tests {
+		t.Run(input, func(t *testing.T) {
+			actual, err := Phone(input, DefaultCountryCode)
+			if assert.NoError(t, err) {
+				assert.Equal(t, expected, actual)
+			}
+		})
+	}
+
+	// Spellings of a number agree with another default country code
+	ukTests := map[string]string{
+		"020 7946 0958":    "+442079460958",
+		"44 20 7946 0958":  "+442079460958",
+		"+44 20 7946 0958": "+442079460958",
+		"07911 123456":     "+447911123456",
+		"447911123456":     "+447911123456",
+	}
+	for input, expected := range ukTests {
+		actual, err := Phone(input, "44")
+		if assert.NoError(t, err, input) {
+			assert.Equal(t, expected, actual, input)
+		}
+	}
+	_, err := Phone("111-234-5678", "999")
+	assert.Error(t, err)
+
+	for _, invalid := range []string{"", "12", "+1234567890123456", "111-CALL-NOW", "+0 111 234 5678"} {
+		_, err := Phone(invalid, DefaultCountryCode)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestIdentifierBytes(t *testing.T) {
+	a, err := Identifier{Type: TypeName, Value: "john smith "}.Bytes()
+	assert.NoError(t, err)
+	b, err := Identifier{Type: TypeName, Value: "JOHN  SMITH"}.Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, a, b)
+	assert.Equal(t, []byte("gallisto-perpid/v1/name:john smith"), a)
+
+	// The same value under a different type must not collide
+	c, err := Identifier{Type: TypeHandle, Value: "johnsmith"}.Bytes()
+	assert.NoError(t, err)
+	d, err := Identifier{Type: TypeName, Value: "johnsmith"}.Bytes()
+	assert.NoError(t, err)
+	assert.NotEqual(t, c, d)
+
+	_, err = Identifier{Type: TypeUnknown, Value: "foo"}.Bytes()
+	assert.Error(t, err)
+}
+
+func TestParseIdentifierType(t *testing.T) {
+	for _, identifierType := range []IdentifierType{TypeName, TypeHandle, TypeEmail, TypePhone, TypeNamePhonetic, TypeNameInitial} {
+		parsed, err := ParseIdentifierType(identifierType.String())
+		assert.NoError(t, err)
+		assert.Equal(t, identifierType, parsed)
+	}
+	_, err := ParseIdentifierType("foo")
+	assert.Error(t, err)
+}
+
+func TestDoubleMetaphone(t *testing.T) {
+	tests := map[string][2]string{
+		"Smith":    {"SM0", "XMT"},
+		"Smyth":    {"SM0", "XMT"},
+		"Schmidt":  {"XMT", "SMT"},
+		"John":     {"JN", "AN"},
+		"Jon":      {"JN", "AN"},
+		"Thompson": {"TMPS", "TMPS"},
+		"Jose":     {"HS", "HS"},
+		"Gallegos": {"KLKS", "KKS"},
+		"Michael":  {"MKL", "MXL"},
+		"":         {"", ""},
+	}
+
+	for input, expected := range tests {
+		t.Run(input, func(t *testing.T) {
+			primary, alternate := DoubleMetaphone(input)
+			assert.Equal(t, expected[0], primary)
+			assert.Equal(t, expected[1], alternate)
+		})
+	}
+}
+
+func TestVariants(t *testing.T) {
+	variants, err := Variants(Identifier{Type: TypeName, Value: "John Smith"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []Identifier{
+			{Type: TypeNamePhonetic, Value: "JN SM0"},
+			{Type: TypeNamePhonetic, Value: "AN XMT"},
+			{Type: TypeNameInitial, Value: "j smith"},
+		}, variants)
+	}
+
+	// Misspellings share phonetic variants
+	misspelled, err := Variants(Identifier{Type: TypeName, Value: "Jon  Smyth"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, variants[:2], misspelled[:2])
+	}
+
+	// Single names have no initial variant
+	single, err := Variants(Identifier{Type: TypeName, Value: "Cher"})
+	if assert.NoError(t, err) {
+		for _, v := range single {
+			assert.Equal(t, TypeNamePhonetic, v.Type)
+		}
+	}
+
+	// Only names have variants
+	none, err := Variants(Identifier{Type: TypeEmail, Value: "foo@bar.com"})
+	if assert.NoError(t, err) {
+		assert.Nil(t, none)
+	}
+}
@@ -0,0 +1,659 @@
+package canonical
+
+import (
+	"strings"
+	"unicode"
+)
+
+// doubleMetaphoneMaxLength is the length of the primary and alternate codes
+// returned by DoubleMetaphone
+const doubleMetaphoneMaxLength = 4
+
+// DoubleMetaphone returns the primary and alternate Double Metaphone encodings
+// of a single word, as described by Lawrence Philips. Words that sound alike,
+// such as "Smith" and "Smyth", share an encoding. The alternate encoding equals
+// the primary one when the word has no alternate pronunciation.
+func DoubleMetaphone(word string) (string, string) {
+	m := &metaphone{value: []rune(strings.ToUpper(strings.TrimSpace(word)))}
+	if len(m.value) == 0 {
+		return "", ""
+	}
+	m.slavoGermanic = m.isSlavoGermanic()
+
+	index := 0
+	if m.isSilentStart() {
+		index = 1
+	}
+
+	for !m.isComplete() && index < len(m.value) {
+		switch c := m.charAt(index); c {
+		case 'A', 'E', 'I', 'O', 'U', 'Y':
+			if index == 0 {
+				m.add("A")
+			}
+			index++
+		case 'B':
+			m.add("P")
+			index = m.skipDouble(index, 'B')
+		case 'Ç':
+			m.add("S")
+			index++
+		case 'C':
+			index = m.handleC(index)
+		case 'D':
+			index = m.handleD(index)
+		case 'F':
+			m.add("F")
+			index = m.skipDouble(index, 'F')
+		case 'G':
+			index = m.handleG(index)
+		case 'H':
+			index = m.handleH(index)
+		case 'J':
+			index = m.handleJ(index)
+		case 'K':
+			m.add("K")
+			index = m.skipDouble(index, 'K')
+		case 'L':
+			index = m.handleL(index)
+		case 'M':
+			m.add("M")
+			if m.conditionM0(index) {
+				index += 2
+			} else {
+				index++
+			}
+		case 'N':
+			m.add("N")
+			index = m.skipDouble(index, 'N')
+		case 'Ñ':
+			m.add("N")
+			index++
+		case 'P':
+			index = m.handleP(index)
+		case 'Q':
+			m.add("K")
+			index = m.skipDouble(index, 'Q')
+		case 'R':
+			index = m.handleR(index)
+		case 'S':
+			index = m.handleS(index)
+		case 'T':
+			index = m.handleT(index)
+		case 'V':
+			m.add("F")
+			index = m.skipDouble(index, 'V')
+		case 'W':
+			index = m.handleW(index)
+		case 'X':
+			index = m.handleX(index)
+		case 'Z':
+			index = m.handleZ(index)
+		default:
+			index++
+		}
+	}
+
+	return m.primary.String(), m.alternate.String()
+}
+
+// metaphone holds the state of a single DoubleMetaphone encoding
+type metaphone struct {
+	value         []rune
+	slavoGermanic bool
+	primary       strings.Builder
+	alternate     strings.Builder
+}
+
+func (m *metaphone) isComplete() bool {
+	return m.primary.Len() >= doubleMetaphoneMaxLength && m.alternate.Len() >= doubleMetaphoneMaxLength
+}
+
+// add appends the same code to both encodings
+func (m *metaphone) add(code string) {
+	m.addPrimary(code)
+	m.addAlternate(code)
+}
+
+// addBoth appends distinct codes to the primary and alternate encodings
+func (m *metaphone) addBoth(primary, alternate string) {
+	m.addPrimary(primary)
+	m.addAlternate(alternate)
+}
+
+func (m *metaphone) addPrimary(code string) {
+	appendTruncated(&m.primary, code)
+}
+
+func (m *metaphone) addAlternate(code string) {
+	appendTruncated(&m.alternate, code)
+}
+
+func appendTruncated(b *strings.Builder, code string) {
+	remaining := doubleMetaphoneMaxLength - b.Len()
+	if remaining <= 0 {
+		return
+	}
+	if len(code) > remaining {
+		code = code[:remaining]
+	}
+	b.WriteString(code)
+}
+
+// charAt returns the rune at index, or zero if index is out of range
+func (m *metaphone) charAt(index int) rune {
+	if index < 0 || index >= len(m.value) {
+		return 0
+	}
+	return m.value[index]
+}
+
+// contains reports whether the length runes starting at start equal any of the
+// given criteria
+func (m *metaphone) contains(start, length int, criteria ...string) bool {
+	if start < 0 || start+length > len(m.value) {
+		return false
+	}
+	target := string(m.value[start : start+length])
+	for _, c := range criteria {
+		if target == c {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *metaphone) skipDouble(index int, c rune) int {
+	if m.charAt(index+1) == c {
+		return index + 2
+	}
+	return index + 1
+}
+
+func (m *metaphone) isVowel(c rune) bool {
+	return strings.ContainsRune("AEIOUY", c)
+}
+
+func (m *metaphone) isSlavoGermanic() bool {
+	s := string(m.value)
+	return strings.ContainsAny(s, "WK") || strings.Contains(s, "CZ") || strings.Contains(s, "WITZ")
+}
+
+func (m *metaphone) isSilentStart() bool {
+	return m.contains(0, 2, "GN", "KN", "PN", "WR", "PS")
+}
+
+func (m *metaphone) last() int {
+	return len(m.value) - 1
+}
+
+func (m *metaphone) handleC(index int) int {
+	switch {
+	case m.conditionC0(index):
+		// Various Germanic
+		m.add("K")
+		index += 2
+	case index == 0 && m.contains(index, 6, "CAESAR"):
+		m.add("S")
+		index += 2
+	case m.contains(index, 2, "CH"):
+		index = m.handleCH(index)
+	case m.contains(index, 2, "CZ") && !m.contains(index-2, 4, "WICZ"):
+		// "Czerny"
+		m.addBoth("S", "X")
+		index += 2
+	case m.contains(index+1, 3, "CIA"):
+		// "focaccia"
+		m.add("X")
+		index += 3
+	case m.contains(index, 2, "CC") && !(index == 1 && m.charAt(0) == 'M'):
+		// Double "cc" but not "McClelland"
+		return m.handleCC(index)
+	case m.contains(index, 2, "CK", "CG", "CQ"):
+		m.add("K")
+		index += 2
+	case m.contains(index, 2, "CI", "CE", "CY"):
+		// Italian vs. English
+		if m.contains(index, 3, "CIO", "CIE", "CIA") {
+			m.addBoth("S", "X")
+		} else {
+			m.add("S")
+		}
+		index += 2
+	default:
+		m.add("K")
+		switch {
+		case m.contains(index+1, 2, " C", " Q", " G"):
+			// "Mac Caffrey", "Mac Gregor"
+			index += 3
+		case m.contains(index+1, 1, "C", "K", "Q") && !m.contains(index+1, 2, "CE", "CI"):
+			index += 2
+		default:
+			index++
+		}
+	}
+	return index
+}
+
+func (m *metaphone) handleCC(index int) int {
+	if m.contains(index+2, 1, "I", "E", "H") && !m.contains(index+2, 2, "HU") {
+		// "bellocchio" but not "bacchus"
+		if (index == 1 && m.charAt(index-1) == 'A') || m.contains(index-1, 5, "UCCEE", "UCCES") {
+			// "accident", "accede", "succeed"
+			m.add("KS")
+		} else {
+			// "bacci", "bertucci", other Italian
+			m.add("X")
+		}
+		return index + 3
+	}
+	// Pierce's rule
+	m.add("K")
+	return index + 2
+}
+
+func (m *metaphone) handleCH(index int) int {
+	switch {
+	case index > 0 && m.contains(index, 4, "CHAE"):
+		// "Michael"
+		m.addBoth("K", "X")
+	case m.conditionCH0(index):
+		// Greek roots ("chemistry", "chorus", etc.)
+		m.add("K")
+	case m.conditionCH1(index):
+		// Germanic, Greek, or otherwise "ch" for "kh" sound
+		m.add("K")
+	case index > 0:
+		if m.contains(0, 2, "MC") {
+			m.add("K")
+		} else {
+			m.addBoth("X", "K")
+		}
+	default:
+		m.add("X")
+	}
+	return index + 2
+}
+
+func (m *metaphone) handleD(index int) int {
+	switch {
+	case m.contains(index, 2, "DG"):
+		if m.contains(index+2, 1, "I", "E", "Y") {
+			// "edge"
+			m.add("J")
+			return index + 3
+		}
+		// "Edgar"
+		m.add("TK")
+		return index + 2
+	case m.contains(index, 2, "DT", "DD"):
+		m.add("T")
+		return index + 2
+	default:
+		m.add("T")
+		return index + 1
+	}
+}
+
+func (m *metaphone) handleG(index int) int {
+	switch {
+	case m.charAt(index+1) == 'H':
+		return m.handleGH(index)
+	case m.charAt(index+1) == 'N':
+		switch {
+		case index == 1 && m.isVowel(m.charAt(0)) && !m.slavoGermanic:
+			m.addBoth("KN", "N")
+		case !m.contains(index+2, 2, "EY") && m.charAt(index+1) != 'Y' && !m.slavoGermanic:
+			m.addBoth("N", "KN")
+		default:
+			m.add("KN")
+		}
+		return index + 2
+	case m.contains(index+1, 2, "LI") && !m.slavoGermanic:
+		m.addBoth("KL", "L")
+		return index + 2
+	case index == 0 && (m.charAt(index+1) == 'Y' ||
+		m.contains(index+1, 2, "ES", "EP", "EB", "EL", "EY", "IB", "IL", "IN", "IE", "EI", "ER")):
+		// -ges-, -gep-, -gel-, -gie- at beginning
+		m.addBoth("K", "J")
+		return index + 2
+	case (m.contains(index+1, 2, "ER") || m.charAt(index+1) == 'Y') &&
+		!m.contains(0, 6, "DANGER", "RANGER", "MANGER") &&
+		!m.contains(index-1, 1, "E", "I") &&
+		!m.contains(index-1, 3, "RGY", "OGY"):
+		// -ger-, -gy-
+		m.addBoth("K", "J")
+		return index + 2
+	case m.contains(index+1, 1, "E", "I", "Y") || m.contains(index-1, 4, "AGGI", "OGGI"):
+		// Italian "biaggi"
+		switch {
+		case m.contains(0, 4, "VAN ", "VON ") || m.contains(0, 3, "SCH") || m.contains(index+1, 2, "ET"):
+			// Obvious Germanic
+			m.add("K")
+		case m.contains(index+1, 3, "IER"):
+			m.add("J")
+		default:
+			m.addBoth("J", "K")
+		}
+		return index + 2
+	case m.charAt(index+1) == 'G':
+		m.add("K")
+		return index + 2
+	default:
+		m.add("K")
+		return index + 1
+	}
+}
+
+func (m *metaphone) handleGH(index int) int {
+	switch {
+	case index > 0 && !m.isVowel(m.charAt(index-1)):
+		m.add("K")
+	case index == 0:
+		if m.charAt(index+2) == 'I' {
+			m.add("J")
+		} else {
+			m.add("K")
+		}
+	case (index > 1 && m.contains(index-2, 1, "B", "H", "D")) ||
+		(index > 2 && m.contains(index-3, 1, "B", "H", "D")) ||
+		(index > 3 && m.contains(index-4, 1, "B", "H")):
+		// Parker's rule (with some further refinements), e.g. "hugh"
+	default:
+		if index > 2 && m.charAt(index-1) == 'U' && m.contains(index-3, 1, "C", "G", "L", "R", "T") {
+			// "laugh", "McLaughlin", "cough", "gough", "rough", "tough"
+			m.add("F")
+		} else if index > 0 && m.charAt(index-1) != 'I' {
+			m.add("K")
+		}
+	}
+	return index + 2
+}
+
+func (m *metaphone) handleH(index int) int {
+	// Only keep if first & before vowel or between 2 vowels
+	if (index == 0 || m.isVowel(m.charAt(index-1))) && m.isVowel(m.charAt(index+1)) {
+		m.add("H")
+		return index + 2
+	}
+	return index + 1
+}
+
+func (m *metaphone) handleJ(index int) int {
+	if m.contains(index, 4, "JOSE") || m.contains(0, 4, "SAN ") {
+		// Obvious Spanish, "Jose", "San Jacinto"
+		if (index == 0 && m.charAt(index+4) == ' ') || len(m.value) == 4 || m.contains(0, 4, "SAN ") {
+			m.add("H")
+		} else {
+			m.addBoth("J", "H")
+		}
+		return index + 1
+	}
+
+	switch {
+	case index == 0 && !m.contains(index, 4, "JOSE"):
+		m.addBoth("J", "A")
+	case m.isVowel(m.charAt(index-1)) && !m.slavoGermanic &&
+		(m.charAt(index+1) == 'A' || m.charAt(index+1) == 'O'):
+		// Spanish pronunciation of e.g. "bajador"
+		m.addBoth("J", "H")
+	case index == m.last():
+		m.addPrimary("J")
+	case !m.contains(index+1, 1, "L", "T", "K", "S", "N", "M", "B", "Z") &&
+		!m.contains(index-1, 1, "S", "K", "L"):
+		m.add("J")
+	}
+	return m.skipDouble(index, 'J')
+}
+
+func (m *metaphone) handleL(index int) int {
+	if m.charAt(index+1) == 'L' {
+		if m.conditionL0(index) {
+			// Spanish e.g. "cabrillo", "gallegos"
+			m.addPrimary("L")
+		} else {
+			m.add("L")
+		}
+		return index + 2
+	}
+	m.add("L")
+	return index + 1
+}
+
+func (m *metaphone) handleP(index int) int {
+	if m.charAt(index+1) == 'H' {
+		m.add("F")
+		return index + 2
+	}
+	m.add("P")
+	if m.contains(index+1, 1, "P", "B") {
+		return index + 2
+	}
+	return index + 1
+}
+
+func (m *metaphone) handleR(index int) int {
+	if index == m.last() && !m.slavoGermanic && m.contains(index-2, 2, "IE") && !m.contains(index-4, 2, "ME", "MA") {
+		// French e.g. "rogier", but exclude "hochmeier"
+		m.addAlternate("R")
+	} else {
+		m.add("R")
+	}
+	return m.skipDouble(index, 'R')
+}
+
+func (m *metaphone) handleS(index int) int {
+	switch {
+	case m.contains(index-1, 3, "ISL", "YSL"):
+		// Special cases "island", "isle", "carlisle", "carlysle"
+		return index + 1
+	case index == 0 && m.contains(index, 5, "SUGAR"):
+		// Special case "sugar-"
+		m.addBoth("X", "S")
+		return index + 1
+	case m.contains(index, 2, "SH"):
+		if m.contains(index+1, 4, "HEIM", "HOEK", "HOLM", "HOLZ") {
+			// Germanic
+			m.add("S")
+		} else {
+			m.add("X")
+		}
+		return index + 2
+	case m.contains(index, 3, "SIO", "SIA") || m.contains(index, 4, "SIAN"):
+		// Italian and Armenian
+		if m.slavoGermanic {
+			m.add("S")
+		} else {
+			m.addBoth("S", "X")
+		}
+		return index + 3
+	case (index == 0 && m.contains(index+1, 1, "M", "N", "L", "W")) || m.contains(index+1, 1, "Z"):
+		// German & anglicisations, e.g. "smith" match "schmidt", "snider"
+		// match "schneider". Also -sz- in Slavic languages.
+		m.addBoth("S", "X")
+		if m.contains(index+1, 1, "Z") {
+			return index + 2
+		}
+		return index + 1
+	case m.contains(index, 2, "SC"):
+		return m.handleSC(index)
+	default:
+		if index == m.last() && m.contains(index-2, 2, "AI", "OI") {
+			// French e.g. "resnais", "artois"
+			m.addAlternate("S")
+		} else {
+			m.add("S")
+		}
+		if m.contains(index+1, 1, "S", "Z") {
+			return index + 2
+		}
+		return index + 1
+	}
+}
+
+func (m *metaphone) handleSC(index int) int {
+	switch {
+	case m.charAt(index+2) == 'H':
+		// Schlesinger's rule
+		if m.contains(index+3, 2, "OO", "ER", "EN", "UY", "ED", "EM") {
+			// Dutch origin, e.g. "school", "schooner"
+			if m.contains(index+3, 2, "ER", "EN") {
+				// "schermerhorn", "schenker"
+				m.addBoth("X", "SK")
+			} else {
+				m.add("SK")
+			}
+		} else if index == 0 && !m.isVowel(m.charAt(3)) && m.charAt(3) != 'W' {
+			m.addBoth("X", "S")
+		} else {
+			m.add("X")
+		}
+	case m.contains(index+2, 1, "I", "E", "Y"):
+		m.add("S")
+	default:
+		m.add("SK")
+	}
+	return index + 3
+}
+
+func (m *metaphone) handleT(index int) int {
+	switch {
+	case m.contains(index, 4, "TION"):
+		m.add("X")
+		return index + 3
+	case m.contains(index, 3, "TIA", "TCH"):
+		m.add("X")
+		return index + 3
+	case m.contains(index, 2, "TH") || m.contains(index, 3, "TTH"):
+		if m.contains(index+2, 2, "OM", "AM") ||
+			// Special case "thomas", "thames" or Germanic
+			m.contains(0, 4, "VAN ", "VON ") || m.contains(0, 3, "SCH") {
+			m.add("T")
+		} else {
+			m.addBoth("0", "T")
+		}
+		return index + 2
+	default:
+		m.add("T")
+		if m.contains(index+1, 1, "T", "D") {
+			return index + 2
+		}
+		return index + 1
+	}
+}
+
+func (m *metaphone) handleW(index int) int {
+	if m.contains(index, 2, "WR") {
+		// Can also be in middle of word
+		m.add("R")
+		return index + 2
+	}
+
+	switch {
+	case index == 0 && (m.isVowel(m.charAt(index+1)) || m.contains(index, 2, "WH")):
+		if m.isVowel(m.charAt(index + 1)) {
+			// "Wasserman" should match "Vasserman"
+			m.addBoth("A", "F")
+		} else {
+			// Need "Uomo" to match "Womo"
+			m.add("A")
+		}
+		return index + 1
+	case (index == m.last() && m.isVowel(m.charAt(index-1))) ||
+		m.contains(index-1, 5, "EWSKI", "EWSKY", "OWSKI", "OWSKY") ||
+		m.contains(0, 3, "SCH"):
+		// "Arnow" should match "Arnoff"
+		m.addAlternate("F")
+		return index + 1
+	case m.contains(index, 4, "WICZ", "WITZ"):
+		// Polish e.g. "filipowicz"
+		m.addBoth("TS", "FX")
+		return index + 4
+	default:
+		return index + 1
+	}
+}
+
+func (m *metaphone) handleX(index int) int {
+	if index == 0 {
+		m.add("S")
+		return index + 1
+	}
+	if !(index == m.last() && (m.contains(index-3, 3, "IAU", "EAU") || m.contains(index-2, 2, "AU", "OU"))) {
+		// Not French e.g. "breaux"
+		m.add("KS")
+	}
+	if m.contains(index+1, 1, "C", "X") {
+		return index + 2
+	}
+	return index + 1
+}
+
+func (m *metaphone) handleZ(index int) int {
+	if m.charAt(index+1) == 'H' {
+		// Chinese pinyin e.g. "zhao"
+		m.add("J")
+		return index + 2
+	}
+	if m.contains(index+1, 2, "ZO", "ZI", "ZA") || (m.slavoGermanic && index > 0 && m.charAt(index-1) != 'T') {
+		m.addBoth("S", "TS")
+	} else {
+		m.add("S")
+	}
+	return m.skipDouble(index, 'Z')
+}
+
+func (m *metaphone) conditionC0(index int) bool {
+	if m.contains(index, 4, "CHIA") {
+		return true
+	}
+	if index <= 1 || m.isVowel(m.charAt(index-2)) || !m.contains(index-1, 3, "ACH") {
+		return false
+	}
+	c := m.charAt(index + 2)
+	return (c != 'I' && c != 'E') || m.contains(index-2, 6, "BACHER", "MACHER")
+}
+
+func (m *metaphone) conditionCH0(index int) bool {
+	if index != 0 {
+		return false
+	}
+	if !m.contains(index+1, 5, "HARAC", "HARIS") && !m.contains(index+1, 3, "HOR", "HYM", "HIA", "HEM") {
+		return false
+	}
+	return !m.contains(0, 5, "CHORE")
+}
+
+func (m *metaphone) conditionCH1(index int) bool {
+	return m.contains(0, 4, "VAN ", "VON ") || m.contains(0, 3, "SCH") ||
+		m.contains(index-2, 6, "ORCHES", "ARCHIT", "ORCHID") ||
+		m.contains(index+2, 1, "T", "S") ||
+		((m.contains(index-1, 1, "A", "O", "U", "E") || index == 0) &&
+			(m.contains(index+2, 1, "L", "R", "N", "M", "B", "H", "F", "V", "W", " ") || index+1 == m.last()))
+}
+
+func (m *metaphone) conditionL0(index int) bool {
+	if index == len(m.value)-3 && m.contains(index-1, 4, "ILLO", "ILLA", "ALLE") {
+		return true
+	}
+	return (m.contains(len(m.value)-2, 2, "AS", "OS") || m.contains(len(m.value)-1, 1, "A", "O")) &&
+		m.contains(index-1, 4, "ALLE")
+}
+
+func (m *metaphone) conditionM0(index int) bool {
+	if m.charAt(index+1) == 'M' {
+		return true
+	}
+	return m.contains(index-1, 3, "UMB") && (index+1 == m.last() || m.contains(index+2, 2, "ER"))
+}
+
+// stripMarks removes combining marks (e.g. accents) left after decomposing a
+// string, so that phonetic encodings treat "José" and "Jose" alike
+func stripMarks(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, s)
+}
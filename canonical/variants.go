@@ -0,0 +1,82 @@
+package canonical
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Variants derives additional, lower-confidence perpetrator identifiers from a
+// name so that misspellings such as "Jon Smyth" and "John Smith" can still
+// match. The returned identifiers are of type TypeNamePhonetic (Double
+// Metaphone encodings of every word) and TypeNameInitial (first initial plus
+// surname). Only TypeName identifiers have variants; nil is returned for any
+// other type.
+func Variants(id Identifier) ([]Identifier, error) {
+	if id.Type != TypeName {
+		return nil, nil
+	}
+	name, err := Name(id.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	var variants []Identifier
+	seen := make(map[Identifier]struct{})
+	addVariant := func(variant Identifier) {
+		if variant.Value == "" {
+			return
+		}
+		if _, ok := seen[variant]; ok {
+			return
+		}
+		seen[variant] = struct{}{}
+		variants = append(variants, variant)
+	}
+
+	primary, alternate := phoneticName(name)
+	addVariant(Identifier{Type: TypeNamePhonetic, Value: primary})
+	addVariant(Identifier{Type: TypeNamePhonetic, Value: alternate})
+
+	words := strings.Fields(name)
+	if len(words) >= 2 {
+		initial := []rune(words[0])[0]
+		addVariant(Identifier{Type: TypeNameInitial, Value: fmt.Sprintf("%c %v", initial, words[len(words)-1])})
+	}
+
+	return variants, nil
+}
+
+// phoneticName returns the primary and alternate phonetic encodings of a
+// canonical name. Each word is encoded separately and the codes are joined by
+// a space.
+func phoneticName(name string) (string, string) {
+	words := strings.Fields(stripMarks(norm.NFD.String(name)))
+	primaries := make([]string, 0, len(words))
+	alternates := make([]string, 0, len(words))
+	for _, word := range words {
+		primary, alternate := DoubleMetaphone(word)
+		if primary == "" {
+			continue
+		}
+		primaries = append(primaries, primary)
+		alternates = append(alternates, alternate)
+	}
+	return strings.Join(primaries, " "), strings.Join(alternates, " ")
+}
+
+// PhoneticCode canonicalizes a list of Double Metaphone codes separated by
+// whitespace
+func PhoneticCode(s string) (string, error) {
+	code := strings.Join(strings.Fields(strings.ToUpper(s)), " ")
+	if code == "" {
+		return "", fmt.Errorf("phonetic code cannot be empty")
+	}
+	for _, r := range code {
+		if !(r >= 'A' && r <= 'Z') && r != '0' && r != ' ' {
+			return "", fmt.Errorf("invalid character %q in phonetic code %q", r, s)
+		}
+	}
+	return code, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ymarcus93/gallisto/audit"
+)
+
+const auditUsage = `usage: gallisto audit <command> [flags]
+
+commands:
+  verify  check the hash chain of an audit log, and that it still contains a checkpoint`
+
+// Audit log of the server, LOC and key operations, if enabled with -audit-log
+var auditLog *audit.Log
+
+// auditRecorder returns a recorder of the audit log acting as actor, or nil if
+// there is no audit log
+func auditRecorder(actor string) audit.Recorder {
+	if auditLog == nil {
+		return nil
+	}
+	return audit.WithActor(auditLog, actor)
+}
+
+// recordKeyGeneration records a new key in the audit log, identified by the
+// SHA-256 of its public key
+func recordKeyGeneration(key string, publicKey []byte) error {
+	if auditLog == nil {
+		return nil
+	}
+	return auditLog.Record(audit.Event{
+		Action: audit.KeyGenerate,
+		Actor:  "operator",
+		Details: map[string]string{
+			"key":         key,
+			"fingerprint": fmt.Sprintf("%x", sha256.Sum256(publicKey)),
+		},
+	})
+}
+
+// printAuditCheckpoint prints the checkpoint of the audit log, to be kept
+// elsewhere for later verification
+func printAuditCheckpoint() {
+	if auditLog != nil {
+		fmt.Printf("audit log checkpoint: %v\n", auditLog.Checkpoint())
+	}
+}
+
+// runAuditCommand runs `gallisto audit ...`
+func runAuditCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New(auditUsage)
+	}
+	switch args[0] {
+	case "verify":
+		return verifyAuditLog(args[1:])
+	default:
+		return fmt.Errorf("unknown audit command %q\n%v", args[0], auditUsage)
+	}
+}
+
+func verifyAuditLog(args []string) error {
+	flags := flag.NewFlagSet("audit verify", flag.ExitOnError)
+	path := flags.String("log", "", "path to the audit log")
+	checkpointFlag := flags.String("checkpoint", "", "checkpoint printed by an earlier run or verification; detects truncation of the log since")
+	flags.Parse(args)
+	if *path == "" {
+		return errors.New("audit verify: -log is required")
+	}
+
+	var checkpoint *audit.Checkpoint
+	if *checkpointFlag != "" {
+		parsed, err := audit.ParseCheckpoint(*checkpointFlag)
+		if err != nil {
+			return err
+		}
+		checkpoint = &parsed
+	}
+	file, err := os.Open(*path)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+	head, err := audit.Verify(file, checkpoint)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("audit log verified: %v records\ncheckpoint: %v\n", head.Size, head)
+	return nil
+}
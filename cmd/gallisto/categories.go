@@ -1,3 +1,14 @@
 package main
 
-var categories = []string{"Rape", "Abuse", "Assault", "Stalking", "Indecent exposure", "Pedophilia", "Harassment"}
+import "github.com/ymarcus93/gallisto/types"
+
+var categories = misconductCategoryNames()
+
+func misconductCategoryNames() []string {
+	all := types.MisconductCategories()
+	names := make([]string, len(all))
+	for i, category := range all {
+		names[i] = category.String()
+	}
+	return names
+}
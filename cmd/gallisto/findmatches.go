@@ -5,18 +5,12 @@ import (
 	"fmt"
 
 	"github.com/AlecAivazis/survey/v2"
-	"github.com/ymarcus93/gallisto/internal/encryption"
 	"github.com/ymarcus93/gallisto/protocol"
 	"github.com/ymarcus93/gallisto/types"
 )
 
 func findMatches() error {
-
-	tuples := make([]protocol.Matchable, len(callistoTuples))
-	for i, tup := range callistoTuples {
-		tuples[i] = tup
-	}
-	matches, err := protocol.FindMatches(tuples)
+	matches, err := callistoServer.Matches()
 	if err != nil {
 		return err
 	}
@@ -39,12 +33,33 @@ func findMatches() error {
 		return err
 	}
 
-	for _, m := range matchedTuples {
-		fmt.Printf("\ndecrypted data for pi: %v\n", m.piValue)
-		err = decryptTuples(m.callistoTuplesSelected)
-		if err != nil {
-			return err
+	// Decrypt every selected match in one batch
+	toDecrypt := make([]protocol.PiMatch, len(matchedTuples))
+	for i, m := range matchedTuples {
+		entries := make([]protocol.Matchable, len(m.callistoTuplesSelected))
+		for j, tuple := range m.callistoTuplesSelected {
+			entries[j] = tuple
 		}
+		toDecrypt[i] = protocol.PiMatch{MatchedEntries: entries, MatchClass: m.matchClass}
+	}
+	// The DLOC decrypts assignment data and the LOC entry data
+	var dlocOpts, locOpts []protocol.DecrypterOption
+	if auditLog != nil {
+		dlocOpts = append(dlocOpts, protocol.WithAuditLog(auditRecorder("dloc")))
+		locOpts = append(locOpts, protocol.WithAuditLog(auditRecorder("loc")))
+	}
+	assignmentResults, err := protocol.NewDecrypter(dlocOpts...).DecryptAssignmentDataBatch(toDecrypt, pubkeys.dlocKeys.PrivateKey)
+	if err != nil {
+		return err
+	}
+	entryResults, err := protocol.NewDecrypter(locOpts...).DecryptEntryDataBatch(toDecrypt, pubkeys.locKeys.PrivateKey)
+	if err != nil {
+		return err
+	}
+
+	for i, m := range matchedTuples {
+		fmt.Printf("\ndecrypted data for pi: %v (%v match)\n", m.piValue, m.matchClass)
+		printDecryptedData(assignmentResults[i], entryResults[i])
 	}
 
 	return nil
@@ -55,14 +70,16 @@ func convertMatchPiValuesToHex(matches []protocol.PiMatch) ([]string, map[string
 	reverseLookup := make(map[string]protocol.PiMatch, 0)
 	for i, match := range matches {
 		piAsHex := hex.EncodeToString(match.SharedPiValue)
-		hexValues[i] = piAsHex
-		reverseLookup[piAsHex] = match
+		label := fmt.Sprintf("%v (%v)", piAsHex, match.MatchClass)
+		hexValues[i] = label
+		reverseLookup[label] = match
 	}
 	return hexValues, reverseLookup
 }
 
 type selectedMatch struct {
 	piValue                string
+	matchClass             types.MatchClass
 	callistoTuplesSelected []types.CallistoTuple
 }
 
@@ -88,51 +105,32 @@ func matchSelector(matches []protocol.PiMatch) ([]selectedMatch, error) {
 	allSelectedMatches := make([]selectedMatch, len(parsedSelectedMatches))
 
 	for i, match := range parsedSelectedMatches {
-		callistoTuplesSelected := make([]types.CallistoTuple, 0)
-		for _, entry := range match.MatchedEntries {
-			callistoTuple := (entry).(types.CallistoTuple)
-			callistoTuplesSelected = append(callistoTuplesSelected, callistoTuple)
+		storedTuples, err := callistoServer.TuplesForMatch(match.SharedPiValue)
+		if err != nil {
+			return nil, err
+		}
+		callistoTuplesSelected := make([]types.CallistoTuple, len(storedTuples))
+		for j, stored := range storedTuples {
+			callistoTuplesSelected[j] = stored.Tuple
 		}
 		constructed := selectedMatch{
 			callistoTuplesSelected: callistoTuplesSelected,
 			piValue:                hex.EncodeToString(match.SharedPiValue),
+			matchClass:             match.MatchClass,
 		}
 		allSelectedMatches[i] = constructed
 	}
 	return allSelectedMatches, nil
 }
 
-func decryptTuples(tuples []types.CallistoTuple) error {
-	dlocCiphertexts := make([][]byte, len(tuples))
-	locCiphertexts := make([][]byte, len(tuples))
-	encryptedAssignmentData := make([]encryption.GCMCiphertext, len(tuples))
-	encryptedEntryData := make([]encryption.GCMCiphertext, len(tuples))
-
-	for i, tuple := range tuples {
-		dlocCiphertexts[i] = tuple.DLOCCiphertext()
-		locCiphertexts[i] = tuple.LOCCiphertext()
-		encryptedAssignmentData[i] = tuple.EncryptedAssignmentData()
-		encryptedEntryData[i] = tuple.EncryptedEntryData()
-	}
-
-	assignmentResults, err := protocol.DecryptAssignmentData(dlocCiphertexts, encryptedAssignmentData, pubkeys.dlocKeys.PrivateKey)
-	if err != nil {
-		return err
-	}
+func printDecryptedData(assignmentResults []types.AssignmentData, entryResults []types.EntryData) {
 	fmt.Println("\ndecrypted assignment data:")
 	for _, d := range assignmentResults {
-		// fmt.Printf("%+v\n", d)
 		fmt.Println(prettyPrint(d))
 	}
 
-	entryResults, err := protocol.DecryptEntryData(locCiphertexts, encryptedEntryData, pubkeys.locKeys.PrivateKey)
-	if err != nil {
-		panic(err)
-	}
 	fmt.Println("\ndecrypted entry data:")
 	for _, d := range entryResults {
 		fmt.Println(prettyPrint(d))
-		// fmt.Printf("%+v\n", d)
 	}
-	return nil
 }
@@ -1,58 +1,210 @@
 package main
 
 import (
+	"crypto/ed25519"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/AlecAivazis/survey/v2"
 	"github.com/AlecAivazis/survey/v2/terminal"
-	"github.com/ymarcus93/gallisto/internal/encryption"
-	"github.com/ymarcus93/gallisto/internal/oprf"
+	"github.com/ymarcus93/gallisto/crypto"
+	"github.com/ymarcus93/gallisto/errcode"
+	"github.com/ymarcus93/gallisto/identity"
+	"github.com/ymarcus93/gallisto/oprf"
 	"github.com/ymarcus93/gallisto/protocol/client"
-	"github.com/ymarcus93/gallisto/types"
+	"github.com/ymarcus93/gallisto/protocol/server"
+	"github.com/ymarcus93/gallisto/suite"
+	"github.com/ymarcus93/gallisto/transparency"
 )
 
-func createOPRFServer() (*oprf.OPRFServer, error) {
-	fmt.Println("generating OPRF key...")
-	key, err := oprf.GenerateKey(types.OPRF_CIPHERSUITE)
+func createCallistoServer() (*server.CallistoServer, error) {
+	selectedSuite, err := suite.Lookup(protocolSuite)
 	if err != nil {
 		return nil, err
 	}
-	fmt.Println("creating OPRF server...")
-	oprfServer, err := oprf.NewOPRFServer(types.OPRF_CIPHERSUITE, key)
+	oprfConfig.Ciphersuite = selectedSuite.OPRFCiphersuite
+
+	fmt.Printf("generating %v OPRF key...\n", oprfConfig.Ciphersuite)
+	key, err := oprf.GenerateKey(oprfConfig.Ciphersuite)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("creating OPRF server in %v mode...\n", oprfConfig.Mode)
+	oprfServer, err := oprf.NewOPRFServer(oprfConfig, key)
+	if err != nil {
+		return nil, err
+	}
+	oprfPublicKey = oprfServer.PublicKey()
+	encodedPublicKey, err := oprfPublicKey.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if err := recordKeyGeneration("oprf", encodedPublicKey); err != nil {
+		return nil, err
+	}
+	var opts []server.Option
+	if auditLog != nil {
+		opts = append(opts, server.WithAuditLog(auditRecorder("server")))
+	}
+	if enrollmentAuthority != nil {
+		verifier, err := identity.NewVerifier(enrollmentAuthority.PublicKey())
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, server.WithCertificateVerifier(verifier))
+	}
+	transparencyLog, err := createTransparencyLog()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, server.WithTransparencyLog(transparencyLog))
+	if signTuples {
+		opts = append(opts, server.WithKeyRegistry(server.NewMemoryKeyRegistry()), server.WithSignedMatchesOnly())
+	}
+	fmt.Println("creating callisto server...")
+	return server.NewCallistoServer(oprfServer, server.NewMemoryTupleStore(), opts...)
+}
+
+// createTransparencyLog creates the server's transparency log and the
+// verifier clients check its receipts with
+func createTransparencyLog() (*transparency.Log, error) {
+	fmt.Println("generating transparency log key...")
+	publicKey, signingKey, err := ed25519.GenerateKey(nil)
 	if err != nil {
 		return nil, err
 	}
-	return oprfServer, nil
+	if err := recordKeyGeneration("transparency", publicKey); err != nil {
+		return nil, err
+	}
+	log, err := transparency.NewLog(signingKey)
+	if err != nil {
+		return nil, err
+	}
+	transparencyVerifier, err = transparency.NewVerifier(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return log, nil
+}
+
+func createEnrollmentAuthority() (*identity.Authority, error) {
+	fmt.Println("generating enrollment authority key...")
+	publicKey, signingKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordKeyGeneration("enrollment_authority", publicKey); err != nil {
+		return nil, err
+	}
+	return identity.NewAuthority(signingKey)
+}
+
+// enrollClient asks for the subject a new client is verified as and enrolls it
+// with the enrollment authority for the holder of holderKey. The same subject
+// always gets the same user ID.
+func enrollClient(holderKey ed25519.PublicKey) (identity.Certificate, error) {
+	var subject string
+	prompt := &survey.Input{Message: "Verified email address of the user to enroll:"}
+	if err := survey.AskOne(prompt, &subject, survey.WithValidator(survey.Required)); err != nil {
+		return identity.Certificate{}, err
+	}
+	certificate, err := enrollmentAuthority.Enroll(subject, holderKey)
+	if err != nil {
+		return identity.Certificate{}, err
+	}
+	fmt.Printf("enrolled %v as user %x\n", subject, certificate.UserID)
+	return certificate, nil
 }
 
 func createCallistoClient(oprfEvaluator oprf.OPRFEvaluator) (*client.CallistoClient, error) {
-	pHatComputer, err := oprf.NewPHatComputer(oprfEvaluator)
+	// Clients take the OPRF key from the key directory
+	doc, ok := keyDirectory.Pinned()
+	if !ok {
+		return nil, fmt.Errorf("no key directory is pinned")
+	}
+	directoryOPRFKey, err := doc.OPRFKey()
 	if err != nil {
 		return nil, err
 	}
-	callistoClient, err := client.NewCallistoClient(pHatComputer)
+	oprfOpts := []oprf.Option{oprf.WithPublicKey(directoryOPRFKey)}
+	if oprfConfig.Mode == oprf.PartialObliviousMode {
+		oprfOpts = append(oprfOpts, oprf.WithMetadata(oprfMetadata))
+	}
+	pHatComputer, err := oprf.NewPHatComputer(oprfConfig, oprfEvaluator, oprfOpts...)
 	if err != nil {
 		return nil, err
 	}
+	opts := []client.Option{client.WithSuite(protocolSuite), client.WithKeyDirectory(keyDirectory)}
+	if fuzzyMatching {
+		opts = append(opts, client.WithFuzzyMatching())
+	}
+	// Certified clients sign their tuples with the key their certificate is
+	// for
+	var encodedCertificate []byte
+	var signingKey ed25519.PrivateKey
+	if signTuples || enrollmentAuthority != nil {
+		publicKey, privateKey, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			return nil, err
+		}
+		signingKey = privateKey
+		opts = append(opts, client.WithSigningKey(signingKey))
+		if enrollmentAuthority != nil {
+			certificate, err := enrollClient(publicKey)
+			if err != nil {
+				return nil, err
+			}
+			if encodedCertificate, err = certificate.MarshalBinary(); err != nil {
+				return nil, err
+			}
+			opts = append(opts, client.WithCertificate(certificate))
+		}
+	}
+	callistoClient, err := client.NewCallistoClient(pHatComputer, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if signTuples {
+		// Register the client's key with the server, proving that it holds the
+		// key, and its user ID with its certificate if the server requires one
+		proof, err := identity.ProveKey(callistoClient.UserID, signingKey)
+		if err != nil {
+			return nil, err
+		}
+		if err := callistoServer.RegisterSigningKey(callistoClient.UserID, encodedCertificate, callistoClient.SigningPublicKey(), proof); err != nil {
+			return nil, err
+		}
+	}
 	return callistoClient, nil
 }
 
 type locAndDLOCKeys struct {
-	locKeys  encryption.RSAKeyPair
-	dlocKeys encryption.RSAKeyPair
+	locKeys  crypto.RSAKeyPair
+	dlocKeys crypto.RSAKeyPair
 }
 
 func createLOCAndDLOCKeys() (locAndDLOCKeys, error) {
 	fmt.Println("creating LOC/DLOC keys...")
-	locKeys, err := encryption.GenerateRSAKeyPair()
+	locKeys, err := crypto.GenerateRSAKeyPair()
 	if err != nil {
 		return locAndDLOCKeys{}, fmt.Errorf("failed to craeate loc keys: %v", err)
 	}
-	dlocKeys, err := encryption.GenerateRSAKeyPair()
+	dlocKeys, err := crypto.GenerateRSAKeyPair()
 	if err != nil {
 		return locAndDLOCKeys{}, fmt.Errorf("failed to craeate dloc keys: %v", err)
 	}
+	for name, keys := range map[string]crypto.RSAKeyPair{"loc": locKeys, "dloc": dlocKeys} {
+		publicKey, err := x509.MarshalPKIXPublicKey(keys.PublicKey)
+		if err != nil {
+			return locAndDLOCKeys{}, err
+		}
+		if err := recordKeyGeneration(name, publicKey); err != nil {
+			return locAndDLOCKeys{}, err
+		}
+	}
 	return locAndDLOCKeys{locKeys: locKeys, dlocKeys: dlocKeys}, nil
 }
 
@@ -60,17 +212,22 @@ func handleError(err error) {
 	// All errors lead to exit
 	if err == terminal.InterruptErr {
 		fmt.Println("Caught interrupt. Exiting...")
+		printAuditCheckpoint()
 		os.Exit(0)
 	}
 	if err != nil {
 		// Print error
 		fmt.Println("\ngot error:")
-		fmt.Fprintf(os.Stderr, "%+v\n", err)
+		fmt.Fprintf(os.Stderr, "[%v] %+v\n", errcode.Of(err), err)
+		printAuditCheckpoint()
 		os.Exit(1)
 	}
 }
 
 func prependAmpersand(s string) string {
+	if s == "" {
+		return s
+	}
 	return "@" + s
 }
 
@@ -78,3 +235,25 @@ func prettyPrint(i interface{}) string {
 	s, _ := json.MarshalIndent(i, "", "\t")
 	return string(s)
 }
+
+const dateLayout = "2006-01-02"
+
+func parseOptionalDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	date, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: expected YYYY-MM-DD", s)
+	}
+	return date, nil
+}
+
+func validateOptionalDate(ans interface{}) error {
+	s, ok := ans.(string)
+	if !ok {
+		return fmt.Errorf("expected a date")
+	}
+	_, err := parseOptionalDate(s)
+	return err
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"time"
+
+	"github.com/ymarcus93/gallisto/directory"
+	"github.com/ymarcus93/gallisto/protocol/client"
+)
+
+// How long the published key directory is valid for
+const keyDirectoryValidity = 365 * 24 * time.Hour
+
+// createKeyDirectory publishes a key directory of the LOC/DLOC keys, OPRF key
+// and suite, signed by a root key that is discarded afterwards as it would be
+// kept offline, and returns the fetcher that clients check their keys with
+func createKeyDirectory() (*directory.Fetcher, error) {
+	fmt.Println("generating key directory root key...")
+	rootPublicKey, rootKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordKeyGeneration("directory_root", rootPublicKey); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	doc, err := directory.NewDocument(1, protocolSuite, pubkeys.locKeys.PublicKey, pubkeys.dlocKeys.PublicKey, oprfPublicKey, now, now.Add(keyDirectoryValidity))
+	if err != nil {
+		return nil, err
+	}
+	published, err := directory.Sign(doc, rootKey)
+	if err != nil {
+		return nil, err
+	}
+
+	fetcher, err := directory.NewFetcher(rootPublicKey, directory.SourceFunc(func(context.Context) (directory.SignedDocument, error) {
+		return published, nil
+	}))
+	if err != nil {
+		return nil, err
+	}
+	pinned, err := fetcher.Fetch()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("verified and pinned key directory %v for suite %v, valid until %v\n", pinned.Serial, pinned.Suite, pinned.NotAfter.Format(time.RFC3339))
+	return fetcher, nil
+}
+
+// directoryLOCKeys returns the LOC/DLOC public keys of the pinned key
+// directory, which clients encrypt to
+func directoryLOCKeys() (client.LOCPublicKeys, error) {
+	doc, ok := keyDirectory.Pinned()
+	if !ok {
+		return client.LOCPublicKeys{}, fmt.Errorf("no key directory is pinned")
+	}
+	locPublicKey, dlocPublicKey, err := doc.LOCKeys()
+	if err != nil {
+		return client.LOCPublicKeys{}, err
+	}
+	return client.LOCPublicKeys{LOCPublicKey: locPublicKey, DLOCPublicKey: dlocPublicKey}, nil
+}
@@ -1,43 +1,150 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"math"
 	"os"
 	"os/signal"
 	"syscall"
 
-	"github.com/ymarcus93/gallisto/internal/oprf"
+	"github.com/ymarcus93/gallisto/audit"
+	"github.com/ymarcus93/gallisto/directory"
+	"github.com/ymarcus93/gallisto/identity"
+	"github.com/ymarcus93/gallisto/oprf"
 	"github.com/ymarcus93/gallisto/protocol/client"
-	"github.com/ymarcus93/gallisto/types"
+	"github.com/ymarcus93/gallisto/protocol/server"
+	"github.com/ymarcus93/gallisto/suite"
+	"github.com/ymarcus93/gallisto/timestamp"
+	"github.com/ymarcus93/gallisto/transparency"
 
 	"github.com/AlecAivazis/survey/v2"
 )
 
 // Global state
-var oprfServer *oprf.OPRFServer
+var callistoServer *server.CallistoServer
 var pubkeys locAndDLOCKeys
 
 var currClient *client.CallistoClient
 var callistoClients = make(map[string]*client.CallistoClient)
-var callistoTuples = make([]types.CallistoTuple, 0)
+var intakeQuestionnaire = defaultQuestionnaire()
+var fuzzyMatching bool
+
+// Enrollment authority certifying client user IDs, if enabled with -certify
+var enrollmentAuthority *identity.Authority
+
+// Whether clients sign their tuples, enabled with -sign
+var signTuples bool
+
+// Verifier of the server's transparency log receipts, and the receipts of
+// every client's submissions
+var transparencyVerifier *transparency.Verifier
+var submissionReceipts = make(map[*client.CallistoClient][]transparency.Receipt)
+
+// Key directory of the LOC/DLOC and OPRF keys that every client pins
+var keyDirectory *directory.Fetcher
+
+// Authority timestamping submitted tuples, its verifier, and every client's
+// timestamped copies of the entries it submitted
+var timestampAuthority *timestamp.Authority
+var timestampVerifier *timestamp.Verifier
+var entryRecords = make(map[*client.CallistoClient][]client.EntryRecord)
+
+// Protocol suite and OPRF setup shared by the server and every client
+var protocolSuite = suite.Default
+var oprfConfig oprf.Config
+var oprfPublicKey oprf.PublicKey
+var oprfMetadata oprf.Metadata
 
 func main() {
+	questionnairePath := flag.String("questionnaire", "", "path to a YAML or JSON intake questionnaire (default: built-in questionnaire)")
+	printQuestionnaire := flag.Bool("print-questionnaire", false, "print the questionnaire in use as YAML and exit")
+	flag.BoolVar(&fuzzyMatching, "fuzzy-matching", false, "also submit phonetic and spelling variants of perpetrator names as lower-confidence matches")
+	suiteName := flag.String("suite", suite.Default.String(), "protocol suite to create tuples with; it also selects the OPRF ciphersuite")
+	oprfMode := flag.String("oprf-mode", oprf.VerifiableMode.String(), "OPRF mode: verifiable or partially-oblivious")
+	flag.StringVar(&oprfMetadata.Tenant, "tenant", "", "tenant ID clients bind into p-hat in the partially-oblivious OPRF mode")
+	certify := flag.Bool("certify", false, "enroll every client with an enrollment authority and only accept tuples with certified user IDs")
+	flag.BoolVar(&signTuples, "sign", false, "have every client sign its tuples and only match tuples with valid signatures")
+	epoch := flag.Uint("epoch", 0, "key epoch clients bind into p-hat in the partially-oblivious OPRF mode")
+	auditLogPath := flag.String("audit-log", "", "path to an audit log of server, LOC and key operations, appended to if it exists")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: gallisto [flags]\n       gallisto vectors <command> [flags]\n       gallisto audit <command> [flags]\n       gallisto record <command> [flags]\n\nflags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.Arg(0) == "vectors" {
+		handleError(runVectorsCommand(flag.Args()[1:]))
+		return
+	}
+	if flag.Arg(0) == "audit" {
+		handleError(runAuditCommand(flag.Args()[1:]))
+		return
+	}
+	if flag.Arg(0) == "record" {
+		handleError(runRecordCommand(flag.Args()[1:]))
+		return
+	}
+
 	SetupCloseHandler()
 	var err error
 
-	// We always start off with a new OPRF server
-	oprfServer, err = createOPRFServer()
+	protocolSuite, err = suite.Parse(*suiteName)
+	handleError(err)
+	oprfConfig.Mode, err = oprf.ParseMode(*oprfMode)
+	handleError(err)
+	// Clients check the OPRF key against the key directory, which only
+	// verified evaluations show
+	if oprfConfig.Mode == oprf.BaseMode {
+		handleError(fmt.Errorf("OPRF mode %v does not verify evaluations; use verifiable or partially-oblivious", oprfConfig.Mode))
+	}
+	if *epoch > math.MaxUint32 {
+		handleError(fmt.Errorf("epoch %v does not fit in 32 bits", *epoch))
+	}
+	oprfMetadata.Epoch = uint32(*epoch)
+
+	if *questionnairePath != "" {
+		intakeQuestionnaire, err = loadQuestionnaire(*questionnairePath)
+		handleError(err)
+	}
+	if *printQuestionnaire {
+		err = printQuestionnaireYAML(intakeQuestionnaire)
+		handleError(err)
+		return
+	}
+
+	if *auditLogPath != "" {
+		auditLog, err = audit.OpenLog(*auditLogPath)
+		handleError(err)
+		fmt.Printf("recording to audit log %v from checkpoint %v\n", *auditLogPath, auditLog.Checkpoint())
+	}
+
+	if *certify {
+		enrollmentAuthority, err = createEnrollmentAuthority()
+		handleError(err)
+	}
+
+	// We always start off with a new Callisto server and OPRF key
+	callistoServer, err = createCallistoServer()
+	handleError(err)
+
+	// and a timestamp authority
+	timestampAuthority, err = createTimestampAuthority()
 	handleError(err)
 
 	// and some DLOC/LOC key generation
 	pubkeys, err = createLOCAndDLOCKeys()
 	handleError(err)
 
+	// which are published in a signed key directory
+	keyDirectory, err = createKeyDirectory()
+	handleError(err)
+
 	println("\ninitial setup complete!")
 
 	mainMenuPrompt := &survey.Select{
 		Message: "Choose an action:",
-		Options: []string{"Submit entry", "Find matches", "Exit"},
+		Options: []string{"Submit entry", "Find matches", "Check receipts", "Export entry record", "Exit"},
 	}
 	for {
 		var action string
@@ -49,6 +156,10 @@ func main() {
 			err = submitEntry()
 		case "Find matches":
 			err = findMatches()
+		case "Check receipts":
+			err = checkReceipts()
+		case "Export entry record":
+			err = exportEntryRecord()
 		case "Exit":
 			exit()
 		}
@@ -59,16 +170,18 @@ func main() {
 }
 
 func SetupCloseHandler() {
-	c := make(chan os.Signal)
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-c
 		fmt.Println(" Caught interrupt. Exiting...")
+		printAuditCheckpoint()
 		os.Exit(0)
 	}()
 }
 
 func exit() {
+	printAuditCheckpoint()
 	fmt.Println("Bye!")
 	os.Exit(0)
 }
@@ -0,0 +1,512 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/ymarcus93/gallisto/protocol/client"
+	"github.com/ymarcus93/gallisto/types"
+	"gopkg.in/yaml.v2"
+)
+
+// Prompt types supported by a questionnaire field
+const (
+	promptInput       = "input"
+	promptMultiline   = "multiline"
+	promptSelect      = "select"
+	promptMultiSelect = "multiselect"
+)
+
+// Names of the transforms a questionnaire field can apply to its answer
+const (
+	transformTitle      = "title"
+	transformPrependAt  = "prependAt"
+	transformTrimSpaces = "trim"
+)
+
+// Names of the validators a questionnaire field can apply to its answer
+const (
+	validatorDate    = "date"
+	validatorPhone   = "phone"
+	validatorEmail   = "email"
+	validatorTwitter = "twitter"
+	validatorText    = "text"
+)
+
+// questionnaire describes the intake questions asked when submitting an entry
+// and where each answer ends up in the submitted CallistoEntry
+type questionnaire struct {
+	Fields []questionField `json:"fields" yaml:"fields"`
+}
+
+// questionField describes a single question of a questionnaire
+type questionField struct {
+	Name      string   `json:"name" yaml:"name"`
+	Message   string   `json:"message" yaml:"message"`
+	Type      string   `json:"type" yaml:"type"`
+	Options   []string `json:"options,omitempty" yaml:"options,omitempty"`
+	Default   string   `json:"default,omitempty" yaml:"default,omitempty"`
+	Required  bool     `json:"required,omitempty" yaml:"required,omitempty"`
+	Validate  []string `json:"validate,omitempty" yaml:"validate,omitempty"`
+	Transform string   `json:"transform,omitempty" yaml:"transform,omitempty"`
+	MapTo     string   `json:"mapTo" yaml:"mapTo"`
+}
+
+// entrySetter stores an answer into a CallistoEntry
+type entrySetter func(entry *client.CallistoEntry, answer interface{}) error
+
+// targetPerpetratorName is the mapping target used to derive the perpetrator
+// ID. Every questionnaire must map a field to it.
+const targetPerpetratorName = "entry.perpetratorName"
+
+// entryTargets lists every location in a CallistoEntry that a questionnaire
+// field can map into
+var entryTargets = map[string]entrySetter{
+	targetPerpetratorName: setString(func(e *client.CallistoEntry, s string) {
+		e.EntryData.PerpetratorName = s
+	}),
+	"entry.perpetratorTwitterUserName": setString(func(e *client.CallistoEntry, s string) {
+		e.EntryData.PerpetratorTwitterUserName = s
+	}),
+	"entry.victimName": setString(func(e *client.CallistoEntry, s string) {
+		e.EntryData.VictimName = s
+	}),
+	"entry.victimPhoneNumber": setString(func(e *client.CallistoEntry, s string) {
+		e.EntryData.VictimPhoneNumber = s
+	}),
+	"entry.victimEmail": setString(func(e *client.CallistoEntry, s string) {
+		e.EntryData.VictimEmail = s
+	}),
+	"entry.incident.startDate": setIncidentDate(func(i *types.Incident, d time.Time) {
+		i.StartDate = d
+	}),
+	"entry.incident.endDate": setIncidentDate(func(i *types.Incident, d time.Time) {
+		i.EndDate = d
+	}),
+	"entry.incident.location": setIncidentString(func(i *types.Incident, s string) {
+		i.Location = s
+	}),
+	"entry.incident.narrative": setIncidentString(func(i *types.Incident, s string) {
+		i.Narrative = s
+	}),
+	"assignment.victimStateOfCurrentResidence": setString(func(e *client.CallistoEntry, s string) {
+		e.AssignmentData.VictimStateOfCurrentResidence = s
+	}),
+	"assignment.categorizationOfSexualMisconduct": setCategories,
+	"assignment.industryOfPerpetrator": setString(func(e *client.CallistoEntry, s string) {
+		e.AssignmentData.IndustryOfPerpetrator = s
+	}),
+}
+
+// defaultQuestionnaire returns the questionnaire used when none is provided on
+// the command line
+func defaultQuestionnaire() questionnaire {
+	return questionnaire{Fields: []questionField{
+		{
+			Name:      "PerpetratorName",
+			Message:   "What is the perpetrator's name?",
+			Type:      promptInput,
+			Default:   "Foo",
+			Required:  true,
+			Transform: transformTitle,
+			MapTo:     targetPerpetratorName,
+		},
+		{
+			Name:      "PerpetratorTwitterUserName",
+			Message:   "What is the perpetrator's twitter user name?",
+			Type:      promptInput,
+			Default:   "foo",
+			Validate:  []string{validatorTwitter},
+			Transform: transformPrependAt,
+			MapTo:     "entry.perpetratorTwitterUserName",
+		},
+		{
+			Name:      "VictimName",
+			Message:   "What is the victim's name?",
+			Type:      promptInput,
+			Default:   "Bar",
+			Transform: transformTitle,
+			MapTo:     "entry.victimName",
+		},
+		{
+			Name:     "VictimPhoneNumber",
+			Message:  "What is the victim's phone number?",
+			Type:     promptInput,
+			Default:  "111-234-5678",
+			Validate: []string{validatorPhone},
+			MapTo:    "entry.victimPhoneNumber",
+		},
+		{
+			Name:     "VictimEmail",
+			Message:  "What is the victim's email?",
+			Type:     promptInput,
+			Default:  "bar@mail.com",
+			Validate: []string{validatorEmail},
+			MapTo:    "entry.victimEmail",
+		},
+		{
+			Name:    "VictimStateOfCurrentResidence",
+			Message: "What is the victim's current state of residence?",
+			Type:    promptSelect,
+			Options: usStates,
+			Default: "Massachusetts",
+			MapTo:   "assignment.victimStateOfCurrentResidence",
+		},
+		{
+			Name:    "CategorizationOfSexualMisconduct",
+			Message: "Which categorie(s) of sexual misconduct does this incident fit?",
+			Type:    promptMultiSelect,
+			Options: categories,
+			MapTo:   "assignment.categorizationOfSexualMisconduct",
+		},
+		{
+			Name:     "IndustryOfPerpetrator",
+			Message:  "What is the perpetrator's industry?",
+			Type:     promptInput,
+			Default:  "Foo industry",
+			Validate: []string{validatorText},
+			MapTo:    "assignment.industryOfPerpetrator",
+		},
+		{
+			Name:     "IncidentStartDate",
+			Message:  "When did the incident start? (YYYY-MM-DD, optional)",
+			Type:     promptInput,
+			Validate: []string{validatorDate},
+			MapTo:    "entry.incident.startDate",
+		},
+		{
+			Name:     "IncidentEndDate",
+			Message:  "When did the incident end? (YYYY-MM-DD, optional)",
+			Type:     promptInput,
+			Validate: []string{validatorDate},
+			MapTo:    "entry.incident.endDate",
+		},
+		{
+			Name:     "IncidentLocation",
+			Message:  "Where did the incident take place? (optional)",
+			Type:     promptInput,
+			Validate: []string{validatorText},
+			MapTo:    "entry.incident.location",
+		},
+		{
+			Name:    "IncidentNarrative",
+			Message: "Describe what happened (optional)",
+			Type:    promptMultiline,
+			MapTo:   "entry.incident.narrative",
+		},
+	}}
+}
+
+// loadQuestionnaire reads a questionnaire definition from a YAML or JSON file.
+// The format is chosen by the file extension.
+func loadQuestionnaire(path string) (questionnaire, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return questionnaire{}, fmt.Errorf("failed to read questionnaire: %v", err)
+	}
+
+	var q questionnaire
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(contents, &q)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(contents))
+		decoder.DisallowUnknownFields()
+		if err = decoder.Decode(&q); err == nil && decoder.More() {
+			err = fmt.Errorf("unexpected data after the questionnaire")
+		}
+	default:
+		return questionnaire{}, fmt.Errorf("unsupported questionnaire format %q: expected .yaml, .yml or .json", filepath.Ext(path))
+	}
+	if err != nil {
+		return questionnaire{}, fmt.Errorf("failed to parse questionnaire %v: %v", path, err)
+	}
+
+	if err := q.IsValid(); err != nil {
+		return questionnaire{}, fmt.Errorf("invalid questionnaire %v: %v", path, err)
+	}
+	return q, nil
+}
+
+// printQuestionnaireYAML writes the questionnaire to stdout so it can be used
+// as a starting point for a custom one
+func printQuestionnaireYAML(q questionnaire) error {
+	out, err := yaml.Marshal(&q)
+	if err != nil {
+		return fmt.Errorf("failed to encode questionnaire: %v", err)
+	}
+	fmt.Print(string(out))
+	return nil
+}
+
+// IsValid returns a non-nil error if the questionnaire cannot be asked or does
+// not produce a usable entry
+func (q questionnaire) IsValid() error {
+	if len(q.Fields) == 0 {
+		return fmt.Errorf("questionnaire has no fields")
+	}
+
+	names := make(map[string]struct{})
+	targets := make(map[string]struct{})
+	for i, f := range q.Fields {
+		if f.Name == "" {
+			return fmt.Errorf("field at index %v has no name", i)
+		}
+		if _, ok := names[f.Name]; ok {
+			return fmt.Errorf("field %v is defined more than once", f.Name)
+		}
+		names[f.Name] = struct{}{}
+
+		if err := f.IsValid(); err != nil {
+			return fmt.Errorf("field %v: %v", f.Name, err)
+		}
+		if _, ok := targets[f.MapTo]; ok {
+			return fmt.Errorf("field %v: %v is already mapped by another field", f.Name, f.MapTo)
+		}
+		targets[f.MapTo] = struct{}{}
+	}
+
+	// Entries must pass EntryData.Validate and AssignmentData.Validate, so
+	// the questionnaire has to ask for every required field
+	for _, required := range []string{targetPerpetratorName, "assignment.victimStateOfCurrentResidence"} {
+		if _, ok := targets[required]; !ok {
+			return fmt.Errorf("no field maps to %v", required)
+		}
+	}
+	_, hasPhone := targets["entry.victimPhoneNumber"]
+	_, hasEmail := targets["entry.victimEmail"]
+	if !hasPhone && !hasEmail {
+		return fmt.Errorf("no field maps to entry.victimPhoneNumber or entry.victimEmail")
+	}
+	return nil
+}
+
+// IsValid returns a non-nil error if the field is malformed
+func (f questionField) IsValid() error {
+	if f.Message == "" {
+		return fmt.Errorf("message cannot be empty")
+	}
+	if _, ok := entryTargets[f.MapTo]; !ok {
+		return fmt.Errorf("unknown mapTo target %q", f.MapTo)
+	}
+
+	switch f.Type {
+	case promptInput, promptMultiline:
+		if len(f.Options) != 0 {
+			return fmt.Errorf("%v prompts do not take options", f.Type)
+		}
+	case promptSelect, promptMultiSelect:
+		if len(f.Options) == 0 {
+			return fmt.Errorf("%v prompts require options", f.Type)
+		}
+		if f.Default != "" && !containsString(f.Options, f.Default) {
+			return fmt.Errorf("default %q is not one of the options", f.Default)
+		}
+	default:
+		return fmt.Errorf("unknown prompt type %q", f.Type)
+	}
+
+	if f.MapTo == "assignment.categorizationOfSexualMisconduct" {
+		if f.Type != promptMultiSelect {
+			return fmt.Errorf("%v must be a %v prompt", f.MapTo, promptMultiSelect)
+		}
+		for _, option := range f.Options {
+			if _, err := types.ParseMisconductCategory(option); err != nil {
+				return err
+			}
+		}
+	} else if f.Type == promptMultiSelect {
+		return fmt.Errorf("%v cannot hold multiple answers", f.MapTo)
+	}
+
+	switch f.Transform {
+	case "", transformTitle, transformPrependAt, transformTrimSpaces:
+	default:
+		return fmt.Errorf("unknown transform %q", f.Transform)
+	}
+
+	// Validators check typed answers; survey passes select prompts' choices
+	// to them as options instead
+	if len(f.Validate) != 0 && f.Type != promptInput && f.Type != promptMultiline {
+		return fmt.Errorf("%v prompts do not take validators", f.Type)
+	}
+	for _, name := range f.Validate {
+		if _, ok := fieldValidators[name]; !ok {
+			return fmt.Errorf("unknown validator %q", name)
+		}
+	}
+	return nil
+}
+
+// ask prompts the user with every question and returns the resulting entry
+func (q questionnaire) ask() (client.CallistoEntry, error) {
+	var entry client.CallistoEntry
+	for _, f := range q.Fields {
+		answer, err := f.ask()
+		if err != nil {
+			return client.CallistoEntry{}, err
+		}
+		if err := entryTargets[f.MapTo](&entry, answer); err != nil {
+			return client.CallistoEntry{}, fmt.Errorf("failed to map answer of %v: %v", f.Name, err)
+		}
+	}
+	return entry, nil
+}
+
+func (f questionField) ask() (interface{}, error) {
+	opts := []survey.AskOpt{}
+	if f.Required {
+		opts = append(opts, survey.WithValidator(survey.Required))
+	}
+	for _, name := range f.Validate {
+		opts = append(opts, survey.WithValidator(fieldValidators[name]))
+	}
+
+	switch f.Type {
+	case promptMultiSelect:
+		var answer []string
+		prompt := &survey.MultiSelect{Message: f.Message, Options: f.Options}
+		if f.Default != "" {
+			prompt.Default = f.Default
+		}
+		if err := survey.AskOne(prompt, &answer, opts...); err != nil {
+			return nil, err
+		}
+		return answer, nil
+	default:
+		var answer string
+		if err := survey.AskOne(f.prompt(), &answer, opts...); err != nil {
+			return nil, err
+		}
+		return f.transform(answer), nil
+	}
+}
+
+func (f questionField) prompt() survey.Prompt {
+	switch f.Type {
+	case promptMultiline:
+		return &survey.Multiline{Message: f.Message, Default: f.Default}
+	case promptSelect:
+		prompt := &survey.Select{Message: f.Message, Options: f.Options}
+		if f.Default != "" {
+			prompt.Default = f.Default
+		}
+		return prompt
+	default:
+		return &survey.Input{Message: f.Message, Default: f.Default}
+	}
+}
+
+func (f questionField) transform(answer string) string {
+	switch f.Transform {
+	case transformTitle:
+		return strings.Title(answer)
+	case transformPrependAt:
+		return prependAmpersand(answer)
+	case transformTrimSpaces:
+		return strings.TrimSpace(answer)
+	default:
+		return answer
+	}
+}
+
+// fieldValidators reuses the validators applied by EntryData.Validate and
+// AssignmentData.Validate so that invalid answers are caught while prompting
+var fieldValidators = map[string]survey.Validator{
+	validatorDate:    validateOptionalDate,
+	validatorPhone:   optionalStringValidator(types.ValidatePhoneNumber),
+	validatorEmail:   optionalStringValidator(types.ValidateEmail),
+	validatorTwitter: optionalStringValidator(types.ValidateTwitterHandle),
+	validatorText:    optionalStringValidator(types.ValidateShortText),
+}
+
+// optionalStringValidator adapts a string validator to survey. Empty answers
+// are accepted; use the required setting to reject them.
+func optionalStringValidator(validate func(string) error) survey.Validator {
+	return func(ans interface{}) error {
+		s, ok := ans.(string)
+		if !ok || s == "" {
+			return nil
+		}
+		return validate(s)
+	}
+}
+
+func setString(set func(e *client.CallistoEntry, s string)) entrySetter {
+	return func(e *client.CallistoEntry, answer interface{}) error {
+		s, ok := answer.(string)
+		if !ok {
+			return fmt.Errorf("expected a single answer")
+		}
+		set(e, s)
+		return nil
+	}
+}
+
+func setIncidentString(set func(i *types.Incident, s string)) entrySetter {
+	return setString(func(e *client.CallistoEntry, s string) {
+		if s == "" {
+			return
+		}
+		set(incidentOf(e), s)
+	})
+}
+
+func setIncidentDate(set func(i *types.Incident, d time.Time)) entrySetter {
+	return func(e *client.CallistoEntry, answer interface{}) error {
+		s, ok := answer.(string)
+		if !ok {
+			return fmt.Errorf("expected a single answer")
+		}
+		date, err := parseOptionalDate(s)
+		if err != nil {
+			return err
+		}
+		if date.IsZero() {
+			return nil
+		}
+		set(incidentOf(e), date)
+		return nil
+	}
+}
+
+func setCategories(e *client.CallistoEntry, answer interface{}) error {
+	names, ok := answer.([]string)
+	if !ok {
+		return fmt.Errorf("expected a list of answers")
+	}
+	categories := make([]types.MisconductCategory, len(names))
+	for i, name := range names {
+		category, err := types.ParseMisconductCategory(name)
+		if err != nil {
+			return err
+		}
+		categories[i] = category
+	}
+	e.AssignmentData.CategorizationOfSexualMisconduct = categories
+	return nil
+}
+
+// incidentOf returns the entry's incident, creating it on first use so that
+// entries without any incident answers keep a nil Incident
+func incidentOf(e *client.CallistoEntry) *types.Incident {
+	if e.EntryData.Incident == nil {
+		e.EntryData.Incident = &types.Incident{}
+	}
+	return e.EntryData.Incident
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
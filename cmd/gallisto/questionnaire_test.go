@@ -0,0 +1,212 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ymarcus93/gallisto/protocol/client"
+	"github.com/ymarcus93/gallisto/types"
+)
+
+func writeQuestionnaireFile(name, contents string, t *testing.T) string {
+	dir, err := ioutil.TempDir("", "questionnaire")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("failed to write questionnaire: %v", err)
+	}
+	return path
+}
+
+func TestDefaultQuestionnaire_IsValid(t *testing.T) {
+	assert.NoError(t, defaultQuestionnaire().IsValid())
+}
+
+func TestLoadQuestionnaire(t *testing.T) {
+	tests := map[string]struct {
+		fileName string
+		contents string
+	}{
+		"yaml": {
+			fileName: "q.yaml",
+			contents: `
+fields:
+- name: Perp
+  message: Who?
+  type: input
+  required: true
+  mapTo: entry.perpetratorName
+- name: Region
+  message: Where?
+  type: select
+  options: [North, South]
+  default: South
+  mapTo: assignment.victimStateOfCurrentResidence
+- name: Email
+  message: Email?
+  type: input
+  validate: [email]
+  mapTo: entry.victimEmail
+`,
+		},
+		"json": {
+			fileName: "q.json",
+			contents: `{"fields": [
+				{"name": "Perp", "message": "Who?", "type": "input", "required": true, "mapTo": "entry.perpetratorName"},
+				{"name": "Region", "message": "Where?", "type": "select", "options": ["North", "South"], "default": "South", "mapTo": "assignment.victimStateOfCurrentResidence"},
+				{"name": "Email", "message": "Email?", "type": "input", "validate": ["email"], "mapTo": "entry.victimEmail"}
+			]}`,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			path := writeQuestionnaireFile(test.fileName, test.contents, t)
+			q, err := loadQuestionnaire(path)
+			if assert.NoError(t, err) && assert.Len(t, q.Fields, 3) {
+				assert.Equal(t, "Perp", q.Fields[0].Name)
+				assert.True(t, q.Fields[0].Required)
+				assert.Equal(t, []string{"North", "South"}, q.Fields[1].Options)
+				assert.Equal(t, "South", q.Fields[1].Default)
+			}
+		})
+	}
+}
+
+func TestLoadQuestionnaire_Invalid(t *testing.T) {
+	perpField := "- {name: Perp, message: 'Who?', type: input, mapTo: entry.perpetratorName}\n" +
+		"- {name: State, message: 'Where?', type: input, mapTo: assignment.victimStateOfCurrentResidence}\n" +
+		"- {name: Phone, message: 'Phone?', type: input, mapTo: entry.victimPhoneNumber}\n"
+	tests := map[string]struct {
+		fileName string
+		contents string
+	}{
+		"unsupported extension": {
+			fileName: "q.txt",
+			contents: "fields:\n" + perpField,
+		},
+		"unknown key": {
+			fileName: "q.yaml",
+			contents: "fields:\n" + perpField + "colour: blue\n",
+		},
+		"unknown key in JSON": {
+			fileName: "q.json",
+			contents: `{"fields": [
+				{"name": "Perp", "message": "Who?", "type": "input", "mapTo": "entry.perpetratorName"},
+				{"name": "State", "message": "Where?", "type": "input", "mapTo": "assignment.victimStateOfCurrentResidence"},
+				{"name": "Email", "message": "Email?", "type": "input", "validaton": ["email"], "mapTo": "entry.victimEmail"}
+			]}`,
+		},
+		"no fields": {
+			fileName: "q.yaml",
+			contents: "fields: []\n",
+		},
+		"missing perpetrator name": {
+			fileName: "q.yaml",
+			contents: "fields:\n- {name: Victim, message: 'Who?', type: input, mapTo: entry.victimName}\n",
+		},
+		"missing state of residence": {
+			fileName: "q.yaml",
+			contents: "fields:\n- {name: Perp, message: 'Who?', type: input, mapTo: entry.perpetratorName}\n" +
+				"- {name: Phone, message: 'Phone?', type: input, mapTo: entry.victimPhoneNumber}\n",
+		},
+		"missing victim contact": {
+			fileName: "q.yaml",
+			contents: "fields:\n- {name: Perp, message: 'Who?', type: input, mapTo: entry.perpetratorName}\n" +
+				"- {name: State, message: 'Where?', type: input, mapTo: assignment.victimStateOfCurrentResidence}\n",
+		},
+		"unknown target": {
+			fileName: "q.yaml",
+			contents: "fields:\n" + perpField + "- {name: Foo, message: 'Foo?', type: input, mapTo: entry.foo}\n",
+		},
+		"duplicate target": {
+			fileName: "q.yaml",
+			contents: "fields:\n" + perpField + "- {name: Perp2, message: 'Who?', type: input, mapTo: entry.perpetratorName}\n",
+		},
+		"duplicate name": {
+			fileName: "q.yaml",
+			contents: "fields:\n" + perpField + "- {name: Perp, message: 'Who?', type: input, mapTo: entry.victimName}\n",
+		},
+		"unknown prompt type": {
+			fileName: "q.yaml",
+			contents: "fields:\n" + perpField + "- {name: Foo, message: 'Foo?', type: slider, mapTo: entry.victimName}\n",
+		},
+		"validator on select": {
+			fileName: "q.yaml",
+			contents: "fields:\n" + perpField + "- {name: Date, message: 'When?', type: select, options: ['2023-01-01'], validate: [date], mapTo: entry.incident.startDate}\n",
+		},
+		"select without options": {
+			fileName: "q.yaml",
+			contents: "fields:\n" + perpField + "- {name: Foo, message: 'Foo?', type: select, mapTo: entry.victimName}\n",
+		},
+		"default not an option": {
+			fileName: "q.yaml",
+			contents: "fields:\n" + perpField + "- {name: Foo, message: 'Foo?', type: select, options: [a], default: b, mapTo: entry.victimName}\n",
+		},
+		"unknown misconduct category": {
+			fileName: "q.yaml",
+			contents: "fields:\n" + perpField + "- {name: Cat, message: 'Which?', type: multiselect, options: [Abuse, Foo], mapTo: assignment.categorizationOfSexualMisconduct}\n",
+		},
+		"multiselect into single value": {
+			fileName: "q.yaml",
+			contents: "fields:\n" + perpField + "- {name: Foo, message: 'Foo?', type: multiselect, options: [a], mapTo: entry.victimName}\n",
+		},
+		"unknown validator": {
+			fileName: "q.yaml",
+			contents: "fields:\n" + perpField + "- {name: Foo, message: 'Foo?', type: input, validate: [foo], mapTo: entry.victimName}\n",
+		},
+		"unknown transform": {
+			fileName: "q.yaml",
+			contents: "fields:\n" + perpField + "- {name: Foo, message: 'Foo?', type: input, transform: foo, mapTo: entry.victimName}\n",
+		},
+	}
+
+	// The fields every case builds on are valid on their own
+	if _, err := loadQuestionnaire(writeQuestionnaireFile("q.yaml", "fields:\n"+perpField, t)); err != nil {
+		t.Fatalf("failed to load base questionnaire: %v", err)
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			path := writeQuestionnaireFile(test.fileName, test.contents, t)
+			_, err := loadQuestionnaire(path)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestEntryTargets(t *testing.T) {
+	var entry client.CallistoEntry
+	answers := map[string]interface{}{
+		"entry.perpetratorName":                       "Foo",
+		"entry.incident.startDate":                    "2020-04-01",
+		"entry.incident.endDate":                      "",
+		"entry.incident.location":                     "Boston",
+		"assignment.categorizationOfSexualMisconduct": []string{"Abuse", "Stalking"},
+	}
+	for target, answer := range answers {
+		assert.NoError(t, entryTargets[target](&entry, answer))
+	}
+
+	assert.Equal(t, "Foo", entry.EntryData.PerpetratorName)
+	assert.Equal(t, &types.Incident{
+		StartDate: time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC),
+		Location:  "Boston",
+	}, entry.EntryData.Incident)
+	assert.Equal(t, []types.MisconductCategory{types.MisconductAbuse, types.MisconductStalking}, entry.AssignmentData.CategorizationOfSexualMisconduct)
+}
+
+func TestEntryTargets_EmptyIncident(t *testing.T) {
+	var entry client.CallistoEntry
+	assert.NoError(t, entryTargets["entry.incident.location"](&entry, ""))
+	assert.NoError(t, entryTargets["entry.incident.startDate"](&entry, ""))
+	assert.Nil(t, entry.EntryData.Incident)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// checkReceipts checks that the server's transparency log still includes
+// every tuple it gave a client a receipt for, and that its current tree
+// extends the tree of each receipt
+func checkReceipts() error {
+	head, err := callistoServer.TreeHead()
+	if err != nil {
+		return err
+	}
+	if err := transparencyVerifier.VerifyTreeHead(head); err != nil {
+		return err
+	}
+	fmt.Printf("transparency log has %v tuples, root %x\n", head.Size, head.Root[:])
+
+	clientIDs := getListOfCallistoClientIDs()
+	sort.Strings(clientIDs)
+	for _, clientID := range clientIDs {
+		for _, receipt := range submissionReceipts[callistoClients[clientID]] {
+			consistency, err := callistoServer.ConsistencyProof(receipt.TreeHead.Size, head.Size)
+			if err != nil {
+				return err
+			}
+			if err := transparencyVerifier.VerifyConsistency(receipt.TreeHead, head, consistency); err != nil {
+				return fmt.Errorf("log of client %v's leaf %v was rewritten: %w", clientID, receipt.LeafIndex, err)
+			}
+			inclusion, err := callistoServer.InclusionProof(receipt.LeafIndex, head.Size)
+			if err != nil {
+				return err
+			}
+			if err := transparencyVerifier.VerifyInclusion(receipt.LeafHash, receipt.LeafIndex, head, inclusion); err != nil {
+				return fmt.Errorf("log dropped client %v's leaf %v: %w", clientID, receipt.LeafIndex, err)
+			}
+			fmt.Printf("client %v: leaf %v is still included\n", clientID, receipt.LeafIndex)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/ymarcus93/gallisto/protocol/client"
+	"github.com/ymarcus93/gallisto/timestamp"
+)
+
+const recordUsage = `usage: gallisto record <command> [flags]
+
+commands:
+  verify  check an exported entry record against the timestamp authority's public key`
+
+// createTimestampAuthority creates the authority timestamping submitted
+// tuples and the verifier clients check its tokens with
+func createTimestampAuthority() (*timestamp.Authority, error) {
+	fmt.Println("generating timestamp authority key...")
+	publicKey, signingKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordKeyGeneration("timestamp", publicKey); err != nil {
+		return nil, err
+	}
+	authority, err := timestamp.NewAuthority(signingKey)
+	if err != nil {
+		return nil, err
+	}
+	timestampVerifier, err = timestamp.NewVerifier(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("timestamp authority public key: %x\n", publicKey)
+	return authority, nil
+}
+
+// exportEntryRecord writes a timestamped entry record of any client to a file
+// that others can verify with `gallisto record verify`
+func exportEntryRecord() error {
+	clientIDs := getListOfCallistoClientIDs()
+	sort.Strings(clientIDs)
+	var labels []string
+	records := make(map[string]client.EntryRecord)
+	for _, clientID := range clientIDs {
+		for _, record := range entryRecords[callistoClients[clientID]] {
+			label := fmt.Sprintf("client %v: %v (%v match), timestamped %v", clientID, record.Entry.EntryData.PerpetratorName,
+				record.Tuple.MatchClass(), record.Timestamp.Time.Format(time.RFC3339))
+			labels = append(labels, label)
+			records[label] = record
+		}
+	}
+	if len(labels) == 0 {
+		fmt.Println("no entries have been submitted yet")
+		return nil
+	}
+
+	var selected string
+	if err := survey.AskOne(&survey.Select{Message: "Which entry should be exported?", Options: labels}, &selected); err != nil {
+		return err
+	}
+	var path string
+	if err := survey.AskOne(&survey.Input{Message: "File to export the record to:", Default: "entry-record.json"}, &path); err != nil {
+		return err
+	}
+	exported, err := records[selected].Export()
+	if err != nil {
+		return err
+	}
+	encoded, err := json.MarshalIndent(exported, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, encoded, 0600); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	fmt.Printf("exported record to %v; it discloses the entry's content\n", path)
+	fmt.Printf("verify with: gallisto record verify -record %v -tsa-key %x\n", path, timestampAuthority.PublicKey())
+	return nil
+}
+
+// runRecordCommand runs `gallisto record ...`
+func runRecordCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New(recordUsage)
+	}
+	switch args[0] {
+	case "verify":
+		return verifyEntryRecord(args[1:])
+	default:
+		return fmt.Errorf("unknown record command %q\n%v", args[0], recordUsage)
+	}
+}
+
+func verifyEntryRecord(args []string) error {
+	flags := flag.NewFlagSet("record verify", flag.ExitOnError)
+	path := flags.String("record", "", "path to an exported entry record")
+	keyHex := flags.String("tsa-key", "", "hex public key of the timestamp authority")
+	flags.Parse(args)
+	if *path == "" || *keyHex == "" {
+		return errors.New("record verify: -record and -tsa-key are required")
+	}
+
+	publicKey, err := hex.DecodeString(*keyHex)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp authority key: %w", err)
+	}
+	verifier, err := timestamp.NewVerifier(publicKey)
+	if err != nil {
+		return err
+	}
+	encoded, err := os.ReadFile(*path)
+	if err != nil {
+		return fmt.Errorf("failed to read record: %w", err)
+	}
+	var record client.ExportedRecord
+	if err := json.Unmarshal(encoded, &record); err != nil {
+		return fmt.Errorf("failed to decode record: %w", err)
+	}
+	timestamped, err := client.VerifyRecord(record, verifier)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("record verified: this entry existed by %v\n", timestamped.Format(time.RFC3339))
+	fmt.Println("entry data:", prettyPrint(record.EntryData))
+	fmt.Println("assignment data:", prettyPrint(record.AssignmentData))
+	return nil
+}
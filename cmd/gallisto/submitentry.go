@@ -3,11 +3,11 @@ package main
 import (
 	"fmt"
 	"strconv"
-	"strings"
+	"time"
 
 	"github.com/AlecAivazis/survey/v2"
+	"github.com/ymarcus93/gallisto/canonical"
 	"github.com/ymarcus93/gallisto/protocol/client"
-	"github.com/ymarcus93/gallisto/types"
 )
 
 func submitEntry() error {
@@ -18,23 +18,45 @@ func submitEntry() error {
 	}
 
 	// User inputs data for submission
-	dataInput, err := dataInput()
+	callistoEntry, err := intakeQuestionnaire.ask()
 	if err != nil {
 		return err
 	}
-	callistoEntry := convertDataInputToCallistoEntry(dataInput)
 
 	// Create the Callisto tuple
-	perpID := []byte(callistoEntry.EntryData.PerpetratorName)
-	locPubKeys := client.LOCPublicKeys{
-		LOCPublicKey:  pubkeys.locKeys.PublicKey,
-		DLOCPublicKey: pubkeys.dlocKeys.PublicKey,
+	perpID := canonical.Identifier{Type: canonical.TypeName, Value: callistoEntry.EntryData.PerpetratorName}
+	locPubKeys, err := directoryLOCKeys()
+	if err != nil {
+		return err
 	}
-	callistoTuple, err := currClient.CreateCallistoTuple(perpID, callistoEntry, locPubKeys)
+	records, err := currClient.CreateEntryRecords(perpID, callistoEntry, locPubKeys)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	for _, record := range records {
+		tuple := record.Tuple
+		_, receipt, err := callistoServer.SubmitWithReceipt(tuple)
+		if err != nil {
+			return err
+		}
+		tupleHash := tuple.Hash()
+		if err := transparencyVerifier.VerifyReceipt(receipt, tupleHash[:]); err != nil {
+			return fmt.Errorf("server returned an invalid receipt: %w", err)
+		}
+		submissionReceipts[currClient] = append(submissionReceipts[currClient], receipt)
+		fmt.Printf("verified receipt: tuple %x is leaf %v of the transparency log, in its tree of %v tuples with root %x signed at %v\n",
+			tupleHash, receipt.LeafIndex, receipt.TreeHead.Size, receipt.TreeHead.Root[:], receipt.TreeHead.Timestamp.Format(time.RFC3339))
+
+		// Keep a timestamped copy of the entry for the user's own record
+		if err := record.RequestTimestamp(timestampAuthority, timestampVerifier); err != nil {
+			return err
+		}
+		entryRecords[currClient] = append(entryRecords[currClient], record)
+		fmt.Printf("timestamped tuple at %v (token %v)\n", record.Timestamp.Time.Format(time.RFC3339), record.Timestamp.Serial)
+	}
+	if len(records) > 1 {
+		fmt.Printf("submitted %v additional lower-confidence tuple(s) for fuzzy matching\n", len(records)-1)
 	}
-	callistoTuples = append(callistoTuples, callistoTuple)
 	println("successfully submitted an entry!")
 	return nil
 }
@@ -43,7 +65,7 @@ func clientSelection() error {
 	// Create a new client if none are available
 	if len(callistoClients) == 0 {
 		fmt.Println("creating initial callisto client")
-		newClient, err := createCallistoClient(oprfServer)
+		newClient, err := createCallistoClient(callistoServer)
 		if err != nil {
 			return fmt.Errorf("failed to create initial callisto client: %v", err)
 		}
@@ -64,7 +86,7 @@ func clientSelection() error {
 		}
 
 		if createAnotherOne {
-			newClient, err := createCallistoClient(oprfServer)
+			newClient, err := createCallistoClient(callistoServer)
 			if err != nil {
 				return fmt.Errorf("failed to create initial callisto client: %v", err)
 			}
@@ -84,107 +106,6 @@ func clientSelection() error {
 	return nil
 }
 
-func dataInput() (dataInputAnswers, error) {
-	var dataInputQuestions = []*survey.Question{
-		{
-			Name: "PerpetratorName",
-			Prompt: &survey.Input{
-				Message: "What is the perpetrator's name?",
-				Default: "Foo",
-			},
-			Transform: survey.Title,
-		},
-		{
-			Name: "PerpetratorTwitterUserName",
-			Prompt: &survey.Input{
-				Message: "What is the perpetrator's twitter user name?",
-				Default: "foo",
-			},
-			Transform: survey.TransformString(prependAmpersand),
-		},
-		{
-			Name: "VictimName",
-			Prompt: &survey.Input{
-				Message: "What is the victim's name?",
-				Default: "Bar",
-			},
-			Transform: survey.Title,
-		},
-		{
-			Name: "VictimPhoneNumber",
-			Prompt: &survey.Input{
-				Message: "What is the victim's phone number?",
-				Default: "111-234-5678",
-			},
-		},
-		{
-			Name: "VictimEmail",
-			Prompt: &survey.Input{
-				Message: "What is the victim's email?",
-				Default: "bar@mail.com",
-			},
-		},
-		{
-			Name: "VictimStateOfCurrentResidence",
-			Prompt: &survey.Select{
-				Message: "What is the victim's current state of residence?",
-				Options: usStates,
-				Default: "Massachusetts",
-			},
-		},
-		{
-			Name: "CategorizationOfSexualMisconduct",
-			Prompt: &survey.MultiSelect{
-				Message: "Which categorie(s) of sexual misconduct does this incident fit?",
-				Options: categories,
-			},
-		},
-		{
-			Name: "IndustryOfPerpetrator",
-			Prompt: &survey.Input{
-				Message: "What is the perpetrator's industry?",
-				Default: "Foo industry",
-			},
-		},
-	}
-
-	// Perform the questions
-	var input dataInputAnswers
-	err := survey.Ask(dataInputQuestions, &input)
-	if err != nil {
-		return dataInputAnswers{}, err
-	}
-	return input, nil
-}
-
-type dataInputAnswers struct {
-	PerpetratorName                  string
-	PerpetratorTwitterUserName       string
-	VictimName                       string
-	VictimPhoneNumber                string
-	VictimEmail                      string
-	VictimStateOfCurrentResidence    string
-	CategorizationOfSexualMisconduct []string
-	IndustryOfPerpetrator            string
-}
-
-func convertDataInputToCallistoEntry(input dataInputAnswers) client.CallistoEntry {
-	return client.CallistoEntry{
-		EntryData: types.EntryData{
-			PerpetratorName:            input.PerpetratorName,
-			PerpetratorTwitterUserName: input.PerpetratorTwitterUserName,
-			VictimName:                 input.VictimName,
-			VictimPhoneNumber:          input.VictimPhoneNumber,
-			VictimEmail:                input.VictimEmail,
-		},
-		AssignmentData: types.AssignmentData{
-			VictimStateOfCurrentResidence:    input.VictimStateOfCurrentResidence,
-			CategorizationOfSexualMisconduct: strings.Join(input.CategorizationOfSexualMisconduct[:], ","),
-			IndustryOfPerpetrator:            input.IndustryOfPerpetrator,
-		},
-	}
-}
-
 func addCallistoClient(client *client.CallistoClient) {
 	name := strconv.Itoa(len(callistoClients) + 1)
 	callistoClients[name] = client
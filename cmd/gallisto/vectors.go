@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ymarcus93/gallisto/vectors"
+)
+
+const defaultVectorsPath = "vectors/testdata/vectors.json"
+
+const vectorsDescription = "Gallisto protocol test vectors. Every random value is given as an input; " +
+	"see the vectors package documentation for the order in which a client consumes them."
+
+const vectorsUsage = `usage: gallisto vectors <command> [flags]
+
+commands:
+  generate  generate a new set of test vectors
+  verify    check this implementation, or another implementation's output, against test vectors`
+
+// runVectorsCommand runs `gallisto vectors ...`
+func runVectorsCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New(vectorsUsage)
+	}
+	switch args[0] {
+	case "generate":
+		return generateVectors(args[1:])
+	case "verify":
+		return verifyVectors(args[1:])
+	default:
+		return fmt.Errorf("unknown vectors command %q\n%v", args[0], vectorsUsage)
+	}
+}
+
+func generateVectors(args []string) error {
+	flags := flag.NewFlagSet("vectors generate", flag.ExitOnError)
+	output := flags.String("o", defaultVectorsPath, "path to write the test vectors to")
+	flags.Parse(args)
+
+	file, err := vectors.Generate(vectorsDescription, vectors.DefaultSpecs())
+	if err != nil {
+		return err
+	}
+	if err := vectors.Save(*output, file); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %v test vectors to %v\n", len(file.Vectors), *output)
+	return nil
+}
+
+func verifyVectors(args []string) error {
+	flags := flag.NewFlagSet("vectors verify", flag.ExitOnError)
+	path := flags.String("vectors", defaultVectorsPath, "path to the test vectors")
+	implOutput := flags.String("output", "", "path to another implementation's output, in the test vector format (default: verify this implementation)")
+	flags.Parse(args)
+
+	file, err := vectors.Load(*path)
+	if err != nil {
+		return err
+	}
+
+	var mismatches []vectors.Mismatch
+	if *implOutput == "" {
+		mismatches, err = vectors.Verify(file)
+		if err != nil {
+			return err
+		}
+	} else {
+		actual, err := vectors.Load(*implOutput)
+		if err != nil {
+			return err
+		}
+		mismatches = vectors.CompareVectors(file.Vectors, actual.Vectors)
+	}
+
+	for _, m := range mismatches {
+		fmt.Fprintln(os.Stderr, m)
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%v mismatches against %v test vectors", len(mismatches), len(file.Vectors))
+	}
+	fmt.Printf("all %v test vectors verified\n", len(file.Vectors))
+	return nil
+}
@@ -1,13 +1,24 @@
-package encryption
+// Package crypto contains the symmetric and asymmetric encryption primitives
+// used by Callisto clients and LOCs, along with the serialization of their keys
+// and ciphertexts.
+package crypto
 
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
+	"errors"
 	"fmt"
+	"io"
 
+	"github.com/ymarcus93/gallisto/internal/detrand"
 	"github.com/ymarcus93/gallisto/internal/util"
 )
 
+// ErrAEADOpen is returned when an AES-GCM ciphertext fails to authenticate,
+// i.e. it was decrypted with the wrong key or has been tampered with
+var ErrAEADOpen = errors.New("failed to authenticate ciphertext")
+
 // GCMCiphertext holds the three components of an AES-GCM ciphertext
 type GCMCiphertext struct {
 	Nonce          []byte
@@ -32,13 +43,22 @@ func (c GCMCiphertext) IsValid() error {
 
 // EncryptAES encrypts the given plaintext using the provided key
 func EncryptAES(key, plaintext, associatedData []byte) (GCMCiphertext, error) {
+	return EncryptAESWithRand(rand.Reader, key, plaintext, associatedData)
+}
+
+// EncryptAESWithRand is like EncryptAES but draws the nonce from random.
+// Outside of tests, random must be crypto/rand's reader.
+func EncryptAESWithRand(random io.Reader, key, plaintext, associatedData []byte) (GCMCiphertext, error) {
+	if err := detrand.Check(random); err != nil {
+		return GCMCiphertext{}, fmt.Errorf("invalid source of randomness: %w", err)
+	}
 	aesGCM, err := initAESGCM(key)
 	if err != nil {
 		return GCMCiphertext{}, err
 	}
 
 	// AES GCM uses nonces of 12 bytes
-	nonce, err := util.GenerateRandomBytes(12)
+	nonce, err := util.GenerateRandomBytesFrom(random, 12)
 	if err != nil {
 		return GCMCiphertext{}, err
 	}
@@ -48,14 +68,33 @@ func EncryptAES(key, plaintext, associatedData []byte) (GCMCiphertext, error) {
 
 // DecryptAES decrypts a GCMCiphertext using the provided key
 func DecryptAES(key []byte, gcmCiphertext GCMCiphertext) ([]byte, error) {
+	aesGCM, err := NewAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return aesGCM.Decrypt(gcmCiphertext)
+}
+
+// AESGCM decrypts GCMCiphertexts under one key. Unlike DecryptAES, it sets up
+// the cipher only once, so it is cheaper for decrypting several ciphertexts.
+type AESGCM struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCM returns an AESGCM for the provided key
+func NewAESGCM(key []byte) (*AESGCM, error) {
 	aesGCM, err := initAESGCM(key)
 	if err != nil {
 		return nil, err
 	}
+	return &AESGCM{aead: aesGCM}, nil
+}
 
-	plaintext, err := aesGCM.Open(nil, gcmCiphertext.Nonce, gcmCiphertext.Ciphertext, gcmCiphertext.AssociatedData)
+// Decrypt decrypts a GCMCiphertext
+func (a *AESGCM) Decrypt(gcmCiphertext GCMCiphertext) ([]byte, error) {
+	plaintext, err := a.aead.Open(nil, gcmCiphertext.Nonce, gcmCiphertext.Ciphertext, gcmCiphertext.AssociatedData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt ciphertext: %v", err)
+		return nil, fmt.Errorf("%w: %v", ErrAEADOpen, err)
 	}
 
 	return plaintext, nil
@@ -64,12 +103,12 @@ func DecryptAES(key []byte, gcmCiphertext GCMCiphertext) ([]byte, error) {
 func initAESGCM(key []byte) (cipher.AEAD, error) {
 	aesBlockCipher, err := aes.NewCipher(key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to instantiate AES block cipher: %v", err)
+		return nil, fmt.Errorf("failed to instantiate AES block cipher: %w", err)
 	}
 
 	aesGCM, err := cipher.NewGCM(aesBlockCipher)
 	if err != nil {
-		return nil, fmt.Errorf("failed to instantiate AES-GCM: %v", err)
+		return nil, fmt.Errorf("failed to instantiate AES-GCM: %w", err)
 	}
 	return aesGCM, nil
 }
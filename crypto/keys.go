@@ -0,0 +1,87 @@
+package crypto
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+)
+
+const (
+	pemTypePublicKey  = "PUBLIC KEY"
+	pemTypePrivateKey = "PRIVATE KEY"
+)
+
+// MarshalRSAPublicKey returns the PEM encoding of a PKIX (SubjectPublicKeyInfo)
+// RSA public key. This is the format in which LOC and DLOC public keys are
+// distributed to clients.
+func MarshalRSAPublicKey(publicKey *rsa.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal RSA public key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemTypePublicKey, Bytes: der}), nil
+}
+
+// ParseRSAPublicKey parses a PEM encoded PKIX RSA public key
+func ParseRSAPublicKey(pemBytes []byte) (*rsa.PublicKey, error) {
+	der, err := decodePEM(pemBytes, pemTypePublicKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	publicKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key is a %T, not an RSA public key", key)
+	}
+	return publicKey, nil
+}
+
+// MarshalRSAPrivateKey returns the PEM encoding of a PKCS #8 RSA private key
+func MarshalRSAPrivateKey(privateKey *rsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal RSA private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemTypePrivateKey, Bytes: der}), nil
+}
+
+// ParseRSAPrivateKey parses a PEM encoded PKCS #8 RSA private key
+func ParseRSAPrivateKey(pemBytes []byte) (*rsa.PrivateKey, error) {
+	der, err := decodePEM(pemBytes, pemTypePrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	privateKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is a %T, not an RSA private key", key)
+	}
+	return privateKey, nil
+}
+
+// ParseRSAKeyPair parses a PEM encoded PKCS #8 RSA private key into a key pair
+func ParseRSAKeyPair(pemBytes []byte) (RSAKeyPair, error) {
+	privateKey, err := ParseRSAPrivateKey(pemBytes)
+	if err != nil {
+		return RSAKeyPair{}, err
+	}
+	return RSAKeyPair{PrivateKey: privateKey, PublicKey: &privateKey.PublicKey}, nil
+}
+
+func decodePEM(pemBytes []byte, expectedType string) ([]byte, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+	if block.Type != expectedType {
+		return nil, fmt.Errorf("unexpected PEM block type %q, expected %q", block.Type, expectedType)
+	}
+	return block.Bytes, nil
+}
@@ -0,0 +1,36 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRSAKeySerialization(t *testing.T) {
+	keyPair, err := GenerateRSAKeyPair()
+	if err != nil {
+		t.Fatalf("failed to generate RSA key pair: %v", err)
+	}
+
+	publicPEM, err := MarshalRSAPublicKey(keyPair.PublicKey)
+	assert.Nil(t, err)
+	privatePEM, err := MarshalRSAPrivateKey(keyPair.PrivateKey)
+	assert.Nil(t, err)
+
+	publicKey, err := ParseRSAPublicKey(publicPEM)
+	assert.Nil(t, err)
+	assert.Equal(t, keyPair.PublicKey, publicKey)
+
+	parsedKeyPair, err := ParseRSAKeyPair(privatePEM)
+	assert.Nil(t, err)
+	assert.Equal(t, keyPair.PrivateKey.D, parsedKeyPair.PrivateKey.D)
+	assert.Equal(t, keyPair.PublicKey, parsedKeyPair.PublicKey)
+
+	// A public key cannot be parsed as a private key and vice versa
+	_, err = ParseRSAPrivateKey(publicPEM)
+	assert.NotNil(t, err)
+	_, err = ParseRSAPublicKey(privatePEM)
+	assert.NotNil(t, err)
+	_, err = ParseRSAPublicKey([]byte("not a pem"))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,75 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ymarcus93/gallisto/internal/detrand"
+)
+
+// ErrRSADecrypt is returned when an RSA-OAEP ciphertext cannot be decrypted,
+// e.g. because it was encrypted to a different key
+var ErrRSADecrypt = errors.New("failed to decrypt RSA ciphertext")
+
+// RSAKeyPair holds a LOC or DLOC key pair
+type RSAKeyPair struct {
+	PrivateKey *rsa.PrivateKey
+	PublicKey  *rsa.PublicKey
+}
+
+// EncryptRSA encrypts the given message using the provided RSA public key
+func EncryptRSA(msg []byte, publicKey *rsa.PublicKey) ([]byte, error) {
+	return EncryptRSAWithRand(rand.Reader, msg, publicKey)
+}
+
+// EncryptRSAWithRand is like EncryptRSA but draws the OAEP seed from random.
+// Outside of tests, random must be crypto/rand's reader.
+func EncryptRSAWithRand(random io.Reader, msg []byte, publicKey *rsa.PublicKey) ([]byte, error) {
+	if err := detrand.Check(random); err != nil {
+		return nil, fmt.Errorf("invalid source of randomness: %w", err)
+	}
+	label := []byte("")
+	hash := sha256.New()
+	ciphertext, err := rsa.EncryptOAEP(hash, random, publicKey, msg, label)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt the message: %w", err)
+	}
+
+	return ciphertext, nil
+}
+
+// DecryptRSA decrypts the given ciphertext using the provided RSA private key
+func DecryptRSA(ciphertext []byte, privateKey *rsa.PrivateKey) ([]byte, error) {
+	label := []byte("")
+	hash := sha256.New()
+	plaintext, err := rsa.DecryptOAEP(hash, rand.Reader, privateKey, ciphertext, label)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRSADecrypt, err)
+	}
+
+	return plaintext, nil
+}
+
+// GenerateRSAKeyPair creates a 4096-bit RSA key
+func GenerateRSAKeyPair() (RSAKeyPair, error) {
+	return GenerateRSAKeyPairWithRand(rand.Reader)
+}
+
+// GenerateRSAKeyPairWithRand is like GenerateRSAKeyPair but draws the key
+// material from random. Outside of tests, random must be crypto/rand's reader.
+func GenerateRSAKeyPairWithRand(random io.Reader) (RSAKeyPair, error) {
+	if err := detrand.Check(random); err != nil {
+		return RSAKeyPair{}, fmt.Errorf("invalid source of randomness: %w", err)
+	}
+	privateKey, err := rsa.GenerateKey(random, 4096)
+	if err != nil {
+		return RSAKeyPair{}, fmt.Errorf("failed to generate RSA key: %w", err)
+	}
+	publicKey := &privateKey.PublicKey
+
+	return RSAKeyPair{PrivateKey: privateKey, PublicKey: publicKey}, nil
+}
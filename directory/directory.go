@@ -0,0 +1,343 @@
+// Package directory publishes the keys Callisto clients encrypt to: the LOC
+// and DLOC public keys, the OPRF public key and the protocol suite, with the
+// period they are valid for.
+//
+// The deployment signs a Document with an offline root key, whose public key
+// clients are configured with. A client's Fetcher verifies the document,
+// pins it and refuses documents older than the one it pinned, and the client
+// refuses to encrypt to keys the pinned document does not list, so that an
+// attacker who can hand the client keys cannot read its entries.
+package directory
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ymarcus93/gallisto/crypto"
+	"github.com/ymarcus93/gallisto/oprf"
+	"github.com/ymarcus93/gallisto/suite"
+)
+
+var (
+	// ErrInvalidDirectory is returned when a key directory is not signed by
+	// the root key, cannot be decoded or is not valid at the current time
+	ErrInvalidDirectory = errors.New("invalid key directory")
+	// ErrRollback is returned when a fetched key directory is older than the
+	// pinned one
+	ErrRollback = errors.New("key directory rolled back")
+	// ErrUnknownKey is returned when a key is not listed in the pinned key
+	// directory
+	ErrUnknownKey = errors.New("key not in the key directory")
+)
+
+// Document lists the keys clients encrypt to. Publish a new document with a
+// higher serial number to rotate keys.
+type Document struct {
+	Serial        uint64    `json:"serial"`
+	Suite         suite.ID  `json:"suite"`
+	LOCPublicKey  string    `json:"locPublicKey"`  // PEM encoded
+	DLOCPublicKey string    `json:"dlocPublicKey"` // PEM encoded
+	OPRFPublicKey []byte    `json:"oprfPublicKey"` // Serialized for the suite's OPRF ciphersuite
+	NotBefore     time.Time `json:"notBefore"`
+	NotAfter      time.Time `json:"notAfter"`
+}
+
+// NewDocument returns a document listing the keys of a deployment using the
+// protocol suite id, valid from notBefore until notAfter
+func NewDocument(serial uint64, id suite.ID, locPublicKey, dlocPublicKey *rsa.PublicKey, oprfPublicKey oprf.PublicKey, notBefore, notAfter time.Time) (Document, error) {
+	locPEM, err := crypto.MarshalRSAPublicKey(locPublicKey)
+	if err != nil {
+		return Document{}, fmt.Errorf("failed to encode LOC public key: %w", err)
+	}
+	dlocPEM, err := crypto.MarshalRSAPublicKey(dlocPublicKey)
+	if err != nil {
+		return Document{}, fmt.Errorf("failed to encode DLOC public key: %w", err)
+	}
+	oprfKey, err := oprfPublicKey.MarshalBinary()
+	if err != nil {
+		return Document{}, fmt.Errorf("failed to encode OPRF public key: %w", err)
+	}
+	doc := Document{
+		Serial:        serial,
+		Suite:         id,
+		LOCPublicKey:  string(locPEM),
+		DLOCPublicKey: string(dlocPEM),
+		OPRFPublicKey: oprfKey,
+		NotBefore:     notBefore.UTC(),
+		NotAfter:      notAfter.UTC(),
+	}
+	if err := doc.validate(); err != nil {
+		return Document{}, err
+	}
+	return doc, nil
+}
+
+// LOCKeys returns the LOC and DLOC public keys of the document
+func (d Document) LOCKeys() (*rsa.PublicKey, *rsa.PublicKey, error) {
+	locPublicKey, err := crypto.ParseRSAPublicKey([]byte(d.LOCPublicKey))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: LOC public key: %v", ErrInvalidDirectory, err)
+	}
+	dlocPublicKey, err := crypto.ParseRSAPublicKey([]byte(d.DLOCPublicKey))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: DLOC public key: %v", ErrInvalidDirectory, err)
+	}
+	return locPublicKey, dlocPublicKey, nil
+}
+
+// OPRFKey returns the OPRF public key of the document
+func (d Document) OPRFKey() (oprf.PublicKey, error) {
+	s, err := suite.Lookup(d.Suite)
+	if err != nil {
+		return oprf.PublicKey{}, fmt.Errorf("%w: %v", ErrInvalidDirectory, err)
+	}
+	key, err := oprf.PublicKeyFromBytes(s.OPRFCiphersuite, d.OPRFPublicKey)
+	if err != nil {
+		return oprf.PublicKey{}, fmt.Errorf("%w: OPRF public key: %v", ErrInvalidDirectory, err)
+	}
+	return key, nil
+}
+
+// ValidAt reports whether now is within the document's validity period
+func (d Document) ValidAt(now time.Time) bool {
+	return !now.Before(d.NotBefore) && !now.After(d.NotAfter)
+}
+
+// validate checks that every key of the document can be decoded
+func (d Document) validate() error {
+	if !d.NotBefore.Before(d.NotAfter) {
+		return fmt.Errorf("%w: validity period ends before it starts", ErrInvalidDirectory)
+	}
+	if _, _, err := d.LOCKeys(); err != nil {
+		return err
+	}
+	_, err := d.OPRFKey()
+	return err
+}
+
+// SignedDocument is a document as published: its JSON encoding and the root
+// key's signature over it
+type SignedDocument struct {
+	Document  []byte `json:"document"`
+	Signature []byte `json:"signature"`
+}
+
+// signatureLabel separates key directories from other signed messages
+var signatureLabel = []byte("gallisto/directory/v1/document")
+
+func signedContent(document []byte) []byte {
+	return append(append([]byte(nil), signatureLabel...), document...)
+}
+
+// Sign encodes doc and signs it with the root key. Keep the root key offline:
+// whoever holds it decides which keys clients encrypt to.
+func Sign(doc Document, rootKey ed25519.PrivateKey) (SignedDocument, error) {
+	if len(rootKey) != ed25519.PrivateKeySize {
+		return SignedDocument{}, fmt.Errorf("root key must be %v bytes, got %v", ed25519.PrivateKeySize, len(rootKey))
+	}
+	if err := doc.validate(); err != nil {
+		return SignedDocument{}, err
+	}
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return SignedDocument{}, fmt.Errorf("failed to encode key directory: %w", err)
+	}
+	return SignedDocument{Document: encoded, Signature: ed25519.Sign(rootKey, signedContent(encoded))}, nil
+}
+
+// Verify checks that signed is signed by the root key and valid at now, and
+// returns its document. It returns an error wrapping ErrInvalidDirectory if it
+// is not.
+func Verify(signed SignedDocument, rootKey ed25519.PublicKey, now time.Time) (Document, error) {
+	if len(rootKey) != ed25519.PublicKeySize {
+		return Document{}, fmt.Errorf("root key must be %v bytes, got %v", ed25519.PublicKeySize, len(rootKey))
+	}
+	if !ed25519.Verify(rootKey, signedContent(signed.Document), signed.Signature) {
+		return Document{}, fmt.Errorf("%w: signature does not verify", ErrInvalidDirectory)
+	}
+	var doc Document
+	if err := json.Unmarshal(signed.Document, &doc); err != nil {
+		return Document{}, fmt.Errorf("%w: %v", ErrInvalidDirectory, err)
+	}
+	if err := doc.validate(); err != nil {
+		return Document{}, err
+	}
+	if !doc.ValidAt(now) {
+		return Document{}, fmt.Errorf("%w: document %v is only valid from %v until %v", ErrInvalidDirectory, doc.Serial, doc.NotBefore, doc.NotAfter)
+	}
+	return doc, nil
+}
+
+// Source fetches the published key directory, e.g. over HTTPS. The root key
+// signature makes the transport's authenticity irrelevant.
+type Source interface {
+	FetchDirectory(ctx context.Context) (SignedDocument, error)
+}
+
+// SourceFunc adapts a function to a Source
+type SourceFunc func(ctx context.Context) (SignedDocument, error)
+
+// FetchDirectory implements Source
+func (f SourceFunc) FetchDirectory(ctx context.Context) (SignedDocument, error) {
+	return f(ctx)
+}
+
+// Fetcher fetches, verifies and pins a key directory for a client. It is safe
+// for concurrent use.
+type Fetcher struct {
+	rootKey ed25519.PublicKey
+	source  Source
+	now     func() time.Time
+
+	mu     sync.RWMutex // guards pinned
+	pinned *Document
+}
+
+// FetcherOption configures optional Fetcher behaviour
+type FetcherOption func(*Fetcher)
+
+// WithClock makes the fetcher read the current time from now instead of
+// time.Now
+func WithClock(now func() time.Time) FetcherOption {
+	return func(f *Fetcher) {
+		f.now = now
+	}
+}
+
+// WithPinned makes the fetcher start from a document pinned earlier, e.g. one
+// the client stored, so that it never accepts an older one
+func WithPinned(doc Document) FetcherOption {
+	return func(f *Fetcher) {
+		f.pinned = &doc
+	}
+}
+
+// NewFetcher returns a fetcher of the documents source publishes that are
+// signed by rootKey
+func NewFetcher(rootKey ed25519.PublicKey, source Source, opts ...FetcherOption) (*Fetcher, error) {
+	if len(rootKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("root key must be %v bytes, got %v", ed25519.PublicKeySize, len(rootKey))
+	}
+	if source == nil {
+		return nil, fmt.Errorf("source cannot be nil")
+	}
+	f := &Fetcher{rootKey: rootKey, source: source, now: time.Now}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f, nil
+}
+
+// Fetch fetches the current document, verifies it and pins it. A document
+// with a lower serial number than the pinned one returns an error wrapping
+// ErrRollback, and a different document with the same serial number one
+// wrapping ErrInvalidDirectory; the pinned document is kept in both cases.
+func (f *Fetcher) Fetch() (Document, error) {
+	return f.FetchContext(context.Background())
+}
+
+// FetchContext is like Fetch but passes ctx on to the source
+func (f *Fetcher) FetchContext(ctx context.Context) (Document, error) {
+	signed, err := f.source.FetchDirectory(ctx)
+	if err != nil {
+		return Document{}, fmt.Errorf("failed to fetch key directory: %w", err)
+	}
+	doc, err := Verify(signed, f.rootKey, f.now())
+	if err != nil {
+		return Document{}, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.pinned != nil {
+		switch {
+		case doc.Serial < f.pinned.Serial:
+			return Document{}, fmt.Errorf("%w: fetched document %v, but document %v is pinned", ErrRollback, doc.Serial, f.pinned.Serial)
+		case doc.Serial == f.pinned.Serial && !doc.equal(*f.pinned):
+			return Document{}, fmt.Errorf("%w: two different documents have serial number %v", ErrInvalidDirectory, doc.Serial)
+		}
+	}
+	f.pinned = &doc
+	return doc, nil
+}
+
+func (d Document) equal(other Document) bool {
+	return d.Serial == other.Serial && d.Suite == other.Suite &&
+		d.LOCPublicKey == other.LOCPublicKey && d.DLOCPublicKey == other.DLOCPublicKey &&
+		bytes.Equal(d.OPRFPublicKey, other.OPRFPublicKey) &&
+		d.NotBefore.Equal(other.NotBefore) && d.NotAfter.Equal(other.NotAfter)
+}
+
+// Pinned returns the pinned document, if any, e.g. for the client to store
+func (f *Fetcher) Pinned() (Document, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.pinned == nil {
+		return Document{}, false
+	}
+	return *f.pinned, true
+}
+
+// CheckKeys checks that the pinned document is valid and lists the protocol
+// suite id and the LOC and DLOC public keys, so that clients only encrypt to
+// published keys. It returns an error wrapping ErrInvalidDirectory if the
+// pinned document has expired, and one wrapping ErrUnknownKey if no document is
+// pinned or the document lists other keys. client.WithKeyDirectory uses it.
+func (f *Fetcher) CheckKeys(id suite.ID, locPublicKey, dlocPublicKey *rsa.PublicKey) error {
+	doc, err := f.current(id)
+	if err != nil {
+		return err
+	}
+	listedLOC, listedDLOC, err := doc.LOCKeys()
+	if err != nil {
+		return err
+	}
+	if locPublicKey == nil || !listedLOC.Equal(locPublicKey) {
+		return fmt.Errorf("%w: LOC public key", ErrUnknownKey)
+	}
+	if dlocPublicKey == nil || !listedDLOC.Equal(dlocPublicKey) {
+		return fmt.Errorf("%w: DLOC public key", ErrUnknownKey)
+	}
+	return nil
+}
+
+// CheckOPRFKey is like CheckKeys but checks the OPRF public key that p-hat
+// evaluations are verified against
+func (f *Fetcher) CheckOPRFKey(id suite.ID, key oprf.PublicKey) error {
+	doc, err := f.current(id)
+	if err != nil {
+		return err
+	}
+	listed, err := doc.OPRFKey()
+	if err != nil {
+		return err
+	}
+	encoded, err := key.MarshalBinary()
+	if err != nil || key.Ciphersuite != listed.Ciphersuite || !bytes.Equal(encoded, doc.OPRFPublicKey) {
+		return fmt.Errorf("%w: OPRF public key", ErrUnknownKey)
+	}
+	return nil
+}
+
+// current returns the pinned document if it is valid and lists keys for suite
+// id
+func (f *Fetcher) current(id suite.ID) (Document, error) {
+	doc, ok := f.Pinned()
+	if !ok {
+		return Document{}, fmt.Errorf("%w: no key directory is pinned", ErrUnknownKey)
+	}
+	if !doc.ValidAt(f.now()) {
+		return Document{}, fmt.Errorf("%w: pinned document %v expired at %v", ErrInvalidDirectory, doc.Serial, doc.NotAfter)
+	}
+	if doc.Suite != id {
+		return Document{}, fmt.Errorf("%w: directory lists keys for suite %v, not %v", ErrUnknownKey, doc.Suite, id)
+	}
+	return doc, nil
+}
@@ -0,0 +1,235 @@
+package directory
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ymarcus93/gallisto/crypto"
+	"github.com/ymarcus93/gallisto/oprf"
+	"github.com/ymarcus93/gallisto/suite"
+)
+
+var now = time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+type testKeys struct {
+	loc, dloc crypto.RSAKeyPair
+	oprf      oprf.PublicKey
+}
+
+// Key sets are shared between tests, as RSA key generation is slow
+var keySets = map[string]testKeys{}
+
+func keySet(t *testing.T, name string) testKeys {
+	if keys, ok := keySets[name]; ok {
+		return keys
+	}
+	keys := generateKeys(t)
+	keySets[name] = keys
+	return keys
+}
+
+func generateKeys(t *testing.T) testKeys {
+	loc, err := crypto.GenerateRSAKeyPair()
+	if err != nil {
+		t.Fatalf("failed to generate LOC keys: %v", err)
+	}
+	dloc, err := crypto.GenerateRSAKeyPair()
+	if err != nil {
+		t.Fatalf("failed to generate DLOC keys: %v", err)
+	}
+	s, err := suite.Lookup(suite.Default)
+	if err != nil {
+		t.Fatalf("failed to look up suite: %v", err)
+	}
+	oprfKey, err := oprf.GenerateKey(s.OPRFCiphersuite)
+	if err != nil {
+		t.Fatalf("failed to generate OPRF key: %v", err)
+	}
+	return testKeys{loc: loc, dloc: dloc, oprf: oprfKey.PublicKey()}
+}
+
+func newDocument(t *testing.T, serial uint64, keys testKeys) Document {
+	doc, err := NewDocument(serial, suite.Default, keys.loc.PublicKey, keys.dloc.PublicKey, keys.oprf, now.Add(-time.Hour), now.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+	return doc
+}
+
+func generateRootKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("failed to generate root key: %v", err)
+	}
+	return publicKey, privateKey
+}
+
+func sign(t *testing.T, doc Document, rootKey ed25519.PrivateKey) SignedDocument {
+	signed, err := Sign(doc, rootKey)
+	if err != nil {
+		t.Fatalf("failed to sign document: %v", err)
+	}
+	return signed
+}
+
+func TestVerify(t *testing.T) {
+	keys := keySet(t, "current")
+	rootPublicKey, rootKey := generateRootKey(t)
+	otherRootPublicKey, _ := generateRootKey(t)
+	doc := newDocument(t, 1, keys)
+	signed := sign(t, doc, rootKey)
+
+	verified, err := Verify(signed, rootPublicKey, now)
+	assert.Nil(t, err)
+	assert.True(t, verified.equal(doc))
+	locKey, dlocKey, err := verified.LOCKeys()
+	assert.Nil(t, err)
+	assert.True(t, keys.loc.PublicKey.Equal(locKey))
+	assert.True(t, keys.dloc.PublicKey.Equal(dlocKey))
+	oprfKey, err := verified.OPRFKey()
+	assert.Nil(t, err)
+	assert.Equal(t, keys.oprf, oprfKey)
+
+	swapped := doc
+	swapped.LOCPublicKey = doc.DLOCPublicKey
+	tampered := signed
+	tampered.Document = sign(t, swapped, rootKey).Document
+
+	tests := map[string]struct {
+		signed  SignedDocument
+		rootKey ed25519.PublicKey
+		now     time.Time
+	}{
+		"tampered": {
+			signed:  tampered,
+			rootKey: rootPublicKey,
+			now:     now,
+		},
+		"other root key": {
+			signed:  signed,
+			rootKey: otherRootPublicKey,
+			now:     now,
+		},
+		"expired": {
+			signed:  signed,
+			rootKey: rootPublicKey,
+			now:     now.Add(48 * time.Hour),
+		},
+		"not yet valid": {
+			signed:  signed,
+			rootKey: rootPublicKey,
+			now:     now.Add(-48 * time.Hour),
+		},
+	}
+
+	for testName, tc := range tests {
+		t.Run(testName, func(t *testing.T) {
+			_, err := Verify(tc.signed, tc.rootKey, tc.now)
+			assert.True(t, errors.Is(err, ErrInvalidDirectory), err)
+		})
+	}
+
+	// Documents with keys that cannot be decoded are not signed
+	invalid := doc
+	invalid.LOCPublicKey = "not a key"
+	_, err = Sign(invalid, rootKey)
+	assert.True(t, errors.Is(err, ErrInvalidDirectory))
+}
+
+func TestFetcher(t *testing.T) {
+	keys := keySet(t, "current")
+	rootPublicKey, rootKey := generateRootKey(t)
+	first := sign(t, newDocument(t, 1, keys), rootKey)
+	rotatedKeys := keySet(t, "other")
+	rotated := sign(t, newDocument(t, 2, rotatedKeys), rootKey)
+	conflicting := sign(t, newDocument(t, 2, keys), rootKey)
+
+	published := first
+	source := SourceFunc(func(context.Context) (SignedDocument, error) { return published, nil })
+	fetcher, err := NewFetcher(rootPublicKey, source, WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatalf("failed to create fetcher: %v", err)
+	}
+	_, ok := fetcher.Pinned()
+	assert.False(t, ok)
+
+	doc, err := fetcher.Fetch()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), doc.Serial)
+
+	// Keys are rotated by publishing a newer document
+	published = rotated
+	doc, err = fetcher.Fetch()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), doc.Serial)
+
+	// Older and conflicting documents are refused, and the pin is kept
+	published = first
+	_, err = fetcher.Fetch()
+	assert.True(t, errors.Is(err, ErrRollback), err)
+	published = conflicting
+	_, err = fetcher.Fetch()
+	assert.True(t, errors.Is(err, ErrInvalidDirectory), err)
+	pinned, ok := fetcher.Pinned()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2), pinned.Serial)
+	assert.Nil(t, fetcher.CheckKeys(suite.Default, rotatedKeys.loc.PublicKey, rotatedKeys.dloc.PublicKey))
+
+	// A pin survives restarts
+	restarted, err := NewFetcher(rootPublicKey, source, WithClock(func() time.Time { return now }), WithPinned(pinned))
+	if err != nil {
+		t.Fatalf("failed to create fetcher: %v", err)
+	}
+	published = first
+	_, err = restarted.Fetch()
+	assert.True(t, errors.Is(err, ErrRollback), err)
+
+	_, err = NewFetcher(rootPublicKey, nil)
+	assert.NotNil(t, err)
+}
+
+func TestFetcher_CheckKeys(t *testing.T) {
+	keys := keySet(t, "current")
+	otherKeys := keySet(t, "other")
+	rootPublicKey, rootKey := generateRootKey(t)
+	signed := sign(t, newDocument(t, 1, keys), rootKey)
+	clock := now
+	fetcher, err := NewFetcher(rootPublicKey, SourceFunc(func(context.Context) (SignedDocument, error) { return signed, nil }),
+		WithClock(func() time.Time { return clock }))
+	if err != nil {
+		t.Fatalf("failed to create fetcher: %v", err)
+	}
+
+	err = fetcher.CheckKeys(suite.Default, keys.loc.PublicKey, keys.dloc.PublicKey)
+	assert.True(t, errors.Is(err, ErrUnknownKey), err)
+	if _, err := fetcher.Fetch(); err != nil {
+		t.Fatalf("failed to fetch directory: %v", err)
+	}
+	assert.Nil(t, fetcher.CheckKeys(suite.Default, keys.loc.PublicKey, keys.dloc.PublicKey))
+	assert.Nil(t, fetcher.CheckOPRFKey(suite.Default, keys.oprf))
+
+	for name, err := range map[string]error{
+		"other LOC key":  fetcher.CheckKeys(suite.Default, otherKeys.loc.PublicKey, keys.dloc.PublicKey),
+		"other DLOC key": fetcher.CheckKeys(suite.Default, keys.loc.PublicKey, otherKeys.dloc.PublicKey),
+		"swapped keys":   fetcher.CheckKeys(suite.Default, keys.dloc.PublicKey, keys.loc.PublicKey),
+		"missing key":    fetcher.CheckKeys(suite.Default, keys.loc.PublicKey, nil),
+		"other suite":    fetcher.CheckKeys(suite.V2P256, keys.loc.PublicKey, keys.dloc.PublicKey),
+		"other OPRF key": fetcher.CheckOPRFKey(suite.Default, otherKeys.oprf),
+		"no OPRF key":    fetcher.CheckOPRFKey(suite.Default, oprf.PublicKey{}),
+		"OPRF suite":     fetcher.CheckOPRFKey(suite.V2P256, keys.oprf),
+	} {
+		assert.True(t, errors.Is(err, ErrUnknownKey), name)
+	}
+
+	// An expired pin must be replaced
+	clock = now.Add(48 * time.Hour)
+	err = fetcher.CheckKeys(suite.Default, keys.loc.PublicKey, keys.dloc.PublicKey)
+	assert.True(t, errors.Is(err, ErrInvalidDirectory), err)
+	err = fetcher.CheckOPRFKey(suite.Default, keys.oprf)
+	assert.True(t, errors.Is(err, ErrInvalidDirectory), err)
+}
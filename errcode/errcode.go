@@ -0,0 +1,174 @@
+// Package errcode maps the errors returned by the library to stable codes
+// that can be shown to users, logged or returned by a network API without
+// depending on error message text.
+package errcode
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/ymarcus93/gallisto/audit"
+	"github.com/ymarcus93/gallisto/canonical"
+	"github.com/ymarcus93/gallisto/crypto"
+	"github.com/ymarcus93/gallisto/directory"
+	"github.com/ymarcus93/gallisto/identity"
+	"github.com/ymarcus93/gallisto/oprf"
+	"github.com/ymarcus93/gallisto/protocol"
+	"github.com/ymarcus93/gallisto/protocol/client"
+	"github.com/ymarcus93/gallisto/protocol/server"
+	"github.com/ymarcus93/gallisto/suite"
+	"github.com/ymarcus93/gallisto/timestamp"
+	"github.com/ymarcus93/gallisto/token"
+	"github.com/ymarcus93/gallisto/transparency"
+	"github.com/ymarcus93/gallisto/types"
+)
+
+// Code is a stable identifier for a class of errors. Codes are never renamed
+// once released.
+type Code string
+
+const (
+	OK                 Code = "ok"
+	Internal           Code = "internal" // Default case
+	InvalidInput       Code = "invalid_input"
+	InvalidTuple       Code = "invalid_tuple"
+	Decode             Code = "decode_failed"
+	UnsupportedVersion Code = "unsupported_version"
+	AEADOpen           Code = "aead_open_failed"
+	LOCDecrypt         Code = "loc_decrypt_failed"
+	LOCTypeMismatch    Code = "loc_type_mismatch"
+	SuiteMismatch      Code = "suite_mismatch"
+	BindingMismatch    Code = "binding_mismatch"
+	LengthMismatch     Code = "length_mismatch"
+	Interpolation      Code = "interpolation_failed"
+	OPRFEvaluation     Code = "oprf_evaluation_failed"
+	OPRFMetadataDenied Code = "oprf_metadata_denied"
+	TokenRejected      Code = "token_rejected"
+	TokenSpent         Code = "token_spent"
+	TokensExhausted    Code = "tokens_exhausted"
+	IssuanceLimited    Code = "token_issuance_limited"
+	UncertifiedUser    Code = "uncertified_user"
+	InvalidSignature   Code = "invalid_signature"
+	KeyConflict        Code = "signing_key_conflict"
+	AuditLogInvalid    Code = "audit_log_invalid"
+	InvalidProof       Code = "invalid_transparency_proof"
+	InvalidTimestamp   Code = "invalid_timestamp"
+	RecordMismatch     Code = "record_mismatch"
+	InvalidDirectory   Code = "invalid_key_directory"
+	UnknownKey         Code = "unknown_key"
+	TupleNotFound      Code = "tuple_not_found"
+	NoMatch            Code = "no_match"
+	Canceled           Code = "canceled"
+	DeadlineExceeded   Code = "deadline_exceeded"
+)
+
+// sentinelCodes is checked in order, so more specific errors come first
+var sentinelCodes = []struct {
+	err  error
+	code Code
+}{
+	{context.DeadlineExceeded, DeadlineExceeded},
+	{context.Canceled, Canceled},
+	{canonical.ErrInvalidIdentifier, InvalidInput},
+	{identity.ErrUncertified, UncertifiedUser},
+	{identity.ErrInvalidKeyProof, InvalidSignature},
+	{types.ErrInvalidSignature, InvalidSignature},
+	{server.ErrUnknownSigner, InvalidSignature},
+	{server.ErrKeyConflict, KeyConflict},
+	{audit.ErrChainBroken, AuditLogInvalid},
+	{audit.ErrTruncated, AuditLogInvalid},
+	{transparency.ErrInvalidProof, InvalidProof},
+	{transparency.ErrInvalidTreeHead, InvalidProof},
+	{timestamp.ErrInvalidToken, InvalidTimestamp},
+	{client.ErrRecordMismatch, RecordMismatch},
+	{directory.ErrInvalidDirectory, InvalidDirectory},
+	{directory.ErrRollback, InvalidDirectory},
+	{directory.ErrUnknownKey, UnknownKey},
+	{types.ErrInvalidTuple, InvalidTuple},
+	{types.ErrInvalidLOCData, InvalidTuple},
+	{types.ErrUnsupportedVersion, UnsupportedVersion},
+	{suite.ErrUnknownSuite, UnsupportedVersion},
+	{types.ErrDecode, Decode},
+	{crypto.ErrAEADOpen, AEADOpen},
+	{crypto.ErrRSADecrypt, LOCDecrypt},
+	{protocol.ErrLOCTypeMismatch, LOCTypeMismatch},
+	{protocol.ErrSuiteMismatch, SuiteMismatch},
+	{protocol.ErrBindingMismatch, BindingMismatch},
+	{protocol.ErrLengthMismatch, LengthMismatch},
+	{protocol.ErrInterpolation, Interpolation},
+	{protocol.ErrInvalidPi, InvalidTuple},
+	{oprf.ErrMetadataDenied, OPRFMetadataDenied},
+	{token.ErrTokenSpent, TokenSpent},
+	{token.ErrTokenRejected, TokenRejected},
+	{token.ErrNoTokens, TokensExhausted},
+	{token.ErrIssuanceLimit, IssuanceLimited},
+	{oprf.ErrOPRFEvaluation, OPRFEvaluation},
+	{server.ErrTupleNotFound, TupleNotFound},
+	{server.ErrNoMatch, NoMatch},
+}
+
+var httpStatuses = map[Code]int{
+	OK:                 http.StatusOK,
+	InvalidInput:       http.StatusBadRequest,
+	InvalidTuple:       http.StatusBadRequest,
+	LengthMismatch:     http.StatusBadRequest,
+	Decode:             http.StatusUnprocessableEntity,
+	UnsupportedVersion: http.StatusUnprocessableEntity,
+	AEADOpen:           http.StatusUnprocessableEntity,
+	LOCDecrypt:         http.StatusUnprocessableEntity,
+	LOCTypeMismatch:    http.StatusUnprocessableEntity,
+	SuiteMismatch:      http.StatusUnprocessableEntity,
+	BindingMismatch:    http.StatusUnprocessableEntity,
+	Interpolation:      http.StatusUnprocessableEntity,
+	OPRFEvaluation:     http.StatusBadGateway,
+	OPRFMetadataDenied: http.StatusForbidden,
+	TokenRejected:      http.StatusUnauthorized,
+	TokenSpent:         http.StatusUnauthorized,
+	TokensExhausted:    http.StatusTooManyRequests,
+	IssuanceLimited:    http.StatusTooManyRequests,
+	UncertifiedUser:    http.StatusForbidden,
+	InvalidSignature:   http.StatusForbidden,
+	KeyConflict:        http.StatusConflict,
+	AuditLogInvalid:    http.StatusInternalServerError,
+	InvalidProof:       http.StatusUnprocessableEntity,
+	InvalidTimestamp:   http.StatusUnprocessableEntity,
+	RecordMismatch:     http.StatusUnprocessableEntity,
+	InvalidDirectory:   http.StatusUnprocessableEntity,
+	UnknownKey:         http.StatusUnprocessableEntity,
+	TupleNotFound:      http.StatusNotFound,
+	NoMatch:            http.StatusNotFound,
+	Canceled:           http.StatusRequestTimeout,
+	DeadlineExceeded:   http.StatusGatewayTimeout,
+}
+
+// Of returns the code of err. It returns OK for a nil error and Internal for
+// errors the library does not classify.
+func Of(err error) Code {
+	if err == nil {
+		return OK
+	}
+	var validationErrors types.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		return InvalidInput
+	}
+	var fieldError types.FieldError
+	if errors.As(err, &fieldError) {
+		return InvalidInput
+	}
+	for _, s := range sentinelCodes {
+		if errors.Is(err, s.err) {
+			return s.code
+		}
+	}
+	return Internal
+}
+
+// HTTPStatus returns the HTTP status code an API should respond with for the
+// code
+func (c Code) HTTPStatus() int {
+	if status, ok := httpStatuses[c]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,143 @@
+package errcode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ymarcus93/gallisto/audit"
+	"github.com/ymarcus93/gallisto/crypto"
+	"github.com/ymarcus93/gallisto/directory"
+	"github.com/ymarcus93/gallisto/identity"
+	"github.com/ymarcus93/gallisto/oprf"
+	"github.com/ymarcus93/gallisto/protocol"
+	"github.com/ymarcus93/gallisto/protocol/client"
+	"github.com/ymarcus93/gallisto/suite"
+	"github.com/ymarcus93/gallisto/timestamp"
+	"github.com/ymarcus93/gallisto/token"
+	"github.com/ymarcus93/gallisto/transparency"
+	"github.com/ymarcus93/gallisto/types"
+)
+
+func TestOf(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		code Code
+	}{
+		"nil": {
+			err:  nil,
+			code: OK,
+		},
+		"unclassified": {
+			err:  errors.New("boom"),
+			code: Internal,
+		},
+		"validation errors": {
+			err:  fmt.Errorf("invalid entry data: %w", types.ValidationErrors{{Field: "VictimEmail", Reason: "required"}}),
+			code: InvalidInput,
+		},
+		"wrapped AEAD failure": {
+			err:  fmt.Errorf("failed to decrypt entry data %w", &protocol.IndexError{Index: 1, Err: fmt.Errorf("%w: bad tag", crypto.ErrAEADOpen)}),
+			code: AEADOpen,
+		},
+		"unsupported version before decode": {
+			err:  fmt.Errorf("%w: entry data schema version 9", types.ErrUnsupportedVersion),
+			code: UnsupportedVersion,
+		},
+		"loc type mismatch": {
+			err:  fmt.Errorf("failed: %w", protocol.ErrLOCTypeMismatch),
+			code: LOCTypeMismatch,
+		},
+		"unknown suite": {
+			err:  fmt.Errorf("failed: %w", suite.ErrUnknownSuite),
+			code: UnsupportedVersion,
+		},
+		"suite mismatch": {
+			err:  fmt.Errorf("failed to decrypt match %w", &protocol.IndexError{Index: 0, Err: protocol.ErrSuiteMismatch}),
+			code: SuiteMismatch,
+		},
+		"binding mismatch": {
+			err:  fmt.Errorf("failed to decrypt match %w", &protocol.IndexError{Index: 1, Err: protocol.ErrBindingMismatch}),
+			code: BindingMismatch,
+		},
+		"oprf evaluation": {
+			err:  &oprf.EvaluationError{Err: errors.New("connection reset")},
+			code: OPRFEvaluation,
+		},
+		"oprf metadata denied": {
+			err:  &oprf.EvaluationError{Err: fmt.Errorf("%w: caller %q may not request tenant %q", oprf.ErrMetadataDenied, "a", "b")},
+			code: OPRFMetadataDenied,
+		},
+		"uncertified user": {
+			err:  fmt.Errorf("failed to verify user certificate: %w", fmt.Errorf("%w: no certificate", identity.ErrUncertified)),
+			code: UncertifiedUser,
+		},
+		"invalid signature": {
+			err:  fmt.Errorf("%w: signature does not verify", types.ErrInvalidSignature),
+			code: InvalidSignature,
+		},
+		"invalid key proof": {
+			err:  fmt.Errorf("%w: signature does not verify", identity.ErrInvalidKeyProof),
+			code: InvalidSignature,
+		},
+		"audit log truncated": {
+			err:  fmt.Errorf("%w: 3 records, but the checkpoint has 5", audit.ErrTruncated),
+			code: AuditLogInvalid,
+		},
+		"tree head forged": {
+			err:  fmt.Errorf("failed to verify receipt: %w", transparency.ErrInvalidTreeHead),
+			code: InvalidProof,
+		},
+		"timestamp backdated": {
+			err:  fmt.Errorf("%w: signature does not verify", timestamp.ErrInvalidToken),
+			code: InvalidTimestamp,
+		},
+		"record content altered": {
+			err:  fmt.Errorf("entry data: %w: tuple encrypts other content", client.ErrRecordMismatch),
+			code: RecordMismatch,
+		},
+		"key directory rolled back": {
+			err:  fmt.Errorf("%w: fetched document 1, but document 2 is pinned", directory.ErrRollback),
+			code: InvalidDirectory,
+		},
+		"unknown LOC key": {
+			err:  fmt.Errorf("refusing to encrypt to LOC keys: %w: LOC public key", directory.ErrUnknownKey),
+			code: UnknownKey,
+		},
+		"token spent": {
+			err:  &oprf.EvaluationError{Err: token.ErrTokenSpent},
+			code: TokenSpent,
+		},
+		"token rejected": {
+			err:  &oprf.EvaluationError{Err: fmt.Errorf("%w: request has no token", token.ErrTokenRejected)},
+			code: TokenRejected,
+		},
+		"issuance limit": {
+			err:  &oprf.EvaluationError{Err: fmt.Errorf("%w: caller %q has 0 of 3 tokens left", token.ErrIssuanceLimit, "a")},
+			code: IssuanceLimited,
+		},
+		"oprf evaluation timed out": {
+			err:  &oprf.EvaluationError{Err: context.DeadlineExceeded},
+			code: DeadlineExceeded,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, test.code, Of(test.err))
+		})
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	assert.Equal(t, http.StatusBadRequest, InvalidInput.HTTPStatus())
+	assert.Equal(t, http.StatusNotFound, NoMatch.HTTPStatus())
+	assert.Equal(t, http.StatusForbidden, OPRFMetadataDenied.HTTPStatus())
+	assert.Equal(t, http.StatusTooManyRequests, IssuanceLimited.HTTPStatus())
+	assert.Equal(t, http.StatusInternalServerError, Internal.HTTPStatus())
+	assert.Equal(t, http.StatusInternalServerError, Code("unknown").HTTPStatus())
+}
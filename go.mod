@@ -1,16 +1,31 @@
 module github.com/ymarcus93/gallisto
 
-go 1.14
+go 1.19
 
 require (
 	github.com/AlecAivazis/survey/v2 v2.0.7
-	github.com/alxdavids/voprf-poc/go v0.0.0-20200327170552-d7e2c578b2b5
-	github.com/golang/protobuf v1.3.5 // indirect
+	github.com/cloudflare/circl v1.3.7
 	github.com/google/uuid v1.1.1
 	github.com/stretchr/testify v1.5.1
 	github.com/superarius/shamir v0.0.0-20180728155515-87c5b9784915
 	github.com/vmihailenco/msgpack v4.0.4+incompatible
-	golang.org/x/crypto v0.0.0-20200406173513-056763e48d71
-	golang.org/x/net v0.0.0-20200324143707-d3edc9973b7e // indirect
+	golang.org/x/crypto v0.17.0
+	golang.org/x/net v0.10.0
+	golang.org/x/text v0.14.0
+	gopkg.in/yaml.v2 v2.2.4
+)
+
+require (
+	github.com/bwesterb/go-ristretto v1.2.3 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/golang/protobuf v1.3.5 // indirect
+	github.com/kballard/go-shellquote v0.0.0-20180428030007-95032a82bc51 // indirect
+	github.com/kr/pretty v0.1.0 // indirect
+	github.com/mattn/go-colorable v0.1.2 // indirect
+	github.com/mattn/go-isatty v0.0.8 // indirect
+	github.com/mgutz/ansi v0.0.0-20170206155736-9520e82c474b // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/sys v0.15.0 // indirect
 	google.golang.org/appengine v1.6.5 // indirect
+	gopkg.in/check.v1 v1.0.0-20190902080502-41f04d3bba15 // indirect
 )
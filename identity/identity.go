@@ -0,0 +1,289 @@
+// Package identity certifies the user IDs tuples are submitted under. A match
+// needs tuples from distinct users, so a user who could invent any number of
+// user IDs could fabricate a match on their own. An enrollment Authority
+// instead gives every verified subject, such as an institutional email
+// address, a single user ID and signs a Certificate binding it to a holder
+// key the user signs their tuples with. Tuples carry the certificate, and a
+// server with a Verifier rejects tuples without a valid certificate for their
+// user ID or not signed with its holder key, so that certificates copied from
+// stored tuples are useless without the holder's private key.
+package identity
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// ErrUncertified is returned when a user ID has no valid certificate
+var ErrUncertified = errors.New("user ID is not certified")
+
+// ErrInvalidKeyProof is returned when a key proof does not verify
+var ErrInvalidKeyProof = errors.New("invalid proof of key possession")
+
+const (
+	// UserIDSize is the size of a certified user ID, a version 4 UUID
+	UserIDSize = 16
+	// DefaultValidity is how long certificates are valid for unless set with
+	// WithValidity
+	DefaultValidity = 365 * 24 * time.Hour
+)
+
+var (
+	// certificateLabel separates signed certificates from other messages
+	certificateLabel = []byte("gallisto/identity/v1/certificate")
+	// userIDLabel derives the key that maps subjects to user IDs
+	userIDLabel = []byte("gallisto/identity/v1/user-id")
+	// keyProofLabel separates key proofs from other messages
+	keyProofLabel = []byte("gallisto/identity/v1/key-proof")
+)
+
+// certificateSize is the size of a serialized certificate
+const certificateSize = UserIDSize + ed25519.PublicKeySize + 8 + ed25519.SignatureSize
+
+// Certificate is an authority's signature over a user ID, the key its holder
+// signs tuples with and its expiry
+type Certificate struct {
+	UserID    []byte
+	HolderKey ed25519.PublicKey
+	Expires   time.Time // Truncated to seconds
+	Signature []byte
+}
+
+// signedMessage returns the message an authority signs for a certificate
+func signedMessage(userID []byte, holderKey ed25519.PublicKey, expires time.Time) []byte {
+	msg := make([]byte, 0, len(certificateLabel)+1+len(userID)+len(holderKey)+8)
+	msg = append(msg, certificateLabel...)
+	msg = append(msg, byte(len(userID)))
+	msg = append(msg, userID...)
+	msg = append(msg, holderKey...)
+	return binary.BigEndian.AppendUint64(msg, uint64(expires.Unix()))
+}
+
+// MarshalBinary returns the user ID, the holder key, the expiry as 8-byte
+// big-endian Unix seconds and the signature of c concatenated
+func (c Certificate) MarshalBinary() ([]byte, error) {
+	if len(c.UserID) != UserIDSize {
+		return nil, fmt.Errorf("user ID must be %v bytes, got %v", UserIDSize, len(c.UserID))
+	}
+	if len(c.HolderKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("holder key must be %v bytes, got %v", ed25519.PublicKeySize, len(c.HolderKey))
+	}
+	if len(c.Signature) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("signature must be %v bytes, got %v", ed25519.SignatureSize, len(c.Signature))
+	}
+	b := make([]byte, 0, certificateSize)
+	b = append(b, c.UserID...)
+	b = append(b, c.HolderKey...)
+	b = binary.BigEndian.AppendUint64(b, uint64(c.Expires.Unix()))
+	return append(b, c.Signature...), nil
+}
+
+// ParseCertificate decodes a certificate serialized by
+// Certificate.MarshalBinary. It does not verify the certificate.
+func ParseCertificate(b []byte) (Certificate, error) {
+	if len(b) != certificateSize {
+		return Certificate{}, fmt.Errorf("certificate must be %v bytes, got %v", certificateSize, len(b))
+	}
+	expiresAt := UserIDSize + ed25519.PublicKeySize
+	return Certificate{
+		UserID:    append([]byte(nil), b[:UserIDSize]...),
+		HolderKey: append(ed25519.PublicKey(nil), b[UserIDSize:expiresAt]...),
+		Expires:   time.Unix(int64(binary.BigEndian.Uint64(b[expiresAt:])), 0),
+		Signature: append([]byte(nil), b[expiresAt+8:]...),
+	}, nil
+}
+
+// Authority enrolls verified subjects. It holds an Ed25519 signing key whose
+// public key servers verify certificates with.
+type Authority struct {
+	signingKey ed25519.PrivateKey
+	userIDKey  []byte
+	validity   time.Duration
+	now        func() time.Time
+}
+
+// AuthorityOption configures optional Authority behaviour
+type AuthorityOption func(*Authority)
+
+// WithValidity sets how long certificates are valid for
+func WithValidity(validity time.Duration) AuthorityOption {
+	return func(a *Authority) {
+		a.validity = validity
+	}
+}
+
+// WithAuthorityClock makes the authority read the current time from now
+// instead of time.Now
+func WithAuthorityClock(now func() time.Time) AuthorityOption {
+	return func(a *Authority) {
+		a.now = now
+	}
+}
+
+// NewAuthority returns an enrollment authority signing with signingKey. User
+// IDs are derived from the key, so an authority restarted with the same key
+// gives every subject the same user ID again.
+func NewAuthority(signingKey ed25519.PrivateKey, opts ...AuthorityOption) (*Authority, error) {
+	if len(signingKey) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("signing key must be %v bytes, got %v", ed25519.PrivateKeySize, len(signingKey))
+	}
+	userIDKey := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, signingKey.Seed(), nil, userIDLabel), userIDKey); err != nil {
+		return nil, fmt.Errorf("failed to derive user ID key: %w", err)
+	}
+	a := &Authority{
+		signingKey: signingKey,
+		userIDKey:  userIDKey,
+		validity:   DefaultValidity,
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if a.validity <= 0 {
+		return nil, fmt.Errorf("invalid certificate validity %v", a.validity)
+	}
+	return a, nil
+}
+
+// PublicKey returns the key certificates are verified with
+func (a *Authority) PublicKey() ed25519.PublicKey {
+	return a.signingKey.Public().(ed25519.PublicKey)
+}
+
+// Enroll certifies the user ID of subject for the holder of holderKey, the
+// Ed25519 key the user signs their tuples with. The caller must have verified
+// that the user controls subject, e.g. with a login to their institution.
+// Enrolling a subject again, say on a new device with a new key, returns a
+// fresh certificate for the same user ID, so a person holds only one user ID
+// per verified subject.
+func (a *Authority) Enroll(subject string, holderKey ed25519.PublicKey) (Certificate, error) {
+	if subject == "" {
+		return Certificate{}, fmt.Errorf("subject cannot be empty")
+	}
+	if len(holderKey) != ed25519.PublicKeySize {
+		return Certificate{}, fmt.Errorf("holder key must be %v bytes, got %v", ed25519.PublicKeySize, len(holderKey))
+	}
+	userID := a.userID(subject)
+	holderKey = append(ed25519.PublicKey(nil), holderKey...)
+	expires := a.now().Add(a.validity).Truncate(time.Second)
+	return Certificate{
+		UserID:    userID,
+		HolderKey: holderKey,
+		Expires:   expires,
+		Signature: ed25519.Sign(a.signingKey, signedMessage(userID, holderKey, expires)),
+	}, nil
+}
+
+// userID returns the user ID of subject: a keyed hash of it, formatted as a
+// version 4 UUID like the IDs of uncertified clients
+func (a *Authority) userID(subject string) []byte {
+	mac := hmac.New(sha256.New, a.userIDKey)
+	mac.Write([]byte(subject))
+	userID := mac.Sum(nil)[:UserIDSize]
+	userID[6] = (userID[6] & 0x0f) | 0x40 // Version 4
+	userID[8] = (userID[8] & 0x3f) | 0x80 // Variant is 10
+	return userID
+}
+
+// Verifier checks certificates against an authority's public key
+type Verifier struct {
+	publicKey ed25519.PublicKey
+	now       func() time.Time
+}
+
+// VerifierOption configures optional Verifier behaviour
+type VerifierOption func(*Verifier)
+
+// WithVerifierClock makes the verifier read the current time from now instead
+// of time.Now
+func WithVerifierClock(now func() time.Time) VerifierOption {
+	return func(v *Verifier) {
+		v.now = now
+	}
+}
+
+// NewVerifier returns a verifier of certificates signed by the authority with
+// publicKey
+func NewVerifier(publicKey ed25519.PublicKey, opts ...VerifierOption) (*Verifier, error) {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be %v bytes, got %v", ed25519.PublicKeySize, len(publicKey))
+	}
+	v := &Verifier{publicKey: publicKey, now: time.Now}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v, nil
+}
+
+// Verify checks that certificate is an unexpired certificate of userID,
+// serialized with Certificate.MarshalBinary, and returns its holder key. The
+// certificate alone proves nothing about who presents it: callers must also
+// check a signature under the holder key, such as the tuple's. Verify returns
+// an error wrapping ErrUncertified if the certificate is invalid.
+func (v *Verifier) Verify(userID, certificate []byte) (ed25519.PublicKey, error) {
+	if len(certificate) == 0 {
+		return nil, fmt.Errorf("%w: no certificate", ErrUncertified)
+	}
+	c, err := ParseCertificate(certificate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUncertified, err)
+	}
+	if !hmac.Equal(c.UserID, userID) {
+		return nil, fmt.Errorf("%w: certificate is for another user ID", ErrUncertified)
+	}
+	if !ed25519.Verify(v.publicKey, signedMessage(c.UserID, c.HolderKey, c.Expires), c.Signature) {
+		return nil, fmt.Errorf("%w: invalid signature", ErrUncertified)
+	}
+	if !v.now().Before(c.Expires) {
+		return nil, fmt.Errorf("%w: certificate expired at %v", ErrUncertified, c.Expires.UTC().Format(time.RFC3339))
+	}
+	return c.HolderKey, nil
+}
+
+// keyProofMessage returns the message a key proof signs
+func keyProofMessage(userID []byte, key ed25519.PublicKey) []byte {
+	msg := make([]byte, 0, len(keyProofLabel)+1+len(userID)+len(key))
+	msg = append(msg, keyProofLabel...)
+	msg = append(msg, byte(len(userID)))
+	msg = append(msg, userID...)
+	return append(msg, key...)
+}
+
+// ProveKey returns a proof that the holder of key claims it as the signing
+// key of userID: the key's signature over a label, the user ID and the public
+// key. Servers require one before registering a signing key, so that nobody
+// registers a key they do not hold.
+func ProveKey(userID []byte, key ed25519.PrivateKey) ([]byte, error) {
+	if len(userID) > 255 {
+		return nil, fmt.Errorf("user ID must be at most 255 bytes, got %v", len(userID))
+	}
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("signing key must be %v bytes, got %v", ed25519.PrivateKeySize, len(key))
+	}
+	return ed25519.Sign(key, keyProofMessage(userID, key.Public().(ed25519.PublicKey))), nil
+}
+
+// VerifyKeyProof checks a proof from ProveKey that the holder of key claims
+// it for userID. It returns an error wrapping ErrInvalidKeyProof if the proof
+// does not verify.
+func VerifyKeyProof(userID []byte, key ed25519.PublicKey, proof []byte) error {
+	if len(userID) > 255 {
+		return fmt.Errorf("%w: user ID must be at most 255 bytes", ErrInvalidKeyProof)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: key must be %v bytes", ErrInvalidKeyProof, ed25519.PublicKeySize)
+	}
+	if !ed25519.Verify(key, keyProofMessage(userID, key), proof) {
+		return fmt.Errorf("%w: signature does not verify", ErrInvalidKeyProof)
+	}
+	return nil
+}
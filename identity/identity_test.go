@@ -0,0 +1,142 @@
+package identity
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createAuthority(t *testing.T, opts ...AuthorityOption) *Authority {
+	_, signingKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("failed to generate signing key: %v", err)
+	}
+	authority, err := NewAuthority(signingKey, opts...)
+	if err != nil {
+		t.Fatalf("failed to create authority: %v", err)
+	}
+	return authority
+}
+
+func generateHolderKey(t *testing.T) ed25519.PublicKey {
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("failed to generate holder key: %v", err)
+	}
+	return publicKey
+}
+
+func TestAuthority_Enroll(t *testing.T) {
+	authority := createAuthority(t)
+	holderKey := generateHolderKey(t)
+	first, err := authority.Enroll("alice@example.edu", holderKey)
+	assert.Nil(t, err)
+	assert.Equal(t, holderKey, first.HolderKey)
+	again, err := authority.Enroll("alice@example.edu", generateHolderKey(t))
+	assert.Nil(t, err)
+	other, err := authority.Enroll("bob@example.edu", holderKey)
+	assert.Nil(t, err)
+
+	assert.Len(t, first.UserID, UserIDSize)
+	assert.Equal(t, byte(0x40), first.UserID[6]&0xf0)
+	assert.Equal(t, first.UserID, again.UserID)
+	assert.NotEqual(t, first.UserID, other.UserID)
+
+	// Another authority key gives unrelated user IDs
+	elsewhere, err := createAuthority(t).Enroll("alice@example.edu", holderKey)
+	assert.Nil(t, err)
+	assert.NotEqual(t, first.UserID, elsewhere.UserID)
+
+	_, err = authority.Enroll("", holderKey)
+	assert.NotNil(t, err)
+	_, err = authority.Enroll("alice@example.edu", nil)
+	assert.NotNil(t, err)
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	authority := createAuthority(t, WithValidity(time.Hour), WithAuthorityClock(func() time.Time { return now }))
+	holderKey := generateHolderKey(t)
+	certificate, err := authority.Enroll("alice@example.edu", holderKey)
+	assert.Nil(t, err)
+	encoded, err := certificate.MarshalBinary()
+	assert.Nil(t, err)
+	parsed, err := ParseCertificate(encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, certificate.UserID, parsed.UserID)
+	assert.Equal(t, certificate.HolderKey, parsed.HolderKey)
+	assert.True(t, certificate.Expires.Equal(parsed.Expires))
+
+	verifier, err := NewVerifier(authority.PublicKey(), WithVerifierClock(func() time.Time { return now }))
+	assert.Nil(t, err)
+	certifiedKey, err := verifier.Verify(certificate.UserID, encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, holderKey, certifiedKey)
+
+	tampered := append([]byte(nil), encoded...)
+	tampered[UserIDSize+ed25519.PublicKeySize+7]++ // Extend the expiry
+	otherHolder := append([]byte(nil), encoded...)
+	copy(otherHolder[UserIDSize:], generateHolderKey(t))
+	otherAuthority, err := createAuthority(t).Enroll("alice@example.edu", holderKey)
+	assert.Nil(t, err)
+	otherEncoded, err := otherAuthority.MarshalBinary()
+	assert.Nil(t, err)
+
+	tests := map[string]struct {
+		userID      []byte
+		certificate []byte
+		now         time.Time
+	}{
+		"no certificate":   {userID: certificate.UserID, now: now},
+		"truncated":        {userID: certificate.UserID, certificate: encoded[1:], now: now},
+		"other user ID":    {userID: make([]byte, UserIDSize), certificate: encoded, now: now},
+		"tampered expiry":  {userID: certificate.UserID, certificate: tampered, now: now},
+		"other holder key": {userID: certificate.UserID, certificate: otherHolder, now: now},
+		"other authority":  {userID: otherAuthority.UserID, certificate: otherEncoded, now: now},
+		"expired":          {userID: certificate.UserID, certificate: encoded, now: now.Add(time.Hour)},
+		"expired long ago": {userID: certificate.UserID, certificate: encoded, now: now.Add(365 * 24 * time.Hour)},
+	}
+	for testName, tc := range tests {
+		t.Run(testName, func(t *testing.T) {
+			verifier, err := NewVerifier(authority.PublicKey(), WithVerifierClock(func() time.Time { return tc.now }))
+			assert.Nil(t, err)
+			_, err = verifier.Verify(tc.userID, tc.certificate)
+			assert.True(t, errors.Is(err, ErrUncertified))
+		})
+	}
+}
+
+func TestVerifyKeyProof(t *testing.T) {
+	publicKey, signingKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("failed to generate signing key: %v", err)
+	}
+	otherKey := generateHolderKey(t)
+	userID := []byte("0123456789abcdef")
+	proof, err := ProveKey(userID, signingKey)
+	if err != nil {
+		t.Fatalf("failed to prove key: %v", err)
+	}
+	assert.Nil(t, VerifyKeyProof(userID, publicKey, proof))
+
+	tests := map[string]struct {
+		userID []byte
+		key    ed25519.PublicKey
+		proof  []byte
+	}{
+		"other user ID": {userID: []byte("fedcba9876543210"), key: publicKey, proof: proof},
+		"other key":     {userID: userID, key: otherKey, proof: proof},
+		"no proof":      {userID: userID, key: publicKey},
+		"short key":     {userID: userID, key: publicKey[:16], proof: proof},
+	}
+
+	for testName, tc := range tests {
+		t.Run(testName, func(t *testing.T) {
+			err := VerifyKeyProof(tc.userID, tc.key, tc.proof)
+			assert.True(t, errors.Is(err, ErrInvalidKeyProof), err)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package detrand
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readN(t *testing.T, r io.Reader, n int) []byte {
+	buffer := make([]byte, n)
+	if _, err := io.ReadFull(r, buffer); err != nil {
+		t.Fatalf("failed to read %v bytes: %v", n, err)
+	}
+	return buffer
+}
+
+func TestNew(t *testing.T) {
+	first, err := New([]byte("seed"))
+	assert.Nil(t, err)
+	second, err := New([]byte("seed"))
+	assert.Nil(t, err)
+	other, err := New([]byte("other seed"))
+	assert.Nil(t, err)
+
+	// Output only depends on the seed, not on how reads are split up
+	firstBytes := append(readN(t, first, 5), readN(t, first, 70)...)
+	assert.Equal(t, firstBytes, readN(t, second, 75))
+	assert.NotEqual(t, firstBytes, readN(t, other, 75))
+}
+
+func TestCheck(t *testing.T) {
+	seeded, err := New([]byte("seed"))
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+	fixed := bytes.NewReader([]byte("fixed"))
+	assert.Nil(t, Check(fixed))
+
+	// Outside of tests, only crypto/rand and allowed readers are accepted
+	defer func(original func() bool) { inTest = original }(inTest)
+	inTest = func() bool { return false }
+	assert.Nil(t, Check(rand.Reader))
+	assert.Nil(t, Check(Allow(fixed)))
+	assert.True(t, errors.Is(Check(fixed), ErrNotTest))
+	assert.True(t, errors.Is(Check(seeded), ErrNotTest))
+	_, err = New([]byte("seed"))
+	assert.True(t, errors.Is(err, ErrNotTest))
+}
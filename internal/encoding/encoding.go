@@ -2,55 +2,146 @@ package encoding
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/vmihailenco/msgpack"
+	"github.com/ymarcus93/gallisto/suite"
 	"github.com/ymarcus93/gallisto/types"
 )
 
+// schemaHeader is used to peek at the schema version of an encoded entry or
+// assignment payload before decoding the rest of it. Version 1 payloads do not
+// contain the field, so it decodes as zero.
+type schemaHeader struct {
+	SchemaVersion uint8
+}
+
+// suiteHeader is used to peek at the protocol suite of an encoded LOC payload
+// before decoding the rest of it. Payloads created before suites were
+// introduced do not contain the field, so it decodes as suite.Legacy.
+type suiteHeader struct {
+	Suite suite.ID
+}
+
+// versionedEntryData is the msgpack layout of entry data from version 2
+// onwards
+type versionedEntryData struct {
+	SchemaVersion uint8
+	Data          types.EntryData
+}
+
+// versionedAssignmentData is the msgpack layout of assignment data from
+// version 2 onwards
+type versionedAssignmentData struct {
+	SchemaVersion uint8
+	Data          types.AssignmentData
+}
+
+// tupleEncodingVersion is the version of the tuple layout written by
+// EncodeCallistoTuple
+const tupleEncodingVersion uint8 = 1
+
+// versionedCallistoTuple is the msgpack layout of a stored tuple
+type versionedCallistoTuple struct {
+	Version uint8
+	Tuple   types.CallistoTupleMsgPack
+}
+
+// entryDataV1 is the unversioned entry data layout
+type entryDataV1 struct {
+	PerpetratorName            string
+	PerpetratorTwitterUserName string
+	VictimName                 string
+	VictimPhoneNumber          string
+	VictimEmail                string
+}
+
+// assignmentDataV1 is the unversioned assignment data layout. Categories were
+// stored as a single comma-joined string.
+type assignmentDataV1 struct {
+	VictimStateOfCurrentResidence    string
+	CategorizationOfSexualMisconduct string
+	IndustryOfPerpetrator            string
+}
+
 // EncodeEntryData returns a msgpack encoding of Callisto entry data
 func EncodeEntryData(entryData types.EntryData) ([]byte, error) {
 	// Create msgpack encoding of entry data
-	entryDataEncodedBytes, err := msgpack.Marshal(&entryData)
+	versioned := versionedEntryData{SchemaVersion: types.SchemaVersion, Data: entryData}
+	entryDataEncodedBytes, err := msgpack.Marshal(&versioned)
 	if err != nil {
-		return nil, fmt.Errorf("failed to encode entry data: %v", err)
+		return nil, fmt.Errorf("failed to encode entry data: %w", err)
 	}
 
 	return entryDataEncodedBytes, nil
 }
 
-// DecodeEntryData decodes msgpack encoding of Callisto entry data
+// DecodeEntryData decodes msgpack encoding of Callisto entry data. Version 1
+// payloads are migrated to the current schema.
 func DecodeEntryData(encodedEntryData []byte) (types.EntryData, error) {
-	// Decode msgpack encoding of entry data
-	var decodedEntryData types.EntryData
-	err := msgpack.Unmarshal(encodedEntryData, &decodedEntryData)
+	version, err := decodeSchemaVersion(encodedEntryData)
 	if err != nil {
-		return types.EntryData{}, fmt.Errorf("failed to decode entry data: %v", err)
+		return types.EntryData{}, fmt.Errorf("failed to decode entry data: %w", err)
 	}
 
-	return decodedEntryData, nil
+	switch version {
+	case types.SchemaVersion1:
+		var decodedEntryData entryDataV1
+		err := msgpack.Unmarshal(encodedEntryData, &decodedEntryData)
+		if err != nil {
+			return types.EntryData{}, fmt.Errorf("%w: version 1 entry data: %v", types.ErrDecode, err)
+		}
+		return migrateEntryDataV1(decodedEntryData), nil
+	case types.SchemaVersion2:
+		var decodedEntryData versionedEntryData
+		err := msgpack.Unmarshal(encodedEntryData, &decodedEntryData)
+		if err != nil {
+			return types.EntryData{}, fmt.Errorf("%w: entry data: %v", types.ErrDecode, err)
+		}
+		return decodedEntryData.Data, nil
+	default:
+		return types.EntryData{}, fmt.Errorf("%w: entry data schema version %v", types.ErrUnsupportedVersion, version)
+	}
 }
 
 // EncodeAssignmentData returns a msgpack encoding of Callisto assignment data
 func EncodeAssignmentData(assignData types.AssignmentData) ([]byte, error) {
 	// Create msgpack encoding of assignment data
-	assignmentDataEncodedBytes, err := msgpack.Marshal(&assignData)
+	versioned := versionedAssignmentData{SchemaVersion: types.SchemaVersion, Data: assignData}
+	assignmentDataEncodedBytes, err := msgpack.Marshal(&versioned)
 	if err != nil {
-		return nil, fmt.Errorf("failed to encode assignment data: %v", err)
+		return nil, fmt.Errorf("failed to encode assignment data: %w", err)
 	}
 
 	return assignmentDataEncodedBytes, nil
 }
 
-// DecodeAssignmentData decodes msgpack encoding of Callisto assignment data
+// DecodeAssignmentData decodes msgpack encoding of Callisto assignment data.
+// Version 1 payloads are migrated to the current schema.
 func DecodeAssignmentData(encodedAssignmentData []byte) (types.AssignmentData, error) {
-	// Decode msgpack encoding of assignment data
-	var decodedAssignmentData types.AssignmentData
-	err := msgpack.Unmarshal(encodedAssignmentData, &decodedAssignmentData)
+	version, err := decodeSchemaVersion(encodedAssignmentData)
 	if err != nil {
-		return types.AssignmentData{}, fmt.Errorf("failed to decode assignment data: %v", err)
+		return types.AssignmentData{}, fmt.Errorf("failed to decode assignment data: %w", err)
 	}
 
-	return decodedAssignmentData, nil
+	switch version {
+	case types.SchemaVersion1:
+		var decodedAssignmentData assignmentDataV1
+		err := msgpack.Unmarshal(encodedAssignmentData, &decodedAssignmentData)
+		if err != nil {
+			return types.AssignmentData{}, fmt.Errorf("%w: version 1 assignment data: %v", types.ErrDecode, err)
+		}
+		return migrateAssignmentDataV1(decodedAssignmentData)
+	case types.SchemaVersion2:
+		var decodedAssignmentData versionedAssignmentData
+		err := msgpack.Unmarshal(encodedAssignmentData, &decodedAssignmentData)
+		if err != nil {
+			return types.AssignmentData{}, fmt.Errorf("%w: assignment data: %v", types.ErrDecode, err)
+		}
+		return decodedAssignmentData.Data, nil
+	default:
+		return types.AssignmentData{}, fmt.Errorf("%w: assignment data schema version %v", types.ErrUnsupportedVersion, version)
+	}
 }
 
 // EncodeLOCData returns a msgpack encoding of LOC data
@@ -59,25 +150,117 @@ func EncodeLOCData(locData types.LOCData) ([]byte, error) {
 	// Create msgpack encoding of LOC data
 	locDataEncodedBytes, err := msgpack.Marshal(&msgPackStruct)
 	if err != nil {
-		return nil, fmt.Errorf("failed to encode %v LOC data: %v", locData.LocType(), err)
+		return nil, fmt.Errorf("failed to encode %v LOC data: %w", locData.LocType(), err)
 	}
 
 	return locDataEncodedBytes, nil
 }
 
-// DecodeLOCData decodes msgpack encoding of LOC data
+// DecodeLOCData decodes msgpack encoding of LOC data. The layout is picked by
+// the protocol suite of the payload.
 func DecodeLOCData(encodedLOCData []byte) (types.LOCData, error) {
-	// Decode msgpack encoding of LOC data
-	var decodedLOCData types.LOCDataMsgPack
-	err := msgpack.Unmarshal(encodedLOCData, &decodedLOCData)
+	var header suiteHeader
+	if err := msgpack.Unmarshal(encodedLOCData, &header); err != nil {
+		return types.LOCData{}, fmt.Errorf("%w: (D)LOC data suite: %v", types.ErrDecode, err)
+	}
+
+	switch header.Suite {
+	case suite.Legacy, suite.V1, suite.V2P256, suite.V2P384, suite.V2P521, suite.V2Ristretto255,
+		suite.V3P256, suite.V3P384, suite.V3P521, suite.V3Ristretto255:
+		// Decode msgpack encoding of LOC data
+		var decodedLOCData types.LOCDataMsgPack
+		err := msgpack.Unmarshal(encodedLOCData, &decodedLOCData)
+		if err != nil {
+			return types.LOCData{}, fmt.Errorf("%w: (D)LOC data: %v", types.ErrDecode, err)
+		}
+
+		locData, err := types.FromLOCDataMsgPack(decodedLOCData)
+		if err != nil {
+			return types.LOCData{}, fmt.Errorf("%w: failed to convert (D)LOC msgpack encoding into LOCData struct: %v", types.ErrDecode, err)
+		}
+		return locData, nil
+	default:
+		return types.LOCData{}, fmt.Errorf("%w: (D)LOC data protocol suite %v", types.ErrUnsupportedVersion, header.Suite)
+	}
+}
+
+// EncodeCallistoTuple returns a msgpack encoding of a Callisto tuple, e.g. for
+// storage
+func EncodeCallistoTuple(tuple types.CallistoTuple) ([]byte, error) {
+	versioned := versionedCallistoTuple{Version: tupleEncodingVersion, Tuple: tuple.ToCallistoTupleMsgPack()}
+	tupleEncodedBytes, err := msgpack.Marshal(&versioned)
 	if err != nil {
-		return types.LOCData{}, fmt.Errorf("failed to decode (D)LOC data: %v", err)
+		return nil, fmt.Errorf("failed to encode tuple: %w", err)
 	}
 
-	locData, err := types.FromLOCDataMsgPack(decodedLOCData)
+	return tupleEncodedBytes, nil
+}
+
+// DecodeCallistoTuple decodes msgpack encoding of a Callisto tuple. Tuples of
+// protocol suites this implementation does not know are rejected.
+func DecodeCallistoTuple(encodedTuple []byte) (types.CallistoTuple, error) {
+	var decodedTuple versionedCallistoTuple
+	err := msgpack.Unmarshal(encodedTuple, &decodedTuple)
 	if err != nil {
-		return types.LOCData{}, fmt.Errorf("failed to convert (D)LOC msgpack encoding into LOCData struct: %v", err)
+		return types.CallistoTuple{}, fmt.Errorf("%w: tuple: %v", types.ErrDecode, err)
+	}
+	if decodedTuple.Version != tupleEncodingVersion {
+		return types.CallistoTuple{}, fmt.Errorf("%w: tuple encoding version %v", types.ErrUnsupportedVersion, decodedTuple.Version)
+	}
+
+	switch decodedTuple.Tuple.Suite {
+	case suite.Legacy, suite.V1, suite.V2P256, suite.V2P384, suite.V2P521, suite.V2Ristretto255,
+		suite.V3P256, suite.V3P384, suite.V3P521, suite.V3Ristretto255:
+		tuple, err := types.FromCallistoTupleMsgPack(decodedTuple.Tuple)
+		if err != nil {
+			return types.CallistoTuple{}, fmt.Errorf("%w: failed to convert tuple msgpack encoding into CallistoTuple struct: %v", types.ErrDecode, err)
+		}
+		return tuple, nil
+	default:
+		return types.CallistoTuple{}, fmt.Errorf("%w: tuple protocol suite %v", types.ErrUnsupportedVersion, decodedTuple.Tuple.Suite)
+	}
+}
+
+// decodeSchemaVersion returns the schema version of an encoded entry or
+// assignment payload
+func decodeSchemaVersion(encoded []byte) (uint8, error) {
+	var header schemaHeader
+	err := msgpack.Unmarshal(encoded, &header)
+	if err != nil {
+		return 0, fmt.Errorf("%w: schema version: %v", types.ErrDecode, err)
+	}
+	if header.SchemaVersion == 0 {
+		return types.SchemaVersion1, nil
+	}
+	return header.SchemaVersion, nil
+}
+
+func migrateEntryDataV1(data entryDataV1) types.EntryData {
+	return types.EntryData{
+		PerpetratorName:            data.PerpetratorName,
+		PerpetratorTwitterUserName: data.PerpetratorTwitterUserName,
+		VictimName:                 data.VictimName,
+		VictimPhoneNumber:          data.VictimPhoneNumber,
+		VictimEmail:                data.VictimEmail,
+	}
+}
+
+func migrateAssignmentDataV1(data assignmentDataV1) (types.AssignmentData, error) {
+	var categories []types.MisconductCategory
+	for _, name := range strings.Split(data.CategorizationOfSexualMisconduct, ",") {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		category, err := types.ParseMisconductCategory(name)
+		if err != nil {
+			return types.AssignmentData{}, fmt.Errorf("%w: failed to migrate version 1 assignment data: %v", types.ErrDecode, err)
+		}
+		categories = append(categories, category)
 	}
 
-	return locData, nil
+	return types.AssignmentData{
+		VictimStateOfCurrentResidence:    data.VictimStateOfCurrentResidence,
+		CategorizationOfSexualMisconduct: categories,
+		IndustryOfPerpetrator:            data.IndustryOfPerpetrator,
+	}, nil
 }
@@ -0,0 +1,277 @@
+package encoding
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack"
+	"github.com/ymarcus93/gallisto/internal/shamir"
+	helper "github.com/ymarcus93/gallisto/internal/test"
+	"github.com/ymarcus93/gallisto/suite"
+	"github.com/ymarcus93/gallisto/types"
+)
+
+func TestEntryDataRoundTrip(t *testing.T) {
+	tests := map[string]types.EntryData{
+		"without incident": {
+			PerpetratorName:            "Foo",
+			PerpetratorTwitterUserName: "@foo",
+			VictimName:                 "Bar",
+			VictimPhoneNumber:          "111-234-5678",
+			VictimEmail:                "bar@mail.com",
+		},
+		"with incident": {
+			PerpetratorName: "Foo",
+			VictimName:      "Bar",
+			Incident: &types.Incident{
+				StartDate: time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC),
+				EndDate:   time.Date(2020, 4, 2, 0, 0, 0, 0, time.UTC),
+				Location:  "Boston",
+				Narrative: "Something happened",
+			},
+		},
+	}
+
+	for testName, entryData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			encoded, err := EncodeEntryData(entryData)
+			if !assert.NoError(t, err) {
+				return
+			}
+			decoded, err := DecodeEntryData(encoded)
+			if assert.NoError(t, err) {
+				assert.Equal(t, entryData.PerpetratorName, decoded.PerpetratorName)
+				if entryData.Incident == nil {
+					assert.Nil(t, decoded.Incident)
+				} else if assert.NotNil(t, decoded.Incident) {
+					assert.True(t, entryData.Incident.StartDate.Equal(decoded.Incident.StartDate))
+					assert.True(t, entryData.Incident.EndDate.Equal(decoded.Incident.EndDate))
+					assert.Equal(t, entryData.Incident.Location, decoded.Incident.Location)
+					assert.Equal(t, entryData.Incident.Narrative, decoded.Incident.Narrative)
+				}
+			}
+		})
+	}
+}
+
+func TestAssignmentDataRoundTrip(t *testing.T) {
+	assignmentData := types.AssignmentData{
+		VictimStateOfCurrentResidence:    "Massachusetts",
+		CategorizationOfSexualMisconduct: []types.MisconductCategory{types.MisconductAssault, types.MisconductStalking},
+		IndustryOfPerpetrator:            "Foo industry",
+	}
+	encoded, err := EncodeAssignmentData(assignmentData)
+	if !assert.NoError(t, err) {
+		return
+	}
+	decoded, err := DecodeAssignmentData(encoded)
+	if assert.NoError(t, err) {
+		assert.Equal(t, assignmentData, decoded)
+	}
+}
+
+func TestDecodeEntryData_Version1(t *testing.T) {
+	v1 := entryDataV1{
+		PerpetratorName:            "Foo",
+		PerpetratorTwitterUserName: "@foo",
+		VictimName:                 "Bar",
+		VictimPhoneNumber:          "111-234-5678",
+		VictimEmail:                "bar@mail.com",
+	}
+	encoded, err := msgpack.Marshal(&v1)
+	if !assert.NoError(t, err) {
+		return
+	}
+	decoded, err := DecodeEntryData(encoded)
+	if assert.NoError(t, err) {
+		assert.Equal(t, types.EntryData{
+			PerpetratorName:            "Foo",
+			PerpetratorTwitterUserName: "@foo",
+			VictimName:                 "Bar",
+			VictimPhoneNumber:          "111-234-5678",
+			VictimEmail:                "bar@mail.com",
+		}, decoded)
+	}
+}
+
+func TestDecodeAssignmentData_Version1(t *testing.T) {
+	tests := map[string]struct {
+		categories    string
+		expected      []types.MisconductCategory
+		expectedError bool
+	}{
+		"no categories": {
+			categories: "",
+			expected:   nil,
+		},
+		"single category": {
+			categories: "Stalking",
+			expected:   []types.MisconductCategory{types.MisconductStalking},
+		},
+		"multiple categories": {
+			categories: "Abuse,Indecent exposure,Harassment",
+			expected:   []types.MisconductCategory{types.MisconductAbuse, types.MisconductIndecentExposure, types.MisconductHarassment},
+		},
+		"unknown category": {
+			categories:    "Abuse,Foo",
+			expectedError: true,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			v1 := assignmentDataV1{
+				VictimStateOfCurrentResidence:    "Massachusetts",
+				CategorizationOfSexualMisconduct: test.categories,
+				IndustryOfPerpetrator:            "Foo industry",
+			}
+			encoded, err := msgpack.Marshal(&v1)
+			if !assert.NoError(t, err) {
+				return
+			}
+			decoded, err := DecodeAssignmentData(encoded)
+			if test.expectedError {
+				assert.True(t, errors.Is(err, types.ErrDecode))
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, "Massachusetts", decoded.VictimStateOfCurrentResidence)
+				assert.Equal(t, test.expected, decoded.CategorizationOfSexualMisconduct)
+				assert.Equal(t, "Foo industry", decoded.IndustryOfPerpetrator)
+			}
+		})
+	}
+}
+
+func TestDecode_UnsupportedVersion(t *testing.T) {
+	futureEntry := versionedEntryData{SchemaVersion: types.SchemaVersion + 1}
+	encodedEntry, err := msgpack.Marshal(&futureEntry)
+	if assert.NoError(t, err) {
+		_, err = DecodeEntryData(encodedEntry)
+		assert.True(t, errors.Is(err, types.ErrUnsupportedVersion))
+	}
+
+	futureAssignment := versionedAssignmentData{SchemaVersion: types.SchemaVersion + 1}
+	encodedAssignment, err := msgpack.Marshal(&futureAssignment)
+	if assert.NoError(t, err) {
+		_, err = DecodeAssignmentData(encodedAssignment)
+		assert.True(t, errors.Is(err, types.ErrUnsupportedVersion))
+	}
+}
+
+func TestDecode_Corrupt(t *testing.T) {
+	corrupt := []byte{0xc1}
+	_, err := DecodeEntryData(corrupt)
+	assert.True(t, errors.Is(err, types.ErrDecode))
+	_, err = DecodeAssignmentData(corrupt)
+	assert.True(t, errors.Is(err, types.ErrDecode))
+	_, err = DecodeLOCData(corrupt)
+	assert.True(t, errors.Is(err, types.ErrDecode))
+	_, err = DecodeCallistoTuple(corrupt)
+	assert.True(t, errors.Is(err, types.ErrDecode))
+}
+
+func TestCallistoTupleRoundTrip(t *testing.T) {
+	tuple, err := types.NewCallistoTuple(
+		helper.GenerateRandomBytes(16, t),
+		helper.GenerateRandomBytes(32, t),
+		helper.GenerateRandomBytes(512, t),
+		helper.GenerateRandomBytes(512, t),
+		helper.CreateGCMCiphertext(t),
+		helper.CreateGCMCiphertext(t),
+		helper.CreateGCMCiphertext(t),
+	)
+	if err != nil {
+		t.Fatalf("failed to create tuple: %v", err)
+	}
+	tuple = tuple.WithMatchClass(types.MatchPhonetic).WithSuite(suite.V1)
+
+	encoded, err := EncodeCallistoTuple(tuple)
+	if assert.NoError(t, err) {
+		decoded, err := DecodeCallistoTuple(encoded)
+		assert.NoError(t, err)
+		assert.Equal(t, tuple, decoded)
+	}
+
+	certified := tuple.WithUserCertificate(helper.GenerateRandomBytes(88, t))
+	encoded, err = EncodeCallistoTuple(certified)
+	if assert.NoError(t, err) {
+		decoded, err := DecodeCallistoTuple(encoded)
+		assert.NoError(t, err)
+		assert.Equal(t, certified, decoded)
+	}
+
+	bound := tuple.WithSuite(suite.V3P256).WithTupleID(helper.GenerateRandomBytes(types.TupleIDSize, t))
+	encoded, err = EncodeCallistoTuple(bound)
+	if assert.NoError(t, err) {
+		decoded, err := DecodeCallistoTuple(encoded)
+		assert.NoError(t, err)
+		assert.Equal(t, bound, decoded)
+	}
+
+	future := versionedCallistoTuple{Version: tupleEncodingVersion + 1, Tuple: tuple.ToCallistoTupleMsgPack()}
+	encoded, err = msgpack.Marshal(&future)
+	if assert.NoError(t, err) {
+		_, err = DecodeCallistoTuple(encoded)
+		assert.True(t, errors.Is(err, types.ErrUnsupportedVersion))
+	}
+
+	unknownSuite := versionedCallistoTuple{Version: tupleEncodingVersion, Tuple: tuple.ToCallistoTupleMsgPack()}
+	unknownSuite.Tuple.Suite = 255
+	encoded, err = msgpack.Marshal(&unknownSuite)
+	if assert.NoError(t, err) {
+		_, err = DecodeCallistoTuple(encoded)
+		assert.True(t, errors.Is(err, types.ErrUnsupportedVersion))
+	}
+}
+
+func TestDecodeLOCData_Suite(t *testing.T) {
+	share := &shamir.ShamirShare{X: shamir.NewElement(1), Y: shamir.NewElement(2)}
+	locData, err := types.NewLOCData(types.Counselor, share, helper.CreateGCMCiphertext(t))
+	if err != nil {
+		t.Fatalf("failed to create LOC data: %v", err)
+	}
+
+	// The suite survives a round trip
+	encoded, err := EncodeLOCData(locData.WithSuite(suite.V1))
+	if assert.NoError(t, err) {
+		decoded, err := DecodeLOCData(encoded)
+		assert.NoError(t, err)
+		assert.Equal(t, suite.V1, decoded.Suite())
+	}
+
+	// So does the binding of suites that bind tuples
+	binding := suite.Binding{UserID: helper.GenerateRandomBytes(16, t), TupleID: helper.GenerateRandomBytes(types.TupleIDSize, t)}
+	encoded, err = EncodeLOCData(locData.WithSuite(suite.V3P256).WithBinding(binding.UserID, binding.TupleID))
+	if assert.NoError(t, err) {
+		decoded, err := DecodeLOCData(encoded)
+		assert.NoError(t, err)
+		assert.Equal(t, suite.V3P256, decoded.Suite())
+		assert.Equal(t, binding, decoded.Binding())
+	}
+
+	// Payloads from before suites were introduced belong to the legacy suite
+	legacy := struct {
+		LocType      types.LOCType
+		U            []byte
+		S            []byte
+		EncryptedKey interface{}
+	}{types.Counselor, share.X.Bytes(), share.Y.Bytes(), locData.EncryptedKey()}
+	encoded, err = msgpack.Marshal(&legacy)
+	if assert.NoError(t, err) {
+		decoded, err := DecodeLOCData(encoded)
+		assert.NoError(t, err)
+		assert.Equal(t, suite.Legacy, decoded.Suite())
+		assert.Equal(t, locData.EncryptedKey(), decoded.EncryptedKey())
+	}
+
+	unknown := locData.ToLOCDataMsgPack()
+	unknown.Suite = 255
+	encoded, err = msgpack.Marshal(&unknown)
+	if assert.NoError(t, err) {
+		_, err = DecodeLOCData(encoded)
+		assert.True(t, errors.Is(err, types.ErrUnsupportedVersion))
+	}
+}
@@ -0,0 +1,96 @@
+package shamir
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/superarius/shamir"
+	ff "github.com/superarius/shamir/modular"
+	"github.com/ymarcus93/gallisto/internal/util"
+)
+
+// The previous implementation used github.com/superarius/shamir with its
+// package-wide modulus set to CallistoPrime. It is kept here only to compare
+// against.
+var setLibraryModulus sync.Once
+
+func libraryShares(b *testing.B, shares []*ShamirShare) []*shamir.Share {
+	setLibraryModulus.Do(func() {
+		prime, err := ff.IntFromString(CallistoPrime, 10)
+		if err != nil {
+			b.Fatal(err)
+		}
+		ff.SetP(prime)
+	})
+	converted := make([]*shamir.Share, len(shares))
+	for i, s := range shares {
+		converted[i] = &shamir.Share{X: ff.IntFromBytes(s.X.Bytes()), Y: ff.IntFromBytes(s.Y.Bytes())}
+	}
+	return converted
+}
+
+func benchmarkRandomBytes(b *testing.B, n int) []byte {
+	randBytes, err := util.GenerateRandomBytes(n)
+	if err != nil {
+		b.Fatalf("failed to generate %v random bytes: %v", n, err)
+	}
+	return randBytes
+}
+
+// benchmarkShares returns n shares of distinct users on the same line
+func benchmarkShares(b *testing.B, n int) []*ShamirShare {
+	aValue := benchmarkRandomBytes(b, 32)
+	kValue := benchmarkRandomBytes(b, 32)
+	shares := make([]*ShamirShare, n)
+	for i := range shares {
+		shares[i] = ComputeShamirShare(aValue, kValue, benchmarkRandomBytes(b, 32))
+	}
+	return shares
+}
+
+func BenchmarkFindShamirKValue(b *testing.B) {
+	for _, n := range []int{2, 3, 10} {
+		shares := benchmarkShares(b, n)
+
+		b.Run(fmt.Sprintf("native/%v shares", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := FindShamirKValue(shares); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("vandermonde/%v shares", n), func(b *testing.B) {
+			converted := libraryShares(b, shares)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := shamir.InterpolatePolynomial(converted); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkComputeShamirShare(b *testing.B) {
+	aValue := benchmarkRandomBytes(b, 32)
+	kValue := benchmarkRandomBytes(b, 32)
+	userID := benchmarkRandomBytes(b, 32)
+
+	b.Run("native", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ComputeShamirShare(aValue, kValue, userID)
+		}
+	})
+
+	b.Run("library", func(b *testing.B) {
+		libraryShares(b, nil)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			elementU := ff.IntFromBytes(sha256Sum(userID))
+			elementS := new(ff.Int).Mul(ff.IntFromBytes(aValue), elementU)
+			elementS.Add(elementS, ff.IntFromBytes(kValue))
+		}
+	})
+}
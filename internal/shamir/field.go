@@ -0,0 +1,278 @@
+package shamir
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"math/bits"
+)
+
+// Element is an element of the prime field of order CallistoPrime
+// (p = 2^256 + 297). The zero value is the zero element.
+//
+// Elements are stored as five little-endian 64-bit limbs and are always fully
+// reduced. Arithmetic does not branch on or index memory by element values, so
+// its timing does not depend on them. Conversions to and from bytes only
+// depend on the length of their input and output.
+type Element struct {
+	l [5]uint64
+}
+
+// p = 2^256 + 297
+var fieldPrime = [5]uint64{297, 0, 0, 0, 1}
+
+// 297 * p = 297 * 2^256 + 88209
+var fieldPrimeTimes297 = [5]uint64{88209, 0, 0, 0, 297}
+
+// p - 2, the exponent used for inversion
+var fieldInverseExponent = [5]uint64{295, 0, 0, 0, 1}
+
+// NewElement returns the element equal to v
+func NewElement(v uint64) *Element {
+	return &Element{l: [5]uint64{v}}
+}
+
+// ElementFromBytes returns the element equal to the big-endian unsigned
+// integer b, reduced modulo p
+func ElementFromBytes(b []byte) *Element {
+	// Pad to a whole number of 256-bit chunks. Each chunk is less than p.
+	padded := make([]byte, (len(b)+31)/32*32)
+	copy(padded[len(padded)-len(b):], b)
+
+	// Horner's rule in base 2^256
+	twoTo256 := &Element{l: [5]uint64{0, 0, 0, 0, 1}}
+	e := new(Element)
+	var chunk Element
+	for i := 0; i < len(padded); i += 32 {
+		for j := 0; j < 4; j++ {
+			chunk.l[j] = binary.BigEndian.Uint64(padded[i+24-8*j:])
+		}
+		e.Mul(e, twoTo256)
+		e.Add(e, &chunk)
+	}
+	return e
+}
+
+// RandomElement returns a uniformly random element read from random
+func RandomElement(random io.Reader) (*Element, error) {
+	// Reducing 512 random bits leaves a bias of about 2^-256
+	b := make([]byte, 64)
+	if _, err := io.ReadFull(random, b); err != nil {
+		return nil, err
+	}
+	return ElementFromBytes(b), nil
+}
+
+// Bytes returns the minimal big-endian encoding of e, as math/big would encode
+// it. The zero element encodes to an empty slice.
+func (e *Element) Bytes() []byte {
+	var b [40]byte
+	for i := 0; i < 5; i++ {
+		binary.BigEndian.PutUint64(b[32-8*i:], e.l[i])
+	}
+	i := 0
+	for i < len(b) && b[i] == 0 {
+		i++
+	}
+	return append([]byte{}, b[i:]...)
+}
+
+// Big returns e as a big.Int
+func (e *Element) Big() *big.Int {
+	return new(big.Int).SetBytes(e.Bytes())
+}
+
+// Set sets e = a and returns e
+func (e *Element) Set(a *Element) *Element {
+	e.l = a.l
+	return e
+}
+
+// Equal reports whether e and a are the same element, in constant time
+func (e *Element) Equal(a *Element) bool {
+	var diff uint64
+	for i := range e.l {
+		diff |= e.l[i] ^ a.l[i]
+	}
+	return subtle.ConstantTimeEq(int32(diff>>32|diff&0xffffffff), 0) == 1
+}
+
+// IsZero reports whether e is the zero element, in constant time
+func (e *Element) IsZero() bool {
+	return e.Equal(new(Element))
+}
+
+// Add sets e = a + b and returns e
+func (e *Element) Add(a, b *Element) *Element {
+	var sum [5]uint64
+	var carry uint64
+	for i := range sum {
+		sum[i], carry = bits.Add64(a.l[i], b.l[i], carry)
+	}
+	// a + b < 2p < 2^258, so the sum fits in five limbs. Subtract p and keep
+	// the difference unless it underflowed.
+	e.l = reduceOnce(sum)
+	return e
+}
+
+// Sub sets e = a - b and returns e
+func (e *Element) Sub(a, b *Element) *Element {
+	var diff [5]uint64
+	var borrow uint64
+	for i := range diff {
+		diff[i], borrow = bits.Sub64(a.l[i], b.l[i], borrow)
+	}
+	// Add p back if the difference underflowed
+	mask := -borrow
+	var carry uint64
+	for i := range diff {
+		diff[i], carry = bits.Add64(diff[i], fieldPrime[i]&mask, carry)
+	}
+	e.l = diff
+	return e
+}
+
+// Neg sets e = -a and returns e
+func (e *Element) Neg(a *Element) *Element {
+	return e.Sub(new(Element), a)
+}
+
+// Mul sets e = a * b and returns e
+func (e *Element) Mul(a, b *Element) *Element {
+	// Split a = A + 2^256 a4 and b = B + 2^256 b4, where a4 and b4 are 0 or 1,
+	// so that a * b = A * B + 2^256 (a4 B + b4 A) + 2^512 a4 b4
+	var t [9]uint64
+	a0, a1, a2, a3 := a.l[0], a.l[1], a.l[2], a.l[3]
+	for j, bj := range [4]uint64{b.l[0], b.l[1], b.l[2], b.l[3]} {
+		var c uint64
+		c, t[j] = mulAdd(a0, bj, t[j], 0)
+		c, t[j+1] = mulAdd(a1, bj, t[j+1], c)
+		c, t[j+2] = mulAdd(a2, bj, t[j+2], c)
+		c, t[j+3] = mulAdd(a3, bj, t[j+3], c)
+		t[j+4] = c
+	}
+
+	maskA, maskB := -a.l[4], -b.l[4]
+	var carryA, carryB uint64
+	for i := 0; i < 4; i++ {
+		t[i+4], carryA = bits.Add64(t[i+4], b.l[i]&maskA, carryA)
+		t[i+4], carryB = bits.Add64(t[i+4], a.l[i]&maskB, carryB)
+	}
+	t[8] = carryA + carryB + a.l[4]&b.l[4]
+
+	e.l = reduceProduct(t)
+	return e
+}
+
+// Square sets e = a * a and returns e
+func (e *Element) Square(a *Element) *Element {
+	return e.Mul(a, a)
+}
+
+// Inverse sets e = 1/a and returns e. The inverse of zero is zero.
+func (e *Element) Inverse(a *Element) *Element {
+	// Fermat's little theorem: a^(p-2) = 1/a. The exponent is public, so
+	// square-and-multiply over its bits does not leak a.
+	result := NewElement(1)
+	base := new(Element).Set(a)
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 64; j++ {
+			if fieldInverseExponent[i]>>uint(j)&1 == 1 {
+				result.Mul(result, base)
+			}
+			base.Square(base)
+		}
+	}
+	return e.Set(result)
+}
+
+// reduceOnce returns x mod p for x < 2p
+func reduceOnce(x [5]uint64) [5]uint64 {
+	var diff [5]uint64
+	var borrow uint64
+	for i := range diff {
+		diff[i], borrow = bits.Sub64(x[i], fieldPrime[i], borrow)
+	}
+	return selectLimbs(borrow, x, diff)
+}
+
+// reduceProduct returns t mod p for t < p^2
+func reduceProduct(t [9]uint64) [5]uint64 {
+	// Write t = L + 2^256 M + 2^512 c, where L and M are 256 bits and c < 4.
+	// Since 2^256 = -297 (mod p) and 2^512 = 297^2 (mod p),
+	//
+	//   t = L - 297 M + 88209 c (mod p)
+	//
+	// 297 M < 297 p, so adding 297 p keeps the result non-negative:
+	//
+	//   r = L + 297 p + 88209 c - 297 M < 2^265
+	var r [5]uint64
+	var carry uint64
+	for i := 0; i < 4; i++ {
+		r[i], carry = bits.Add64(t[i], fieldPrimeTimes297[i], carry)
+	}
+	r[4] = fieldPrimeTimes297[4] + carry
+
+	r[0], carry = bits.Add64(r[0], 88209*t[8], 0)
+	for i := 1; i < 5; i++ {
+		r[i], carry = bits.Add64(r[i], 0, carry)
+	}
+
+	m := mul297(t[4], t[5], t[6], t[7])
+	var borrow uint64
+	for i := range r {
+		r[i], borrow = bits.Sub64(r[i], m[i], borrow)
+	}
+
+	// Fold the top limb again: r = R + 2^256 h = R - 297 h (mod p), with
+	// h < 2^9. R - 297 h > -p, so adding p once if it underflowed reduces it
+	// fully.
+	h := r[4]
+	var s [5]uint64
+	s[0], borrow = bits.Sub64(r[0], 297*h, 0)
+	for i := 1; i < 4; i++ {
+		s[i], borrow = bits.Sub64(r[i], 0, borrow)
+	}
+	s[4] = -borrow // Sign extension of the 256-bit difference
+
+	mask := -borrow
+	for i := range s {
+		s[i], carry = bits.Add64(s[i], fieldPrime[i]&mask, carry)
+	}
+	return s
+}
+
+// mulAdd returns x * y + z + carry as a high and a low limb
+func mulAdd(x, y, z, carry uint64) (uint64, uint64) {
+	hi, lo := bits.Mul64(x, y)
+	var c uint64
+	lo, c = bits.Add64(lo, z, 0)
+	hi += c
+	lo, c = bits.Add64(lo, carry, 0)
+	return hi + c, lo
+}
+
+// mul297 returns 297 * (x0 + 2^64 x1 + 2^128 x2 + 2^192 x3)
+func mul297(x0, x1, x2, x3 uint64) [5]uint64 {
+	var m [5]uint64
+	var carry uint64
+	for i, x := range [4]uint64{x0, x1, x2, x3} {
+		hi, lo := bits.Mul64(x, 297)
+		var c uint64
+		m[i], c = bits.Add64(lo, carry, 0)
+		carry = hi + c
+	}
+	m[4] = carry
+	return m
+}
+
+// selectLimbs returns a if choice is 1 and b if choice is 0
+func selectLimbs(choice uint64, a, b [5]uint64) [5]uint64 {
+	mask := -choice
+	var out [5]uint64
+	for i := range out {
+		out[i] = b[i] ^ (mask & (a[i] ^ b[i]))
+	}
+	return out
+}
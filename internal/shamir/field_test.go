@@ -0,0 +1,101 @@
+package shamir
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	helper "github.com/ymarcus93/gallisto/internal/test"
+)
+
+func callistoPrime(t testing.TB) *big.Int {
+	p, ok := new(big.Int).SetString(CallistoPrime, 10)
+	if !ok {
+		t.Fatalf("failed to parse CallistoPrime")
+	}
+	return p
+}
+
+// fieldTestValues returns edge case values, some of which are not reduced,
+// followed by random values of various lengths
+func fieldTestValues(t *testing.T) [][]byte {
+	p := callistoPrime(t)
+	one := big.NewInt(1)
+	twoTo256 := new(big.Int).Lsh(one, 256)
+	values := [][]byte{
+		{},
+		{1},
+		{2},
+		new(big.Int).Sub(p, one).Bytes(),
+		p.Bytes(),
+		new(big.Int).Add(p, one).Bytes(),
+		new(big.Int).Sub(twoTo256, one).Bytes(),
+		twoTo256.Bytes(),
+		new(big.Int).Lsh(one, 511).Bytes(),
+		new(big.Int).Sub(new(big.Int).Lsh(one, 512), one).Bytes(),
+	}
+	for _, n := range []int{1, 8, 31, 32, 33, 64, 100} {
+		for i := 0; i < 8; i++ {
+			values = append(values, helper.GenerateRandomBytes(n, t))
+		}
+	}
+	return values
+}
+
+func TestElementFromBytes(t *testing.T) {
+	p := callistoPrime(t)
+	for _, v := range fieldTestValues(t) {
+		expected := new(big.Int).Mod(new(big.Int).SetBytes(v), p)
+		actual := ElementFromBytes(v)
+		assert.Equal(t, expected.Bytes(), actual.Bytes(), "value %x", v)
+		assert.Equal(t, 0, expected.Cmp(actual.Big()), "value %x", v)
+	}
+}
+
+func TestElementArithmetic(t *testing.T) {
+	p := callistoPrime(t)
+	mod := func(x *big.Int) []byte { return x.Mod(x, p).Bytes() }
+
+	values := fieldTestValues(t)
+	for _, x := range values {
+		for _, y := range values {
+			a, b := ElementFromBytes(x), ElementFromBytes(y)
+			bigA, bigB := a.Big(), b.Big()
+
+			assert.Equal(t, mod(new(big.Int).Add(bigA, bigB)), new(Element).Add(a, b).Bytes(), "%x + %x", x, y)
+			assert.Equal(t, mod(new(big.Int).Sub(bigA, bigB)), new(Element).Sub(a, b).Bytes(), "%x - %x", x, y)
+			assert.Equal(t, mod(new(big.Int).Mul(bigA, bigB)), new(Element).Mul(a, b).Bytes(), "%x * %x", x, y)
+			assert.Equal(t, bigA.Cmp(bigB) == 0, a.Equal(b))
+		}
+	}
+}
+
+func TestElementInverse(t *testing.T) {
+	p := callistoPrime(t)
+	for _, v := range fieldTestValues(t) {
+		a := ElementFromBytes(v)
+		inverse := new(Element).Inverse(a)
+		if a.IsZero() {
+			assert.True(t, inverse.IsZero())
+			continue
+		}
+		assert.Equal(t, new(big.Int).ModInverse(a.Big(), p).Bytes(), inverse.Bytes(), "value %x", v)
+		assert.True(t, new(Element).Mul(a, inverse).Equal(NewElement(1)))
+	}
+}
+
+func TestElementAliasing(t *testing.T) {
+	a := ElementFromBytes(helper.GenerateRandomBytes(32, t))
+	b := ElementFromBytes(helper.GenerateRandomBytes(32, t))
+
+	expected := new(Element).Mul(a, b)
+	expected.Add(expected, a)
+
+	// Results may alias their operands
+	actual := new(Element).Set(a)
+	actual.Mul(actual, b)
+	actual.Add(actual, a)
+	assert.Equal(t, expected, actual)
+
+	assert.True(t, new(Element).Add(a, new(Element).Neg(a)).IsZero())
+}
@@ -3,30 +3,27 @@ package shamir
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
-
-	"github.com/superarius/shamir"
-	ff "github.com/superarius/shamir/modular"
 )
 
 // CallistoPrime is the prime modulus mentioned in the Callisto paper: 2^256 + 297
 const CallistoPrime string = "115792089237316195423570985008687907853269984665640564039457584007913129640233"
 
-type ShamirShare shamir.Share
+// ErrDuplicateX is returned when two distinct shares have the same x value, so
+// no polynomial passes through both
+var ErrDuplicateX = errors.New("distinct shares have the same x value")
 
-func (s *ShamirShare) Hex() string {
-	xAsHex := hex.EncodeToString(s.X.AsBig().Bytes())
-	yAsHex := hex.EncodeToString(s.Y.AsBig().Bytes())
-	return xAsHex + yAsHex
+// ShamirShare is a point (x, y) on a secret sharing polynomial
+type ShamirShare struct {
+	X *Element
+	Y *Element
 }
 
-func init() {
-	// Set prime modulus for the finite field package to CallistoPrime
-	prime, err := ff.IntFromString(CallistoPrime, 10)
-	if err != nil {
-		panic(err.Error())
-	}
-	ff.SetP(prime)
+func (s *ShamirShare) Hex() string {
+	xAsHex := hex.EncodeToString(s.X.Bytes())
+	yAsHex := hex.EncodeToString(s.Y.Bytes())
+	return xAsHex + yAsHex
 }
 
 func filterForUniqueShares(shares []*ShamirShare) []*ShamirShare {
@@ -44,30 +41,91 @@ func filterForUniqueShares(shares []*ShamirShare) []*ShamirShare {
 	return uniqueShares
 }
 
-func interpolate(shares []*ShamirShare) ([]*ff.Int, error) {
-	shamirPackageShares := make([]*shamir.Share, len(shares))
-	for i, share := range shares {
-		shamirPackageShares[i] = (*shamir.Share)(share)
+// interpolateAtZero evaluates the polynomial through shares at zero. The
+// common case of two shares uses a closed form.
+func interpolateAtZero(shares []*ShamirShare) (*Element, error) {
+	if len(shares) == 2 {
+		return interpolateLineAtZero(shares[0], shares[1])
 	}
+	return lagrangeAtZero(shares)
+}
 
-	return shamir.InterpolatePolynomial(shamirPackageShares)
+// lagrangeAtZero evaluates the Lagrange polynomial through shares at zero:
+//
+//	f(0) = sum_i y_i * prod_{j != i} x_j / (x_j - x_i)
+//
+// All denominators are inverted at once (Montgomery's trick), so only one
+// field inversion is needed.
+func lagrangeAtZero(shares []*ShamirShare) (*Element, error) {
+	numerators := make([]Element, len(shares))
+	denominators := make([]Element, len(shares))
+	difference := new(Element)
+	for i, si := range shares {
+		numerators[i].Set(NewElement(1))
+		denominators[i].Set(NewElement(1))
+		for j, sj := range shares {
+			if i == j {
+				continue
+			}
+			numerators[i].Mul(&numerators[i], sj.X)
+			denominators[i].Mul(&denominators[i], difference.Sub(sj.X, si.X))
+		}
+	}
+
+	// prefixes[i] is the product of the first i denominators
+	prefixes := make([]Element, len(shares)+1)
+	prefixes[0].Set(NewElement(1))
+	for i := range denominators {
+		prefixes[i+1].Mul(&prefixes[i], &denominators[i])
+	}
+	if prefixes[len(shares)].IsZero() {
+		return nil, ErrDuplicateX
+	}
+	inverse := new(Element).Inverse(&prefixes[len(shares)])
+
+	result := new(Element)
+	term := new(Element)
+	for i := len(shares) - 1; i >= 0; i-- {
+		// inverse is now 1 / (d_0 * ... * d_i)
+		term.Mul(inverse, &prefixes[i])
+		inverse.Mul(inverse, &denominators[i])
+
+		term.Mul(term, &numerators[i])
+		term.Mul(term, shares[i].Y)
+		result.Add(result, term)
+	}
+	return result, nil
 }
 
-// FindShamirKValue uses a Vandermonde matrix to interpolate a polynomial and
-// finds the y-intercept of the polynomial if possible
-func FindShamirKValue(shares []*ShamirShare) (*ff.Int, error) {
+// interpolateLineAtZero is the closed form of lagrangeAtZero for two shares:
+//
+//	f(0) = (x2 y1 - x1 y2) / (x2 - x1)
+func interpolateLineAtZero(s1, s2 *ShamirShare) (*Element, error) {
+	denominator := new(Element).Sub(s2.X, s1.X)
+	if denominator.IsZero() {
+		return nil, ErrDuplicateX
+	}
+	numerator := new(Element).Mul(s2.X, s1.Y)
+	numerator.Sub(numerator, new(Element).Mul(s1.X, s2.Y))
+	return numerator.Mul(numerator, denominator.Inverse(denominator)), nil
+}
+
+// FindShamirKValue uses Lagrange interpolation to find the y-intercept of the
+// polynomial passing through the given shares
+func FindShamirKValue(shares []*ShamirShare) (*Element, error) {
 	// If we don't filter for unique shares, we run into a problem where if
 	// there are >= 2 shares with the same X value (i.e. same user reported more
 	// than once on same perp), interpolation will fail. It even fails if there
 	// is a valid share from another user (different X value).
 	uniqueShares := filterForUniqueShares(shares)
-	result, err := interpolate(uniqueShares)
+	if len(uniqueShares) == 0 {
+		return nil, errors.New("failed to interpolate polynomial: no shares given")
+	}
+	result, err := interpolateAtZero(uniqueShares)
 	if err != nil {
-		return nil, fmt.Errorf("failed to interpolate polynomial: %v", err)
+		return nil, fmt.Errorf("failed to interpolate polynomial: %w", err)
 	}
-
-	yIntercept := result[0]
-	return yIntercept, nil
+	return result, nil
 }
 
 // ComputeShamirShare computes a (U,s) SSSS share given a userID and KDF derived
@@ -76,13 +134,13 @@ func ComputeShamirShare(aValue, kValue, userId []byte) *ShamirShare {
 	// Hash the userID
 	userIdHash := sha256Sum(userId)
 
-	elementA := ff.IntFromBytes(aValue)
-	elementK := ff.IntFromBytes(kValue)
-	elementU := ff.IntFromBytes(userIdHash)
+	elementA := ElementFromBytes(aValue)
+	elementK := ElementFromBytes(kValue)
+	elementU := ElementFromBytes(userIdHash)
 
 	// Compute point s = aU + k
-	elementAMultU := new(ff.Int).Mul(elementA, elementU)
-	elementS := new(ff.Int).Add(elementAMultU, elementK)
+	elementS := new(Element).Mul(elementA, elementU)
+	elementS.Add(elementS, elementK)
 
 	return &ShamirShare{X: elementU, Y: elementS}
 }
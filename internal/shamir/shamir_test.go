@@ -2,11 +2,11 @@ package shamir
 
 import (
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
-	"github.com/superarius/shamir/modular"
 	helper "github.com/ymarcus93/gallisto/internal/test"
 )
 
@@ -33,19 +33,19 @@ func TestComputeShamirShare(t *testing.T) {
 	// Element U
 	userID := helper.GenerateRandomBytes(32, t)
 	userIDHash := sha256.Sum256(userID)
-	elementU := modular.IntFromBytes(userIDHash[:])
+	elementU := ElementFromBytes(userIDHash[:])
 
 	// Element a
 	aValue := helper.GenerateRandomBytes(32, t)
-	elementA := modular.IntFromBytes(aValue)
+	elementA := ElementFromBytes(aValue)
 
 	// Element k
 	kValue := helper.GenerateRandomBytes(32, t)
-	elementK := modular.IntFromBytes(kValue)
+	elementK := ElementFromBytes(kValue)
 
 	// Compute point s = aU + k
-	aTimesU := new(modular.Int).Mul(elementA, elementU)
-	elementS := new(modular.Int).Add(aTimesU, elementK)
+	aTimesU := new(Element).Mul(elementA, elementU)
+	elementS := new(Element).Add(aTimesU, elementK)
 
 	// Assert equality
 	actualShare := ComputeShamirShare(aValue, kValue, userID)
@@ -55,10 +55,10 @@ func TestComputeShamirShare(t *testing.T) {
 func TestFindShamirKValue_IncorrectlyInterpolates(t *testing.T) {
 	aValue1 := helper.GenerateRandomBytes(32, t)
 	kValue1 := helper.GenerateRandomBytes(32, t)
-	kValue1AsModInt := modular.IntFromBytes(kValue1)
+	kValue1AsModInt := ElementFromBytes(kValue1)
 	aValue2 := helper.GenerateRandomBytes(32, t)
 	kValue2 := helper.GenerateRandomBytes(32, t)
-	kValue2AsModInt := modular.IntFromBytes(kValue2)
+	kValue2AsModInt := ElementFromBytes(kValue2)
 
 	share1 := computeShamirShares(aValue1, kValue1, 1, t)
 	share2 := computeShamirShares(aValue2, kValue2, 1, t)
@@ -166,7 +166,7 @@ func TestFindShamirKValue_CorrectlyInterpolatesWhenMixed(t *testing.T) {
 			}
 			foundKValue, err := FindShamirKValue(testInput)
 			if assert.NoError(t, err) {
-				expectedKValue := modular.IntFromBytes(kValue)
+				expectedKValue := ElementFromBytes(kValue)
 				assert.Equal(t, expectedKValue, foundKValue)
 			}
 		})
@@ -183,9 +183,46 @@ func TestFindShamirKValue_CorrectlyInterpolates(t *testing.T) {
 			shares := computeShamirShares(aValue, kValue, numOfShares, t)
 			foundKValue, err := FindShamirKValue(shares)
 			if assert.NoError(t, err) {
-				expectedKValue := modular.IntFromBytes(kValue)
+				expectedKValue := ElementFromBytes(kValue)
 				assert.Equal(t, expectedKValue, foundKValue)
 			}
 		})
 	}
 }
+
+func TestFindShamirKValue_DuplicateX(t *testing.T) {
+	userID := helper.GenerateRandomBytes(32, t)
+	tests := map[string]int{
+		"two shares":   0,
+		"three shares": 1,
+	}
+
+	for testName, numOtherShares := range tests {
+		t.Run(testName, func(t *testing.T) {
+			// Same user, different lines
+			shares := []*ShamirShare{
+				ComputeShamirShare(helper.GenerateRandomBytes(32, t), helper.GenerateRandomBytes(32, t), userID),
+				ComputeShamirShare(helper.GenerateRandomBytes(32, t), helper.GenerateRandomBytes(32, t), userID),
+			}
+			shares = append(shares, computeShamirShares(helper.GenerateRandomBytes(32, t), helper.GenerateRandomBytes(32, t), numOtherShares, t)...)
+			_, err := FindShamirKValue(shares)
+			assert.True(t, errors.Is(err, ErrDuplicateX))
+		})
+	}
+}
+
+func TestInterpolateLineAtZero(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		// Any two shares, not necessarily on the same line
+		shares := []*ShamirShare{
+			ComputeShamirShare(helper.GenerateRandomBytes(32, t), helper.GenerateRandomBytes(32, t), helper.GenerateRandomBytes(32, t)),
+			ComputeShamirShare(helper.GenerateRandomBytes(32, t), helper.GenerateRandomBytes(32, t), helper.GenerateRandomBytes(32, t)),
+		}
+		expected, err := lagrangeAtZero(shares)
+		if assert.NoError(t, err) {
+			actual, err := interpolateLineAtZero(shares[0], shares[1])
+			assert.NoError(t, err)
+			assert.Equal(t, expected, actual)
+		}
+	}
+}
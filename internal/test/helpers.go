@@ -3,8 +3,7 @@ package test
 import (
 	"testing"
 
-	"github.com/superarius/shamir/modular"
-	"github.com/ymarcus93/gallisto/internal/encryption"
+	"github.com/ymarcus93/gallisto/crypto"
 	"github.com/ymarcus93/gallisto/internal/util"
 )
 
@@ -16,7 +15,7 @@ func GenerateRandomBytes(num int, t *testing.T) []byte {
 	return randBytes
 }
 
-func CreateGCMCiphertext(t *testing.T) encryption.GCMCiphertext {
+func CreateGCMCiphertext(t *testing.T) crypto.GCMCiphertext {
 	listOfRandBytes := make([][]byte, 3)
 	for i := range listOfRandBytes {
 		randBytes, err := util.GenerateRandomBytes(16)
@@ -25,24 +24,16 @@ func CreateGCMCiphertext(t *testing.T) encryption.GCMCiphertext {
 		}
 		listOfRandBytes[i] = randBytes
 	}
-	return encryption.GCMCiphertext{
+	return crypto.GCMCiphertext{
 		Nonce:          listOfRandBytes[0],
 		Ciphertext:     listOfRandBytes[1],
 		AssociatedData: listOfRandBytes[2],
 	}
 }
 
-func CreateInvalidGCMCiphertexts(t *testing.T) []encryption.GCMCiphertext {
-	nilNonce := encryption.GCMCiphertext{Nonce: nil, Ciphertext: GenerateRandomBytes(32, t), AssociatedData: GenerateRandomBytes(32, t)}
-	nilCiphertext := encryption.GCMCiphertext{Nonce: GenerateRandomBytes(32, t), Ciphertext: nil, AssociatedData: GenerateRandomBytes(32, t)}
-	nilAssociatedData := encryption.GCMCiphertext{Nonce: GenerateRandomBytes(32, t), Ciphertext: GenerateRandomBytes(32, t), AssociatedData: nil}
-	return []encryption.GCMCiphertext{nilNonce, nilCiphertext, nilAssociatedData}
-}
-
-func GenerateRandomModularInt(t *testing.T) *modular.Int {
-	rand, err := modular.RandInt()
-	if err != nil {
-		t.Fatalf("failed to generate random modular int: %v", err)
-	}
-	return rand
+func CreateInvalidGCMCiphertexts(t *testing.T) []crypto.GCMCiphertext {
+	nilNonce := crypto.GCMCiphertext{Nonce: nil, Ciphertext: GenerateRandomBytes(32, t), AssociatedData: GenerateRandomBytes(32, t)}
+	nilCiphertext := crypto.GCMCiphertext{Nonce: GenerateRandomBytes(32, t), Ciphertext: nil, AssociatedData: GenerateRandomBytes(32, t)}
+	nilAssociatedData := crypto.GCMCiphertext{Nonce: GenerateRandomBytes(32, t), Ciphertext: GenerateRandomBytes(32, t), AssociatedData: nil}
+	return []crypto.GCMCiphertext{nilNonce, nilCiphertext, nilAssociatedData}
 }
@@ -3,13 +3,19 @@ package util
 import (
 	"crypto/rand"
 	"fmt"
+	"io"
 )
 
 func GenerateRandomBytes(n int) ([]byte, error) {
+	return GenerateRandomBytesFrom(rand.Reader, n)
+}
+
+// GenerateRandomBytesFrom reads n bytes from the given source of randomness
+func GenerateRandomBytesFrom(random io.Reader, n int) ([]byte, error) {
 	buffer := make([]byte, n)
-	_, err := rand.Read(buffer)
+	_, err := io.ReadFull(random, buffer)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate %v random bytes: %v", n, err)
+		return nil, fmt.Errorf("failed to generate %v random bytes: %w", n, err)
 	}
 	return buffer, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,100 @@
+package oprf
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cloudflare/circl/oprf"
+)
+
+// oprfClient performs the client-side (verifier) operations of the OPRF
+// protocol in one mode
+type oprfClient struct {
+	config Config
+	suite  oprf.Suite
+
+	base             oprf.Client
+	verifiable       oprf.VerifiableClient
+	partialOblivious oprf.PartialObliviousClient
+}
+
+// newOPRFClient returns a client for config. The verifiable and
+// partially-oblivious modes check evaluations against publicKey.
+func newOPRFClient(config Config, publicKey *PublicKey) (*oprfClient, error) {
+	suite, err := config.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &oprfClient{config: config, suite: suite}
+	if config.Mode == BaseMode {
+		c.base = oprf.NewClient(suite)
+		return c, nil
+	}
+
+	if publicKey == nil || publicKey.key == nil {
+		return nil, fmt.Errorf("the %v mode requires the evaluator's public key", config.Mode)
+	}
+	if publicKey.Ciphersuite != config.Ciphersuite {
+		return nil, fmt.Errorf("public key of ciphersuite %v cannot be used with %v", publicKey.Ciphersuite, config.Ciphersuite)
+	}
+	if config.Mode == VerifiableMode {
+		c.verifiable = oprf.NewVerifiableClient(suite, publicKey.key)
+	} else {
+		c.partialOblivious = oprf.NewPartialObliviousClient(suite, publicKey.key)
+	}
+	return c, nil
+}
+
+// blind blinds every input with the scalar of the same index in blinds
+func (c *oprfClient) blind(inputs [][]byte, blinds []oprf.Blind) (*oprf.FinalizeData, *oprf.EvaluationRequest, error) {
+	var finData *oprf.FinalizeData
+	var evalReq *oprf.EvaluationRequest
+	var err error
+	switch c.config.Mode {
+	case BaseMode:
+		finData, evalReq, err = c.base.DeterministicBlind(inputs, blinds)
+	case VerifiableMode:
+		finData, evalReq, err = c.verifiable.DeterministicBlind(inputs, blinds)
+	default:
+		finData, evalReq, err = c.partialOblivious.DeterministicBlind(inputs, blinds)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to blind input: %w", err)
+	}
+	return finData, evalReq, nil
+}
+
+// finalize checks the evaluation of n blinded inputs, unblinds them and
+// hashes them into their OPRF outputs. info is the public input of the
+// partially-oblivious mode.
+func (c *oprfClient) finalize(finData *oprf.FinalizeData, eval Evaluation, info []byte, n int) ([][]byte, error) {
+	if len(eval.Elements) != n {
+		return nil, &EvaluationError{Err: fmt.Errorf("expected %v evaluated elements, got %v", n, len(eval.Elements))}
+	}
+	if err := checkElements(c.suite.Group(), eval.Elements); err != nil {
+		return nil, &EvaluationError{Err: fmt.Errorf("invalid evaluated element: %w", err)}
+	}
+	if c.config.Mode != BaseMode && eval.Proof == nil {
+		return nil, &EvaluationError{Err: ErrInvalidProof}
+	}
+
+	circlEval := &oprf.Evaluation{Elements: eval.Elements, Proof: eval.Proof}
+	var outputs [][]byte
+	var err error
+	switch c.config.Mode {
+	case BaseMode:
+		outputs, err = c.base.Finalize(finData, circlEval)
+	case VerifiableMode:
+		outputs, err = c.verifiable.Finalize(finData, circlEval)
+	default:
+		outputs, err = c.partialOblivious.Finalize(finData, circlEval, info)
+	}
+	if errors.Is(err, oprf.ErrInvalidProof) {
+		return nil, &EvaluationError{Err: ErrInvalidProof}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to finalize: %w", err)
+	}
+	return outputs, nil
+}
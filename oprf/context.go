@@ -0,0 +1,46 @@
+package oprf
+
+import "context"
+
+// ContextOPRFEvaluator is an OPRFEvaluator that honours a context's deadline
+// and cancellation, e.g. because evaluation happens on a remote server. The
+// context may also carry request-scoped metadata such as the caller's identity
+// (see WithCallerID).
+type ContextOPRFEvaluator interface {
+	EvaluateOPRFContext(ctx context.Context, req EvaluationRequest) (Evaluation, error)
+}
+
+// WithContext adapts an in-process OPRFEvaluator to a ContextOPRFEvaluator. If
+// evaluator already implements ContextOPRFEvaluator it is returned as is.
+// Otherwise the returned evaluator fails without evaluating once ctx is done.
+func WithContext(evaluator OPRFEvaluator) ContextOPRFEvaluator {
+	if contextEvaluator, ok := evaluator.(ContextOPRFEvaluator); ok {
+		return contextEvaluator
+	}
+	return contextAdapter{evaluator}
+}
+
+type contextAdapter struct {
+	evaluator OPRFEvaluator
+}
+
+func (a contextAdapter) EvaluateOPRFContext(ctx context.Context, req EvaluationRequest) (Evaluation, error) {
+	if err := ctx.Err(); err != nil {
+		return Evaluation{}, err
+	}
+	return a.evaluator.EvaluateOPRF(req)
+}
+
+type callerIDKey struct{}
+
+// WithCallerID returns a copy of ctx carrying the identity of the caller on
+// whose behalf an OPRF evaluation is requested
+func WithCallerID(ctx context.Context, callerID string) context.Context {
+	return context.WithValue(ctx, callerIDKey{}, callerID)
+}
+
+// CallerIDFromContext returns the caller identity stored by WithCallerID
+func CallerIDFromContext(ctx context.Context) (string, bool) {
+	callerID, ok := ctx.Value(callerIDKey{}).(string)
+	return callerID, ok
+}
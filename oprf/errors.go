@@ -0,0 +1,28 @@
+package oprf
+
+import "errors"
+
+// ErrOPRFEvaluation matches every EvaluationError, whatever its cause
+var ErrOPRFEvaluation = errors.New("OPRF evaluation failed")
+
+// ErrInvalidProof is the cause of an EvaluationError when the proof sent by an
+// evaluator in the verifiable or partially-oblivious mode does not verify,
+// e.g. because it evaluated with a different key
+var ErrInvalidProof = errors.New("OPRF evaluation proof is invalid")
+
+// EvaluationError is returned when an OPRFEvaluator fails to evaluate blinded
+// inputs, or returns an evaluation the client rejects. Err is the cause, which
+// may be a context error if the evaluation was cancelled or timed out.
+type EvaluationError struct {
+	Err error
+}
+
+func (e *EvaluationError) Error() string {
+	return ErrOPRFEvaluation.Error() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the evaluator's error
+func (e *EvaluationError) Unwrap() error { return e.Err }
+
+// Is reports whether target is ErrOPRFEvaluation
+func (e *EvaluationError) Is(target error) bool { return target == ErrOPRFEvaluation }
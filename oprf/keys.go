@@ -0,0 +1,156 @@
+package oprf
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/cloudflare/circl/oprf"
+
+	"github.com/ymarcus93/gallisto/internal/detrand"
+)
+
+// SecretKey is an OPRF server key of a ciphersuite
+type SecretKey struct {
+	Ciphersuite string
+	key         *oprf.PrivateKey
+}
+
+// PublicKey is the public key of an OPRF server. Clients check evaluations in
+// the verifiable and partially-oblivious modes against it.
+type PublicKey struct {
+	Ciphersuite string
+	key         *oprf.PublicKey
+}
+
+// GenerateKey returns a random OPRF key to be used by an OPRF server
+func GenerateKey(ciphersuite string) (SecretKey, error) {
+	return GenerateKeyWithRand(rand.Reader, ciphersuite)
+}
+
+// GenerateKeyWithRand is like GenerateKey but draws the key from random.
+// Outside of tests, random must be crypto/rand's reader.
+func GenerateKeyWithRand(random io.Reader, ciphersuite string) (SecretKey, error) {
+	if err := detrand.Check(random); err != nil {
+		return SecretKey{}, fmt.Errorf("invalid source of randomness: %w", err)
+	}
+	suite, err := ParseCiphersuiteString(ciphersuite)
+	if err != nil {
+		return SecretKey{}, err
+	}
+
+	// Sample the scalar ourselves so that it is never zero
+	k, err := suite.Group().RandomNonZeroScalar(random).MarshalBinary()
+	if err != nil {
+		return SecretKey{}, fmt.Errorf("failed to generate server key: %w", err)
+	}
+	return secretKeyFromBytes(ciphersuite, suite, k)
+}
+
+// DeriveKey deterministically derives an OPRF key from seed and info for the
+// given mode, as DeriveKeyPair in RFC 9497
+func DeriveKey(ciphersuite string, mode Mode, seed, info []byte) (SecretKey, error) {
+	suite, err := ParseCiphersuiteString(ciphersuite)
+	if err != nil {
+		return SecretKey{}, err
+	}
+	key, err := oprf.DeriveKey(suite, oprf.Mode(mode), seed, info)
+	if err != nil {
+		return SecretKey{}, fmt.Errorf("failed to derive server key: %w", err)
+	}
+	return SecretKey{Ciphersuite: ciphersuite, key: key}, nil
+}
+
+// PublicKey returns the public key of k
+func (k SecretKey) PublicKey() PublicKey {
+	if k.key == nil {
+		return PublicKey{Ciphersuite: k.Ciphersuite}
+	}
+	return PublicKey{Ciphersuite: k.Ciphersuite, key: k.key.Public()}
+}
+
+// MarshalBinary returns the serialized scalar of k
+func (k SecretKey) MarshalBinary() ([]byte, error) {
+	if k.key == nil {
+		return nil, fmt.Errorf("secret key is not set")
+	}
+	return k.key.MarshalBinary()
+}
+
+// MarshalBinary returns the serialized group element of k
+func (k PublicKey) MarshalBinary() ([]byte, error) {
+	if k.key == nil {
+		return nil, fmt.Errorf("public key is not set")
+	}
+	return k.key.MarshalBinary()
+}
+
+// PublicKeyFromBytes decodes a public key serialized with MarshalBinary
+func PublicKeyFromBytes(ciphersuite string, b []byte) (PublicKey, error) {
+	suite, err := ParseCiphersuiteString(ciphersuite)
+	if err != nil {
+		return PublicKey{}, err
+	}
+	key := new(oprf.PublicKey)
+	if err := key.UnmarshalBinary(suite, b); err != nil {
+		return PublicKey{}, fmt.Errorf("failed to deserialize public key: %w", err)
+	}
+	return PublicKey{Ciphersuite: ciphersuite, key: key}, nil
+}
+
+// KeyToHex returns the hex-encoded representaton of the sercet key k and its
+// public key Y = kG, where G is the generator of the ciphersuite's group
+func (k SecretKey) KeyToHex() (string, string, error) {
+	kBytes, err := k.MarshalBinary()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to serialize secret key: %w", err)
+	}
+	pubKey, err := k.PublicKey().MarshalBinary()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to serialize public key: %w", err)
+	}
+	return hex.EncodeToString(kBytes), hex.EncodeToString(pubKey), nil
+}
+
+// KeyFromHex converts a hex-encoded representation of a secret key into an
+// SecretKey to be used by an OPRF server. The public key must belong to the
+// secret key.
+func KeyFromHex(ciphersuite, kValueHex, pubKeyValueHex string) (SecretKey, error) {
+	// Decode K value
+	decodedKeyBytes, err := hex.DecodeString(kValueHex)
+	if err != nil {
+		return SecretKey{}, fmt.Errorf("failed to decode kValueHex %v: %w", kValueHex, err)
+	}
+	// Decode PubKey
+	decodedPubKeyBytes, err := hex.DecodeString(pubKeyValueHex)
+	if err != nil {
+		return SecretKey{}, fmt.Errorf("failed to decode pubKeyValueHex %v: %w", pubKeyValueHex, err)
+	}
+
+	suite, err := ParseCiphersuiteString(ciphersuite)
+	if err != nil {
+		return SecretKey{}, err
+	}
+	key, err := secretKeyFromBytes(ciphersuite, suite, decodedKeyBytes)
+	if err != nil {
+		return SecretKey{}, err
+	}
+	pubKey, err := key.PublicKey().MarshalBinary()
+	if err != nil {
+		return SecretKey{}, fmt.Errorf("failed to serialize public key: %w", err)
+	}
+	if !bytes.Equal(pubKey, decodedPubKeyBytes) {
+		return SecretKey{}, fmt.Errorf("public key does not belong to the secret key")
+	}
+	return key, nil
+}
+
+func secretKeyFromBytes(ciphersuite string, suite oprf.Suite, b []byte) (SecretKey, error) {
+	key := new(oprf.PrivateKey)
+	if err := key.UnmarshalBinary(suite, b); err != nil {
+		return SecretKey{}, fmt.Errorf("failed to deserialize secret key: %w", err)
+	}
+	return SecretKey{Ciphersuite: ciphersuite, key: key}, nil
+}
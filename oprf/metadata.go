@@ -0,0 +1,127 @@
+package oprf
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrMetadataDenied is the cause of an EvaluationError when the evaluator's
+// MetadataPolicy does not allow the caller to request the metadata
+var ErrMetadataDenied = errors.New("OPRF metadata not allowed for caller")
+
+// metadataPrefix separates encoded Metadata from other public inputs
+var metadataPrefix = []byte("gallisto/metadata/v1")
+
+// Metadata is public information bound into p-hat values in the
+// partially-oblivious mode. Tenants sharing an evaluator and key get
+// independent p-hat, and therefore pi, values; so do key epochs of a tenant.
+type Metadata struct {
+	Tenant string
+	Epoch  uint32
+}
+
+// Info returns the public input of the partially-oblivious mode that binds m:
+// a prefix, the tenant as a 2-byte big-endian length and its bytes, and the
+// epoch as a 4-byte big-endian integer
+func (m Metadata) Info() ([]byte, error) {
+	if len(m.Tenant) > math.MaxUint16-len(metadataPrefix)-6 {
+		return nil, fmt.Errorf("tenant of %v bytes is too long", len(m.Tenant))
+	}
+	info := make([]byte, 0, len(metadataPrefix)+2+len(m.Tenant)+4)
+	info = append(info, metadataPrefix...)
+	info = append(info, byte(len(m.Tenant)>>8), byte(len(m.Tenant)))
+	info = append(info, m.Tenant...)
+	info = append(info, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(info[len(info)-4:], m.Epoch)
+	return info, nil
+}
+
+// ParseMetadata decodes the public input created by Metadata.Info
+func ParseMetadata(info []byte) (Metadata, error) {
+	if !bytes.HasPrefix(info, metadataPrefix) {
+		return Metadata{}, fmt.Errorf("public input is not metadata")
+	}
+	rest := info[len(metadataPrefix):]
+	if len(rest) < 2 {
+		return Metadata{}, fmt.Errorf("metadata is truncated")
+	}
+	tenantLength := int(binary.BigEndian.Uint16(rest))
+	rest = rest[2:]
+	if len(rest) != tenantLength+4 {
+		return Metadata{}, fmt.Errorf("metadata has the wrong length")
+	}
+	return Metadata{
+		Tenant: string(rest[:tenantLength]),
+		Epoch:  binary.BigEndian.Uint32(rest[tenantLength:]),
+	}, nil
+}
+
+// WithMetadata binds m into every p-hat value the computer returns. It
+// requires the partially-oblivious mode, and replaces any public input set
+// with WithInfo.
+func WithMetadata(m Metadata) Option {
+	return func(p *PHatComputer) {
+		p.metadata = &m
+	}
+}
+
+// MetadataPolicy decides which metadata a caller may request evaluations for.
+// The caller is identified by the request context, e.g. with WithCallerID.
+type MetadataPolicy interface {
+	// Allow returns nil if the evaluation may go ahead
+	Allow(ctx context.Context, m Metadata) error
+}
+
+// MetadataPolicyFunc adapts a function to a MetadataPolicy
+type MetadataPolicyFunc func(ctx context.Context, m Metadata) error
+
+// Allow calls f
+func (f MetadataPolicyFunc) Allow(ctx context.Context, m Metadata) error {
+	return f(ctx, m)
+}
+
+// TenantPolicy is a MetadataPolicy that lets each caller, as set with
+// WithCallerID, request the tenants it is listed with at the accepted epochs
+type TenantPolicy struct {
+	Tenants map[string][]string // Caller ID to the tenants it may request
+	// Epochs are the key epochs evaluations may be requested for. All epochs
+	// are accepted if it is empty.
+	Epochs []uint32
+}
+
+// Allow implements MetadataPolicy
+func (p TenantPolicy) Allow(ctx context.Context, m Metadata) error {
+	callerID, ok := CallerIDFromContext(ctx)
+	if !ok {
+		return fmt.Errorf("%w: no caller ID", ErrMetadataDenied)
+	}
+	if !containsString(p.Tenants[callerID], m.Tenant) {
+		return fmt.Errorf("%w: caller %q may not request tenant %q", ErrMetadataDenied, callerID, m.Tenant)
+	}
+	if len(p.Epochs) > 0 && !containsEpoch(p.Epochs, m.Epoch) {
+		return fmt.Errorf("%w: epoch %v is not accepted", ErrMetadataDenied, m.Epoch)
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsEpoch(epochs []uint32, epoch uint32) bool {
+	for _, e := range epochs {
+		if e == epoch {
+			return true
+		}
+	}
+	return false
+}
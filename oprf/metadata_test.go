@@ -0,0 +1,126 @@
+package oprf
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var partialConfig = Config{Ciphersuite: P256SHA256, Mode: PartialObliviousMode}
+
+func TestMetadata_Info(t *testing.T) {
+	for _, m := range []Metadata{{}, {Tenant: "university-a", Epoch: 7}, {Tenant: "ü", Epoch: 1<<32 - 1}} {
+		info, err := m.Info()
+		assert.Nil(t, err)
+		parsed, err := ParseMetadata(info)
+		assert.Nil(t, err)
+		assert.Equal(t, m, parsed)
+	}
+
+	info, err := Metadata{Tenant: "a", Epoch: 1}.Info()
+	assert.Nil(t, err)
+	for name, invalid := range map[string][]byte{
+		"raw info":     []byte("test info"),
+		"truncated":    info[:len(info)-1],
+		"extra bytes":  append(append([]byte(nil), info...), 0),
+		"prefix alone": metadataPrefix,
+	} {
+		_, err := ParseMetadata(invalid)
+		assert.NotNil(t, err, name)
+	}
+
+	_, err = Metadata{Tenant: strings.Repeat("a", 1<<16)}.Info()
+	assert.NotNil(t, err)
+}
+
+func TestGetPHatValue_Metadata(t *testing.T) {
+	key := generateKey(t, P256SHA256)
+	pHat := func(m Metadata) []byte {
+		value, err := createPHatComputer(t, partialConfig, key, WithMetadata(m)).GetPHatValue([]byte("perp"))
+		assert.Nil(t, err)
+		return value
+	}
+
+	// Tenants and epochs get independent p-hat values under one key
+	tenantA := pHat(Metadata{Tenant: "a", Epoch: 1})
+	assert.Equal(t, tenantA, pHat(Metadata{Tenant: "a", Epoch: 1}))
+	assert.NotEqual(t, tenantA, pHat(Metadata{Tenant: "b", Epoch: 1}))
+	assert.NotEqual(t, tenantA, pHat(Metadata{Tenant: "a", Epoch: 2}))
+
+	computer := createPHatComputer(t, partialConfig, key, WithMetadata(Metadata{Tenant: "a", Epoch: 1}))
+	m, ok := computer.Metadata()
+	assert.True(t, ok)
+	assert.Equal(t, Metadata{Tenant: "a", Epoch: 1}, m)
+
+	// Metadata is only bound in the partially-oblivious mode
+	_, err := NewPHatComputer(testConfig, createServer(t, testConfig, key), WithMetadata(Metadata{Tenant: "a"}))
+	assert.NotNil(t, err)
+}
+
+func TestOPRFServer_MetadataPolicy(t *testing.T) {
+	key := generateKey(t, P256SHA256)
+	policy := TenantPolicy{
+		Tenants: map[string][]string{"caller-a": {"a"}, "caller-ab": {"a", "b"}},
+		Epochs:  []uint32{2, 3},
+	}
+	server, err := NewOPRFServer(partialConfig, key, WithMetadataPolicy(policy))
+	if err != nil {
+		t.Fatalf("failed to create OPRF server: %v", err)
+	}
+
+	tests := map[string]struct {
+		callerID string
+		opts     []Option
+		allowed  bool
+	}{
+		"allowed tenant":          {callerID: "caller-a", opts: []Option{WithMetadata(Metadata{Tenant: "a", Epoch: 2})}, allowed: true},
+		"second allowed tenant":   {callerID: "caller-ab", opts: []Option{WithMetadata(Metadata{Tenant: "b", Epoch: 3})}, allowed: true},
+		"other tenant":            {callerID: "caller-a", opts: []Option{WithMetadata(Metadata{Tenant: "b", Epoch: 2})}},
+		"epoch not accepted":      {callerID: "caller-a", opts: []Option{WithMetadata(Metadata{Tenant: "a", Epoch: 1})}},
+		"unknown caller":          {callerID: "caller-c", opts: []Option{WithMetadata(Metadata{Tenant: "a", Epoch: 2})}},
+		"no caller ID":            {opts: []Option{WithMetadata(Metadata{Tenant: "a", Epoch: 2})}},
+		"public input not parsed": {callerID: "caller-a", opts: []Option{WithInfo([]byte("a"))}},
+		"no public input":         {callerID: "caller-a"},
+	}
+
+	for testName, tc := range tests {
+		t.Run(testName, func(t *testing.T) {
+			opts := append([]Option{WithPublicKey(server.PublicKey())}, tc.opts...)
+			computer, err := NewPHatComputerContext(partialConfig, server, opts...)
+			if err != nil {
+				t.Fatalf("failed to create p-hat computer: %v", err)
+			}
+			ctx := context.Background()
+			if tc.callerID != "" {
+				ctx = WithCallerID(ctx, tc.callerID)
+			}
+			_, err = computer.GetPHatValueContext(ctx, []byte("perp"))
+			if tc.allowed {
+				assert.Nil(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, ErrOPRFEvaluation))
+			assert.True(t, errors.Is(err, ErrMetadataDenied))
+		})
+	}
+
+	// Errors of other policies are reported as denials
+	denyAll := MetadataPolicyFunc(func(ctx context.Context, m Metadata) error {
+		return errors.New("denied")
+	})
+	server, err = NewOPRFServer(partialConfig, key, WithMetadataPolicy(denyAll))
+	if err != nil {
+		t.Fatalf("failed to create OPRF server: %v", err)
+	}
+	computer, err := NewPHatComputer(partialConfig, server, WithPublicKey(server.PublicKey()), WithMetadata(Metadata{Tenant: "a"}))
+	assert.Nil(t, err)
+	_, err = computer.GetPHatValue([]byte("perp"))
+	assert.True(t, errors.Is(err, ErrMetadataDenied))
+
+	// Policies need the partially-oblivious mode
+	_, err = NewOPRFServer(Config{Ciphersuite: P256SHA256, Mode: VerifiableMode}, key, WithMetadataPolicy(policy))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,231 @@
+package oprf
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/cloudflare/circl/oprf"
+
+	"github.com/ymarcus93/gallisto/internal/detrand"
+)
+
+// EvaluationRequest is sent by a PHatComputer to an OPRFEvaluator
+type EvaluationRequest struct {
+	Elements []GroupElement // Blinded inputs
+	Info     []byte         // Public input of the partially-oblivious mode
+	// Extensions carry data the evaluator needs besides the inputs, such as a
+	// rate-limit token, by name. They are not bound into the evaluation.
+	Extensions map[string][]byte
+}
+
+// Evaluation is an OPRFEvaluator's response to an EvaluationRequest
+type Evaluation struct {
+	Elements []GroupElement // Evaluated elements, in the order of the request
+	Proof    *Proof         // Nil in the base mode
+}
+
+// OPRFEvaluator represents the holder of the OPRF key who can evaluate
+// arbitrary inputs
+type OPRFEvaluator interface {
+	EvaluateOPRF(req EvaluationRequest) (Evaluation, error)
+}
+
+// RequestExtender adds extensions to the requests a PHatComputer sends, e.g.
+// to attach a fresh rate-limit token to every evaluation
+type RequestExtender interface {
+	ExtendRequest(ctx context.Context, req *EvaluationRequest) error
+}
+
+// PHatComputer encapuslates both an OPRF client (the one who has input) and an
+// evaluator (the one who holds the key) which when combined can compute p-hat
+// values
+type PHatComputer struct {
+	config        Config
+	publicKey     *PublicKey
+	info          []byte
+	metadata      *Metadata
+	random        io.Reader // Source of blinds
+	extender      RequestExtender
+	oprfClient    *oprfClient
+	oprfEvaluator ContextOPRFEvaluator
+}
+
+// Option configures optional PHatComputer behaviour
+type Option func(*PHatComputer)
+
+// WithRand makes the PHatComputer draw OPRF blinds from random instead of
+// crypto/rand. P-hat values do not depend on the blinds; only the messages
+// exchanged with the evaluator do. Outside of tests, NewPHatComputer refuses
+// any reader but crypto/rand's.
+func WithRand(random io.Reader) Option {
+	return func(p *PHatComputer) {
+		p.random = random
+	}
+}
+
+// WithPublicKey sets the evaluator's public key. It is required in the
+// verifiable and partially-oblivious modes, where evaluations that do not
+// prove the use of its secret key are rejected.
+func WithPublicKey(publicKey PublicKey) Option {
+	return func(p *PHatComputer) {
+		p.publicKey = &publicKey
+	}
+}
+
+// WithInfo sets the public input of the partially-oblivious mode. It is bound
+// into every p-hat value, so values computed with different inputs never
+// match. The default is empty.
+func WithInfo(info []byte) Option {
+	return func(p *PHatComputer) {
+		p.info = info
+	}
+}
+
+// WithRequestExtender makes the computer pass every evaluation request to
+// extender before sending it. A request is not sent if extender fails.
+func WithRequestExtender(extender RequestExtender) Option {
+	return func(p *PHatComputer) {
+		p.extender = extender
+	}
+}
+
+// Returns a computer that can compute p-hat values. P-hat values are computed
+// by using an OPRF set up with config
+func NewPHatComputer(config Config, oprfEvaluator OPRFEvaluator, opts ...Option) (*PHatComputer, error) {
+	return NewPHatComputerContext(config, WithContext(oprfEvaluator), opts...)
+}
+
+// NewPHatComputerContext returns a computer that can compute p-hat values using
+// an evaluator that takes a context, such as a remote OPRF server
+func NewPHatComputerContext(config Config, oprfEvaluator ContextOPRFEvaluator, opts ...Option) (*PHatComputer, error) {
+	p := &PHatComputer{
+		config:        config,
+		random:        rand.Reader,
+		oprfEvaluator: oprfEvaluator,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if err := detrand.Check(p.random); err != nil {
+		return nil, fmt.Errorf("invalid source of randomness: %w", err)
+	}
+	if p.metadata != nil {
+		if config.Mode != PartialObliviousMode {
+			return nil, fmt.Errorf("metadata requires the %v mode", PartialObliviousMode)
+		}
+		info, err := p.metadata.Info()
+		if err != nil {
+			return nil, err
+		}
+		p.info = info
+	}
+
+	oprfClient, err := newOPRFClient(config, p.publicKey)
+	if err != nil {
+		return nil, err
+	}
+	p.oprfClient = oprfClient
+	return p, nil
+}
+
+// Config returns the OPRF setup the computer was created with
+func (p *PHatComputer) Config() Config {
+	return p.config
+}
+
+// Metadata returns the metadata bound into p-hat values, if set with
+// WithMetadata
+func (p *PHatComputer) Metadata() (Metadata, bool) {
+	if p.metadata == nil {
+		return Metadata{}, false
+	}
+	return *p.metadata, true
+}
+
+// PublicKey returns the evaluator's public key that evaluations are verified
+// against. There is none in the base mode, whose evaluations are not verified.
+func (p *PHatComputer) PublicKey() (PublicKey, bool) {
+	if p.config.Mode == BaseMode || p.publicKey == nil {
+		return PublicKey{}, false
+	}
+	return *p.publicKey, true
+}
+
+// GetPHatValue asks an OPRF evaluator to transform a low-entropy perpetrator ID
+// into a pseudorandom value with sufficient entropy
+func (p *PHatComputer) GetPHatValue(perpID []byte) ([]byte, error) {
+	return p.GetPHatValueContext(context.Background(), perpID)
+}
+
+// GetPHatValueContext is like GetPHatValue but passes ctx on to the OPRF
+// evaluator, so that the evaluation can be given a deadline or cancelled
+func (p *PHatComputer) GetPHatValueContext(ctx context.Context, perpID []byte) ([]byte, error) {
+	pHats, err := p.GetPHatValuesContext(ctx, [][]byte{perpID})
+	if err != nil {
+		return nil, err
+	}
+	return pHats[0], nil
+}
+
+// GetPHatValues computes the p-hat values of several inputs with a single
+// evaluation request. In the verifiable and partially-oblivious modes the
+// evaluator proves all evaluations at once.
+func (p *PHatComputer) GetPHatValues(perpIDs [][]byte) ([][]byte, error) {
+	return p.GetPHatValuesContext(context.Background(), perpIDs)
+}
+
+// GetPHatValuesContext is like GetPHatValues but passes ctx on to the OPRF
+// evaluator
+func (p *PHatComputer) GetPHatValuesContext(ctx context.Context, perpIDs [][]byte) ([][]byte, error) {
+	if len(perpIDs) == 0 {
+		return nil, fmt.Errorf("no inputs")
+	}
+	// Sample the blinds ourselves so that the source of randomness can be
+	// chosen
+	blinds := make([]oprf.Blind, len(perpIDs))
+	for i := range blinds {
+		blinds[i] = p.oprfClient.suite.Group().RandomNonZeroScalar(p.random)
+	}
+	return p.computePHats(ctx, perpIDs, blinds)
+}
+
+// computePHat computes the p-hat value of perpID with the blind r
+func (p *PHatComputer) computePHat(ctx context.Context, perpID []byte, r oprf.Blind) ([]byte, error) {
+	pHats, err := p.computePHats(ctx, [][]byte{perpID}, []oprf.Blind{r})
+	if err != nil {
+		return nil, err
+	}
+	return pHats[0], nil
+}
+
+// computePHats computes the p-hat values of perpIDs, blinding each with the
+// scalar of the same index in blinds
+func (p *PHatComputer) computePHats(ctx context.Context, perpIDs [][]byte, blinds []oprf.Blind) ([][]byte, error) {
+	// Create blinded group elements
+	finData, evalReq, err := p.oprfClient.blind(perpIDs, blinds)
+	if err != nil {
+		return nil, err
+	}
+
+	req := EvaluationRequest{Elements: evalReq.Elements, Info: p.info}
+	if p.extender != nil {
+		if err := p.extender.ExtendRequest(ctx, &req); err != nil {
+			return nil, fmt.Errorf("failed to extend evaluation request: %w", err)
+		}
+	}
+
+	// Evaluate the OPRF
+	eval, err := p.oprfEvaluator.EvaluateOPRFContext(ctx, req)
+	if err != nil {
+		if errors.Is(err, ErrOPRFEvaluation) {
+			return nil, err
+		}
+		return nil, &EvaluationError{Err: err}
+	}
+
+	// Verify, unblind and finalize to get the resulting p-hats
+	return p.oprfClient.finalize(finData, eval, p.info, len(perpIDs))
+}
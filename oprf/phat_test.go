@@ -0,0 +1,300 @@
+package oprf
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testConfig = Config{Ciphersuite: P256SHA256}
+
+func generateKey(t *testing.T, ciphersuite string) SecretKey {
+	key, err := GenerateKey(ciphersuite)
+	if err != nil {
+		t.Fatalf("failed to generate OPRF key: %v", err)
+	}
+	return key
+}
+
+func createServer(t *testing.T, config Config, key SecretKey) *OPRFServer {
+	server, err := NewOPRFServer(config, key)
+	if err != nil {
+		t.Fatalf("failed to create OPRF server: %v", err)
+	}
+	return server
+}
+
+// createPHatComputer returns a computer evaluating the OPRF with a server
+// using key. The computer is given the server's public key.
+func createPHatComputer(t *testing.T, config Config, key SecretKey, opts ...Option) *PHatComputer {
+	server := createServer(t, config, key)
+	opts = append([]Option{WithPublicKey(server.PublicKey())}, opts...)
+	computer, err := NewPHatComputer(config, server, opts...)
+	if err != nil {
+		t.Fatalf("failed to create p-hat computer: %v", err)
+	}
+	return computer
+}
+
+func TestGetPHatValue(t *testing.T) {
+	outputSizes := map[string]int{
+		P256SHA256:         32,
+		P384SHA384:         48,
+		P521SHA512:         64,
+		Ristretto255SHA512: 64,
+	}
+	for _, ciphersuite := range Ciphersuites() {
+		for _, mode := range []Mode{BaseMode, VerifiableMode, PartialObliviousMode} {
+			config := Config{Ciphersuite: ciphersuite, Mode: mode}
+			t.Run(ciphersuite+"/"+mode.String(), func(t *testing.T) {
+				key := generateKey(t, ciphersuite)
+				computer := createPHatComputer(t, config, key)
+				pHat, err := computer.GetPHatValue([]byte("perp"))
+				assert.Nil(t, err)
+				assert.Len(t, pHat, outputSizes[ciphersuite])
+
+				// Blinding is random, yet the output only depends on the key and input
+				again, err := computer.GetPHatValue([]byte("perp"))
+				assert.Nil(t, err)
+				assert.Equal(t, pHat, again)
+
+				other, err := computer.GetPHatValue([]byte("other perp"))
+				assert.Nil(t, err)
+				assert.NotEqual(t, pHat, other)
+
+				otherKeyPHat, err := createPHatComputer(t, config, generateKey(t, ciphersuite)).GetPHatValue([]byte("perp"))
+				assert.Nil(t, err)
+				assert.NotEqual(t, pHat, otherKeyPHat)
+			})
+		}
+	}
+}
+
+func TestGetPHatValue_Modes(t *testing.T) {
+	key := generateKey(t, P256SHA256)
+	pHat := func(mode Mode, opts ...Option) []byte {
+		config := Config{Ciphersuite: P256SHA256, Mode: mode}
+		value, err := createPHatComputer(t, config, key, opts...).GetPHatValue([]byte("perp"))
+		assert.Nil(t, err)
+		return value
+	}
+
+	// The mode and public input are bound into the output
+	base := pHat(BaseMode)
+	verifiable := pHat(VerifiableMode)
+	partial := pHat(PartialObliviousMode)
+	partialWithInfo := pHat(PartialObliviousMode, WithInfo([]byte("info")))
+	assert.NotEqual(t, base, verifiable)
+	assert.NotEqual(t, verifiable, partial)
+	assert.NotEqual(t, partial, partialWithInfo)
+	assert.Equal(t, partialWithInfo, pHat(PartialObliviousMode, WithInfo([]byte("info"))))
+}
+
+func TestGetPHatValue_Verification(t *testing.T) {
+	key := generateKey(t, P256SHA256)
+	otherKey := generateKey(t, P256SHA256)
+
+	tests := map[string]struct {
+		config    Config
+		opts      []Option
+		createErr bool
+	}{
+		"verifiable mode without a public key": {
+			config:    Config{Ciphersuite: P256SHA256, Mode: VerifiableMode},
+			createErr: true,
+		},
+		"public key of another ciphersuite": {
+			config:    Config{Ciphersuite: P256SHA256, Mode: VerifiableMode},
+			opts:      []Option{WithPublicKey(generateKey(t, P384SHA384).PublicKey())},
+			createErr: true,
+		},
+		"verifiable mode with another public key": {
+			config: Config{Ciphersuite: P256SHA256, Mode: VerifiableMode},
+			opts:   []Option{WithPublicKey(otherKey.PublicKey())},
+		},
+		"partially-oblivious mode with another public key": {
+			config: Config{Ciphersuite: P256SHA256, Mode: PartialObliviousMode},
+			opts:   []Option{WithPublicKey(otherKey.PublicKey())},
+		},
+	}
+
+	for testName, tc := range tests {
+		t.Run(testName, func(t *testing.T) {
+			computer, err := NewPHatComputer(tc.config, createServer(t, tc.config, key), tc.opts...)
+			if tc.createErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			_, err = computer.GetPHatValue([]byte("perp"))
+			assert.True(t, errors.Is(err, ErrOPRFEvaluation))
+			assert.True(t, errors.Is(err, ErrInvalidProof))
+		})
+	}
+}
+
+func TestOPRFServer_InvalidRequests(t *testing.T) {
+	key := generateKey(t, P256SHA256)
+	server := createServer(t, testConfig, key)
+	p384, err := ParseCiphersuiteString(P384SHA384)
+	if err != nil {
+		t.Fatalf("failed to parse ciphersuite: %v", err)
+	}
+
+	_, err = NewOPRFServer(Config{Ciphersuite: P384SHA384}, key)
+	assert.NotNil(t, err)
+	_, err = NewOPRFServer(Config{Ciphersuite: "OPRF-P521-HKDF-SHA512-SSWU-RO"}, key)
+	assert.NotNil(t, err)
+	_, err = NewOPRFServer(Config{Ciphersuite: P256SHA256, Mode: 3}, key)
+	assert.NotNil(t, err)
+
+	tests := map[string]EvaluationRequest{
+		"no elements":              {},
+		"element of another group": {Elements: []GroupElement{p384.Group().Generator()}},
+		"identity element":         {Elements: []GroupElement{server.suite.Group().Identity()}},
+		"public input":             {Elements: []GroupElement{server.suite.Group().Generator()}, Info: []byte("info")},
+	}
+	for testName, req := range tests {
+		t.Run(testName, func(t *testing.T) {
+			_, err := server.EvaluateOPRF(req)
+			assert.True(t, errors.Is(err, ErrOPRFEvaluation))
+		})
+	}
+}
+
+func TestKeyHexRoundTrip(t *testing.T) {
+	key := generateKey(t, P256SHA256)
+	server := createServer(t, testConfig, key)
+
+	kHex, pubKeyHex, err := server.KeyToHex()
+	assert.Nil(t, err)
+	decodedKey, err := KeyFromHex(P256SHA256, kHex, pubKeyHex)
+	assert.Nil(t, err)
+
+	want, err := createPHatComputer(t, testConfig, key).GetPHatValue([]byte("perp"))
+	assert.Nil(t, err)
+	got, err := createPHatComputer(t, testConfig, decodedKey).GetPHatValue([]byte("perp"))
+	assert.Nil(t, err)
+	assert.Equal(t, want, got)
+
+	// The public key must belong to the secret key
+	_, otherPubKeyHex, err := generateKey(t, P256SHA256).KeyToHex()
+	assert.Nil(t, err)
+	_, err = KeyFromHex(P256SHA256, kHex, otherPubKeyHex)
+	assert.NotNil(t, err)
+}
+
+// recordingEvaluator is a ContextOPRFEvaluator that records the caller ID of
+// the last evaluation
+type recordingEvaluator struct {
+	evaluator OPRFEvaluator
+	callerID  string
+}
+
+func (r *recordingEvaluator) EvaluateOPRFContext(ctx context.Context, req EvaluationRequest) (Evaluation, error) {
+	r.callerID, _ = CallerIDFromContext(ctx)
+	return r.evaluator.EvaluateOPRF(req)
+}
+
+func TestGetPHatValueContext(t *testing.T) {
+	key := generateKey(t, P256SHA256)
+	server := createServer(t, testConfig, key)
+
+	// Request-scoped metadata reaches the evaluator
+	evaluator := &recordingEvaluator{evaluator: server}
+	computer, err := NewPHatComputerContext(testConfig, evaluator)
+	assert.Nil(t, err)
+	pHat, err := computer.GetPHatValueContext(WithCallerID(context.Background(), "caller"), []byte("perp"))
+	assert.Nil(t, err)
+	assert.Equal(t, "caller", evaluator.callerID)
+
+	// The context variant computes the same value as the in-process API
+	want, err := createPHatComputer(t, testConfig, key).GetPHatValue([]byte("perp"))
+	assert.Nil(t, err)
+	assert.Equal(t, want, pHat)
+
+	// Evaluators adapted from the in-process API do not evaluate once the
+	// context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for name, evaluator := range map[string]OPRFEvaluator{"OPRFServer": server, "adapted": struct{ OPRFEvaluator }{server}} {
+		computer, err := NewPHatComputer(testConfig, evaluator)
+		assert.Nil(t, err)
+		_, err = computer.GetPHatValueContext(ctx, []byte("perp"))
+		assert.True(t, errors.Is(err, ErrOPRFEvaluation), name)
+		assert.True(t, errors.Is(err, context.Canceled), name)
+	}
+}
+
+func TestGetPHatValues(t *testing.T) {
+	config := Config{Ciphersuite: P256SHA256, Mode: VerifiableMode}
+	key := generateKey(t, P256SHA256)
+	computer := createPHatComputer(t, config, key)
+
+	pHats, err := computer.GetPHatValues([][]byte{[]byte("a"), []byte("b")})
+	assert.Nil(t, err)
+	assert.Len(t, pHats, 2)
+	for i, input := range []string{"a", "b"} {
+		pHat, err := computer.GetPHatValue([]byte(input))
+		assert.Nil(t, err)
+		assert.Equal(t, pHat, pHats[i])
+		assert.True(t, createServer(t, config, key).VerifyOutput([]byte(input), nil, pHat))
+	}
+	assert.False(t, createServer(t, config, key).VerifyOutput([]byte("a"), nil, pHats[1]))
+
+	_, err = computer.GetPHatValues(nil)
+	assert.NotNil(t, err)
+}
+
+type extensionSetter string
+
+func (e extensionSetter) ExtendRequest(ctx context.Context, req *EvaluationRequest) error {
+	if e == "" {
+		return errors.New("no extension")
+	}
+	req.Extensions = map[string][]byte{"test": []byte(e)}
+	return nil
+}
+
+type extensionChecker struct{}
+
+func (extensionChecker) CheckRequest(ctx context.Context, req EvaluationRequest) error {
+	if string(req.Extensions["test"]) != "paid" {
+		return errors.New("not paid")
+	}
+	return nil
+}
+
+func TestRequestExtensions(t *testing.T) {
+	key := generateKey(t, P256SHA256)
+	server, err := NewOPRFServer(testConfig, key, WithRequestChecker(extensionChecker{}))
+	if err != nil {
+		t.Fatalf("failed to create OPRF server: %v", err)
+	}
+
+	tests := map[string]struct {
+		opts    []Option
+		allowed bool
+	}{
+		"accepted extension": {opts: []Option{WithRequestExtender(extensionSetter("paid"))}, allowed: true},
+		"rejected extension": {opts: []Option{WithRequestExtender(extensionSetter("unpaid"))}},
+		"extender fails":     {opts: []Option{WithRequestExtender(extensionSetter(""))}},
+		"no extension":       {},
+	}
+	for testName, tc := range tests {
+		t.Run(testName, func(t *testing.T) {
+			computer, err := NewPHatComputer(testConfig, server, tc.opts...)
+			if err != nil {
+				t.Fatalf("failed to create p-hat computer: %v", err)
+			}
+			_, err = computer.GetPHatValue([]byte("perp"))
+			if tc.allowed {
+				assert.Nil(t, err)
+			} else {
+				assert.NotNil(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package oprf
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// elementRecorder records the blinded and evaluated elements of the last
+// evaluation
+type elementRecorder struct {
+	evaluator OPRFEvaluator
+	blinded   []byte
+	evaluated []byte
+}
+
+func (r *elementRecorder) EvaluateOPRF(req EvaluationRequest) (Evaluation, error) {
+	eval, err := r.evaluator.EvaluateOPRF(req)
+	if err != nil {
+		return Evaluation{}, err
+	}
+	if r.blinded, err = req.Elements[0].MarshalBinaryCompress(); err != nil {
+		return Evaluation{}, err
+	}
+	if r.evaluated, err = eval.Elements[0].MarshalBinaryCompress(); err != nil {
+		return Evaluation{}, err
+	}
+	return eval, nil
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("failed to decode hex %q: %v", s, err)
+	}
+	return b
+}
+
+// TestRFC9497Vectors checks the first test vector of every supported
+// ciphersuite and mode in appendix A of RFC 9497
+func TestRFC9497Vectors(t *testing.T) {
+	seed := bytes.Repeat([]byte{0xa3}, 32)
+	keyInfo := []byte("test key")
+	input := []byte{0x00}
+
+	tests := map[string]struct {
+		ciphersuite string
+		mode        Mode
+		skSm        string
+		blind       string
+		info        string
+		blinded     string
+		evaluated   string
+		output      string
+	}{
+		"ristretto255-SHA512/base": {
+			ciphersuite: Ristretto255SHA512,
+			mode:        BaseMode,
+			skSm:        "5ebcea5ee37023ccb9fc2d2019f9d7737be85591ae8652ffa9ef0f4d37063b0e",
+			blind:       "64d37aed22a27f5191de1c1d69fadb899d8862b58eb4220029e036ec4c1f6706",
+			blinded:     "609a0ae68c15a3cf6903766461307e5c8bb2f95e7e6550e1ffa2dc99e412803c",
+			evaluated:   "7ec6578ae5120958eb2db1745758ff379e77cb64fe77b0b2d8cc917ea0869c7e",
+			output:      "527759c3d9366f277d8c6020418d96bb393ba2afb20ff90df23fb7708264e2f3ab9135e3bd69955851de4b1f9fe8a0973396719b7912ba9ee8aa7d0b5e24bcf6",
+		},
+		"ristretto255-SHA512/verifiable": {
+			ciphersuite: Ristretto255SHA512,
+			mode:        VerifiableMode,
+			skSm:        "e6f73f344b79b379f1a0dd37e07ff62e38d9f71345ce62ae3a9bc60b04ccd909",
+			blind:       "64d37aed22a27f5191de1c1d69fadb899d8862b58eb4220029e036ec4c1f6706",
+			blinded:     "863f330cc1a1259ed5a5998a23acfd37fb4351a793a5b3c090b642ddc439b945",
+			evaluated:   "aa8fa048764d5623868679402ff6108d2521884fa138cd7f9c7669a9a014267e",
+			output:      "b58cfbe118e0cb94d79b5fd6a6dafb98764dff49c14e1770b566e42402da1a7da4d8527693914139caee5bd03903af43a491351d23b430948dd50cde10d32b3c",
+		},
+		"ristretto255-SHA512/partially-oblivious": {
+			ciphersuite: Ristretto255SHA512,
+			mode:        PartialObliviousMode,
+			skSm:        "145c79c108538421ac164ecbe131942136d5570b16d8bf41a24d4337da981e07",
+			blind:       "64d37aed22a27f5191de1c1d69fadb899d8862b58eb4220029e036ec4c1f6706",
+			info:        "7465737420696e666f",
+			blinded:     "c8713aa89241d6989ac142f22dba30596db635c772cbf25021fdd8f3d461f715",
+			evaluated:   "1a4b860d808ff19624731e67b5eff20ceb2df3c3c03b906f5693e2078450d874",
+			output:      "ca688351e88afb1d841fde4401c79efebb2eb75e7998fa9737bd5a82a152406d38bd29f680504e54fd4587eddcf2f37a2617ac2fbd2993f7bdf45442ace7d221",
+		},
+		"P256-SHA256/base": {
+			ciphersuite: P256SHA256,
+			mode:        BaseMode,
+			skSm:        "159749d750713afe245d2d39ccfaae8381c53ce92d098a9375ee70739c7ac0bf",
+			blind:       "3338fa65ec36e0290022b48eb562889d89dbfa691d1cde91517fa222ed7ad364",
+			blinded:     "03723a1e5c09b8b9c18d1dcbca29e8007e95f14f4732d9346d490ffc195110368d",
+			evaluated:   "030de02ffec47a1fd53efcdd1c6faf5bdc270912b8749e783c7ca75bb412958832",
+			output:      "a0b34de5fa4c5b6da07e72af73cc507cceeb48981b97b7285fc375345fe495dd",
+		},
+		"P256-SHA256/verifiable": {
+			ciphersuite: P256SHA256,
+			mode:        VerifiableMode,
+			skSm:        "ca5d94c8807817669a51b196c34c1b7f8442fde4334a7121ae4736364312fca6",
+			blind:       "3338fa65ec36e0290022b48eb562889d89dbfa691d1cde91517fa222ed7ad364",
+			blinded:     "02dd05901038bb31a6fae01828fd8d0e49e35a486b5c5d4b4994013648c01277da",
+			evaluated:   "0209f33cab60cf8fe69239b0afbcfcd261af4c1c5632624f2e9ba29b90ae83e4a2",
+			output:      "0412e8f78b02c415ab3a288e228978376f99927767ff37c5718d420010a645a1",
+		},
+		"P256-SHA256/partially-oblivious": {
+			ciphersuite: P256SHA256,
+			mode:        PartialObliviousMode,
+			skSm:        "6ad2173efa689ef2c27772566ad7ff6e2d59b3b196f00219451fb2c89ee4dae2",
+			blind:       "3338fa65ec36e0290022b48eb562889d89dbfa691d1cde91517fa222ed7ad364",
+			info:        "7465737420696e666f",
+			blinded:     "031563e127099a8f61ed51eeede05d747a8da2be329b40ba1f0db0b2bd9dd4e2c0",
+			evaluated:   "02c5e5300c2d9e6ba7f3f4ad60500ad93a0157e6288eb04b67e125db024a2c74d2",
+			output:      "193a92520bd8fd1f37accb918040a57108daa110dc4f659abe212636d245c592",
+		},
+		"P384-SHA384/base": {
+			ciphersuite: P384SHA384,
+			mode:        BaseMode,
+			skSm:        "dfe7ddc41a4646901184f2b432616c8ba6d452f9bcd0c4f75a5150ef2b2ed02ef40b8b92f60ae591bcabd72a6518f188",
+			blind:       "504650f53df8f16f6861633388936ea23338fa65ec36e0290022b48eb562889d89dbfa691d1cde91517fa222ed7ad364",
+			blinded:     "02a36bc90e6db34096346eaf8b7bc40ee1113582155ad3797003ce614c835a874343701d3f2debbd80d97cbe45de6e5f1f",
+			evaluated:   "03af2a4fc94770d7a7bf3187ca9cc4faf3732049eded2442ee50fbddda58b70ae2999366f72498cdbc43e6f2fc184afe30",
+			output:      "ed84ad3f31a552f0456e58935fcc0a3039db42e7f356dcb32aa6d487b6b815a07d5813641fb1398c03ddab5763874357",
+		},
+		"P384-SHA384/verifiable": {
+			ciphersuite: P384SHA384,
+			mode:        VerifiableMode,
+			skSm:        "051646b9e6e7a71ae27c1e1d0b87b4381db6d3595eeeb1adb41579adbf992f4278f9016eafc944edaa2b43183581779d",
+			blind:       "504650f53df8f16f6861633388936ea23338fa65ec36e0290022b48eb562889d89dbfa691d1cde91517fa222ed7ad364",
+			blinded:     "02d338c05cbecb82de13d6700f09cb61190543a7b7e2c6cd4fca56887e564ea82653b27fdad383995ea6d02cf26d0e24d9",
+			evaluated:   "02a7bba589b3e8672aa19e8fd258de2e6aae20101c8d761246de97a6b5ee9cf105febce4327a326255a3c604f63f600ef6",
+			output:      "3333230886b562ffb8329a8be08fea8025755372817ec969d114d1203d026b4a622beab60220bf19078bca35a529b35c",
+		},
+		"P384-SHA384/partially-oblivious": {
+			ciphersuite: P384SHA384,
+			mode:        PartialObliviousMode,
+			skSm:        "5b2690d6954b8fbb159f19935d64133f12770c00b68422559c65431942d721ff79d47d7a75906c30b7818ec0f38b7fb2",
+			blind:       "504650f53df8f16f6861633388936ea23338fa65ec36e0290022b48eb562889d89dbfa691d1cde91517fa222ed7ad364",
+			info:        "7465737420696e666f",
+			blinded:     "03859b36b95e6564faa85cd3801175eda2949707f6aa0640ad093cbf8ad2f58e762f08b56b2a1b42a64953aaf49cbf1ae3",
+			evaluated:   "0220710e2e00306453f5b4f574cb6a512453f35c45080d09373e190c19ce5b185914fbf36582d7e0754bb7c8b683205b91",
+			output:      "0188653cfec38119a6c7dd7948b0f0720460b4310e40824e048bf82a16527303ed449a08caf84272c3bbc972ede797df",
+		},
+		"P521-SHA512/base": {
+			ciphersuite: P521SHA512,
+			mode:        BaseMode,
+			skSm:        "0153441b8faedb0340439036d6aed06d1217b34c42f17f8db4c5cc610a4a955d698a688831b16d0dc7713a1aa3611ec60703bffc7dc9c84e3ed673b3dbe1d5fccea6",
+			blind:       "00d1dccf7a51bafaf75d4a866d53d8cafe4d504650f53df8f16f6861633388936ea23338fa65ec36e0290022b48eb562889d89dbfa691d1cde91517fa222ed7ad364",
+			blinded:     "0300e78bf846b0e1e1a3c320e353d758583cd876df56100a3a1e62bacba470fa6e0991be1be80b721c50c5fd0c672ba764457acc18c6200704e9294fbf28859d916351",
+			evaluated:   "030166371cf827cb2fb9b581f97907121a16e2dc5d8b10ce9f0ede7f7d76a0d047657735e8ad07bcda824907b3e5479bd72cdef6b839b967ba5c58b118b84d26f2ba07",
+			output:      "26232de6fff83f812adadadb6cc05d7bbeee5dca043dbb16b03488abb9981d0a1ef4351fad52dbd7e759649af393348f7b9717566c19a6b8856284d69375c809",
+		},
+		"P521-SHA512/verifiable": {
+			ciphersuite: P521SHA512,
+			mode:        VerifiableMode,
+			skSm:        "015c7fc1b4a0b1390925bae915bd9f3d72009d44d9241b962428aad5d13f22803311e7102632a39addc61ea440810222715c9d2f61f03ea424ec9ab1fe5e31cf9238",
+			blind:       "00d1dccf7a51bafaf75d4a866d53d8cafe4d504650f53df8f16f6861633388936ea23338fa65ec36e0290022b48eb562889d89dbfa691d1cde91517fa222ed7ad364",
+			blinded:     "0301d6e4fb545e043ddb6aee5d5ceeee1b44102615ab04430c27dd0f56988dedcb1df32ef384f160e0e76e718605f14f3f582f9357553d153b996795b4b3628a4f6380",
+			evaluated:   "03013fdeaf887f3d3d283a79e696a54b66ff0edcb559265e204a958acf840e0930cc147e2a6835148d8199eebc26c03e9394c9762a1c991dde40bca0f8ca003eefb045",
+			output:      "5e003d9b2fb540b3d4bab5fedd154912246da1ee5e557afd8f56415faa1a0fadff6517da802ee254437e4f60907b4cda146e7ba19e249eef7be405549f62954b",
+		},
+		"P521-SHA512/partially-oblivious": {
+			ciphersuite: P521SHA512,
+			mode:        PartialObliviousMode,
+			skSm:        "014893130030ce69cf714f536498a02ff6b396888f9bb507985c32928c4427d6d39de10ef509aca4240e8569e3a88debc0d392e3361bcd934cb9bdd59e339dff7b27",
+			blind:       "00d1dccf7a51bafaf75d4a866d53d8cafe4d504650f53df8f16f6861633388936ea23338fa65ec36e0290022b48eb562889d89dbfa691d1cde91517fa222ed7ad364",
+			info:        "7465737420696e666f",
+			blinded:     "020095cff9d7ecf65bdfee4ea92d6e748d60b02de34ad98094f82e25d33a8bf50138ccc2cc633556f1a97d7ea9438cbb394df612f041c485a515849d5ebb2238f2f0e2",
+			evaluated:   "0301408e9c5be3ffcc1c16e5ae8f8aa68446223b0804b11962e856af5a6d1c65ebbb5db7278c21db4e8cc06d89a35b6804fb1738a295b691638af77aa1327253f26d01",
+			output:      "808ae5b87662eaaf0b39151dd85991b94c96ef214cb14a68bf5c143954882d330da8953a80eea20788e552bc8bbbfff3100e89f9d6e341197b122c46a208733b",
+		},
+	}
+
+	for testName, tc := range tests {
+		t.Run(testName, func(t *testing.T) {
+			key, err := DeriveKey(tc.ciphersuite, tc.mode, seed, keyInfo)
+			if err != nil {
+				t.Fatalf("failed to derive key: %v", err)
+			}
+			sk, err := key.MarshalBinary()
+			assert.Nil(t, err)
+			assert.Equal(t, tc.skSm, hex.EncodeToString(sk))
+
+			config := Config{Ciphersuite: tc.ciphersuite, Mode: tc.mode}
+			evaluator := &elementRecorder{evaluator: createServer(t, config, key)}
+			computer, err := NewPHatComputer(config, evaluator, WithPublicKey(key.PublicKey()), WithInfo(decodeHex(t, tc.info)))
+			if err != nil {
+				t.Fatalf("failed to create p-hat computer: %v", err)
+			}
+			blind := computer.oprfClient.suite.Group().NewScalar()
+			if err := blind.UnmarshalBinary(decodeHex(t, tc.blind)); err != nil {
+				t.Fatalf("failed to decode blind: %v", err)
+			}
+
+			output, err := computer.computePHat(context.Background(), input, blind)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.blinded, hex.EncodeToString(evaluator.blinded))
+			assert.Equal(t, tc.evaluated, hex.EncodeToString(evaluator.evaluated))
+			assert.Equal(t, tc.output, hex.EncodeToString(output))
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package oprf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cloudflare/circl/oprf"
+)
+
+// OPRFServer performs the server-side (prover) operations of the OPRF protocol
+// and holds the OPRF secret key. It implements OPRFEvaluator
+type OPRFServer struct {
+	Ciphersuite string
+	config      Config
+	suite       oprf.Suite
+	key         SecretKey
+	policy      MetadataPolicy
+	checker     RequestChecker
+
+	base             oprf.Server
+	verifiable       oprf.VerifiableServer
+	partialOblivious oprf.PartialObliviousServer
+}
+
+// ServerOption configures optional OPRFServer behaviour
+type ServerOption func(*OPRFServer)
+
+// WithMetadataPolicy makes the server only evaluate requests whose public input
+// is Metadata that policy allows. It requires the partially-oblivious mode.
+func WithMetadataPolicy(policy MetadataPolicy) ServerOption {
+	return func(s *OPRFServer) {
+		s.policy = policy
+	}
+}
+
+// RequestChecker vets evaluation requests before an OPRFServer evaluates them,
+// e.g. by redeeming a rate-limit token from their extensions
+type RequestChecker interface {
+	// CheckRequest returns nil if req may be evaluated
+	CheckRequest(ctx context.Context, req EvaluationRequest) error
+}
+
+// WithRequestChecker makes the server only evaluate requests that checker
+// accepts. Requests are checked after they are validated and allowed by any
+// metadata policy.
+func WithRequestChecker(checker RequestChecker) ServerOption {
+	return func(s *OPRFServer) {
+		s.checker = checker
+	}
+}
+
+// NewOPRFServer returns an OPRF server that can perform server-side operations
+// (prover) of the OPRF protocol in the mode of config. The secret key must be
+// of the config's ciphersuite.
+func NewOPRFServer(config Config, secretKey SecretKey, opts ...ServerOption) (*OPRFServer, error) {
+	suite, err := config.validate()
+	if err != nil {
+		return nil, err
+	}
+	if secretKey.key == nil {
+		return nil, fmt.Errorf("secret key is not set")
+	}
+	if secretKey.Ciphersuite != config.Ciphersuite {
+		return nil, fmt.Errorf("secret key of ciphersuite %v cannot be used with %v", secretKey.Ciphersuite, config.Ciphersuite)
+	}
+
+	s := &OPRFServer{
+		Ciphersuite: config.Ciphersuite,
+		config:      config,
+		suite:       suite,
+		key:         secretKey,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.policy != nil && config.Mode != PartialObliviousMode {
+		return nil, fmt.Errorf("a metadata policy requires the %v mode", PartialObliviousMode)
+	}
+	switch config.Mode {
+	case BaseMode:
+		s.base = oprf.NewServer(suite, secretKey.key)
+	case VerifiableMode:
+		s.verifiable = oprf.NewVerifiableServer(suite, secretKey.key)
+	case PartialObliviousMode:
+		s.partialOblivious = oprf.NewPartialObliviousServer(suite, secretKey.key)
+	}
+	return s, nil
+}
+
+// Config returns the OPRF setup of the server, to be shared with its clients
+func (s *OPRFServer) Config() Config {
+	return s.config
+}
+
+// PublicKey returns the public key of the server, to be shared with its
+// clients in the verifiable and partially-oblivious modes
+func (s *OPRFServer) PublicKey() PublicKey {
+	return s.key.PublicKey()
+}
+
+// EvaluateOPRF evaluates all blinded inputs of req with the server's secret
+// key. The public input of req must be empty unless the server is in the
+// partially-oblivious mode. A metadata policy is given context.Background(),
+// which identifies no caller; use EvaluateOPRFContext instead.
+func (s *OPRFServer) EvaluateOPRF(req EvaluationRequest) (Evaluation, error) {
+	return s.evaluate(context.Background(), req)
+}
+
+// EvaluateOPRFContext is like EvaluateOPRF but fails without evaluating once
+// ctx is done. A metadata policy is given ctx to identify the caller.
+func (s *OPRFServer) EvaluateOPRFContext(ctx context.Context, req EvaluationRequest) (Evaluation, error) {
+	if err := ctx.Err(); err != nil {
+		return Evaluation{}, err
+	}
+	return s.evaluate(ctx, req)
+}
+
+func (s *OPRFServer) evaluate(ctx context.Context, req EvaluationRequest) (Evaluation, error) {
+	if len(req.Elements) == 0 {
+		return Evaluation{}, &EvaluationError{Err: fmt.Errorf("no blinded inputs")}
+	}
+	if err := checkElements(s.suite.Group(), req.Elements); err != nil {
+		return Evaluation{}, &EvaluationError{Err: fmt.Errorf("invalid blinded input: %w", err)}
+	}
+	if len(req.Info) != 0 && s.config.Mode != PartialObliviousMode {
+		return Evaluation{}, &EvaluationError{Err: fmt.Errorf("public input is not supported in the %v mode", s.config.Mode)}
+	}
+	if err := s.checkPolicy(ctx, req.Info); err != nil {
+		return Evaluation{}, &EvaluationError{Err: err}
+	}
+	if s.checker != nil {
+		if err := s.checker.CheckRequest(ctx, req); err != nil {
+			return Evaluation{}, &EvaluationError{Err: err}
+		}
+	}
+
+	circlReq := &oprf.EvaluationRequest{Elements: req.Elements}
+	var eval *oprf.Evaluation
+	var err error
+	switch s.config.Mode {
+	case BaseMode:
+		eval, err = s.base.Evaluate(circlReq)
+	case VerifiableMode:
+		eval, err = s.verifiable.Evaluate(circlReq)
+	case PartialObliviousMode:
+		eval, err = s.partialOblivious.Evaluate(circlReq, req.Info)
+	}
+	if err != nil {
+		return Evaluation{}, &EvaluationError{Err: err}
+	}
+	return Evaluation{Elements: eval.Elements, Proof: eval.Proof}, nil
+}
+
+// checkPolicy returns an error wrapping ErrMetadataDenied unless the server
+// has no policy or its policy allows the metadata of info
+func (s *OPRFServer) checkPolicy(ctx context.Context, info []byte) error {
+	if s.policy == nil {
+		return nil
+	}
+	metadata, err := ParseMetadata(info)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrMetadataDenied, err)
+	}
+	if err := s.policy.Allow(ctx, metadata); err != nil {
+		if errors.Is(err, ErrMetadataDenied) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", ErrMetadataDenied, err)
+	}
+	return nil
+}
+
+// VerifyOutput reports whether output is the OPRF output of input under the
+// server's secret key. info is the public input of the partially-oblivious
+// mode.
+func (s *OPRFServer) VerifyOutput(input, info, output []byte) bool {
+	switch s.config.Mode {
+	case BaseMode:
+		return s.base.VerifyFinalize(input, output)
+	case VerifiableMode:
+		return s.verifiable.VerifyFinalize(input, output)
+	default:
+		return s.partialOblivious.VerifyFinalize(input, info, output)
+	}
+}
+
+// KeyToHex returns the hex-encoded representaton of the server's secret key
+// and public key (see SecretKey.KeyToHex)
+func (s *OPRFServer) KeyToHex() (string, string, error) {
+	return s.key.KeyToHex()
+}
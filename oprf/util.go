@@ -0,0 +1,111 @@
+// Package oprf implements the oblivious pseudorandom function used by Callisto
+// clients to turn a low-entropy perpetrator ID into a p-hat value, and the
+// server-side evaluator holding the OPRF key. It follows RFC 9497 in its base,
+// verifiable and partially-oblivious modes.
+package oprf
+
+import (
+	"fmt"
+
+	"github.com/cloudflare/circl/group"
+	"github.com/cloudflare/circl/oprf"
+	"github.com/cloudflare/circl/zk/dleq"
+)
+
+// RFC 9497 ciphersuite identifiers
+const (
+	P256SHA256         = "P256-SHA256"
+	P384SHA384         = "P384-SHA384"
+	P521SHA512         = "P521-SHA512"
+	Ristretto255SHA512 = "ristretto255-SHA512"
+)
+
+// Ciphersuites returns the identifiers of the supported ciphersuites
+func Ciphersuites() []string {
+	return []string{P256SHA256, P384SHA384, P521SHA512, Ristretto255SHA512}
+}
+
+// ParseCiphersuiteString returns the OPRF ciphersuite with the given RFC 9497
+// identifier
+func ParseCiphersuiteString(ciphersuite string) (oprf.Suite, error) {
+	suite, err := oprf.GetSuite(ciphersuite)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ciphersuite string %v: %w", ciphersuite, err)
+	}
+	return suite, nil
+}
+
+// Mode is an RFC 9497 protocol variant
+type Mode uint8
+
+const (
+	// BaseMode only hides the input from the evaluator
+	BaseMode Mode = Mode(oprf.BaseMode)
+	// VerifiableMode also proves to the client that the evaluator used the key
+	// of its public key
+	VerifiableMode Mode = Mode(oprf.VerifiableMode)
+	// PartialObliviousMode is VerifiableMode with a public input, shared by the
+	// client and evaluator, that is bound into the output
+	PartialObliviousMode Mode = Mode(oprf.PartialObliviousMode)
+)
+
+var modeNames = map[Mode]string{
+	BaseMode:             "base",
+	VerifiableMode:       "verifiable",
+	PartialObliviousMode: "partially-oblivious",
+}
+
+// String returns the name of the mode
+func (m Mode) String() string {
+	if name, ok := modeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("mode %v", uint8(m))
+}
+
+// ParseMode returns the mode with the given name
+func ParseMode(name string) (Mode, error) {
+	for mode, modeName := range modeNames {
+		if modeName == name {
+			return mode, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown OPRF mode %q", name)
+}
+
+// Config is the OPRF setup shared by the evaluator and its clients. The
+// ciphersuite and mode both change the output, so p-hat values computed under
+// different configs never match.
+type Config struct {
+	Ciphersuite string
+	Mode        Mode
+}
+
+func (c Config) validate() (oprf.Suite, error) {
+	if _, ok := modeNames[c.Mode]; !ok {
+		return nil, fmt.Errorf("unknown OPRF mode %v", uint8(c.Mode))
+	}
+	return ParseCiphersuiteString(c.Ciphersuite)
+}
+
+// GroupElement is an element of the OPRF ciphersuite's prime-order group. It
+// is the unit exchanged between a PHatComputer and an OPRFEvaluator
+type GroupElement = group.Element
+
+// Proof is the proof of correct evaluation sent by evaluators in the
+// verifiable and partially-oblivious modes
+type Proof = dleq.Proof
+
+// checkElements returns an error unless elements are non-identity elements of
+// g
+func checkElements(g group.Group, elements []GroupElement) error {
+	for i, e := range elements {
+		if e == nil || e.Group() != g {
+			return fmt.Errorf("element %v is not in the ciphersuite's group", i)
+		}
+		if e.IsIdentity() {
+			return fmt.Errorf("element %v is the identity", i)
+		}
+	}
+	return nil
+}
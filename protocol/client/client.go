@@ -1,25 +1,150 @@
 package client
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
 	"crypto/rsa"
-	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 
+	"github.com/ymarcus93/gallisto/canonical"
+	"github.com/ymarcus93/gallisto/crypto"
+	"github.com/ymarcus93/gallisto/identity"
+	"github.com/ymarcus93/gallisto/internal/detrand"
 	"github.com/ymarcus93/gallisto/internal/encoding"
-	"github.com/ymarcus93/gallisto/internal/encryption"
 	"github.com/ymarcus93/gallisto/internal/shamir"
 	"github.com/ymarcus93/gallisto/internal/util"
+	"github.com/ymarcus93/gallisto/oprf"
+	"github.com/ymarcus93/gallisto/suite"
 	"github.com/ymarcus93/gallisto/types"
 
 	"github.com/google/uuid"
-	"golang.org/x/crypto/hkdf"
 )
 
 type CallistoClient struct {
-	UserID       []byte
-	userKey      []byte
-	pHatComputer PHatComputer
+	UserID        []byte
+	userKey       []byte
+	pHatComputer  ContextPHatComputer
+	fuzzyMatching bool
+	random        io.Reader // Source of keys, nonces and the user ID
+	trace         *Trace
+	suiteID       suite.ID
+	suite         *suite.Suite
+	certificate   *identity.Certificate
+	encodedCert   []byte
+	signingKey    ed25519.PrivateKey
+	keyDirectory  KeyDirectory
+}
+
+// Option configures optional CallistoClient behaviour
+type Option func(*CallistoClient)
+
+// WithFuzzyMatching makes CreateCallistoTuples submit additional
+// lower-confidence tuples derived from phonetic and spelling variants of the
+// perpetrator ID (see canonical.Variants)
+func WithFuzzyMatching() Option {
+	return func(c *CallistoClient) {
+		c.fuzzyMatching = true
+	}
+}
+
+// WithRand makes the client draw its user key, user ID, data keys and all
+// encryption randomness from random instead of crypto/rand. It is for
+// reproducible test runs: outside of tests, NewCallistoClient refuses any
+// reader but crypto/rand's.
+func WithRand(random io.Reader) Option {
+	return func(c *CallistoClient) {
+		c.random = random
+	}
+}
+
+// WithSuite makes the client create tuples with the given protocol suite
+// instead of suite.Default. The suite must be creatable, and a p-hat computer
+// that reports its OPRF setup, such as oprf.PHatComputer, must use the OPRF
+// ciphersuite of the suite.
+func WithSuite(id suite.ID) Option {
+	return func(c *CallistoClient) {
+		c.suiteID = id
+	}
+}
+
+// WithCertificate makes the client submit tuples under the user ID certified
+// by certificate, which every tuple carries, instead of a random user ID. The
+// client must sign its tuples with the private key of the certificate's holder
+// key (see WithSigningKey).
+func WithCertificate(certificate identity.Certificate) Option {
+	return func(c *CallistoClient) {
+		c.certificate = &certificate
+	}
+}
+
+// WithSigningKey makes the client sign every tuple it creates with key, so that
+// a server holding the registered public key of the client's user ID can
+// check who submitted it and that it was not altered
+func WithSigningKey(key ed25519.PrivateKey) Option {
+	return func(c *CallistoClient) {
+		c.signingKey = key
+	}
+}
+
+// KeyDirectory vouches for the LOC, DLOC and OPRF keys a client uses.
+// directory.Fetcher implements it.
+type KeyDirectory interface {
+	// CheckKeys returns an error unless the keys are the current LOC and DLOC
+	// keys of the protocol suite id
+	CheckKeys(id suite.ID, locPublicKey, dlocPublicKey *rsa.PublicKey) error
+	// CheckOPRFKey returns an error unless key is the current OPRF key of the
+	// protocol suite id
+	CheckOPRFKey(id suite.ID, key oprf.PublicKey) error
+}
+
+// OPRFKeyHolder is implemented by p-hat computers that may verify evaluations
+// against an OPRF public key, such as oprf.PHatComputer. PublicKey returns
+// false if they do not.
+type OPRFKeyHolder interface {
+	PublicKey() (oprf.PublicKey, bool)
+}
+
+// WithKeyDirectory makes the client refuse to create tuples for LOC, DLOC and
+// OPRF keys that directory does not list for the client's suite, so that a
+// caller passing an attacker's keys cannot expose entries. The p-hat computer
+// must verify evaluations against an OPRF public key (see OPRFKeyHolder), as
+// in the verifiable and partially-oblivious modes of oprf.PHatComputer: in the
+// base mode, nothing shows which key the evaluator used.
+func WithKeyDirectory(directory KeyDirectory) Option {
+	return func(c *CallistoClient) {
+		c.keyDirectory = directory
+	}
+}
+
+// Trace holds the intermediate values computed while creating a tuple. It is
+// meant for debugging and for producing test vectors.
+type Trace struct {
+	PerpID                 []byte // Canonical perpetrator ID given to the OPRF
+	TupleID                []byte // Nil unless the suite binds tuples
+	PHat                   []byte
+	A                      []byte
+	K                      []byte
+	Pi                     []byte
+	ShamirShareX           []byte
+	ShamirShareY           []byte
+	EntryDataKey           []byte // k_e
+	EntryDataEncoding      []byte
+	AssignmentDataKey      []byte // k_a
+	AssignmentDataEncoding []byte
+	LOCDataEncoding        []byte
+	DLOCDataEncoding       []byte
+}
+
+// WithTrace makes the client record the intermediate values of the last tuple
+// it created into trace. Traces contain secrets, so never enable this for real
+// entries.
+func WithTrace(trace *Trace) Option {
+	return func(c *CallistoClient) {
+		c.trace = trace
+	}
 }
 
 // LOCPublicKeys encapsulates the public keys needed by a CallistoClient to
@@ -41,6 +166,25 @@ type PHatComputer interface {
 	GetPHatValue(perpID []byte) ([]byte, error)
 }
 
+// ContextPHatComputer is a PHatComputer whose computation honours a context's
+// deadline and cancellation, e.g. because the OPRF is evaluated remotely.
+// oprf.PHatComputer implements it.
+type ContextPHatComputer interface {
+	GetPHatValueContext(ctx context.Context, perpID []byte) ([]byte, error)
+}
+
+// contextPHatComputer adapts a PHatComputer that does not take a context
+type contextPHatComputer struct {
+	pHatComputer PHatComputer
+}
+
+func (c contextPHatComputer) GetPHatValueContext(ctx context.Context, perpID []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.pHatComputer.GetPHatValue(perpID)
+}
+
 type akpi struct {
 	a  []byte
 	k  []byte
@@ -48,63 +192,286 @@ type akpi struct {
 }
 
 // NewCallistoClient returns a CallistoClient capabale of performing Callisto
-// client responsibilities
-func NewCallistoClient(pHatComputer PHatComputer) (*CallistoClient, error) {
-	userKey, err := util.GenerateRandomBytes(32)
+// client responsibilities. If pHatComputer also implements ContextPHatComputer,
+// the contexts given to the client are passed on to it.
+func NewCallistoClient(pHatComputer PHatComputer, opts ...Option) (*CallistoClient, error) {
+	if contextComputer, ok := pHatComputer.(ContextPHatComputer); ok {
+		return NewCallistoClientContext(contextComputer, opts...)
+	}
+	return NewCallistoClientContext(contextPHatComputer{pHatComputer}, opts...)
+}
+
+// NewCallistoClientContext returns a CallistoClient that computes p-hat values
+// with a ContextPHatComputer
+func NewCallistoClientContext(pHatComputer ContextPHatComputer, opts ...Option) (*CallistoClient, error) {
+	client := &CallistoClient{
+		pHatComputer: pHatComputer,
+		random:       rand.Reader,
+		suiteID:      suite.Default,
+	}
+	for _, opt := range opts {
+		opt(client)
+	}
+	if err := detrand.Check(client.random); err != nil {
+		return nil, fmt.Errorf("invalid source of randomness: %w", err)
+	}
+
+	protocolSuite, err := suite.Lookup(client.suiteID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate user key: %v", err)
+		return nil, err
+	}
+	if !protocolSuite.Creatable {
+		return nil, fmt.Errorf("tuples of suite %v can no longer be created", protocolSuite.Name)
+	}
+	if configured, ok := client.innerPHatComputer().(oprfConfigured); ok && configured.Config().Ciphersuite != protocolSuite.OPRFCiphersuite {
+		return nil, fmt.Errorf("suite %v requires the OPRF ciphersuite %v, but the p-hat computer uses %v",
+			protocolSuite.Name, protocolSuite.OPRFCiphersuite, configured.Config().Ciphersuite)
 	}
+	client.suite = protocolSuite
 
-	uuid := uuid.New()
-	uuidAsBytes, err := uuid.MarshalBinary()
+	userKey, err := util.GenerateRandomBytesFrom(client.random, 32)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal uuid to bytes: %v", err)
+		return nil, fmt.Errorf("failed to generate user key: %w", err)
+	}
+	var userID []byte
+	if client.certificate != nil {
+		if client.encodedCert, err = client.certificate.MarshalBinary(); err != nil {
+			return nil, fmt.Errorf("invalid user certificate: %w", err)
+		}
+		userID = client.certificate.UserID
+	} else if userID, err = newUserID(client.random); err != nil {
+		return nil, fmt.Errorf("failed to generate user ID: %w", err)
 	}
 
-	return &CallistoClient{
-		userKey:      userKey,
-		UserID:       uuidAsBytes,
-		pHatComputer: pHatComputer,
-	}, nil
+	if client.signingKey != nil && len(client.signingKey) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("signing key must be %v bytes, got %v", ed25519.PrivateKeySize, len(client.signingKey))
+	}
+	if client.keyDirectory != nil {
+		if _, ok := client.evaluatorKey(); !ok {
+			return nil, fmt.Errorf("a key directory requires verified OPRF evaluations: %w", errUnverifiedEvaluations)
+		}
+	}
+	if client.certificate != nil && !client.certificate.HolderKey.Equal(client.SigningPublicKey()) {
+		return nil, fmt.Errorf("invalid user certificate: client must sign with the certificate's holder key")
+	}
+
+	client.userKey = userKey
+	client.UserID = userID
+	return client, nil
+}
+
+// SigningPublicKey returns the public key of the client's signing key, to be
+// registered with the server for its user ID. It is nil if the client does not
+// sign tuples.
+func (c *CallistoClient) SigningPublicKey() ed25519.PublicKey {
+	if c.signingKey == nil {
+		return nil
+	}
+	return c.signingKey.Public().(ed25519.PublicKey)
+}
+
+// newUserID returns the bytes of a random (version 4) UUID
+func newUserID(random io.Reader) ([]byte, error) {
+	randomBytes, err := util.GenerateRandomBytesFrom(random, 16)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := uuid.FromBytes(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+	userID[6] = (userID[6] & 0x0f) | 0x40 // Version 4
+	userID[8] = (userID[8] & 0x3f) | 0x80 // Variant is 10
+	return userID.MarshalBinary()
 }
 
 // CreateCallistoTuple performs the entire Callisto client encryption of a
 // Callisto entry and returns the 6-tuple to be sent to a Callisto database
-// server
-func (c *CallistoClient) CreateCallistoTuple(perpID []byte, entry CallistoEntry, pubKeys LOCPublicKeys) (types.CallistoTuple, error) {
-	pHat, err := c.pHatComputer.GetPHatValue(perpID)
+// server. The entry is validated before anything is encrypted. The perpetrator
+// ID is canonicalized before deriving p-hat so that equivalent spellings of the
+// same identifier match.
+func (c *CallistoClient) CreateCallistoTuple(perpID canonical.Identifier, entry CallistoEntry, pubKeys LOCPublicKeys) (types.CallistoTuple, error) {
+	return c.CreateCallistoTupleContext(context.Background(), perpID, entry, pubKeys)
+}
+
+// CreateCallistoTupleContext is like CreateCallistoTuple but passes ctx on to
+// the p-hat computation, so that a remote OPRF evaluation can be given a
+// deadline or cancelled
+func (c *CallistoClient) CreateCallistoTupleContext(ctx context.Context, perpID canonical.Identifier, entry CallistoEntry, pubKeys LOCPublicKeys) (types.CallistoTuple, error) {
+	record, err := c.createEntryRecord(ctx, perpID, types.MatchExact, entry, pubKeys)
+	if err != nil {
+		return types.CallistoTuple{}, err
+	}
+	return record.Tuple, nil
+}
+
+// CreateCallistoTuples returns the exact tuple created by CreateCallistoTuple.
+// If the client was created WithFuzzyMatching, it also returns one tuple per
+// variant of the perpetrator ID, each tagged with a lower-confidence match
+// class.
+func (c *CallistoClient) CreateCallistoTuples(perpID canonical.Identifier, entry CallistoEntry, pubKeys LOCPublicKeys) ([]types.CallistoTuple, error) {
+	return c.CreateCallistoTuplesContext(context.Background(), perpID, entry, pubKeys)
+}
+
+// CreateCallistoTuplesContext is like CreateCallistoTuples but passes ctx on to
+// every p-hat computation
+func (c *CallistoClient) CreateCallistoTuplesContext(ctx context.Context, perpID canonical.Identifier, entry CallistoEntry, pubKeys LOCPublicKeys) ([]types.CallistoTuple, error) {
+	records, err := c.createEntryRecords(ctx, perpID, entry, pubKeys)
 	if err != nil {
-		return types.CallistoTuple{}, fmt.Errorf("failed to derive p-hat: %v", err)
+		return nil, err
+	}
+	tuples := make([]types.CallistoTuple, len(records))
+	for i, record := range records {
+		tuples[i] = record.Tuple
+	}
+	return tuples, nil
+}
+
+// createEntryRecords creates the exact tuple and, with fuzzy matching, the
+// variant tuples of an entry
+func (c *CallistoClient) createEntryRecords(ctx context.Context, perpID canonical.Identifier, entry CallistoEntry, pubKeys LOCPublicKeys) ([]EntryRecord, error) {
+	exactRecord, err := c.createEntryRecord(ctx, perpID, types.MatchExact, entry, pubKeys)
+	if err != nil {
+		return nil, err
+	}
+	records := []EntryRecord{exactRecord}
+	if !c.fuzzyMatching {
+		return records, nil
+	}
+
+	variants, err := canonical.Variants(perpID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive perpetrator ID variants: %w", err)
+	}
+	for _, variant := range variants {
+		record, err := c.createEntryRecord(ctx, variant, variantMatchClass(variant), entry, pubKeys)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %v tuple: %w", variant.Type, err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func variantMatchClass(variant canonical.Identifier) types.MatchClass {
+	if variant.Type == canonical.TypeNamePhonetic {
+		return types.MatchPhonetic
+	}
+	return types.MatchVariant
+}
+
+// oprfConfigured is implemented by p-hat computers that report their OPRF
+// setup, such as oprf.PHatComputer
+type oprfConfigured interface {
+	Config() oprf.Config
+}
+
+// innerPHatComputer returns the p-hat computer the client was created with,
+// without the context adapter of NewCallistoClient
+func (c *CallistoClient) innerPHatComputer() interface{} {
+	if adapted, ok := c.pHatComputer.(contextPHatComputer); ok {
+		return adapted.pHatComputer
+	}
+	return c.pHatComputer
+}
+
+// evaluatorKey returns the OPRF public key the p-hat computer verifies
+// evaluations against, if any
+func (c *CallistoClient) evaluatorKey() (oprf.PublicKey, bool) {
+	holder, ok := c.innerPHatComputer().(OPRFKeyHolder)
+	if !ok {
+		return oprf.PublicKey{}, false
+	}
+	return holder.PublicKey()
+}
+
+// checkOPRFKey checks the OPRF public key the p-hat computer verifies
+// evaluations against with the key directory
+func (c *CallistoClient) checkOPRFKey() error {
+	key, ok := c.evaluatorKey()
+	if !ok {
+		return errUnverifiedEvaluations
+	}
+	return c.keyDirectory.CheckOPRFKey(c.suite.ID, key)
+}
+
+var errUnverifiedEvaluations = errors.New("p-hat computer does not verify evaluations against an OPRF public key")
+
+// createEntryRecord creates a tuple of the entry and the client's copy of it
+func (c *CallistoClient) createEntryRecord(ctx context.Context, perpID canonical.Identifier, matchClass types.MatchClass, entry CallistoEntry, pubKeys LOCPublicKeys) (EntryRecord, error) {
+	// Reject invalid content before doing any work. The returned errors wrap
+	// types.ValidationErrors.
+	if err := entry.EntryData.Validate(); err != nil {
+		return EntryRecord{}, fmt.Errorf("invalid entry data: %w", err)
+	}
+	if err := entry.AssignmentData.Validate(); err != nil {
+		return EntryRecord{}, fmt.Errorf("invalid assignment data: %w", err)
+	}
+
+	if c.keyDirectory != nil {
+		if err := c.keyDirectory.CheckKeys(c.suite.ID, pubKeys.LOCPublicKey, pubKeys.DLOCPublicKey); err != nil {
+			return EntryRecord{}, fmt.Errorf("refusing to encrypt to LOC keys: %w", err)
+		}
+		if err := c.checkOPRFKey(); err != nil {
+			return EntryRecord{}, fmt.Errorf("refusing to use OPRF key: %w", err)
+		}
+	}
+
+	canonicalPerpID, err := perpID.Bytes()
+	if err != nil {
+		return EntryRecord{}, fmt.Errorf("failed to canonicalize perpetrator ID: %w", err)
+	}
+
+	pHat, err := c.pHatComputer.GetPHatValueContext(ctx, canonicalPerpID)
+	if err != nil {
+		return EntryRecord{}, fmt.Errorf("failed to derive p-hat: %w", err)
 	}
 
 	// Derive from P-Hat three 32-byte pseudorandom values
-	akpiValues, err := deriveAKPiValues(pHat)
+	var akpiValues akpi
+	akpiValues.a, akpiValues.k, akpiValues.pi, err = c.suite.KDF.Derive(pHat)
 	if err != nil {
-		return types.CallistoTuple{}, fmt.Errorf("failed to derived a, k, and pi: %v", err)
+		return EntryRecord{}, fmt.Errorf("failed to derived a, k, and pi: %w", err)
 	}
 
 	// Evaluate shamir polynomial y = ax + k at x = U to get y = s
 	shamirShare := shamir.ComputeShamirShare(akpiValues.a, akpiValues.k, c.UserID)
 
+	// Identify the tuple its ciphertexts are bound to
+	binding := suite.Binding{UserID: c.UserID}
+	if c.suite.BindsTuple {
+		if binding.TupleID, err = util.GenerateRandomBytesFrom(c.random, types.TupleIDSize); err != nil {
+			return EntryRecord{}, fmt.Errorf("failed to generate tuple ID: %w", err)
+		}
+	}
+
 	// Encrypt Callisto entry data
-	encryptedCallistoEntryData, err := c.encryptEntry(entry, akpiValues)
+	encryptedCallistoEntryData, err := c.encryptEntry(entry, akpiValues, binding)
 	if err != nil {
-		return types.CallistoTuple{}, err
+		return EntryRecord{}, err
 	}
 
 	// Encrypt data for LOCs
-	locCiphertext, err := encryptLOCData(
+	locCiphertext, locDataEncoding, err := c.encryptLOCData(
 		types.Counselor,
 		shamirShare,
 		encryptedCallistoEntryData.encryptedEntryDataKeyByK,
+		binding,
 		pubKeys.LOCPublicKey,
 	)
-	dlocCiphertext, err := encryptLOCData(
+	if err != nil {
+		return EntryRecord{}, err
+	}
+	dlocCiphertext, dlocDataEncoding, err := c.encryptLOCData(
 		types.Director,
 		shamirShare,
 		encryptedCallistoEntryData.encryptedAssignmentDataKeyByK,
+		binding,
 		pubKeys.DLOCPublicKey,
 	)
+	if err != nil {
+		return EntryRecord{}, err
+	}
 
 	tuple, err := types.NewCallistoTuple(
 		c.UserID,
@@ -116,51 +483,92 @@ func (c *CallistoClient) CreateCallistoTuple(perpID []byte, entry CallistoEntry,
 		encryptedCallistoEntryData.encryptedAssignmentData,
 	)
 	if err != nil {
-		return types.CallistoTuple{}, fmt.Errorf("failed to construct tuple: %v", err)
+		return EntryRecord{}, fmt.Errorf("failed to construct tuple: %w", err)
 	}
-	return tuple, nil
+
+	if c.trace != nil {
+		*c.trace = Trace{
+			PerpID:                 canonicalPerpID,
+			TupleID:                binding.TupleID,
+			PHat:                   pHat,
+			A:                      akpiValues.a,
+			K:                      akpiValues.k,
+			Pi:                     akpiValues.pi,
+			ShamirShareX:           shamirShare.X.Bytes(),
+			ShamirShareY:           shamirShare.Y.Bytes(),
+			EntryDataKey:           encryptedCallistoEntryData.entryDataKey,
+			EntryDataEncoding:      encryptedCallistoEntryData.entryDataEncoding,
+			AssignmentDataKey:      encryptedCallistoEntryData.assignmentDataKey,
+			AssignmentDataEncoding: encryptedCallistoEntryData.assignmentDataEncoding,
+			LOCDataEncoding:        locDataEncoding,
+			DLOCDataEncoding:       dlocDataEncoding,
+		}
+	}
+	tuple = tuple.WithMatchClass(matchClass).WithSuite(c.suite.ID).WithUserCertificate(c.encodedCert).WithTupleID(binding.TupleID)
+	if c.signingKey != nil {
+		if tuple, err = tuple.Sign(c.signingKey); err != nil {
+			return EntryRecord{}, err
+		}
+	}
+	return EntryRecord{
+		Entry:             entry,
+		Tuple:             tuple,
+		EntryDataKey:      encryptedCallistoEntryData.entryDataKey,
+		AssignmentDataKey: encryptedCallistoEntryData.assignmentDataKey,
+	}, nil
 }
 
 type encryptedCallistoEntry struct {
-	encryptedEntryData       encryption.GCMCiphertext // eEntry value
-	encryptedEntryDataKeyByK encryption.GCMCiphertext // c_e value
-	encryptedEntryDataKeyByU encryption.GCMCiphertext // c_u value
-
-	encryptedAssignmentData       encryption.GCMCiphertext // eAssign value
-	encryptedAssignmentDataKeyByK encryption.GCMCiphertext // c_a value
+	encryptedEntryData       crypto.GCMCiphertext // eEntry value
+	encryptedEntryDataKeyByK crypto.GCMCiphertext // c_e value
+	encryptedEntryDataKeyByU crypto.GCMCiphertext // c_u value
+
+	encryptedAssignmentData       crypto.GCMCiphertext // eAssign value
+	encryptedAssignmentDataKeyByK crypto.GCMCiphertext // c_a value
+
+	// Plaintext values, kept for tracing and the client's entry record
+	entryDataKey           []byte
+	entryDataEncoding      []byte
+	assignmentDataKey      []byte
+	assignmentDataEncoding []byte
 }
 
 // encryptEntry performs client-side symmetric encryption operations involved in
-// encrypting an entry
-func (c *CallistoClient) encryptEntry(entry CallistoEntry, akpiValues akpi) (encryptedCallistoEntry, error) {
+// encrypting an entry. Each ciphertext's associated data is given by the suite
+// for its component and the tuple's binding.
+func (c *CallistoClient) encryptEntry(entry CallistoEntry, akpiValues akpi, binding suite.Binding) (encryptedCallistoEntry, error) {
+	associatedData := func(component suite.Component) []byte {
+		return c.suite.AssociatedData(component, akpiValues.pi, binding)
+	}
+
 	// Encrypt entry data: eEntry
-	encryptedEntryData, entryDataKey, err := encryptEntryData(entry.EntryData, akpiValues.pi)
+	encryptedEntryData, entryDataKey, entryDataEncoding, err := c.encryptEntryData(entry.EntryData, associatedData(suite.EntryData))
 	if err != nil {
-		return encryptedCallistoEntry{}, fmt.Errorf("failed to encrypt entry data: %v", err)
+		return encryptedCallistoEntry{}, fmt.Errorf("failed to encrypt entry data: %w", err)
 	}
 
 	// c_e
-	encryptedEntryDataKeyByK, err := encryption.EncryptAES(akpiValues.k, entryDataKey, akpiValues.pi)
+	encryptedEntryDataKeyByK, err := c.suite.AEAD.Encrypt(c.random, akpiValues.k, entryDataKey, associatedData(suite.EntryDataKey))
 	if err != nil {
-		return encryptedCallistoEntry{}, fmt.Errorf("failed to create c_e: %v", err)
+		return encryptedCallistoEntry{}, fmt.Errorf("failed to create c_e: %w", err)
 	}
 
 	// c_u
-	encryptedEntryDataKeyByU, err := encryption.EncryptAES(c.userKey, entryDataKey, akpiValues.pi)
+	encryptedEntryDataKeyByU, err := c.suite.AEAD.Encrypt(c.random, c.userKey, entryDataKey, associatedData(suite.EntryDataKeyForOwner))
 	if err != nil {
-		return encryptedCallistoEntry{}, fmt.Errorf("failed to create c_u: %v", err)
+		return encryptedCallistoEntry{}, fmt.Errorf("failed to create c_u: %w", err)
 	}
 
 	// Encrypt assignment data: eAssign
-	encryptedAssignmentData, assignmentDataKey, err := encryptAssignmentData(entry.AssignmentData, akpiValues.pi)
+	encryptedAssignmentData, assignmentDataKey, assignmentDataEncoding, err := c.encryptAssignmentData(entry.AssignmentData, associatedData(suite.AssignmentData))
 	if err != nil {
-		return encryptedCallistoEntry{}, fmt.Errorf("failed to encrypt assignment data: %v", err)
+		return encryptedCallistoEntry{}, fmt.Errorf("failed to encrypt assignment data: %w", err)
 	}
 
 	// c_a
-	encryptedAssignmentDataKey, err := encryption.EncryptAES(akpiValues.k, assignmentDataKey, akpiValues.pi)
+	encryptedAssignmentDataKey, err := c.suite.AEAD.Encrypt(c.random, akpiValues.k, assignmentDataKey, associatedData(suite.AssignmentDataKey))
 	if err != nil {
-		return encryptedCallistoEntry{}, fmt.Errorf("failed to create c_a: %v", err)
+		return encryptedCallistoEntry{}, fmt.Errorf("failed to create c_a: %w", err)
 	}
 
 	return encryptedCallistoEntry{
@@ -169,102 +577,87 @@ func (c *CallistoClient) encryptEntry(entry CallistoEntry, akpiValues akpi) (enc
 		encryptedEntryDataKeyByU:      encryptedEntryDataKeyByU,
 		encryptedAssignmentData:       encryptedAssignmentData,
 		encryptedAssignmentDataKeyByK: encryptedAssignmentDataKey,
+		entryDataKey:                  entryDataKey,
+		entryDataEncoding:             entryDataEncoding,
+		assignmentDataKey:             assignmentDataKey,
+		assignmentDataEncoding:        assignmentDataEncoding,
 	}, nil
 }
 
 // encryptEntryData generates a fresh random key and uses it to encrypt
 // entry data. The returned result is both the encrypted entry data
-// and the random key generated.
-func encryptEntryData(data types.EntryData, pi []byte) (encryption.GCMCiphertext, []byte, error) {
+// and the random key generated, along with the plaintext encoding.
+func (c *CallistoClient) encryptEntryData(data types.EntryData, associatedData []byte) (crypto.GCMCiphertext, []byte, []byte, error) {
 	// Create msgpack encoding of entry data
 	entryDataEncodedBytes, err := encoding.EncodeEntryData(data)
 	if err != nil {
-		return encryption.GCMCiphertext{}, nil, err
+		return crypto.GCMCiphertext{}, nil, nil, err
 	}
 
 	// Generate random entry data key: k_e
-	entryDataKey, err := util.GenerateRandomBytes(32)
+	entryDataKey, err := util.GenerateRandomBytesFrom(c.random, c.suite.AEAD.KeySize)
 	if err != nil {
-		return encryption.GCMCiphertext{}, nil, err
+		return crypto.GCMCiphertext{}, nil, nil, err
 	}
 
 	// Encrypt entryData to get eEntry
-	encryptedEntryData, err := encryption.EncryptAES(entryDataKey, entryDataEncodedBytes, pi)
+	encryptedEntryData, err := c.suite.AEAD.Encrypt(c.random, entryDataKey, entryDataEncodedBytes, associatedData)
 	if err != nil {
-		return encryption.GCMCiphertext{}, nil, fmt.Errorf("failed to encrypt entry data: %v", err)
+		return crypto.GCMCiphertext{}, nil, nil, fmt.Errorf("failed to encrypt entry data: %w", err)
 	}
 
-	return encryptedEntryData, entryDataKey, nil
+	return encryptedEntryData, entryDataKey, entryDataEncodedBytes, nil
 }
 
 // encryptAssignmentData generates a fresh random key and uses it to encrypt
 // assignment data. The returned result is both the encrypted assignment data
-// and the random key generated.
-func encryptAssignmentData(data types.AssignmentData, pi []byte) (encryption.GCMCiphertext, []byte, error) {
+// and the random key generated, along with the plaintext encoding.
+func (c *CallistoClient) encryptAssignmentData(data types.AssignmentData, associatedData []byte) (crypto.GCMCiphertext, []byte, []byte, error) {
 	// Create msgpack encoding of assignment data
 	assignmentDataEncodedBytes, err := encoding.EncodeAssignmentData(data)
 	if err != nil {
-		return encryption.GCMCiphertext{}, nil, err
+		return crypto.GCMCiphertext{}, nil, nil, err
 	}
 
 	// Generate random assignment data key: k_a
-	assignmentDataKey, err := util.GenerateRandomBytes(32)
+	assignmentDataKey, err := util.GenerateRandomBytesFrom(c.random, c.suite.AEAD.KeySize)
 	if err != nil {
-		return encryption.GCMCiphertext{}, nil, err
+		return crypto.GCMCiphertext{}, nil, nil, err
 	}
 
 	// Encrypt assignmentData to get eAssign
-	encryptedAssignmentData, err := encryption.EncryptAES(assignmentDataKey, assignmentDataEncodedBytes, pi)
+	encryptedAssignmentData, err := c.suite.AEAD.Encrypt(c.random, assignmentDataKey, assignmentDataEncodedBytes, associatedData)
 	if err != nil {
-		return encryption.GCMCiphertext{}, nil, fmt.Errorf("failed to encrypt assignment data: %v", err)
+		return crypto.GCMCiphertext{}, nil, nil, fmt.Errorf("failed to encrypt assignment data: %w", err)
 	}
 
-	return encryptedAssignmentData, assignmentDataKey, nil
+	return encryptedAssignmentData, assignmentDataKey, assignmentDataEncodedBytes, nil
 }
 
 // encryptLOCData forms the c or c_assign tuple (depending on locType) and
-// encrypts the necessary data for a LOC
-func encryptLOCData(locType types.LOCType, shamirShare *shamir.ShamirShare, encryptedKey encryption.GCMCiphertext, locPublicKey *rsa.PublicKey) ([]byte, error) {
+// encrypts the necessary data for a LOC. It returns the ciphertext and the
+// plaintext encoding.
+func (c *CallistoClient) encryptLOCData(locType types.LOCType, shamirShare *shamir.ShamirShare, encryptedKey crypto.GCMCiphertext, binding suite.Binding, locPublicKey *rsa.PublicKey) ([]byte, []byte, error) {
 	locData, err := types.NewLOCData(locType, shamirShare, encryptedKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to construct loc data: %v", err)
+		return nil, nil, fmt.Errorf("failed to construct loc data: %w", err)
+	}
+	locData = locData.WithSuite(c.suite.ID)
+	if c.suite.BindsTuple {
+		locData = locData.WithBinding(binding.UserID, binding.TupleID)
 	}
 
 	// Create msgpack encoding of LOC data
 	locDataEncodedBytes, err := encoding.EncodeLOCData(locData)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Encrypt data to LOC
-	locCiphertext, err := encryption.EncryptRSA(locDataEncodedBytes, locPublicKey)
+	locCiphertext, err := c.suite.LOCEncryption.Encrypt(c.random, locDataEncodedBytes, locPublicKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to encrypt locData to %v LOC: %v", locType, err)
+		return nil, nil, fmt.Errorf("failed to encrypt locData to %v LOC: %w", locType, err)
 	}
 
-	return locCiphertext, nil
-}
-
-// deriveAKPiValues derives the triple: (a, k, pi) from a result given by an
-// evaluated OPRF function
-func deriveAKPiValues(pHat []byte) (akpi, error) {
-	// Underlying hash function for HMAC.
-	hash := sha256.New
-	hkdf := hkdf.New(hash, pHat, nil, nil)
-
-	// Generate three 256-bit derived keys.
-	var keys [][]byte
-	for i := 0; i < 3; i++ {
-		key := make([]byte, 32)
-		if _, err := io.ReadFull(hkdf, key); err != nil {
-			return akpi{}, fmt.Errorf("failed to derive AKPi values at index %v: %v", i, err)
-		}
-		keys = append(keys, key)
-	}
-
-	return akpi{
-		a:  keys[0],
-		k:  keys[1],
-		pi: keys[2],
-	}, nil
+	return locCiphertext, locDataEncodedBytes, nil
 }
@@ -0,0 +1,332 @@
+package client
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ymarcus93/gallisto/canonical"
+	"github.com/ymarcus93/gallisto/crypto"
+	"github.com/ymarcus93/gallisto/identity"
+	"github.com/ymarcus93/gallisto/internal/detrand"
+	"github.com/ymarcus93/gallisto/internal/encoding"
+	"github.com/ymarcus93/gallisto/oprf"
+	"github.com/ymarcus93/gallisto/suite"
+	"github.com/ymarcus93/gallisto/types"
+)
+
+func deterministicReader(t *testing.T, seed string) io.Reader {
+	r, err := detrand.New([]byte(seed))
+	if err != nil {
+		t.Fatalf("failed to create deterministic reader: %v", err)
+	}
+	return r
+}
+
+func createEntry() CallistoEntry {
+	return CallistoEntry{
+		EntryData: types.EntryData{
+			PerpetratorName: "John Smith",
+			VictimEmail:     "victim@example.com",
+		},
+		AssignmentData: types.AssignmentData{
+			VictimStateOfCurrentResidence:    "Massachusetts",
+			CategorizationOfSexualMisconduct: []types.MisconductCategory{types.MisconductHarassment},
+		},
+	}
+}
+
+// createDeterministicClient creates a client with every source of randomness
+// derived from seed
+func createDeterministicClient(t *testing.T, seed string, opts ...Option) *CallistoClient {
+	key, err := oprf.GenerateKeyWithRand(deterministicReader(t, seed+"/oprf-key"), types.OPRF_CIPHERSUITE)
+	if err != nil {
+		t.Fatalf("failed to generate OPRF key: %v", err)
+	}
+	oprfServer, err := oprf.NewOPRFServer(oprf.Config{Ciphersuite: types.OPRF_CIPHERSUITE}, key)
+	if err != nil {
+		t.Fatalf("failed to create OPRF server: %v", err)
+	}
+	pHatComputer, err := oprf.NewPHatComputer(oprfServer.Config(), oprfServer, oprf.WithRand(deterministicReader(t, seed+"/blind")))
+	if err != nil {
+		t.Fatalf("failed to create p-hat computer: %v", err)
+	}
+	client, err := NewCallistoClient(pHatComputer, append([]Option{WithRand(deterministicReader(t, seed+"/client"))}, opts...)...)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return client
+}
+
+// createDeterministicTuple runs the whole client pipeline with every source of
+// randomness derived from seed
+func createDeterministicTuple(t *testing.T, seed string, pubKeys LOCPublicKeys, opts ...Option) types.CallistoTuple {
+	client := createDeterministicClient(t, seed, opts...)
+	perpID := canonical.Identifier{Type: canonical.TypeName, Value: "John Smith"}
+	tuple, err := client.CreateCallistoTuple(perpID, createEntry(), pubKeys)
+	if err != nil {
+		t.Fatalf("failed to create tuple: %v", err)
+	}
+	return tuple
+}
+
+func TestCreateCallistoTuple_Deterministic(t *testing.T) {
+	locKeys, err := crypto.GenerateRSAKeyPair()
+	if err != nil {
+		t.Fatalf("failed to generate LOC keys: %v", err)
+	}
+	dlocKeys, err := crypto.GenerateRSAKeyPair()
+	if err != nil {
+		t.Fatalf("failed to generate DLOC keys: %v", err)
+	}
+	pubKeys := LOCPublicKeys{LOCPublicKey: locKeys.PublicKey, DLOCPublicKey: dlocKeys.PublicKey}
+
+	first := createDeterministicTuple(t, "seed", pubKeys)
+	second := createDeterministicTuple(t, "seed", pubKeys)
+	other := createDeterministicTuple(t, "other seed", pubKeys)
+
+	assert.Equal(t, first, second)
+	assert.NotEqual(t, first.UserID(), other.UserID())
+	assert.NotEqual(t, first.Pi(), other.Pi())
+
+	// The user ID is still a well-formed version 4 UUID
+	assert.Len(t, first.UserID(), 16)
+	assert.Equal(t, byte(0x40), first.UserID()[6]&0xf0)
+}
+
+func TestCreateCallistoTuple_Suite(t *testing.T) {
+	locKeys, err := crypto.GenerateRSAKeyPair()
+	if err != nil {
+		t.Fatalf("failed to generate LOC keys: %v", err)
+	}
+	pubKeys := LOCPublicKeys{LOCPublicKey: locKeys.PublicKey, DLOCPublicKey: locKeys.PublicKey}
+
+	current := createDeterministicTuple(t, "seed", pubKeys)
+	v2 := createDeterministicTuple(t, "seed", pubKeys, WithSuite(suite.V2P256))
+	assert.Equal(t, suite.Default, current.Suite())
+	assert.Equal(t, suite.V2P256, v2.Suite())
+
+	// Suites derive pi differently, so their tuples never match
+	assert.NotEqual(t, current.Pi(), v2.Pi())
+
+	_, err = NewCallistoClient(nil, WithSuite(255))
+	assert.True(t, errors.Is(err, suite.ErrUnknownSuite))
+
+	// Tuples are only created with a creatable suite of the computer's OPRF
+	// ciphersuite
+	pHatComputer, err := oprf.NewPHatComputer(oprf.Config{Ciphersuite: oprf.P256SHA256}, nil)
+	if err != nil {
+		t.Fatalf("failed to create p-hat computer: %v", err)
+	}
+	for _, id := range []suite.ID{suite.Legacy, suite.V1, suite.V2P384, suite.V3P521, suite.V3Ristretto255} {
+		_, err = NewCallistoClient(pHatComputer, WithSuite(id))
+		assert.NotNil(t, err, id)
+	}
+	_, err = NewCallistoClientContext(pHatComputer, WithSuite(suite.V3P521))
+	assert.NotNil(t, err)
+	_, err = NewCallistoClient(pHatComputer, WithSuite(suite.V2P256))
+	assert.Nil(t, err)
+}
+
+func TestCreateCallistoTuple_Binding(t *testing.T) {
+	locKeys, err := crypto.GenerateRSAKeyPair()
+	if err != nil {
+		t.Fatalf("failed to generate LOC keys: %v", err)
+	}
+	pubKeys := LOCPublicKeys{LOCPublicKey: locKeys.PublicKey, DLOCPublicKey: locKeys.PublicKey}
+
+	// Every ciphertext is bound to its component, the user ID and a fresh
+	// tuple ID
+	tuple := createDeterministicTuple(t, "seed", pubKeys)
+	other := createDeterministicTuple(t, "other seed", pubKeys)
+	assert.Len(t, tuple.TupleID(), types.TupleIDSize)
+	assert.NotEqual(t, tuple.TupleID(), other.TupleID())
+
+	current, err := suite.Lookup(suite.Default)
+	if err != nil {
+		t.Fatalf("failed to look up suite: %v", err)
+	}
+	binding := suite.Binding{UserID: tuple.UserID(), TupleID: tuple.TupleID()}
+	assert.Equal(t, current.AssociatedData(suite.EntryData, nil, binding), tuple.EncryptedEntryData().AssociatedData)
+	assert.Equal(t, current.AssociatedData(suite.AssignmentData, nil, binding), tuple.EncryptedAssignmentData().AssociatedData)
+	assert.Equal(t, current.AssociatedData(suite.EntryDataKeyForOwner, nil, binding), tuple.EncryptedEntryDataKeyUnderUserKey().AssociatedData)
+
+	encodedLOCData, err := crypto.DecryptRSA(tuple.LOCCiphertext(), locKeys.PrivateKey)
+	if err != nil {
+		t.Fatalf("failed to decrypt LOC ciphertext: %v", err)
+	}
+	locData, err := encoding.DecodeLOCData(encodedLOCData)
+	if assert.Nil(t, err) {
+		assert.Equal(t, binding, locData.Binding())
+		assert.Equal(t, current.AssociatedData(suite.EntryDataKey, nil, binding), locData.EncryptedKey().AssociatedData)
+	}
+
+	// Earlier suites use pi throughout and have no tuple ID
+	v2 := createDeterministicTuple(t, "seed", pubKeys, WithSuite(suite.V2P256))
+	assert.Nil(t, v2.TupleID())
+	assert.Equal(t, v2.Pi(), v2.EncryptedEntryData().AssociatedData)
+	assert.Equal(t, v2.Pi(), v2.EncryptedAssignmentData().AssociatedData)
+}
+
+func TestCreateCallistoTuple_Certificate(t *testing.T) {
+	locKeys, err := crypto.GenerateRSAKeyPair()
+	if err != nil {
+		t.Fatalf("failed to generate LOC keys: %v", err)
+	}
+	pubKeys := LOCPublicKeys{LOCPublicKey: locKeys.PublicKey, DLOCPublicKey: locKeys.PublicKey}
+
+	_, signingKey, err := ed25519.GenerateKey(nil)
+	assert.Nil(t, err)
+	authority, err := identity.NewAuthority(signingKey)
+	assert.Nil(t, err)
+	holderKey, holderSigningKey, err := ed25519.GenerateKey(nil)
+	assert.Nil(t, err)
+	certificate, err := authority.Enroll("alice@example.edu", holderKey)
+	assert.Nil(t, err)
+
+	tuple := createDeterministicTuple(t, "seed", pubKeys, WithCertificate(certificate), WithSigningKey(holderSigningKey))
+	assert.Equal(t, certificate.UserID, tuple.UserID())
+	verifier, err := identity.NewVerifier(authority.PublicKey())
+	assert.Nil(t, err)
+	certifiedKey, err := verifier.Verify(tuple.UserID(), tuple.UserCertificate())
+	assert.Nil(t, err)
+	assert.Nil(t, tuple.VerifySignature(certifiedKey))
+
+	// Uncertified clients submit no certificate
+	assert.Nil(t, createDeterministicTuple(t, "seed", pubKeys).UserCertificate())
+
+	// Certified clients must sign with the certificate's holder key
+	_, otherSigningKey, err := ed25519.GenerateKey(nil)
+	assert.Nil(t, err)
+	for name, opts := range map[string][]Option{
+		"invalid certificate": {WithCertificate(identity.Certificate{UserID: certificate.UserID}), WithSigningKey(holderSigningKey)},
+		"unsigned":            {WithCertificate(certificate)},
+		"other signing key":   {WithCertificate(certificate), WithSigningKey(otherSigningKey)},
+	} {
+		_, err = NewCallistoClient(nil, opts...)
+		assert.NotNil(t, err, name)
+	}
+}
+
+func TestCreateCallistoTuple_Signed(t *testing.T) {
+	locKeys, err := crypto.GenerateRSAKeyPair()
+	if err != nil {
+		t.Fatalf("failed to generate LOC keys: %v", err)
+	}
+	pubKeys := LOCPublicKeys{LOCPublicKey: locKeys.PublicKey, DLOCPublicKey: locKeys.PublicKey}
+
+	publicKey, signingKey, err := ed25519.GenerateKey(nil)
+	assert.Nil(t, err)
+	tuple := createDeterministicTuple(t, "seed", pubKeys, WithSigningKey(signingKey))
+	assert.Nil(t, tuple.VerifySignature(publicKey))
+	assert.Nil(t, createDeterministicTuple(t, "seed", pubKeys).Signature())
+
+	client, err := NewCallistoClient(nil, WithSigningKey(signingKey))
+	assert.Nil(t, err)
+	assert.Equal(t, publicKey, client.SigningPublicKey())
+	_, err = NewCallistoClient(nil, WithSigningKey(signingKey[:32]))
+	assert.NotNil(t, err)
+}
+
+// trustedKeys is a KeyDirectory listing a single LOC key for both LOCs
+type trustedKeys struct {
+	suite   suite.ID
+	key     *rsa.PublicKey
+	oprfKey oprf.PublicKey
+}
+
+func (k trustedKeys) CheckKeys(id suite.ID, locPublicKey, dlocPublicKey *rsa.PublicKey) error {
+	if id != k.suite || !k.key.Equal(locPublicKey) || !k.key.Equal(dlocPublicKey) {
+		return errUntrustedKey
+	}
+	return nil
+}
+
+func (k trustedKeys) CheckOPRFKey(id suite.ID, key oprf.PublicKey) error {
+	listed, err := k.oprfKey.MarshalBinary()
+	if err != nil {
+		return errUntrustedKey
+	}
+	encoded, err := key.MarshalBinary()
+	if id != k.suite || err != nil || !bytes.Equal(listed, encoded) {
+		return errUntrustedKey
+	}
+	return nil
+}
+
+var errUntrustedKey = errors.New("untrusted key")
+
+// createVerifiableClient creates a client whose p-hat computer verifies
+// evaluations of an evaluator holding key
+func createVerifiableClient(t *testing.T, key oprf.SecretKey, opts ...Option) *CallistoClient {
+	config := oprf.Config{Ciphersuite: types.OPRF_CIPHERSUITE, Mode: oprf.VerifiableMode}
+	oprfServer, err := oprf.NewOPRFServer(config, key)
+	if err != nil {
+		t.Fatalf("failed to create OPRF server: %v", err)
+	}
+	pHatComputer, err := oprf.NewPHatComputer(config, oprfServer, oprf.WithPublicKey(key.PublicKey()))
+	if err != nil {
+		t.Fatalf("failed to create p-hat computer: %v", err)
+	}
+	client, err := NewCallistoClient(pHatComputer, opts...)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return client
+}
+
+func TestCreateCallistoTuple_KeyDirectory(t *testing.T) {
+	locKeys, err := crypto.GenerateRSAKeyPair()
+	if err != nil {
+		t.Fatalf("failed to generate LOC keys: %v", err)
+	}
+	attackerKeys, err := crypto.GenerateRSAKeyPair()
+	if err != nil {
+		t.Fatalf("failed to generate attacker keys: %v", err)
+	}
+	oprfKey, err := oprf.GenerateKey(types.OPRF_CIPHERSUITE)
+	if err != nil {
+		t.Fatalf("failed to generate OPRF key: %v", err)
+	}
+	attackerOPRFKey, err := oprf.GenerateKey(types.OPRF_CIPHERSUITE)
+	if err != nil {
+		t.Fatalf("failed to generate attacker OPRF key: %v", err)
+	}
+	directory := trustedKeys{suite: suite.Default, key: locKeys.PublicKey, oprfKey: oprfKey.PublicKey()}
+	client := createVerifiableClient(t, oprfKey, WithKeyDirectory(directory))
+	perpID := canonical.Identifier{Type: canonical.TypeName, Value: "John Smith"}
+	pubKeys := LOCPublicKeys{LOCPublicKey: locKeys.PublicKey, DLOCPublicKey: locKeys.PublicKey}
+
+	_, err = client.CreateCallistoTuple(perpID, createEntry(), pubKeys)
+	assert.Nil(t, err)
+	_, err = client.CreateCallistoTuple(perpID, createEntry(), LOCPublicKeys{LOCPublicKey: attackerKeys.PublicKey, DLOCPublicKey: locKeys.PublicKey})
+	assert.True(t, errors.Is(err, errUntrustedKey), err)
+	_, err = client.CreateCallistoTuples(perpID, createEntry(), LOCPublicKeys{LOCPublicKey: locKeys.PublicKey, DLOCPublicKey: attackerKeys.PublicKey})
+	assert.True(t, errors.Is(err, errUntrustedKey), err)
+
+	// Keys are checked for the client's suite
+	v2Client := createVerifiableClient(t, oprfKey, WithSuite(suite.V2P256), WithKeyDirectory(directory))
+	_, err = v2Client.CreateCallistoTuple(perpID, createEntry(), pubKeys)
+	assert.True(t, errors.Is(err, errUntrustedKey), err)
+
+	// Verified evaluations must come from the listed OPRF key
+	_, err = createVerifiableClient(t, attackerOPRFKey, WithKeyDirectory(directory)).CreateCallistoTuple(perpID, createEntry(), pubKeys)
+	assert.True(t, errors.Is(err, errUntrustedKey), err)
+
+	// Unverified evaluations could come from any OPRF key
+	oprfServer, err := oprf.NewOPRFServer(oprf.Config{Ciphersuite: types.OPRF_CIPHERSUITE}, attackerOPRFKey)
+	if err != nil {
+		t.Fatalf("failed to create OPRF server: %v", err)
+	}
+	pHatComputer, err := oprf.NewPHatComputer(oprfServer.Config(), oprfServer, oprf.WithPublicKey(oprfKey.PublicKey()))
+	if err != nil {
+		t.Fatalf("failed to create p-hat computer: %v", err)
+	}
+	_, err = NewCallistoClient(pHatComputer, WithKeyDirectory(directory))
+	assert.True(t, errors.Is(err, errUnverifiedEvaluations), err)
+}
@@ -0,0 +1,183 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ymarcus93/gallisto/canonical"
+	"github.com/ymarcus93/gallisto/crypto"
+	"github.com/ymarcus93/gallisto/internal/encoding"
+	"github.com/ymarcus93/gallisto/suite"
+	"github.com/ymarcus93/gallisto/timestamp"
+	"github.com/ymarcus93/gallisto/types"
+)
+
+// ErrRecordMismatch is returned when the content of an exported record is not
+// what its tuple encrypts
+var ErrRecordMismatch = errors.New("record content does not match its tuple")
+
+// EntryRecord is a client's own copy of an entry it submitted: the entry, the
+// tuple created for it and the data keys the tuple's ciphertexts were
+// encrypted with. Keep it with the user; the keys decrypt the tuple's entry
+// and assignment data.
+type EntryRecord struct {
+	Entry             CallistoEntry
+	Tuple             types.CallistoTuple
+	EntryDataKey      []byte // k_e
+	AssignmentDataKey []byte // k_a
+	// Timestamp over the tuple and the entry, set by RequestTimestamp
+	Timestamp *timestamp.Token
+}
+
+// CreateEntryRecords is like CreateCallistoTuples but returns a record of each
+// tuple, to be kept by the user once the tuple is submitted
+func (c *CallistoClient) CreateEntryRecords(perpID canonical.Identifier, entry CallistoEntry, pubKeys LOCPublicKeys) ([]EntryRecord, error) {
+	return c.CreateEntryRecordsContext(context.Background(), perpID, entry, pubKeys)
+}
+
+// CreateEntryRecordsContext is like CreateEntryRecords but passes ctx on to
+// every p-hat computation
+func (c *CallistoClient) CreateEntryRecordsContext(ctx context.Context, perpID canonical.Identifier, entry CallistoEntry, pubKeys LOCPublicKeys) ([]EntryRecord, error) {
+	return c.createEntryRecords(ctx, perpID, entry, pubKeys)
+}
+
+// Timestamper issues timestamp tokens. timestamp.Authority implements it.
+type Timestamper interface {
+	Timestamp(req timestamp.Request) (timestamp.Token, error)
+}
+
+// RequestTimestamp has timestamper timestamp the record's imprint, which
+// commits to the tuple and the entry (see VerifyRecord), checks the token with
+// verifier and keeps it in the record. Call it when the tuple is submitted.
+func (r *EntryRecord) RequestTimestamp(timestamper Timestamper, verifier *timestamp.Verifier) error {
+	entryData, err := encoding.EncodeEntryData(r.Entry.EntryData)
+	if err != nil {
+		return err
+	}
+	assignmentData, err := encoding.EncodeAssignmentData(r.Entry.AssignmentData)
+	if err != nil {
+		return err
+	}
+	req, err := timestamp.NewRequest(recordImprint(r.Tuple, entryData, assignmentData))
+	if err != nil {
+		return err
+	}
+	token, err := timestamper.Timestamp(req)
+	if err != nil {
+		return fmt.Errorf("failed to timestamp tuple: %w", err)
+	}
+	if err := verifier.VerifyResponse(req, token); err != nil {
+		return err
+	}
+	r.Timestamp = &token
+	return nil
+}
+
+// recordImprintLabel separates record imprints from other hashes
+var recordImprintLabel = []byte("gallisto/record/v1/imprint")
+
+// recordImprint returns the SHA-256 of a label, the tuple's hash and the
+// SHA-256 of the encoded entry and assignment data. AES-GCM does not commit to
+// its key, so a submitter could craft a tuple whose ciphertexts decrypt to
+// other content under other keys; timestamping the content as well fixes it.
+func recordImprint(tuple types.CallistoTuple, entryData, assignmentData []byte) timestamp.Hash {
+	tupleHash := tuple.Hash()
+	entryDataHash := sha256.Sum256(entryData)
+	assignmentDataHash := sha256.Sum256(assignmentData)
+	b := append([]byte(nil), recordImprintLabel...)
+	b = append(b, tupleHash[:]...)
+	b = append(b, entryDataHash[:]...)
+	b = append(b, assignmentDataHash[:]...)
+	return sha256.Sum256(b)
+}
+
+// ExportedRecord is an entry record in a form that anyone trusting the
+// timestamp authority can verify with VerifyRecord: the entry's content, the
+// tuple encrypting it, the tuple's data keys and the timestamp over both.
+// It discloses the entry's content and nothing else; the data keys only
+// decrypt this tuple.
+type ExportedRecord struct {
+	EntryData         types.EntryData      `json:"entryData"`
+	AssignmentData    types.AssignmentData `json:"assignmentData"`
+	Tuple             []byte               `json:"tuple"` // Encoded as for storage
+	EntryDataKey      []byte               `json:"entryDataKey"`
+	AssignmentDataKey []byte               `json:"assignmentDataKey"`
+	Timestamp         timestamp.Token      `json:"timestamp"`
+}
+
+// Export returns the record for verification by others. The record must be
+// timestamped.
+func (r EntryRecord) Export() (ExportedRecord, error) {
+	if r.Timestamp == nil {
+		return ExportedRecord{}, fmt.Errorf("record has no timestamp")
+	}
+	encodedTuple, err := encoding.EncodeCallistoTuple(r.Tuple)
+	if err != nil {
+		return ExportedRecord{}, err
+	}
+	return ExportedRecord{
+		EntryData:         r.Entry.EntryData,
+		AssignmentData:    r.Entry.AssignmentData,
+		Tuple:             encodedTuple,
+		EntryDataKey:      r.EntryDataKey,
+		AssignmentDataKey: r.AssignmentDataKey,
+		Timestamp:         *r.Timestamp,
+	}, nil
+}
+
+// VerifyRecord checks that the authority of verifier timestamped the record's
+// tuple together with its entry and assignment data, and that the tuple
+// encrypts them under its data keys. It returns the time the tuple was
+// timestamped, which the entry existed by. A record whose content differs from
+// what was timestamped returns an error wrapping timestamp.ErrInvalidToken, and
+// one whose content differs from its tuple an error wrapping
+// ErrRecordMismatch.
+func VerifyRecord(record ExportedRecord, verifier *timestamp.Verifier) (time.Time, error) {
+	tuple, err := encoding.DecodeCallistoTuple(record.Tuple)
+	if err != nil {
+		return time.Time{}, err
+	}
+	entryData, err := encoding.EncodeEntryData(record.EntryData)
+	if err != nil {
+		return time.Time{}, err
+	}
+	assignmentData, err := encoding.EncodeAssignmentData(record.AssignmentData)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return verifyRecord(tuple, entryData, assignmentData, record.EntryDataKey, record.AssignmentDataKey, record.Timestamp, verifier)
+}
+
+// verifyRecord is VerifyRecord on the encoded entry and assignment data
+func verifyRecord(tuple types.CallistoTuple, entryData, assignmentData, entryDataKey, assignmentDataKey []byte, token timestamp.Token, verifier *timestamp.Verifier) (time.Time, error) {
+	if err := verifier.Verify(token, recordImprint(tuple, entryData, assignmentData)); err != nil {
+		return time.Time{}, err
+	}
+	s, err := suite.Lookup(tuple.Suite())
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := checkDecryptsTo(s, entryDataKey, tuple.EncryptedEntryData(), entryData); err != nil {
+		return time.Time{}, fmt.Errorf("entry data: %w", err)
+	}
+	if err := checkDecryptsTo(s, assignmentDataKey, tuple.EncryptedAssignmentData(), assignmentData); err != nil {
+		return time.Time{}, fmt.Errorf("assignment data: %w", err)
+	}
+	return token.Time, nil
+}
+
+// checkDecryptsTo checks that ciphertext decrypts to plaintext under key
+func checkDecryptsTo(s *suite.Suite, key []byte, ciphertext crypto.GCMCiphertext, plaintext []byte) error {
+	decrypted, err := s.AEAD.Decrypt(key, ciphertext)
+	if err != nil {
+		return fmt.Errorf("%w: key does not decrypt the tuple: %v", ErrRecordMismatch, err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		return fmt.Errorf("%w: tuple encrypts other content", ErrRecordMismatch)
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package client
+
+import (
+	"crypto/aes"
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ymarcus93/gallisto/canonical"
+	"github.com/ymarcus93/gallisto/crypto"
+	"github.com/ymarcus93/gallisto/internal/encoding"
+	helper "github.com/ymarcus93/gallisto/internal/test"
+	"github.com/ymarcus93/gallisto/suite"
+	"github.com/ymarcus93/gallisto/timestamp"
+	"github.com/ymarcus93/gallisto/types"
+)
+
+func createTimestampAuthority(t *testing.T, now time.Time) (*timestamp.Authority, *timestamp.Verifier) {
+	_, authorityKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("failed to generate authority key: %v", err)
+	}
+	authority, err := timestamp.NewAuthority(authorityKey, timestamp.WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatalf("failed to create timestamp authority: %v", err)
+	}
+	verifier, err := timestamp.NewVerifier(authority.PublicKey())
+	if err != nil {
+		t.Fatalf("failed to create timestamp verifier: %v", err)
+	}
+	return authority, verifier
+}
+
+func TestEntryRecord_Export(t *testing.T) {
+	locKeys, err := crypto.GenerateRSAKeyPair()
+	if err != nil {
+		t.Fatalf("failed to generate LOC keys: %v", err)
+	}
+	pubKeys := LOCPublicKeys{LOCPublicKey: locKeys.PublicKey, DLOCPublicKey: locKeys.PublicKey}
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	authority, verifier := createTimestampAuthority(t, now)
+
+	client := createDeterministicClient(t, "seed", WithFuzzyMatching())
+	entry := createEntry()
+	entry.EntryData.Incident = &types.Incident{StartDate: time.Date(2023, time.May, 4, 0, 0, 0, 0, time.UTC), Location: "Boston"}
+	perpID := canonical.Identifier{Type: canonical.TypeName, Value: "John Smith"}
+	records, err := client.CreateEntryRecords(perpID, entry, pubKeys)
+	if err != nil {
+		t.Fatalf("failed to create entry records: %v", err)
+	}
+	assert.Greater(t, len(records), 1)
+	assert.Equal(t, entry, records[0].Entry)
+	assert.Equal(t, types.MatchExact, records[0].Tuple.MatchClass())
+
+	record := records[0]
+	_, err = record.Export()
+	assert.NotNil(t, err)
+	assert.Nil(t, record.RequestTimestamp(authority, verifier))
+	exported, err := record.Export()
+	if err != nil {
+		t.Fatalf("failed to export record: %v", err)
+	}
+
+	// The record verifies after a round trip through JSON
+	encoded, err := json.Marshal(exported)
+	if err != nil {
+		t.Fatalf("failed to encode record: %v", err)
+	}
+	var decoded ExportedRecord
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("failed to decode record: %v", err)
+	}
+	timestamped, err := VerifyRecord(decoded, verifier)
+	assert.Nil(t, err)
+	assert.Equal(t, now, timestamped)
+
+	otherContent := exported
+	otherContent.EntryData.VictimName = "Someone Else"
+	otherKey := exported
+	otherKey.AssignmentDataKey = records[1].AssignmentDataKey
+	otherTuple := exported
+	otherTuple.Tuple, err = encoding.EncodeCallistoTuple(records[1].Tuple)
+	if err != nil {
+		t.Fatalf("failed to encode tuple: %v", err)
+	}
+	backdated := exported
+	backdated.Timestamp.Time = now.Add(-24 * time.Hour)
+
+	tests := map[string]struct {
+		record      ExportedRecord
+		expectedErr error
+	}{
+		"other content": {
+			record:      otherContent,
+			expectedErr: timestamp.ErrInvalidToken,
+		},
+		"other key": {
+			record:      otherKey,
+			expectedErr: ErrRecordMismatch,
+		},
+		"other tuple": {
+			record:      otherTuple,
+			expectedErr: timestamp.ErrInvalidToken,
+		},
+		"backdated": {
+			record:      backdated,
+			expectedErr: timestamp.ErrInvalidToken,
+		},
+	}
+
+	for testName, tc := range tests {
+		t.Run(testName, func(t *testing.T) {
+			_, err := VerifyRecord(tc.record, verifier)
+			assert.True(t, errors.Is(err, tc.expectedErr), err)
+		})
+	}
+}
+
+func TestVerifyRecord_TwoKeys(t *testing.T) {
+	locKeys, err := crypto.GenerateRSAKeyPair()
+	if err != nil {
+		t.Fatalf("failed to generate LOC keys: %v", err)
+	}
+	pubKeys := LOCPublicKeys{LOCPublicKey: locKeys.PublicKey, DLOCPublicKey: locKeys.PublicKey}
+	authority, verifier := createTimestampAuthority(t, time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC))
+	perpID := canonical.Identifier{Type: canonical.TypeName, Value: "John Smith"}
+	records, err := createDeterministicClient(t, "seed").CreateEntryRecords(perpID, createEntry(), pubKeys)
+	if err != nil {
+		t.Fatalf("failed to create entry records: %v", err)
+	}
+	record := records[0]
+	assignmentData, err := encoding.EncodeAssignmentData(record.Entry.AssignmentData)
+	if err != nil {
+		t.Fatalf("failed to encode assignment data: %v", err)
+	}
+
+	// A submitter crafts entry data that decrypts under two keys
+	firstKey := helper.GenerateRandomBytes(32, t)
+	secondKey := helper.GenerateRandomBytes(32, t)
+	ciphertext, first, second := twoKeyCiphertext(t, firstKey, secondKey, record.Tuple.EncryptedEntryData().AssociatedData, 4)
+	tuple, err := types.NewCallistoTuple(record.Tuple.UserID(), record.Tuple.Pi(), record.Tuple.LOCCiphertext(), record.Tuple.DLOCCiphertext(),
+		record.Tuple.EncryptedEntryDataKeyUnderUserKey(), ciphertext, record.Tuple.EncryptedAssignmentData())
+	if err != nil {
+		t.Fatalf("failed to create tuple: %v", err)
+	}
+	tuple = tuple.WithSuite(record.Tuple.Suite()).WithTupleID(record.Tuple.TupleID())
+	s, err := suite.Lookup(tuple.Suite())
+	if err != nil {
+		t.Fatalf("failed to look up suite: %v", err)
+	}
+	assert.NotEqual(t, first, second)
+	assert.Nil(t, checkDecryptsTo(s, firstKey, ciphertext, first))
+	assert.Nil(t, checkDecryptsTo(s, secondKey, ciphertext, second))
+
+	// The timestamp fixes the content, so only the first key verifies
+	req, err := timestamp.NewRequest(recordImprint(tuple, first, assignmentData))
+	if err != nil {
+		t.Fatalf("failed to create timestamp request: %v", err)
+	}
+	token, err := authority.Timestamp(req)
+	if err != nil {
+		t.Fatalf("failed to timestamp record: %v", err)
+	}
+	_, err = verifyRecord(tuple, first, assignmentData, firstKey, record.AssignmentDataKey, token, verifier)
+	assert.Nil(t, err)
+	_, err = verifyRecord(tuple, second, assignmentData, secondKey, record.AssignmentDataKey, token, verifier)
+	assert.True(t, errors.Is(err, timestamp.ErrInvalidToken), err)
+}
+
+// twoKeyCiphertext returns an AES-GCM ciphertext of the given number of blocks
+// that authenticates under both keys, and what it decrypts to under each. Its
+// last block is chosen so that the GHASH of both keys gives the same tag.
+func twoKeyCiphertext(t *testing.T, firstKey, secondKey, associatedData []byte, blocks int) (crypto.GCMCiphertext, []byte, []byte) {
+	nonce := helper.GenerateRandomBytes(12, t)
+	ciphertext := append(helper.GenerateRandomBytes(16*(blocks-1), t), make([]byte, 16)...)
+
+	var hashKeys, tagMasks, ghashes [2]gfElement
+	var keyStreams [2][]byte
+	for i, key := range [][]byte{firstKey, secondKey} {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			t.Fatalf("failed to create cipher: %v", err)
+		}
+		counterBlock := func(counter uint32) []byte {
+			out := make([]byte, 16)
+			block.Encrypt(out, binary.BigEndian.AppendUint32(append([]byte(nil), nonce...), counter))
+			return out
+		}
+		hashKey := make([]byte, 16)
+		block.Encrypt(hashKey, hashKey)
+		hashKeys[i] = newGFElement(hashKey)
+		tagMasks[i] = newGFElement(counterBlock(1))
+		for j := 0; j < blocks; j++ {
+			keyStreams[i] = append(keyStreams[i], counterBlock(uint32(j+2))...)
+		}
+		ghashes[i] = ghash(hashKeys[i], associatedData, ciphertext)
+	}
+
+	// With a last block x, the tags differ by x(H1^2 + H2^2) + G1 + G2 + S1 + S2
+	h1, h2 := hashKeys[0].mul(hashKeys[0]), hashKeys[1].mul(hashKeys[1])
+	constant := ghashes[0].add(ghashes[1]).add(tagMasks[0]).add(tagMasks[1])
+	last := constant.mul(h1.add(h2).inverse()).bytes()
+	copy(ciphertext[16*(blocks-1):], last)
+	tag := ghash(hashKeys[0], associatedData, ciphertext).add(tagMasks[0]).bytes()
+
+	plaintexts := make([][]byte, 2)
+	for i := range plaintexts {
+		plaintexts[i] = make([]byte, len(ciphertext))
+		for j := range ciphertext {
+			plaintexts[i][j] = ciphertext[j] ^ keyStreams[i][j]
+		}
+	}
+	sealed := crypto.GCMCiphertext{Nonce: nonce, Ciphertext: append(ciphertext, tag...), AssociatedData: associatedData}
+	return sealed, plaintexts[0], plaintexts[1]
+}
+
+// gfElement is an element of GCM's GF(2^128), in its bit-reflected order
+type gfElement struct {
+	hi, lo uint64
+}
+
+func newGFElement(b []byte) gfElement {
+	return gfElement{hi: binary.BigEndian.Uint64(b[:8]), lo: binary.BigEndian.Uint64(b[8:16])}
+}
+
+func (x gfElement) bytes() []byte {
+	return binary.BigEndian.AppendUint64(binary.BigEndian.AppendUint64(nil, x.hi), x.lo)
+}
+
+func (x gfElement) add(y gfElement) gfElement {
+	return gfElement{hi: x.hi ^ y.hi, lo: x.lo ^ y.lo}
+}
+
+// mul multiplies as in NIST SP 800-38D, algorithm 1
+func (x gfElement) mul(y gfElement) gfElement {
+	var z gfElement
+	v := y
+	for i := 0; i < 128; i++ {
+		var bit uint64
+		if i < 64 {
+			bit = x.hi >> (63 - i) & 1
+		} else {
+			bit = x.lo >> (127 - i) & 1
+		}
+		if bit == 1 {
+			z = z.add(v)
+		}
+		carry := v.lo & 1
+		v.lo = v.lo>>1 | v.hi<<63
+		v.hi >>= 1
+		if carry == 1 {
+			v.hi ^= 0xe1 << 56
+		}
+	}
+	return z
+}
+
+// inverse returns x^(2^128-2), the product of x^(2^i) for i from 1 to 127
+func (x gfElement) inverse() gfElement {
+	result := gfElement{hi: 1 << 63}
+	power := x
+	for i := 1; i < 128; i++ {
+		power = power.mul(power)
+		result = result.mul(power)
+	}
+	return result
+}
+
+func ghash(hashKey gfElement, associatedData, ciphertext []byte) gfElement {
+	var y gfElement
+	absorb := func(data []byte) {
+		for len(data) > 0 {
+			block := make([]byte, 16)
+			n := copy(block, data)
+			data = data[n:]
+			y = y.add(newGFElement(block)).mul(hashKey)
+		}
+	}
+	absorb(associatedData)
+	absorb(ciphertext)
+	lengths := binary.BigEndian.AppendUint64(binary.BigEndian.AppendUint64(nil, uint64(len(associatedData))*8), uint64(len(ciphertext))*8)
+	return y.add(newGFElement(lengths)).mul(hashKey)
+}
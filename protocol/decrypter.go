@@ -0,0 +1,511 @@
+package protocol
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ymarcus93/gallisto/audit"
+	"github.com/ymarcus93/gallisto/crypto"
+	"github.com/ymarcus93/gallisto/internal/encoding"
+	"github.com/ymarcus93/gallisto/suite"
+	"github.com/ymarcus93/gallisto/types"
+)
+
+// LOCEncrypted is implemented by matched entries that carry data for the LOC,
+// such as types.CallistoTuple
+type LOCEncrypted interface {
+	Suite() suite.ID
+	LOCCiphertext() []byte
+	EncryptedEntryData() crypto.GCMCiphertext
+}
+
+// DLOCEncrypted is implemented by matched entries that carry data for the
+// DLOC, such as types.CallistoTuple
+type DLOCEncrypted interface {
+	Suite() suite.ID
+	DLOCCiphertext() []byte
+	EncryptedAssignmentData() crypto.GCMCiphertext
+}
+
+// TupleIdentified is implemented by matched entries that carry the ID their
+// ciphertexts are bound to, such as types.CallistoTuple
+type TupleIdentified interface {
+	TupleID() []byte
+}
+
+// Decrypter decrypts the entry and assignment data of matches with a pool of
+// workers. Decrypted data is returned in the same order as its ciphertexts.
+type Decrypter struct {
+	concurrency int
+	suite       *suite.ID // Suite of the ciphertexts, if asserted with WithSuite
+	audit       audit.Recorder
+}
+
+// DecrypterOption configures optional Decrypter behaviour
+type DecrypterOption func(*Decrypter)
+
+// WithConcurrency sets how many decryptions run at the same time
+func WithConcurrency(concurrency int) DecrypterOption {
+	return func(d *Decrypter) {
+		d.concurrency = concurrency
+	}
+}
+
+// WithSuite makes DecryptEntryData and DecryptAssignmentData refuse
+// ciphertexts of any protocol suite but id, with an error wrapping
+// ErrSuiteMismatch. Without it, they decrypt with the suite named by the
+// decrypted (D)LOC data. The batch methods use the suite of each matched entry
+// instead.
+func WithSuite(id suite.ID) DecrypterOption {
+	return func(d *Decrypter) {
+		d.suite = &id
+	}
+}
+
+// WithAuditLog makes the decrypter record every call of its decryption methods
+// with recorder, naming the matches or ciphertexts it was given. A call is
+// recorded before anything is decrypted, and nothing is decrypted if it cannot
+// be recorded. Give each (D)LOC its own actor with audit.WithActor.
+func WithAuditLog(recorder audit.Recorder) DecrypterOption {
+	return func(d *Decrypter) {
+		d.audit = recorder
+	}
+}
+
+// NewDecrypter returns a Decrypter. By default it runs one decryption per CPU
+// at a time.
+func NewDecrypter(opts ...DecrypterOption) *Decrypter {
+	d := &Decrypter{concurrency: runtime.GOMAXPROCS(0)}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.concurrency < 1 {
+		d.concurrency = 1
+	}
+	return d
+}
+
+// lockKind describes the data held for one of the (D)LOCs
+type lockKind struct {
+	locType       types.LOCType
+	name          string          // LOC or DLOC
+	data          string          // What the (D)LOC decrypts
+	keyComponent  suite.Component // The data key under k
+	dataComponent suite.Component // The data under its key
+	action        audit.Action    // How a decryption is recorded
+}
+
+var (
+	locKind = lockKind{
+		locType:       types.Counselor,
+		name:          "LOC",
+		data:          "entry data",
+		keyComponent:  suite.EntryDataKey,
+		dataComponent: suite.EntryData,
+		action:        audit.DecryptEntryData,
+	}
+	dlocKind = lockKind{
+		locType:       types.Director,
+		name:          "DLOC",
+		data:          "assignment data",
+		keyComponent:  suite.AssignmentDataKey,
+		dataComponent: suite.AssignmentData,
+		action:        audit.DecryptAssignmentData,
+	}
+)
+
+// lockedData holds the ciphertexts of one match for a (D)LOC
+type lockedData struct {
+	// The suite of the ciphertexts. If it is nil, the suite named by the
+	// (D)LOC data is used.
+	suite          *suite.ID
+	locCiphertexts [][]byte
+	encryptedData  []crypto.GCMCiphertext
+	// The user ID and tuple ID of each matched entry, if known
+	bindings []suite.Binding
+}
+
+// DecryptEntryData is the parallel version of the package level
+// DecryptEntryData
+func (d *Decrypter) DecryptEntryData(locCiphertexts [][]byte, encryptedEntryData []crypto.GCMCiphertext, locPrivateKey *rsa.PrivateKey) ([]types.EntryData, error) {
+	if len(locCiphertexts) != len(encryptedEntryData) {
+		return nil, fmt.Errorf("%w: between locCiphertexts and encrypted entry data", ErrLengthMismatch)
+	}
+
+	if err := d.recordCiphertexts(locKind, locCiphertexts); err != nil {
+		return nil, err
+	}
+	entryDatas := make([]types.EntryData, len(locCiphertexts))
+	_, err := d.decrypt(locKind, []lockedData{{suite: d.suite, locCiphertexts: locCiphertexts, encryptedData: encryptedEntryData}}, locPrivateKey,
+		func(_, i int, plaintext []byte) (err error) {
+			entryDatas[i], err = encoding.DecodeEntryData(plaintext)
+			return err
+		})
+	if err != nil {
+		return nil, err
+	}
+	return entryDatas, nil
+}
+
+// DecryptAssignmentData is the parallel version of the package level
+// DecryptAssignmentData
+func (d *Decrypter) DecryptAssignmentData(dlocCiphertexts [][]byte, encryptedAssignmentData []crypto.GCMCiphertext, dlocPrivateKey *rsa.PrivateKey) ([]types.AssignmentData, error) {
+	if len(dlocCiphertexts) != len(encryptedAssignmentData) {
+		return nil, fmt.Errorf("%w: between dlocCiphertexts and encrypted assignment data", ErrLengthMismatch)
+	}
+
+	if err := d.recordCiphertexts(dlocKind, dlocCiphertexts); err != nil {
+		return nil, err
+	}
+	assignmentDatas := make([]types.AssignmentData, len(dlocCiphertexts))
+	_, err := d.decrypt(dlocKind, []lockedData{{suite: d.suite, locCiphertexts: dlocCiphertexts, encryptedData: encryptedAssignmentData}}, dlocPrivateKey,
+		func(_, i int, plaintext []byte) (err error) {
+			assignmentDatas[i], err = encoding.DecodeAssignmentData(plaintext)
+			return err
+		})
+	if err != nil {
+		return nil, err
+	}
+	return assignmentDatas, nil
+}
+
+// DecryptEntryDataBatch decrypts the entry data of several matches at once.
+// Every matched entry must implement LOCEncrypted. The result holds the entry
+// data of each match in order. If a match fails to decrypt, the returned error
+// wraps an IndexError holding the index of the match.
+func (d *Decrypter) DecryptEntryDataBatch(matches []PiMatch, locPrivateKey *rsa.PrivateKey) ([][]types.EntryData, error) {
+	batch := make([]lockedData, len(matches))
+	results := make([][]types.EntryData, len(matches))
+	for m, match := range matches {
+		batch[m] = newLockedData(match)
+		for i, e := range match.MatchedEntries {
+			encrypted, ok := e.(LOCEncrypted)
+			if !ok {
+				return nil, fmt.Errorf("failed to decrypt match %w", &IndexError{Index: m, Err: fmt.Errorf("entry %v does not carry LOC data", i)})
+			}
+			if i == 0 {
+				id := encrypted.Suite()
+				batch[m].suite = &id
+			} else if encrypted.Suite() != *batch[m].suite {
+				return nil, fmt.Errorf("failed to decrypt match %w", &IndexError{Index: m, Err: fmt.Errorf("%w: entry %v is of %v, not %v", ErrSuiteMismatch, i, encrypted.Suite(), *batch[m].suite)})
+			}
+			batch[m].locCiphertexts[i] = encrypted.LOCCiphertext()
+			batch[m].encryptedData[i] = encrypted.EncryptedEntryData()
+		}
+		results[m] = make([]types.EntryData, len(match.MatchedEntries))
+	}
+
+	if err := d.recordMatches(locKind, matches); err != nil {
+		return nil, err
+	}
+	m, err := d.decrypt(locKind, batch, locPrivateKey, func(m, i int, plaintext []byte) (err error) {
+		results[m][i], err = encoding.DecodeEntryData(plaintext)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt match %w", &IndexError{Index: m, Err: err})
+	}
+	return results, nil
+}
+
+// DecryptAssignmentDataBatch decrypts the assignment data of several matches
+// at once. Every matched entry must implement DLOCEncrypted. The result holds
+// the assignment data of each match in order. If a match fails to decrypt, the
+// returned error wraps an IndexError holding the index of the match.
+func (d *Decrypter) DecryptAssignmentDataBatch(matches []PiMatch, dlocPrivateKey *rsa.PrivateKey) ([][]types.AssignmentData, error) {
+	batch := make([]lockedData, len(matches))
+	results := make([][]types.AssignmentData, len(matches))
+	for m, match := range matches {
+		batch[m] = newLockedData(match)
+		for i, e := range match.MatchedEntries {
+			encrypted, ok := e.(DLOCEncrypted)
+			if !ok {
+				return nil, fmt.Errorf("failed to decrypt match %w", &IndexError{Index: m, Err: fmt.Errorf("entry %v does not carry DLOC data", i)})
+			}
+			if i == 0 {
+				id := encrypted.Suite()
+				batch[m].suite = &id
+			} else if encrypted.Suite() != *batch[m].suite {
+				return nil, fmt.Errorf("failed to decrypt match %w", &IndexError{Index: m, Err: fmt.Errorf("%w: entry %v is of %v, not %v", ErrSuiteMismatch, i, encrypted.Suite(), *batch[m].suite)})
+			}
+			batch[m].locCiphertexts[i] = encrypted.DLOCCiphertext()
+			batch[m].encryptedData[i] = encrypted.EncryptedAssignmentData()
+		}
+		results[m] = make([]types.AssignmentData, len(match.MatchedEntries))
+	}
+
+	if err := d.recordMatches(dlocKind, matches); err != nil {
+		return nil, err
+	}
+	m, err := d.decrypt(dlocKind, batch, dlocPrivateKey, func(m, i int, plaintext []byte) (err error) {
+		results[m][i], err = encoding.DecodeAssignmentData(plaintext)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt match %w", &IndexError{Index: m, Err: err})
+	}
+	return results, nil
+}
+
+// recordCiphertexts records the decryption of (D)LOC ciphertexts, identified
+// by their number and a digest of them, if the decrypter is audited
+func (d *Decrypter) recordCiphertexts(kind lockKind, locCiphertexts [][]byte) error {
+	if d.audit == nil {
+		return nil
+	}
+	digest := sha256.New()
+	for _, c := range locCiphertexts {
+		digest.Write(c)
+	}
+	details := map[string]string{
+		"ciphertexts": strconv.Itoa(len(locCiphertexts)),
+		"digest":      hex.EncodeToString(digest.Sum(nil)),
+	}
+	if d.suite != nil {
+		details["suite"] = d.suite.String()
+	}
+	return d.record(kind, details)
+}
+
+// recordMatches records the decryption of matches, identified by the pi value
+// of each, if the decrypter is audited
+func (d *Decrypter) recordMatches(kind lockKind, matches []PiMatch) error {
+	if d.audit == nil {
+		return nil
+	}
+	pis := make([]string, len(matches))
+	var entries int
+	for m, match := range matches {
+		pi := match.SharedPiValue
+		if pi == nil && len(match.MatchedEntries) > 0 {
+			pi = match.MatchedEntries[0].Pi()
+		}
+		pis[m] = hex.EncodeToString(pi)
+		entries += len(match.MatchedEntries)
+	}
+	return d.record(kind, map[string]string{
+		"matches": strconv.Itoa(len(matches)),
+		"entries": strconv.Itoa(entries),
+		"pi":      strings.Join(pis, ","),
+	})
+}
+
+func (d *Decrypter) record(kind lockKind, details map[string]string) error {
+	if err := d.audit.Record(audit.Event{Action: kind.action, Details: details}); err != nil {
+		return fmt.Errorf("failed to record %v decryption in audit log: %w", kind.name, err)
+	}
+	return nil
+}
+
+// newLockedData returns the locked data of match with room for its
+// ciphertexts, and the binding of each matched entry
+func newLockedData(match PiMatch) lockedData {
+	data := lockedData{
+		locCiphertexts: make([][]byte, len(match.MatchedEntries)),
+		encryptedData:  make([]crypto.GCMCiphertext, len(match.MatchedEntries)),
+		bindings:       make([]suite.Binding, len(match.MatchedEntries)),
+	}
+	for i, e := range match.MatchedEntries {
+		data.bindings[i].UserID = e.UserID()
+		if identified, ok := e.(TupleIdentified); ok {
+			data.bindings[i].TupleID = identified.TupleID()
+		}
+	}
+	return data
+}
+
+// decrypt decrypts the data of every match in batch for a (D)LOC.
+// The RSA decryptions of all matches share one worker pool, as do the AES
+// decryptions. decode is called concurrently with the plaintext of data i of
+// match m.
+// If decryption fails, decrypt returns the index of the match that failed along
+// with the error.
+func (d *Decrypter) decrypt(kind lockKind, batch []lockedData, privateKey *rsa.PrivateKey, decode func(m, i int, plaintext []byte) error) (int, error) {
+	var tasks []batchTask
+	suites := make([]*suite.Suite, len(batch))
+	for m, data := range batch {
+		if data.suite != nil {
+			var err error
+			if suites[m], err = suite.Lookup(*data.suite); err != nil {
+				return m, err
+			}
+		}
+		if len(data.locCiphertexts) != len(data.encryptedData) {
+			return m, fmt.Errorf("%w: between %v ciphertexts and encrypted %v", ErrLengthMismatch, kind.name, kind.data)
+		}
+		for i := range data.locCiphertexts {
+			tasks = append(tasks, batchTask{m, i})
+		}
+	}
+
+	// Decrypt the (D)LOC ciphertexts
+	locData := make([][]types.LOCData, len(batch))
+	for m, data := range batch {
+		locData[m] = make([]types.LOCData, len(data.locCiphertexts))
+	}
+	errs := d.run(tasks, func() func(batchTask) error {
+		return func(t batchTask) error {
+			locEncryption := defaultLOCEncryption
+			if suites[t.match] != nil {
+				locEncryption = suites[t.match].LOCEncryption
+			}
+			decrypted, err := decryptLOCCiphertext(locEncryption, batch[t.match].locCiphertexts[t.index], privateKey)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt (D)LOC ciphertext %w", &IndexError{Index: t.index, Err: err})
+			}
+			locData[t.match][t.index] = decrypted
+			return nil
+		}
+	})
+	if m, err := firstError(tasks, errs); err != nil {
+		return m, fmt.Errorf("failed to decrypt %v ciphertexts: %w", kind.name, err)
+	}
+
+	// Check the data is meant for this (D)LOC and suite and find each match's
+	// k
+	kValues := make([][]byte, len(batch))
+	for m, data := range batch {
+		if !validateLOCData(locData[m], kind.locType) {
+			return m, fmt.Errorf("%w: decrypted %v ciphertexts but found data for another LOC type", ErrLOCTypeMismatch, kind.name)
+		}
+		if suites[m] == nil {
+			// Dispatch on the suite the (D)LOC data names
+			id := suite.Default
+			if len(locData[m]) > 0 {
+				id = locData[m][0].Suite()
+			}
+			var err error
+			if suites[m], err = suite.Lookup(id); err != nil {
+				return m, err
+			}
+		}
+		for i, d := range locData[m] {
+			if d.Suite() != suites[m].ID {
+				return m, fmt.Errorf("%w: %v data %v is of %v, not %v", ErrSuiteMismatch, kind.name, i, d.Suite(), suites[m].ID)
+			}
+		}
+		if suites[m].BindsTuple {
+			if err := checkBindings(kind, suites[m], data, locData[m]); err != nil {
+				return m, err
+			}
+		}
+		k, err := findKValueFromLOCData(locData[m])
+		if err != nil {
+			return m, fmt.Errorf("failed to find k value from %v ciphertexts: %w", kind.name, err)
+		}
+		kValues[m] = k
+	}
+
+	// Decrypt each data key under k, then the data under its key
+	errs = d.run(tasks, func() func(batchTask) error {
+		// Each worker sets up the cipher for k once per match
+		currentMatch := -1
+		var kCipher suite.Opener
+		return func(t batchTask) error {
+			aead := suites[t.match].AEAD
+			if t.match != currentMatch {
+				var err error
+				if kCipher, err = aead.NewOpener(kValues[t.match]); err != nil {
+					return fmt.Errorf("failed to decrypt %v key %w", kind.data, &IndexError{Index: t.index, Err: err})
+				}
+				currentMatch = t.match
+			}
+			dataKey, err := kCipher.Decrypt(locData[t.match][t.index].EncryptedKey())
+			if err != nil {
+				return fmt.Errorf("failed to decrypt %v key %w", kind.data, &IndexError{Index: t.index, Err: err})
+			}
+			plaintext, err := aead.Decrypt(dataKey, batch[t.match].encryptedData[t.index])
+			if err != nil {
+				return fmt.Errorf("failed to decrypt %v %w", kind.data, &IndexError{Index: t.index, Err: err})
+			}
+			if err := decode(t.match, t.index, plaintext); err != nil {
+				return fmt.Errorf("failed to decode %v %w", kind.data, &IndexError{Index: t.index, Err: err})
+			}
+			return nil
+		}
+	})
+	return firstError(tasks, errs)
+}
+
+// checkBindings checks that the ciphertexts of a match in a suite binding
+// tuples are bound to the tuple named by their (D)LOC data. Ciphertexts are
+// checked before they are decrypted: AEAD decryption uses the associated data
+// stored with a ciphertext, which would accept one spliced in from another
+// tuple.
+func checkBindings(kind lockKind, s *suite.Suite, data lockedData, locData []types.LOCData) error {
+	for i, d := range locData {
+		binding := d.Binding()
+		if len(binding.UserID) == 0 || len(binding.TupleID) == 0 {
+			return fmt.Errorf("%w: %v data %v does not name its tuple", ErrBindingMismatch, kind.name, i)
+		}
+		if data.bindings != nil {
+			entry := data.bindings[i]
+			if !bytes.Equal(entry.UserID, binding.UserID) || !bytes.Equal(entry.TupleID, binding.TupleID) {
+				return fmt.Errorf("%w: %v data %v belongs to another tuple than its entry", ErrBindingMismatch, kind.name, i)
+			}
+		}
+		if !bytes.Equal(d.EncryptedKey().AssociatedData, s.AssociatedData(kind.keyComponent, nil, binding)) {
+			return fmt.Errorf("%w: %v key %v", ErrBindingMismatch, kind.data, i)
+		}
+		if !bytes.Equal(data.encryptedData[i].AssociatedData, s.AssociatedData(kind.dataComponent, nil, binding)) {
+			return fmt.Errorf("%w: %v %v", ErrBindingMismatch, kind.data, i)
+		}
+	}
+	return nil
+}
+
+// batchTask identifies data i of match m
+type batchTask struct {
+	match int
+	index int
+}
+
+// run calls a worker function for every task, with up to d.concurrency
+// workers at once. newWorker is called once per worker, so workers can keep
+// state between tasks. The error of each task is returned at its index.
+func (d *Decrypter) run(tasks []batchTask, newWorker func() func(batchTask) error) []error {
+	errs := make([]error, len(tasks))
+	workers := d.concurrency
+	if workers > len(tasks) {
+		workers = len(tasks)
+	}
+
+	var next int64 = -1
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work := newWorker()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(tasks) {
+					return
+				}
+				errs[i] = work(tasks[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// firstError returns the first error in task order and the match it belongs
+// to. Reporting the first error, rather than whichever happened first, keeps
+// errors the same from run to run.
+func firstError(tasks []batchTask, errs []error) (int, error) {
+	for i, err := range errs {
+		if err != nil {
+			return tasks[i].match, err
+		}
+	}
+	return 0, nil
+}
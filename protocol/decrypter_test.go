@@ -0,0 +1,383 @@
+package protocol
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ymarcus93/gallisto/audit"
+	"github.com/ymarcus93/gallisto/canonical"
+	"github.com/ymarcus93/gallisto/crypto"
+	"github.com/ymarcus93/gallisto/oprf"
+	"github.com/ymarcus93/gallisto/protocol/client"
+	"github.com/ymarcus93/gallisto/suite"
+	"github.com/ymarcus93/gallisto/types"
+)
+
+type decrypterKeys struct {
+	oprfServer *oprf.OPRFServer
+	loc        crypto.RSAKeyPair
+	dloc       crypto.RSAKeyPair
+}
+
+func createDecrypterKeys(t testing.TB) decrypterKeys {
+	key, err := oprf.GenerateKey(types.OPRF_CIPHERSUITE)
+	if err != nil {
+		t.Fatalf("failed to generate OPRF key: %v", err)
+	}
+	oprfServer, err := oprf.NewOPRFServer(oprf.Config{Ciphersuite: types.OPRF_CIPHERSUITE}, key)
+	if err != nil {
+		t.Fatalf("failed to create OPRF server: %v", err)
+	}
+	locKeys, err := crypto.GenerateRSAKeyPair()
+	if err != nil {
+		t.Fatalf("failed to generate LOC keys: %v", err)
+	}
+	dlocKeys, err := crypto.GenerateRSAKeyPair()
+	if err != nil {
+		t.Fatalf("failed to generate DLOC keys: %v", err)
+	}
+	return decrypterKeys{oprfServer: oprfServer, loc: locKeys, dloc: dlocKeys}
+}
+
+// createMatch returns a match of numUsers tuples naming perpetrator. The entry
+// of user i has victim email i@example.com.
+func createMatch(t testing.TB, keys decrypterKeys, perpetrator string, numUsers int, opts ...client.Option) PiMatch {
+	pubKeys := client.LOCPublicKeys{LOCPublicKey: keys.loc.PublicKey, DLOCPublicKey: keys.dloc.PublicKey}
+	perpID := canonical.Identifier{Type: canonical.TypeName, Value: perpetrator}
+
+	match := PiMatch{MatchedEntries: make([]Matchable, numUsers)}
+	for i := 0; i < numUsers; i++ {
+		pHatComputer, err := oprf.NewPHatComputer(keys.oprfServer.Config(), keys.oprfServer)
+		if err != nil {
+			t.Fatalf("failed to create p-hat computer: %v", err)
+		}
+		c, err := client.NewCallistoClient(pHatComputer, opts...)
+		if err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		tuple, err := c.CreateCallistoTuple(perpID, createDecrypterEntry(perpetrator, i), pubKeys)
+		if err != nil {
+			t.Fatalf("failed to create tuple: %v", err)
+		}
+		match.MatchedEntries[i] = tuple
+		match.SharedPiValue = tuple.Pi()
+	}
+	return match
+}
+
+func createDecrypterEntry(perpetrator string, i int) client.CallistoEntry {
+	return client.CallistoEntry{
+		EntryData: types.EntryData{
+			PerpetratorName: perpetrator,
+			VictimEmail:     fmt.Sprintf("%v@example.com", i),
+		},
+		AssignmentData: types.AssignmentData{
+			VictimStateOfCurrentResidence:    "Massachusetts",
+			CategorizationOfSexualMisconduct: []types.MisconductCategory{types.MisconductHarassment},
+		},
+	}
+}
+
+func matchCiphertexts(match PiMatch) ([][]byte, []crypto.GCMCiphertext, [][]byte, []crypto.GCMCiphertext) {
+	var locCiphertexts, dlocCiphertexts [][]byte
+	var encryptedEntryData, encryptedAssignmentData []crypto.GCMCiphertext
+	for _, e := range match.MatchedEntries {
+		tuple := e.(types.CallistoTuple)
+		locCiphertexts = append(locCiphertexts, tuple.LOCCiphertext())
+		encryptedEntryData = append(encryptedEntryData, tuple.EncryptedEntryData())
+		dlocCiphertexts = append(dlocCiphertexts, tuple.DLOCCiphertext())
+		encryptedAssignmentData = append(encryptedAssignmentData, tuple.EncryptedAssignmentData())
+	}
+	return locCiphertexts, encryptedEntryData, dlocCiphertexts, encryptedAssignmentData
+}
+
+func TestDecrypter(t *testing.T) {
+	keys := createDecrypterKeys(t)
+	match := createMatch(t, keys, "John Smith", 5)
+	locCiphertexts, encryptedEntryData, dlocCiphertexts, encryptedAssignmentData := matchCiphertexts(match)
+
+	tests := map[string]struct {
+		decrypter *Decrypter
+	}{
+		"sequential":     {decrypter: NewDecrypter(WithConcurrency(1))},
+		"more workers":   {decrypter: NewDecrypter(WithConcurrency(3))},
+		"excess workers": {decrypter: NewDecrypter(WithConcurrency(64))},
+		"default":        {decrypter: NewDecrypter()},
+	}
+
+	for testName, tc := range tests {
+		t.Run(testName, func(t *testing.T) {
+			entryData, err := tc.decrypter.DecryptEntryData(locCiphertexts, encryptedEntryData, keys.loc.PrivateKey)
+			assert.Nil(t, err)
+			assignmentData, err := tc.decrypter.DecryptAssignmentData(dlocCiphertexts, encryptedAssignmentData, keys.dloc.PrivateKey)
+			assert.Nil(t, err)
+
+			// Results are in the order of the ciphertexts
+			assert.Len(t, entryData, 5)
+			assert.Len(t, assignmentData, 5)
+			for i := range entryData {
+				expected := createDecrypterEntry("John Smith", i)
+				assert.Equal(t, expected.EntryData, entryData[i])
+				assert.Equal(t, expected.AssignmentData, assignmentData[i])
+			}
+		})
+	}
+}
+
+func TestDecrypter_Batch(t *testing.T) {
+	keys := createDecrypterKeys(t)
+	matches := []PiMatch{
+		createMatch(t, keys, "John Smith", 2),
+		createMatch(t, keys, "Jane Doe", 4),
+		createMatch(t, keys, "Richard Roe", 3),
+	}
+	decrypter := NewDecrypter(WithConcurrency(4))
+
+	entryData, err := decrypter.DecryptEntryDataBatch(matches, keys.loc.PrivateKey)
+	assert.Nil(t, err)
+	assignmentData, err := decrypter.DecryptAssignmentDataBatch(matches, keys.dloc.PrivateKey)
+	assert.Nil(t, err)
+
+	// Each match decrypts the same as on its own
+	assert.Len(t, entryData, len(matches))
+	assert.Len(t, assignmentData, len(matches))
+	for m, match := range matches {
+		locCiphertexts, encryptedEntryData, dlocCiphertexts, encryptedAssignmentData := matchCiphertexts(match)
+		expectedEntryData, err := DecryptEntryData(locCiphertexts, encryptedEntryData, keys.loc.PrivateKey)
+		assert.Nil(t, err)
+		expectedAssignmentData, err := DecryptAssignmentData(dlocCiphertexts, encryptedAssignmentData, keys.dloc.PrivateKey)
+		assert.Nil(t, err)
+		assert.Equal(t, expectedEntryData, entryData[m])
+		assert.Equal(t, expectedAssignmentData, assignmentData[m])
+	}
+
+	// No matches decrypt to nothing
+	entryData, err = decrypter.DecryptEntryDataBatch(nil, keys.loc.PrivateKey)
+	assert.Nil(t, err)
+	assert.Empty(t, entryData)
+}
+
+func TestDecrypter_Errors(t *testing.T) {
+	keys := createDecrypterKeys(t)
+	first := createMatch(t, keys, "John Smith", 3)
+	second := createMatch(t, keys, "Jane Doe", 2)
+	decrypter := NewDecrypter(WithConcurrency(4))
+
+	// Swapping in another match's entry leaves shares on different lines.
+	// Interpolation still succeeds but recovers the wrong k, which fails to
+	// decrypt any key.
+	mixed := PiMatch{MatchedEntries: []Matchable{first.MatchedEntries[0], first.MatchedEntries[1], second.MatchedEntries[0]}}
+
+	tests := map[string]struct {
+		matches       []PiMatch
+		privateKey    *rsa.PrivateKey
+		expectedErr   error
+		expectedMatch int
+	}{
+		"wrong private key": {
+			matches:       []PiMatch{first, second},
+			privateKey:    keys.dloc.PrivateKey,
+			expectedErr:   crypto.ErrRSADecrypt,
+			expectedMatch: 0,
+		},
+		"mixed match": {
+			matches:       []PiMatch{first, mixed},
+			privateKey:    keys.loc.PrivateKey,
+			expectedMatch: 1,
+		},
+		"empty match": {
+			matches:       []PiMatch{first, second, {}},
+			privateKey:    keys.loc.PrivateKey,
+			expectedErr:   ErrInterpolation,
+			expectedMatch: 2,
+		},
+		"entry without ciphertexts": {
+			matches:       []PiMatch{first, {MatchedEntries: []Matchable{testPi{}}}},
+			privateKey:    keys.loc.PrivateKey,
+			expectedMatch: 1,
+		},
+	}
+
+	for testName, tc := range tests {
+		t.Run(testName, func(t *testing.T) {
+			_, err := decrypter.DecryptEntryDataBatch(tc.matches, tc.privateKey)
+			if tc.expectedErr != nil {
+				assert.True(t, errors.Is(err, tc.expectedErr), err)
+			}
+			var indexErr *IndexError
+			if assert.True(t, errors.As(err, &indexErr)) {
+				assert.Equal(t, tc.expectedMatch, indexErr.Index)
+			}
+		})
+	}
+}
+
+func TestDecrypter_LOCTypeMismatch(t *testing.T) {
+	keys := createDecrypterKeys(t)
+	_, _, dlocCiphertexts, encryptedAssignmentData := matchCiphertexts(createMatch(t, keys, "John Smith", 2))
+
+	// DLOC ciphertexts hold Director data, which the LOC must not decrypt
+	_, err := NewDecrypter().DecryptEntryData(dlocCiphertexts, encryptedAssignmentData, keys.dloc.PrivateKey)
+	assert.True(t, errors.Is(err, ErrLOCTypeMismatch))
+}
+
+func TestDecrypter_Suites(t *testing.T) {
+	keys := createDecrypterKeys(t)
+	v2 := createMatch(t, keys, "John Smith", 2, client.WithSuite(suite.V2P256))
+	current := createMatch(t, keys, "John Smith", 2)
+	locCiphertexts, encryptedEntryData, _, _ := matchCiphertexts(v2)
+
+	// Batches use the suite of each match
+	entryData, err := NewDecrypter().DecryptEntryDataBatch([]PiMatch{v2, current}, keys.loc.PrivateKey)
+	if assert.Nil(t, err) {
+		assert.Equal(t, entryData[0], entryData[1])
+	}
+
+	// Ciphertexts alone are of the suite their LOC data names, which can be
+	// asserted
+	v2EntryData, err := DecryptEntryData(locCiphertexts, encryptedEntryData, keys.loc.PrivateKey)
+	if assert.Nil(t, err) {
+		assert.Equal(t, entryData[0], v2EntryData)
+	}
+	_, _, dlocCiphertexts, encryptedAssignmentData := matchCiphertexts(v2)
+	_, err = DecryptAssignmentData(dlocCiphertexts, encryptedAssignmentData, keys.dloc.PrivateKey)
+	assert.Nil(t, err)
+	_, err = NewDecrypter(WithSuite(suite.V2P256)).DecryptEntryData(locCiphertexts, encryptedEntryData, keys.loc.PrivateKey)
+	assert.Nil(t, err)
+	_, err = NewDecrypter(WithSuite(suite.Default)).DecryptEntryData(locCiphertexts, encryptedEntryData, keys.loc.PrivateKey)
+	assert.True(t, errors.Is(err, ErrSuiteMismatch))
+	_, err = NewDecrypter(WithSuite(255)).DecryptEntryData(locCiphertexts, encryptedEntryData, keys.loc.PrivateKey)
+	assert.True(t, errors.Is(err, suite.ErrUnknownSuite))
+	currentLOCCiphertexts, currentEntryData, _, _ := matchCiphertexts(current)
+	_, err = DecryptEntryData([][]byte{locCiphertexts[0], currentLOCCiphertexts[1]},
+		[]crypto.GCMCiphertext{encryptedEntryData[0], currentEntryData[1]}, keys.loc.PrivateKey)
+	assert.True(t, errors.Is(err, ErrSuiteMismatch))
+
+	// A match cannot mix suites
+	mixed := PiMatch{MatchedEntries: []Matchable{v2.MatchedEntries[0], current.MatchedEntries[1]}}
+	_, err = NewDecrypter().DecryptEntryDataBatch([]PiMatch{mixed}, keys.loc.PrivateKey)
+	assert.True(t, errors.Is(err, ErrSuiteMismatch))
+}
+
+// reassignedTuple is a tuple presented under another user ID
+type reassignedTuple struct {
+	types.CallistoTuple
+	userID []byte
+}
+
+func (r reassignedTuple) UserID() []byte { return r.userID }
+
+func TestDecrypter_Bindings(t *testing.T) {
+	keys := createDecrypterKeys(t)
+	match := createMatch(t, keys, "John Smith", 3)
+	locCiphertexts, encryptedEntryData, dlocCiphertexts, encryptedAssignmentData := matchCiphertexts(match)
+	decrypter := NewDecrypter()
+
+	// Entry data spliced in from another tuple of the match is no longer
+	// bound to the tuple its LOC data names
+	spliced := append([]crypto.GCMCiphertext(nil), encryptedEntryData...)
+	spliced[0] = encryptedEntryData[1]
+	_, err := decrypter.DecryptEntryData(locCiphertexts, spliced, keys.loc.PrivateKey)
+	assert.True(t, errors.Is(err, ErrBindingMismatch), err)
+
+	splicedAssignment := append([]crypto.GCMCiphertext(nil), encryptedAssignmentData...)
+	splicedAssignment[2] = encryptedAssignmentData[0]
+	_, err = decrypter.DecryptAssignmentData(dlocCiphertexts, splicedAssignment, keys.dloc.PrivateKey)
+	assert.True(t, errors.Is(err, ErrBindingMismatch), err)
+
+	// An entry presented under another user ID than its LOC data names
+	other := createMatch(t, keys, "Jane Doe", 1)
+	reassigned := PiMatch{MatchedEntries: []Matchable{
+		match.MatchedEntries[0],
+		match.MatchedEntries[1],
+		reassignedTuple{CallistoTuple: match.MatchedEntries[2].(types.CallistoTuple), userID: other.MatchedEntries[0].UserID()},
+	}}
+	_, err = decrypter.DecryptEntryDataBatch([]PiMatch{match, reassigned}, keys.loc.PrivateKey)
+	assert.True(t, errors.Is(err, ErrBindingMismatch), err)
+	var indexErr *IndexError
+	if assert.True(t, errors.As(err, &indexErr)) {
+		assert.Equal(t, 1, indexErr.Index)
+	}
+
+	// Unmodified matches still decrypt
+	_, err = decrypter.DecryptAssignmentDataBatch([]PiMatch{match}, keys.dloc.PrivateKey)
+	assert.Nil(t, err)
+}
+
+// failingRecorder records events until it is told to fail
+type failingRecorder struct {
+	events []audit.Event
+	fail   bool
+}
+
+func (r *failingRecorder) Record(event audit.Event) error {
+	if r.fail {
+		return errors.New("audit log unavailable")
+	}
+	r.events = append(r.events, event)
+	return nil
+}
+
+func TestDecrypter_AuditLog(t *testing.T) {
+	keys := createDecrypterKeys(t)
+	match := createMatch(t, keys, "John Smith", 2)
+	locCiphertexts, encryptedEntryData, dlocCiphertexts, encryptedAssignmentData := matchCiphertexts(match)
+	recorder := &failingRecorder{}
+	loc := NewDecrypter(WithAuditLog(audit.WithActor(recorder, "loc")))
+
+	_, err := loc.DecryptEntryDataBatch([]PiMatch{match}, keys.loc.PrivateKey)
+	assert.Nil(t, err)
+	_, err = loc.DecryptAssignmentDataBatch([]PiMatch{match}, keys.dloc.PrivateKey)
+	assert.Nil(t, err)
+	_, err = loc.DecryptEntryData(locCiphertexts, encryptedEntryData, keys.loc.PrivateKey)
+	assert.Nil(t, err)
+	_, err = loc.DecryptAssignmentData(dlocCiphertexts, encryptedAssignmentData, keys.dloc.PrivateKey)
+	assert.Nil(t, err)
+
+	// Failed decryptions are recorded too
+	_, err = loc.DecryptEntryData(locCiphertexts, encryptedEntryData, keys.dloc.PrivateKey)
+	assert.NotNil(t, err)
+
+	if assert.Len(t, recorder.events, 5) {
+		assert.Equal(t, audit.Event{
+			Action:  audit.DecryptEntryData,
+			Actor:   "loc",
+			Details: map[string]string{"matches": "1", "entries": "2", "pi": fmt.Sprintf("%x", match.SharedPiValue)},
+		}, recorder.events[0])
+		assert.Equal(t, audit.DecryptAssignmentData, recorder.events[1].Action)
+		assert.Equal(t, "2", recorder.events[2].Details["ciphertexts"])
+		assert.Equal(t, audit.DecryptAssignmentData, recorder.events[3].Action)
+		assert.Equal(t, recorder.events[2].Details, recorder.events[4].Details)
+	}
+
+	// Nothing is decrypted unrecorded
+	recorder.fail = true
+	entryData, err := loc.DecryptEntryData(locCiphertexts, encryptedEntryData, keys.loc.PrivateKey)
+	assert.NotNil(t, err)
+	assert.Nil(t, entryData)
+	_, err = loc.DecryptAssignmentDataBatch([]PiMatch{match}, keys.dloc.PrivateKey)
+	assert.NotNil(t, err)
+}
+
+func BenchmarkDecrypter_DecryptEntryDataBatch(b *testing.B) {
+	keys := createDecrypterKeys(b)
+	matches := make([]PiMatch, 10)
+	for m := range matches {
+		matches[m] = createMatch(b, keys, fmt.Sprintf("Perpetrator %v", m), 4)
+	}
+
+	for _, concurrency := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("concurrency %v", concurrency), func(b *testing.B) {
+			decrypter := NewDecrypter(WithConcurrency(concurrency))
+			for i := 0; i < b.N; i++ {
+				if _, err := decrypter.DecryptEntryDataBatch(matches, keys.loc.PrivateKey); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
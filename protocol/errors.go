@@ -0,0 +1,41 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrLengthMismatch is returned when the LOC ciphertexts and encrypted data
+	// passed for decryption are not of the same length
+	ErrLengthMismatch = errors.New("mismatched number of ciphertexts")
+	// ErrLOCTypeMismatch is returned when a decrypted (D)LOC ciphertext was
+	// created for the other LOC type, e.g. DLOC data given to a LOC
+	ErrLOCTypeMismatch = errors.New("LOC type mismatch")
+	// ErrInterpolation is returned when the key k cannot be recovered from the
+	// Shamir shares of a match
+	ErrInterpolation = errors.New("failed to interpolate shamir shares")
+	// ErrInvalidPi is returned when an entry to be matched has a pi value of
+	// the wrong size
+	ErrInvalidPi = errors.New("invalid pi value")
+	// ErrSuiteMismatch is returned when the entries of a match, or an entry and
+	// its decrypted (D)LOC data, are of different protocol suites
+	ErrSuiteMismatch = errors.New("protocol suite mismatch")
+	// ErrBindingMismatch is returned when a ciphertext is not bound to the
+	// tuple its (D)LOC data names, e.g. because it was spliced in from
+	// another tuple
+	ErrBindingMismatch = errors.New("ciphertext bound to another tuple")
+)
+
+// IndexError reports which element of a batch failed to decrypt
+type IndexError struct {
+	Index int
+	Err   error
+}
+
+func (e *IndexError) Error() string {
+	return fmt.Sprintf("at index %v: %v", e.Index, e.Err)
+}
+
+// Unwrap returns the error of the failed element
+func (e *IndexError) Unwrap() error { return e.Err }
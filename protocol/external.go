@@ -0,0 +1,388 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// MatchRecord is the fixed-size summary of a stored entry that
+// FindMatchesExternal sorts and merges instead of the entry itself
+type MatchRecord struct {
+	Pi   PiKey
+	User [sha256.Size]byte // SHA-256 of the user ID
+	Ref  uint64            // Locates the entry in its store
+}
+
+const matchRecordSize = PiSize + sha256.Size + 8
+
+// maxMergeFanIn bounds how many runs are merged, and so held open, at once.
+// More runs are first merged into fewer, longer ones.
+const maxMergeFanIn = 128
+
+// NewMatchRecord returns the match record of an entry stored at ref
+func NewMatchRecord(entry Matchable, ref uint64) (MatchRecord, error) {
+	key, err := NewPiKey(entry.Pi())
+	if err != nil {
+		return MatchRecord{}, err
+	}
+	return MatchRecord{Pi: key, User: sha256.Sum256(entry.UserID()), Ref: ref}, nil
+}
+
+// less orders records by pi value, then by ref
+func (r MatchRecord) less(other MatchRecord) bool {
+	if c := r.Pi.Compare(other.Pi); c != 0 {
+		return c < 0
+	}
+	return r.Ref < other.Ref
+}
+
+func (r MatchRecord) marshal(b []byte) {
+	copy(b, r.Pi[:])
+	copy(b[PiSize:], r.User[:])
+	binary.BigEndian.PutUint64(b[PiSize+sha256.Size:], r.Ref)
+}
+
+func (r *MatchRecord) unmarshal(b []byte) {
+	copy(r.Pi[:], b)
+	copy(r.User[:], b[PiSize:])
+	r.Ref = binary.BigEndian.Uint64(b[PiSize+sha256.Size:])
+}
+
+// RecordSource is a store of entries too large to match in memory
+type RecordSource interface {
+	// MatchRecords calls fn with the match record of every stored entry. It
+	// stops and returns the error if fn returns one.
+	MatchRecords(fn func(MatchRecord) error) error
+	// LoadEntries returns the entries stored at refs, in the same order
+	LoadEntries(refs []uint64) ([]Matchable, error)
+}
+
+// FindMatchesExternal returns the matches among the entries of source, ordered
+// by pi value, with matched entries ordered by ref. Only match records are
+// held in memory, at most the run size at a time; larger sources are sorted
+// into runs on disk that are then merged. Only matched entries are loaded.
+func (m *Matcher) FindMatchesExternal(source RecordSource) ([]PiMatch, error) {
+	var dir string
+	defer func() {
+		if dir != "" {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	// Sort records into runs
+	var runs []string
+	buffer := make([]MatchRecord, 0, m.runSize)
+	flush := func() error {
+		if dir == "" {
+			var err error
+			if dir, err = ioutil.TempDir(m.tempDir, "gallisto-match"); err != nil {
+				return fmt.Errorf("failed to create directory for sorted runs: %w", err)
+			}
+		}
+		run, err := writeRun(dir, len(runs), buffer)
+		if err != nil {
+			return err
+		}
+		runs = append(runs, run)
+		buffer = buffer[:0]
+		return nil
+	}
+	err := source.MatchRecords(func(r MatchRecord) error {
+		buffer = append(buffer, r)
+		if len(buffer) == m.runSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read match records: %w", err)
+	}
+
+	// Merge the runs, or just the buffer if everything fit in memory
+	var merged recordIterator
+	if len(runs) == 0 {
+		sortRecords(buffer)
+		merged = &sliceIterator{records: buffer}
+	} else {
+		if len(buffer) > 0 {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+		for len(runs) > maxMergeFanIn {
+			if runs, err = mergeRuns(dir, runs); err != nil {
+				return nil, err
+			}
+		}
+		merger, err := newRunMerger(runs)
+		if err != nil {
+			return nil, err
+		}
+		defer merger.Close()
+		merged = merger
+	}
+
+	return findMatchesInSorted(merged, source, m.filter)
+}
+
+// findMatchesInSorted groups sorted records by pi value and loads the entries
+// of groups that form a match. If filter is set, groups must still form a
+// match once the entries it rejects are dropped.
+func findMatchesInSorted(records recordIterator, source RecordSource, filter func(Matchable) bool) ([]PiMatch, error) {
+	var matches []PiMatch
+	var group []MatchRecord
+	emit := func() error {
+		if len(group) < 2 || !hasDistinctUserRecords(group) {
+			return nil
+		}
+		refs := make([]uint64, len(group))
+		for i, r := range group {
+			refs[i] = r.Ref
+		}
+		entries, err := source.LoadEntries(refs)
+		if err != nil {
+			return fmt.Errorf("failed to load matched entries: %w", err)
+		}
+		if filter != nil {
+			entries = filterEntries(entries, filter)
+			if len(entries) < 2 || !hasDistinctEntryUsers(entries) {
+				return nil
+			}
+		}
+		matches = append(matches, PiMatch{
+			SharedPiValue:  append([]byte(nil), group[0].Pi[:]...),
+			MatchedEntries: entries,
+			MatchClass:     leastConfidentMatchClass(entries),
+		})
+		return nil
+	}
+
+	for {
+		r, err := records.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(group) > 0 && r.Pi != group[0].Pi {
+			if err := emit(); err != nil {
+				return nil, err
+			}
+			group = group[:0]
+		}
+		group = append(group, r)
+	}
+	if err := emit(); err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+func hasDistinctUserRecords(group []MatchRecord) bool {
+	for _, r := range group[1:] {
+		if r.User != group[0].User {
+			return true
+		}
+	}
+	return false
+}
+
+func filterEntries(entries []Matchable, filter func(Matchable) bool) []Matchable {
+	kept := entries[:0]
+	for _, e := range entries {
+		if filter(e) {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+func hasDistinctEntryUsers(entries []Matchable) bool {
+	for _, e := range entries[1:] {
+		if !bytes.Equal(e.UserID(), entries[0].UserID()) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortRecords(records []MatchRecord) {
+	sort.Slice(records, func(i, j int) bool { return records[i].less(records[j]) })
+}
+
+// writeRun sorts records and writes them to a new run file in dir
+func writeRun(dir string, n int, records []MatchRecord) (string, error) {
+	sortRecords(records)
+	return writeRecords(filepath.Join(dir, fmt.Sprintf("run-%06d", n)), &sliceIterator{records: records})
+}
+
+// mergeRuns merges runs in groups of maxMergeFanIn and returns the merged runs
+func mergeRuns(dir string, runs []string) ([]string, error) {
+	var merged []string
+	for start := 0; start < len(runs); start += maxMergeFanIn {
+		end := start + maxMergeFanIn
+		if end > len(runs) {
+			end = len(runs)
+		}
+		merger, err := newRunMerger(runs[start:end])
+		if err != nil {
+			return nil, err
+		}
+		path := filepath.Join(dir, fmt.Sprintf("%v-merged-%06d", filepath.Base(runs[start]), len(merged)))
+		_, err = writeRecords(path, merger)
+		merger.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, run := range runs[start:end] {
+			os.Remove(run)
+		}
+		merged = append(merged, path)
+	}
+	return merged, nil
+}
+
+// writeRecords writes every record of an iterator to a new file
+func writeRecords(path string, records recordIterator) (string, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create sorted run: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	var b [matchRecordSize]byte
+	for {
+		r, err := records.Next()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			r.marshal(b[:])
+			_, err = w.Write(b[:])
+		}
+		if err != nil {
+			f.Close()
+			return "", fmt.Errorf("failed to write sorted run: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to write sorted run: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to write sorted run: %w", err)
+	}
+	return path, nil
+}
+
+// recordIterator yields records in sorted order. Next returns io.EOF after the
+// last record.
+type recordIterator interface {
+	Next() (MatchRecord, error)
+}
+
+type sliceIterator struct {
+	records []MatchRecord
+}
+
+func (s *sliceIterator) Next() (MatchRecord, error) {
+	if len(s.records) == 0 {
+		return MatchRecord{}, io.EOF
+	}
+	r := s.records[0]
+	s.records = s.records[1:]
+	return r, nil
+}
+
+// runReader reads the records of one sorted run
+type runReader struct {
+	file    *os.File
+	reader  *bufio.Reader
+	current MatchRecord
+}
+
+func (r *runReader) advance() error {
+	var b [matchRecordSize]byte
+	if _, err := io.ReadFull(r.reader, b[:]); err != nil {
+		if err == io.EOF {
+			return err
+		}
+		return fmt.Errorf("failed to read sorted run: %w", err)
+	}
+	r.current.unmarshal(b[:])
+	return nil
+}
+
+// runMerger merges sorted runs with a min-heap of their current records
+type runMerger struct {
+	readers []*runReader // All readers, for closing
+	heap    runHeap
+}
+
+func newRunMerger(runs []string) (*runMerger, error) {
+	m := &runMerger{}
+	for _, path := range runs {
+		f, err := os.Open(path)
+		if err != nil {
+			m.Close()
+			return nil, fmt.Errorf("failed to open sorted run: %w", err)
+		}
+		r := &runReader{file: f, reader: bufio.NewReader(f)}
+		m.readers = append(m.readers, r)
+		switch err := r.advance(); err {
+		case nil:
+			m.heap = append(m.heap, r)
+		case io.EOF:
+		default:
+			m.Close()
+			return nil, err
+		}
+	}
+	heap.Init(&m.heap)
+	return m, nil
+}
+
+func (m *runMerger) Next() (MatchRecord, error) {
+	if len(m.heap) == 0 {
+		return MatchRecord{}, io.EOF
+	}
+	r := m.heap[0]
+	record := r.current
+	switch err := r.advance(); err {
+	case nil:
+		heap.Fix(&m.heap, 0)
+	case io.EOF:
+		heap.Pop(&m.heap)
+	default:
+		return MatchRecord{}, err
+	}
+	return record, nil
+}
+
+func (m *runMerger) Close() {
+	for _, r := range m.readers {
+		r.file.Close()
+	}
+}
+
+type runHeap []*runReader
+
+func (h runHeap) Len() int            { return len(h) }
+func (h runHeap) Less(i, j int) bool  { return h[i].current.less(h[j].current) }
+func (h runHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x interface{}) { *h = append(*h, x.(*runReader)) }
+func (h *runHeap) Pop() interface{} {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
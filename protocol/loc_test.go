@@ -0,0 +1,21 @@
+package protocol
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ymarcus93/gallisto/crypto"
+	helper "github.com/ymarcus93/gallisto/internal/test"
+)
+
+func TestDecrypt_LengthMismatch(t *testing.T) {
+	ciphertexts := [][]byte{helper.GenerateRandomBytes(SIZE_BYTES, t)}
+	encryptedData := []crypto.GCMCiphertext{helper.CreateGCMCiphertext(t), helper.CreateGCMCiphertext(t)}
+
+	_, err := DecryptEntryData(ciphertexts, encryptedData, nil)
+	assert.True(t, errors.Is(err, ErrLengthMismatch))
+	_, err = DecryptAssignmentData(ciphertexts, encryptedData, nil)
+	assert.True(t, errors.Is(err, ErrLengthMismatch))
+}
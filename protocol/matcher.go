@@ -0,0 +1,224 @@
+package protocol
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// PiSize is the size in bytes of a pi value
+const PiSize = 32
+
+// PiKey is a pi value as a fixed-size key. Keys order the same way as the
+// bytes of their pi values.
+type PiKey [PiSize]byte
+
+// NewPiKey returns the key of a pi value. It returns ErrInvalidPi if pi is not
+// PiSize bytes long.
+func NewPiKey(pi []byte) (PiKey, error) {
+	var key PiKey
+	if len(pi) != PiSize {
+		return key, fmt.Errorf("%w: got %v bytes", ErrInvalidPi, len(pi))
+	}
+	copy(key[:], pi)
+	return key, nil
+}
+
+// Compare orders keys by their bytes, like bytes.Compare
+func (k PiKey) Compare(other PiKey) int {
+	return bytes.Compare(k[:], other[:])
+}
+
+// shard returns which of n shards the key belongs to. Shards partition the
+// key space into contiguous ranges by the first 16 bits of the key, so that
+// shard i only holds keys less than those of shard i+1.
+func (k PiKey) shard(n int) int {
+	prefix := int(k[0])<<8 | int(k[1])
+	return prefix * n >> 16
+}
+
+// Matcher finds matches among large sets of entries. Entries are partitioned
+// by pi prefix into shards that are matched in parallel, and FindMatchesExternal
+// can match more entries than fit in memory by sorting them on disk.
+type Matcher struct {
+	shards  int
+	workers int
+	runSize int
+	tempDir string
+	filter  func(Matchable) bool
+}
+
+// MatcherOption configures optional Matcher behaviour
+type MatcherOption func(*Matcher)
+
+// WithShards sets the number of shards entries are partitioned into. It is at
+// most 65536.
+func WithShards(shards int) MatcherOption {
+	return func(m *Matcher) {
+		m.shards = shards
+	}
+}
+
+// WithWorkers sets how many shards are matched at the same time
+func WithWorkers(workers int) MatcherOption {
+	return func(m *Matcher) {
+		m.workers = workers
+	}
+}
+
+// WithRunSize sets how many records FindMatchesExternal sorts in memory before
+// writing them to disk
+func WithRunSize(records int) MatcherOption {
+	return func(m *Matcher) {
+		m.runSize = records
+	}
+}
+
+// WithTempDir sets the directory FindMatchesExternal writes sorted runs to.
+// The default is the system temporary directory.
+func WithTempDir(dir string) MatcherOption {
+	return func(m *Matcher) {
+		m.tempDir = dir
+	}
+}
+
+// WithEntryFilter makes the matcher ignore entries for which filter returns
+// false, e.g. tuples whose signature does not verify. FindMatchesExternal
+// filters the entries of a group once they are loaded.
+func WithEntryFilter(filter func(Matchable) bool) MatcherOption {
+	return func(m *Matcher) {
+		m.filter = filter
+	}
+}
+
+// NewMatcher returns a Matcher. By default it uses 256 shards, one worker per
+// CPU and sorted runs of a million records.
+func NewMatcher(opts ...MatcherOption) *Matcher {
+	m := &Matcher{
+		shards:  256,
+		workers: runtime.GOMAXPROCS(0),
+		runSize: 1 << 20,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.shards < 1 {
+		m.shards = 1
+	}
+	if m.shards > 1<<16 {
+		m.shards = 1 << 16
+	}
+	if m.workers < 1 {
+		m.workers = 1
+	}
+	if m.runSize < 1 {
+		m.runSize = 1
+	}
+	return m
+}
+
+// With returns a copy of the matcher with opts applied
+func (m *Matcher) With(opts ...MatcherOption) *Matcher {
+	copied := *m
+	for _, opt := range opts {
+		opt(&copied)
+	}
+	return &copied
+}
+
+// shardEntry refers to an entry by its position in the input
+type shardEntry struct {
+	key   PiKey
+	index int
+}
+
+// FindMatches returns the matches among entries, ordered by pi value. Matched
+// entries are in input order. See the package level FindMatches.
+func (m *Matcher) FindMatches(entries []Matchable) ([]PiMatch, error) {
+	// Partition entries by pi prefix, preserving input order within a shard
+	shards := make([][]shardEntry, m.shards)
+	for i, e := range entries {
+		if m.filter != nil && !m.filter(e) {
+			continue
+		}
+		key, err := NewPiKey(e.Pi())
+		if err != nil {
+			return nil, &IndexError{Index: i, Err: err}
+		}
+		s := key.shard(m.shards)
+		shards[s] = append(shards[s], shardEntry{key: key, index: i})
+	}
+
+	// Match shards in parallel
+	results := make([][]PiMatch, m.shards)
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < m.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s := range next {
+				results[s] = matchShard(entries, shards[s])
+			}
+		}()
+	}
+	for s := range shards {
+		next <- s
+	}
+	close(next)
+	wg.Wait()
+
+	// Shards cover increasing key ranges, so concatenating them keeps the
+	// matches ordered
+	var matches []PiMatch
+	for _, r := range results {
+		matches = append(matches, r...)
+	}
+	return matches, nil
+}
+
+// matchShard returns the matches among the entries of one shard, ordered by
+// pi value
+func matchShard(entries []Matchable, shard []shardEntry) []PiMatch {
+	sort.Slice(shard, func(i, j int) bool {
+		if c := shard[i].key.Compare(shard[j].key); c != 0 {
+			return c < 0
+		}
+		return shard[i].index < shard[j].index
+	})
+
+	var matches []PiMatch
+	for start := 0; start < len(shard); {
+		end := start + 1
+		for end < len(shard) && shard[end].key == shard[start].key {
+			end++
+		}
+		if group := shard[start:end]; len(group) >= 2 && hasDistinctUsers(entries, group) {
+			matched := make([]Matchable, len(group))
+			for i, se := range group {
+				matched[i] = entries[se.index]
+			}
+			matches = append(matches, PiMatch{
+				SharedPiValue:  append([]byte(nil), group[0].key[:]...),
+				MatchedEntries: matched,
+				MatchClass:     leastConfidentMatchClass(matched),
+			})
+		}
+		start = end
+	}
+	return matches
+}
+
+// hasDistinctUsers reports whether a group of entries was submitted by more
+// than one user
+func hasDistinctUsers(entries []Matchable, group []shardEntry) bool {
+	first := entries[group[0].index].UserID()
+	for _, se := range group[1:] {
+		if !bytes.Equal(first, entries[se.index].UserID()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,238 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	mathrand "math/rand"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sliceRecordSource is a RecordSource over entries in memory. Refs are
+// indices into entries.
+type sliceRecordSource []Matchable
+
+func (s sliceRecordSource) MatchRecords(fn func(MatchRecord) error) error {
+	for i, e := range s {
+		r, err := NewMatchRecord(e, uint64(i))
+		if err != nil {
+			return err
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s sliceRecordSource) LoadEntries(refs []uint64) ([]Matchable, error) {
+	entries := make([]Matchable, len(refs))
+	for i, ref := range refs {
+		entries[i] = s[ref]
+	}
+	return entries, nil
+}
+
+// createRandomEntries returns numEntries entries spread over numPis pi values
+// and numUsers users
+func createRandomEntries(numEntries, numPis, numUsers int, seed int64) []Matchable {
+	random := mathrand.New(mathrand.NewSource(seed))
+	pis := make([][]byte, numPis)
+	for i := range pis {
+		pis[i] = make([]byte, PiSize)
+		random.Read(pis[i])
+	}
+	entries := make([]Matchable, numEntries)
+	for i := range entries {
+		entries[i] = testPi{
+			pi:     pis[random.Intn(numPis)],
+			userId: []byte(fmt.Sprintf("user%v", random.Intn(numUsers))),
+		}
+	}
+	return entries
+}
+
+// referenceMatches finds matches the straightforward way, ordered by pi value
+func referenceMatches(entries []Matchable) []PiMatch {
+	groups := make(map[string][]Matchable)
+	for _, e := range entries {
+		groups[string(e.Pi())] = append(groups[string(e.Pi())], e)
+	}
+	var matches []PiMatch
+	for pi, group := range groups {
+		users := make(map[string]struct{})
+		for _, e := range group {
+			users[string(e.UserID())] = struct{}{}
+		}
+		if len(users) > 1 {
+			matches = append(matches, PiMatch{SharedPiValue: []byte(pi), MatchedEntries: group})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return bytes.Compare(matches[i].SharedPiValue, matches[j].SharedPiValue) < 0
+	})
+	return matches
+}
+
+func TestMatcher(t *testing.T) {
+	entries := createRandomEntries(5000, 2000, 50, 1)
+	expected := referenceMatches(entries)
+	if !assert.NotEmpty(t, expected) {
+		return
+	}
+
+	tests := map[string][]MatcherOption{
+		"defaults":             nil,
+		"one shard":            {WithShards(1), WithWorkers(1)},
+		"more shards than pis": {WithShards(1 << 16), WithWorkers(3)},
+		"odd shard count":      {WithShards(7), WithWorkers(2)},
+	}
+
+	for testName, opts := range tests {
+		t.Run(testName, func(t *testing.T) {
+			matches, err := NewMatcher(opts...).FindMatches(entries)
+			if assert.NoError(t, err) {
+				assert.Equal(t, expected, matches)
+			}
+		})
+	}
+}
+
+func TestMatcher_FindMatchesExternal(t *testing.T) {
+	entries := createRandomEntries(5000, 2000, 50, 2)
+	expected := referenceMatches(entries)
+
+	tempDir, err := ioutil.TempDir("", "matcher-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	tests := map[string]int{
+		"fits in memory":   len(entries),
+		"one full run":     len(entries) / 2,
+		"many runs":        128,
+		"one record a run": 1,
+	}
+
+	for testName, runSize := range tests {
+		t.Run(testName, func(t *testing.T) {
+			m := NewMatcher(WithRunSize(runSize), WithTempDir(tempDir))
+			matches, err := m.FindMatchesExternal(sliceRecordSource(entries))
+			if assert.NoError(t, err) {
+				assert.Equal(t, expected, matches)
+			}
+
+			// Sorted runs are removed
+			files, err := ioutil.ReadDir(tempDir)
+			assert.NoError(t, err)
+			assert.Empty(t, files)
+		})
+	}
+}
+
+func TestMatcher_WithEntryFilter(t *testing.T) {
+	entries := createRandomEntries(5000, 2000, 50, 4)
+	// Drop the entries of a few users, as if their signatures did not verify
+	filter := func(e Matchable) bool {
+		return !bytes.HasSuffix(e.UserID(), []byte("7"))
+	}
+	var kept []Matchable
+	for _, e := range entries {
+		if filter(e) {
+			kept = append(kept, e)
+		}
+	}
+	expected := referenceMatches(kept)
+	assert.NotEqual(t, referenceMatches(entries), expected)
+
+	m := NewMatcher(WithEntryFilter(filter), WithTempDir(t.TempDir()), WithRunSize(128))
+	matches, err := m.FindMatches(entries)
+	if assert.NoError(t, err) {
+		assert.Equal(t, expected, matches)
+	}
+	matches, err = m.FindMatchesExternal(sliceRecordSource(entries))
+	if assert.NoError(t, err) {
+		assert.Equal(t, expected, matches)
+	}
+
+	// With leaves the original matcher unfiltered
+	unfiltered := NewMatcher()
+	filtered := unfiltered.With(WithEntryFilter(filter))
+	matches, err = unfiltered.FindMatches(entries)
+	assert.NoError(t, err)
+	assert.Equal(t, referenceMatches(entries), matches)
+	matches, err = filtered.FindMatches(entries)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, matches)
+}
+
+func TestMatcher_InvalidPi(t *testing.T) {
+	entries := append(createPisWithDistinctUserIDs(2, make([]byte, PiSize), t), testPi{pi: []byte("short"), userId: []byte("user")})
+
+	_, err := FindMatches(entries)
+	assert.True(t, errors.Is(err, ErrInvalidPi))
+	var indexErr *IndexError
+	if assert.True(t, errors.As(err, &indexErr)) {
+		assert.Equal(t, 2, indexErr.Index)
+	}
+
+	_, err = NewMatcher().FindMatchesExternal(sliceRecordSource(entries))
+	assert.True(t, errors.Is(err, ErrInvalidPi))
+}
+
+// benchmarkEntries is shared between benchmarks, as creating a million entries
+// takes longer than matching them
+var benchmarkEntries = func() func() []Matchable {
+	var entries []Matchable
+	return func() []Matchable {
+		if entries == nil {
+			// Most pi values are unique; about 1% of entries are in a match
+			entries = createRandomEntries(1000000, 995000, 1000000, 3)
+		}
+		return entries
+	}
+}()
+
+func BenchmarkFindMatches(b *testing.B) {
+	entries := benchmarkEntries()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := FindMatches(entries); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMatcher_Shards(b *testing.B) {
+	entries := benchmarkEntries()
+	for _, shards := range []int{1, 16, 256, 4096} {
+		m := NewMatcher(WithShards(shards))
+		b.Run(fmt.Sprintf("%v shards", shards), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := m.FindMatches(entries); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMatcher_FindMatchesExternal(b *testing.B) {
+	source := sliceRecordSource(benchmarkEntries())
+	for _, runSize := range []int{1 << 20, 1 << 17} {
+		m := NewMatcher(WithRunSize(runSize))
+		b.Run(fmt.Sprintf("run size %v", runSize), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := m.FindMatchesExternal(source); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -1,13 +1,13 @@
 package protocol
 
 import (
-	"encoding/hex"
-	"fmt"
+	"github.com/ymarcus93/gallisto/types"
 )
 
 type PiMatch struct {
 	SharedPiValue  []byte
 	MatchedEntries []Matchable
+	MatchClass     types.MatchClass // The least confident class among MatchedEntries
 }
 
 type Matchable interface {
@@ -15,51 +15,28 @@ type Matchable interface {
 	UserID() []byte
 }
 
+// MatchClassifier is implemented by Matchables that are tagged with a match
+// class. Matchables that do not implement it are treated as exact matches.
+type MatchClassifier interface {
+	MatchClass() types.MatchClass
+}
+
 // FindMatches returns a list of Pi matches. A match is defined as >= 2 entries
 // sharing the same pi value, but different user IDs. The returned list is nil
-// if no matches were found.
+// if no matches were found. Matches are ordered by pi value.
+//
+// FindMatches uses a Matcher with default options. Every pi value must be
+// PiSize bytes long.
 func FindMatches(entries []Matchable) ([]PiMatch, error) {
-	piMap := make(map[string][]Matchable)
-
-	// Scan through entries and create pi-->list(entries) mapping of entries
-	// with common pi value
-	for _, e := range entries {
-		piAsHexString := hex.EncodeToString(e.Pi())
-		tupleList := piMap[piAsHexString]
-		tupleList = append(tupleList, e)
-		piMap[piAsHexString] = tupleList
-	}
-
-	// Create match structs
-	var matches []PiMatch
-	for k, v := range piMap {
-		if len(v) >= 2 {
-			// If there are only common pi values under the same user, then we
-			// return zero matches as a match requires distinct users with the
-			// same pi value
-			if !existsUniqueIDs(v) {
-				continue
-			}
-			piValue, err := hex.DecodeString(k)
-			if err != nil {
-				return nil, fmt.Errorf("failed to decode %v: %v", k, err)
-			}
-			match := PiMatch{
-				SharedPiValue:  piValue,
-				MatchedEntries: v,
-			}
-			matches = append(matches, match)
-		}
-	}
-	return matches, nil
+	return NewMatcher().FindMatches(entries)
 }
 
-func existsUniqueIDs(entries []Matchable) bool {
-	seen := make(map[string]struct{}, 0)
+func leastConfidentMatchClass(entries []Matchable) types.MatchClass {
+	matchClass := types.MatchExact
 	for _, e := range entries {
-		userIDAsHexString := hex.EncodeToString(e.UserID())
-		seen[userIDAsHexString] = struct{}{}
+		if classifier, ok := e.(MatchClassifier); ok && classifier.MatchClass() > matchClass {
+			matchClass = classifier.MatchClass()
+		}
 	}
-
-	return len(seen) > 1
+	return matchClass
 }
@@ -6,6 +6,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	helper "github.com/ymarcus93/gallisto/internal/test"
+	"github.com/ymarcus93/gallisto/types"
 )
 
 const SIZE_BYTES = 32
@@ -123,3 +124,48 @@ func TestFindMatches(t *testing.T) {
 		})
 	}
 }
+
+type testClassifiedPi struct {
+	testPi
+	matchClass types.MatchClass
+}
+
+func (f testClassifiedPi) MatchClass() types.MatchClass {
+	return f.matchClass
+}
+
+func TestFindMatches_MatchClass(t *testing.T) {
+	pi := helper.GenerateRandomBytes(SIZE_BYTES, t)
+	tests := map[string]struct {
+		input    []Matchable
+		expected types.MatchClass
+	}{
+		"unclassified entries are exact": {
+			input:    createPisWithDistinctUserIDs(2, pi, t),
+			expected: types.MatchExact,
+		},
+		"phonetic entries": {
+			input: []Matchable{
+				testClassifiedPi{testPi{pi: pi, userId: []byte("userID1")}, types.MatchPhonetic},
+				testClassifiedPi{testPi{pi: pi, userId: []byte("userID2")}, types.MatchPhonetic},
+			},
+			expected: types.MatchPhonetic,
+		},
+		"mixed entries report the least confident class": {
+			input: []Matchable{
+				testClassifiedPi{testPi{pi: pi, userId: []byte("userID1")}, types.MatchExact},
+				testClassifiedPi{testPi{pi: pi, userId: []byte("userID2")}, types.MatchVariant},
+			},
+			expected: types.MatchVariant,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			matches, err := FindMatches(test.input)
+			if assert.NoError(t, err) && assert.Len(t, matches, 1) {
+				assert.Equal(t, test.expected, matches[0].MatchClass)
+			}
+		})
+	}
+}
@@ -0,0 +1,360 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/ymarcus93/gallisto/internal/encoding"
+	"github.com/ymarcus93/gallisto/protocol"
+	"github.com/ymarcus93/gallisto/types"
+)
+
+const (
+	tuplesFileName  = "tuples.log"
+	deletedFileName = "deleted.log"
+)
+
+// Each tuple is stored as a header followed by its encoding. The header holds
+// the tuple's match record, so matching only reads headers.
+//
+//	length   uint32 (big-endian)  length of the encoding
+//	checksum uint32 (big-endian)  CRC-32C of the rest of the header and the encoding
+//	pi       [32]byte
+//	user     [32]byte             SHA-256 of the user ID
+//	encoding [length]byte         see encoding.EncodeCallistoTuple
+const diskHeaderSize = 4 + 4 + protocol.PiSize + sha256.Size
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrCorruptStore is returned when a DiskTupleStore's files are damaged
+var ErrCorruptStore = errors.New("corrupt tuple store")
+
+// RecordTupleStore is a TupleStore that can be matched without loading every
+// tuple into memory. A CallistoServer matches such stores with
+// protocol.Matcher.FindMatchesExternal.
+type RecordTupleStore interface {
+	TupleStore
+	protocol.RecordSource
+	// Load returns the stored tuples at refs, in the same order
+	Load(refs []uint64) ([]StoredTuple, error)
+}
+
+// DiskTupleStore is a TupleStore that keeps tuples in an append-only file.
+// Deleted tuples are recorded in a second file and skipped when reading. Only
+// the offsets of stored tuples are held in memory.
+//
+// Add returns once a tuple is written to the file; call Sync to flush the
+// files to stable storage.
+type DiskTupleStore struct {
+	tuples  *os.File
+	deleted *os.File
+	size    int64               // End of the last complete tuple
+	offsets []uint64            // Offset of every tuple, in increasing order
+	removed map[uint64]struct{} // Offsets of deleted tuples
+}
+
+// OpenDiskTupleStore opens the tuple store in dir, creating it if needed. A
+// tuple left incomplete by a crash while it was being added is discarded.
+func OpenDiskTupleStore(dir string) (*DiskTupleStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create tuple store: %w", err)
+	}
+	tuples, err := os.OpenFile(filepath.Join(dir, tuplesFileName), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tuple store: %w", err)
+	}
+	deleted, err := os.OpenFile(filepath.Join(dir, deletedFileName), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		tuples.Close()
+		return nil, fmt.Errorf("failed to open tuple store: %w", err)
+	}
+
+	s := &DiskTupleStore{tuples: tuples, deleted: deleted, removed: make(map[uint64]struct{})}
+	if err := s.load(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// load indexes the stored and deleted tuples, checking every tuple's checksum
+func (s *DiskTupleStore) load() error {
+	info, err := s.tuples.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to open tuple store: %w", err)
+	}
+
+	end, err := s.scan(info.Size(), func(offset uint64, header []byte, r io.Reader) error {
+		if _, err := readEncoding(header, r); err != nil {
+			return fmt.Errorf("tuple %v: %w", diskTupleID(offset), err)
+		}
+		s.offsets = append(s.offsets, offset)
+		return nil
+	})
+	s.size = end
+	if err == io.ErrUnexpectedEOF {
+		// Drop the incomplete tuple at the end
+		if err := s.tuples.Truncate(s.size); err != nil {
+			return fmt.Errorf("failed to discard incomplete tuple: %w", err)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	deleted, err := readDeleted(s.deleted)
+	if err != nil {
+		return err
+	}
+	for _, offset := range deleted {
+		s.removed[offset] = struct{}{}
+	}
+	return nil
+}
+
+// scan calls fn with the header of every tuple up to limit, including deleted
+// ones. fn may read the encoding that follows the header from r. scan returns
+// where the last complete tuple ends, and io.ErrUnexpectedEOF if an incomplete
+// tuple follows it.
+func (s *DiskTupleStore) scan(limit int64, fn func(offset uint64, header []byte, r io.Reader) error) (int64, error) {
+	r := bufio.NewReader(io.NewSectionReader(s.tuples, 0, limit))
+	var offset int64
+	header := make([]byte, diskHeaderSize)
+	for offset < limit {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return offset, io.ErrUnexpectedEOF
+		}
+		length := int64(binary.BigEndian.Uint32(header))
+		if offset+diskHeaderSize+length > limit {
+			return offset, io.ErrUnexpectedEOF
+		}
+
+		// Let fn read as much of the encoding as it wants and skip the rest
+		counted := &countingReader{r: io.LimitReader(r, length)}
+		if err := fn(uint64(offset), header, counted); err != nil {
+			return offset, err
+		}
+		if _, err := r.Discard(int(length - counted.n)); err != nil {
+			return offset, io.ErrUnexpectedEOF
+		}
+		offset += diskHeaderSize + length
+	}
+	return offset, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func readDeleted(f *os.File) ([]uint64, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read deleted tuples: %w", err)
+	}
+	r := bufio.NewReader(f)
+	var offsets []uint64
+	var b [8]byte
+	for {
+		_, err := io.ReadFull(r, b[:])
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			// An incomplete entry was never acknowledged, so it is ignored
+			return offsets, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read deleted tuples: %w", err)
+		}
+		offsets = append(offsets, binary.BigEndian.Uint64(b[:]))
+	}
+}
+
+// Add appends a tuple to the store. Its ID is derived from its offset in the
+// file.
+func (s *DiskTupleStore) Add(tuple types.CallistoTuple) (TupleID, error) {
+	record, err := protocol.NewMatchRecord(tuple, uint64(s.size))
+	if err != nil {
+		return "", err
+	}
+	encoded, err := encoding.EncodeCallistoTuple(tuple)
+	if err != nil {
+		return "", err
+	}
+
+	data := make([]byte, diskHeaderSize+len(encoded))
+	binary.BigEndian.PutUint32(data, uint32(len(encoded)))
+	copy(data[8:], record.Pi[:])
+	copy(data[8+protocol.PiSize:], record.User[:])
+	copy(data[diskHeaderSize:], encoded)
+	binary.BigEndian.PutUint32(data[4:], crc32.Checksum(data[8:], crcTable))
+
+	if _, err := s.tuples.WriteAt(data, s.size); err != nil {
+		// Discard whatever part of the tuple was written
+		s.tuples.Truncate(s.size)
+		return "", fmt.Errorf("failed to write tuple: %w", err)
+	}
+	s.offsets = append(s.offsets, record.Ref)
+	s.size += int64(len(data))
+	return diskTupleID(record.Ref), nil
+}
+
+// Remove marks the tuple with the given ID as deleted. The tuple stays in the
+// file but is no longer returned.
+func (s *DiskTupleStore) Remove(id TupleID) (bool, error) {
+	offset, err := strconv.ParseUint(string(id), 16, 64)
+	if err != nil || !s.exists(offset) {
+		return false, nil
+	}
+
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], offset)
+	if _, err := s.deleted.Write(b[:]); err != nil {
+		return false, fmt.Errorf("failed to record deleted tuple: %w", err)
+	}
+	s.removed[offset] = struct{}{}
+	return true, nil
+}
+
+// exists reports whether a tuple that has not been deleted starts at offset
+func (s *DiskTupleStore) exists(offset uint64) bool {
+	i := sort.Search(len(s.offsets), func(i int) bool { return s.offsets[i] >= offset })
+	if i == len(s.offsets) || s.offsets[i] != offset {
+		return false
+	}
+	_, removed := s.removed[offset]
+	return !removed
+}
+
+// All reads and returns every stored tuple in insertion order
+func (s *DiskTupleStore) All() ([]StoredTuple, error) {
+	var tuples []StoredTuple
+	_, err := s.scan(s.size, func(offset uint64, header []byte, r io.Reader) error {
+		if _, removed := s.removed[offset]; removed {
+			return nil
+		}
+		tuple, err := readTuple(header, r)
+		if err != nil {
+			return fmt.Errorf("tuple %v: %w", diskTupleID(offset), err)
+		}
+		tuples = append(tuples, StoredTuple{ID: diskTupleID(offset), Tuple: tuple})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tuples, nil
+}
+
+// MatchRecords calls fn with the match record of every stored tuple. Refs
+// are offsets into the file. Only tuple headers are read.
+func (s *DiskTupleStore) MatchRecords(fn func(protocol.MatchRecord) error) error {
+	_, err := s.scan(s.size, func(offset uint64, header []byte, _ io.Reader) error {
+		if _, removed := s.removed[offset]; removed {
+			return nil
+		}
+		var record protocol.MatchRecord
+		copy(record.Pi[:], header[8:])
+		copy(record.User[:], header[8+protocol.PiSize:])
+		record.Ref = offset
+		return fn(record)
+	})
+	return err
+}
+
+// LoadEntries returns the tuples at refs, as types.CallistoTuple values
+func (s *DiskTupleStore) LoadEntries(refs []uint64) ([]protocol.Matchable, error) {
+	stored, err := s.Load(refs)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]protocol.Matchable, len(stored))
+	for i, st := range stored {
+		entries[i] = st.Tuple
+	}
+	return entries, nil
+}
+
+// Load returns the stored tuples at refs, in the same order
+func (s *DiskTupleStore) Load(refs []uint64) ([]StoredTuple, error) {
+	tuples := make([]StoredTuple, len(refs))
+	for i, ref := range refs {
+		if !s.exists(ref) {
+			return nil, fmt.Errorf("%w: %v", ErrTupleNotFound, diskTupleID(ref))
+		}
+		header := make([]byte, diskHeaderSize)
+		if _, err := s.tuples.ReadAt(header, int64(ref)); err != nil {
+			return nil, fmt.Errorf("failed to read tuple %v: %w", diskTupleID(ref), err)
+		}
+		length := int64(binary.BigEndian.Uint32(header))
+		r := io.NewSectionReader(s.tuples, int64(ref)+diskHeaderSize, length)
+		tuple, err := readTuple(header, r)
+		if err != nil {
+			return nil, fmt.Errorf("tuple %v: %w", diskTupleID(ref), err)
+		}
+		tuples[i] = StoredTuple{ID: diskTupleID(ref), Tuple: tuple}
+	}
+	return tuples, nil
+}
+
+// readTuple reads, checks and decodes the encoding that follows a header
+func readTuple(header []byte, r io.Reader) (types.CallistoTuple, error) {
+	encoded, err := readEncoding(header, r)
+	if err != nil {
+		return types.CallistoTuple{}, err
+	}
+	return encoding.DecodeCallistoTuple(encoded)
+}
+
+// readEncoding reads the encoding that follows a header and checks it against
+// the header's checksum
+func readEncoding(header []byte, r io.Reader) ([]byte, error) {
+	encoded := make([]byte, binary.BigEndian.Uint32(header))
+	if _, err := io.ReadFull(r, encoded); err != nil {
+		return nil, fmt.Errorf("failed to read tuple: %w", err)
+	}
+	checksum := crc32.Update(crc32.Checksum(header[8:], crcTable), crcTable, encoded)
+	if checksum != binary.BigEndian.Uint32(header[4:]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorruptStore)
+	}
+	return encoded, nil
+}
+
+// Sync flushes the store's files to stable storage
+func (s *DiskTupleStore) Sync() error {
+	if err := s.tuples.Sync(); err != nil {
+		return fmt.Errorf("failed to sync tuple store: %w", err)
+	}
+	if err := s.deleted.Sync(); err != nil {
+		return fmt.Errorf("failed to sync tuple store: %w", err)
+	}
+	return nil
+}
+
+// Close syncs and closes the store
+func (s *DiskTupleStore) Close() error {
+	syncErr := s.Sync()
+	tuplesErr := s.tuples.Close()
+	deletedErr := s.deleted.Close()
+	for _, err := range []error{syncErr, tuplesErr, deletedErr} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func diskTupleID(offset uint64) TupleID {
+	return TupleID(fmt.Sprintf("%016x", offset))
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	helper "github.com/ymarcus93/gallisto/internal/test"
+	"github.com/ymarcus93/gallisto/protocol"
+	"github.com/ymarcus93/gallisto/types"
+)
+
+func createTempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "gallisto-store")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func openDiskStore(t *testing.T, dir string) *DiskTupleStore {
+	store, err := OpenDiskTupleStore(dir)
+	if err != nil {
+		t.Fatalf("failed to open disk store: %v", err)
+	}
+	return store
+}
+
+func createDiskStore(t *testing.T) *DiskTupleStore {
+	store := openDiskStore(t, createTempDir(t))
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestDiskTupleStore(t *testing.T) {
+	dir := createTempDir(t)
+	store := openDiskStore(t, dir)
+
+	pi := helper.GenerateRandomBytes(32, t)
+	tuples := []types.CallistoTuple{
+		createTuple(t, []byte("user1"), pi),
+		createTuple(t, []byte("user2"), pi),
+		createTuple(t, []byte("user3"), helper.GenerateRandomBytes(32, t)).WithMatchClass(types.MatchVariant),
+	}
+	var ids []TupleID
+	for _, tuple := range tuples {
+		id, err := store.Add(tuple)
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	removed, err := store.Remove(ids[1])
+	assert.NoError(t, err)
+	assert.True(t, removed)
+	for _, id := range []TupleID{ids[1], "not an id", "0000000000000001"} {
+		removed, err = store.Remove(id)
+		assert.NoError(t, err)
+		assert.False(t, removed)
+	}
+
+	expected := []StoredTuple{{ID: ids[0], Tuple: tuples[0]}, {ID: ids[2], Tuple: tuples[2]}}
+	stored, err := store.All()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, stored)
+
+	// Everything survives reopening
+	assert.NoError(t, store.Close())
+	store = openDiskStore(t, dir)
+	defer store.Close()
+	stored, err = store.All()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, stored)
+
+	var records []protocol.MatchRecord
+	err = store.MatchRecords(func(r protocol.MatchRecord) error {
+		records = append(records, r)
+		return nil
+	})
+	if assert.NoError(t, err) && assert.Len(t, records, 2) {
+		loaded, err := store.Load([]uint64{records[1].Ref, records[0].Ref})
+		assert.NoError(t, err)
+		assert.Equal(t, []StoredTuple{expected[1], expected[0]}, loaded)
+	}
+}
+
+func TestDiskTupleStore_IncompleteTuple(t *testing.T) {
+	dir := createTempDir(t)
+	store := openDiskStore(t, dir)
+	first := createTuple(t, []byte("user1"), helper.GenerateRandomBytes(32, t))
+	_, err := store.Add(first)
+	assert.NoError(t, err)
+	assert.NoError(t, store.Close())
+
+	// Simulate a crash part way through writing a tuple
+	f, err := os.OpenFile(filepath.Join(dir, tuplesFileName), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatalf("failed to open tuples file: %v", err)
+	}
+	_, err = f.Write([]byte{0, 0, 1, 0, 0xde, 0xad})
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	store = openDiskStore(t, dir)
+	defer store.Close()
+	second := createTuple(t, []byte("user2"), helper.GenerateRandomBytes(32, t))
+	secondID, err := store.Add(second)
+	assert.NoError(t, err)
+
+	stored, err := store.All()
+	if assert.NoError(t, err) && assert.Len(t, stored, 2) {
+		assert.Equal(t, first, stored[0].Tuple)
+		assert.Equal(t, StoredTuple{ID: secondID, Tuple: second}, stored[1])
+	}
+}
+
+func TestDiskTupleStore_Corrupt(t *testing.T) {
+	dir := createTempDir(t)
+	store := openDiskStore(t, dir)
+	_, err := store.Add(createTuple(t, []byte("user1"), helper.GenerateRandomBytes(32, t)))
+	assert.NoError(t, err)
+	assert.NoError(t, store.Close())
+
+	// Flip a bit of the stored encoding
+	path := filepath.Join(dir, tuplesFileName)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read tuples file: %v", err)
+	}
+	data[len(data)-1] ^= 1
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("failed to write tuples file: %v", err)
+	}
+
+	_, err = OpenDiskTupleStore(dir)
+	assert.True(t, errors.Is(err, ErrCorruptStore))
+}
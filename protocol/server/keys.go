@@ -0,0 +1,93 @@
+package server
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrUnknownSigner is returned when no signing key is registered for a
+	// user ID
+	ErrUnknownSigner = errors.New("no signing key registered for user")
+	// ErrKeyConflict is returned when a user ID already has a different
+	// signing key
+	ErrKeyConflict = errors.New("user already has a different signing key")
+)
+
+// KeyRegistry holds the Ed25519 key each user signs tuples with
+type KeyRegistry interface {
+	// Register sets the signing key of userID. A registered key cannot be
+	// replaced this way, so that nobody can take over a user's tuples.
+	Register(userID []byte, key ed25519.PublicKey) error
+	// Replace sets the signing key of userID, replacing any registered key.
+	// Callers must have checked that the user owns key, e.g. with a
+	// certificate.
+	Replace(userID []byte, key ed25519.PublicKey) error
+	// SigningKey returns the key of userID, or an error wrapping
+	// ErrUnknownSigner
+	SigningKey(userID []byte) (ed25519.PublicKey, error)
+}
+
+// MemoryKeyRegistry is a KeyRegistry that keeps keys in memory. It is safe for
+// concurrent use.
+type MemoryKeyRegistry struct {
+	mu   sync.RWMutex
+	keys map[string]ed25519.PublicKey
+}
+
+// NewMemoryKeyRegistry returns an empty MemoryKeyRegistry
+func NewMemoryKeyRegistry() *MemoryKeyRegistry {
+	return &MemoryKeyRegistry{keys: make(map[string]ed25519.PublicKey)}
+}
+
+// Register implements KeyRegistry. Registering the same key again succeeds.
+func (r *MemoryKeyRegistry) Register(userID []byte, key ed25519.PublicKey) error {
+	if err := checkRegistration(userID, key); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if registered, ok := r.keys[string(userID)]; ok {
+		if bytes.Equal(registered, key) {
+			return nil
+		}
+		return ErrKeyConflict
+	}
+	r.keys[string(userID)] = append(ed25519.PublicKey(nil), key...)
+	return nil
+}
+
+// Replace implements KeyRegistry
+func (r *MemoryKeyRegistry) Replace(userID []byte, key ed25519.PublicKey) error {
+	if err := checkRegistration(userID, key); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys[string(userID)] = append(ed25519.PublicKey(nil), key...)
+	return nil
+}
+
+func checkRegistration(userID []byte, key ed25519.PublicKey) error {
+	if len(userID) == 0 {
+		return fmt.Errorf("user ID cannot be empty")
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("signing key must be %v bytes, got %v", ed25519.PublicKeySize, len(key))
+	}
+	return nil
+}
+
+// SigningKey implements KeyRegistry
+func (r *MemoryKeyRegistry) SigningKey(userID []byte) (ed25519.PublicKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	key, ok := r.keys[string(userID)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %x", ErrUnknownSigner, userID)
+	}
+	return key, nil
+}
@@ -0,0 +1,481 @@
+// Package server implements the Callisto server role: it holds the OPRF key,
+// stores submitted tuples and finds matches between them.
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ymarcus93/gallisto/audit"
+	"github.com/ymarcus93/gallisto/identity"
+	"github.com/ymarcus93/gallisto/oprf"
+	"github.com/ymarcus93/gallisto/protocol"
+	"github.com/ymarcus93/gallisto/suite"
+	"github.com/ymarcus93/gallisto/transparency"
+	"github.com/ymarcus93/gallisto/types"
+)
+
+var (
+	// ErrTupleNotFound is returned when no stored tuple has the requested ID
+	ErrTupleNotFound = errors.New("tuple not found")
+	// ErrNoMatch is returned when the tuples sharing a pi value do not form a
+	// match
+	ErrNoMatch = errors.New("no match for pi value")
+)
+
+// CallistoServer stores Callisto tuples, finds matches between them and
+// evaluates the OPRF for clients. It is safe for concurrent use.
+type CallistoServer struct {
+	evaluator         oprf.ContextOPRFEvaluator
+	matcher           *protocol.Matcher
+	verifier          CertificateVerifier
+	registry          KeyRegistry
+	signedMatchesOnly bool
+	audit             audit.Recorder
+	transparency      *transparency.Log
+
+	mu    sync.RWMutex // guards store
+	store TupleStore
+}
+
+// Option configures optional CallistoServer behaviour
+type Option func(*CallistoServer)
+
+// WithMatcher sets the matcher used to find matches among stored tuples. The
+// default is protocol.NewMatcher().
+func WithMatcher(matcher *protocol.Matcher) Option {
+	return func(s *CallistoServer) {
+		s.matcher = matcher
+	}
+}
+
+// CertificateVerifier checks the certificate of a user ID and returns the key
+// its holder signs tuples with. identity.Verifier implements it.
+type CertificateVerifier interface {
+	Verify(userID, certificate []byte) (ed25519.PublicKey, error)
+}
+
+// WithCertificateVerifier makes the server only accept tuples carrying a
+// certificate of their user ID that verifier accepts and signed with the
+// certificate's holder key, so that matches count certified users only and a
+// certificate copied from a stored tuple is useless without the holder's
+// private key. Certificates are checked on submission; stored tuples stay
+// matchable after their certificate expires.
+func WithCertificateVerifier(verifier CertificateVerifier) Option {
+	return func(s *CallistoServer) {
+		s.verifier = verifier
+	}
+}
+
+// WithKeyRegistry makes the server check tuple signatures against the signing
+// keys registered in registry. Submit rejects signed tuples whose signature
+// does not verify under their user's key, and accepts unsigned tuples unless
+// WithSignedMatchesOnly is also set.
+func WithKeyRegistry(registry KeyRegistry) Option {
+	return func(s *CallistoServer) {
+		s.registry = registry
+	}
+}
+
+// WithSignedMatchesOnly makes the server reject unsigned tuples and ignore,
+// when matching, stored tuples whose signature no longer verifies, e.g.
+// because they were altered in storage. It requires WithKeyRegistry.
+func WithSignedMatchesOnly() Option {
+	return func(s *CallistoServer) {
+		s.signedMatchesOnly = true
+	}
+}
+
+// WithAuditLog makes the server record submissions, deletions, match queries
+// and signing key registrations with recorder. Deletions, queries and
+// registrations are recorded before they run and fail if they cannot be
+// recorded. A submission is recorded once stored, as its record names the ID
+// it was stored under, and is removed again if it cannot be recorded.
+func WithAuditLog(recorder audit.Recorder) Option {
+	return func(s *CallistoServer) {
+		s.audit = recorder
+	}
+}
+
+// WithTransparencyLog makes the server append the hash of every tuple it
+// stores to log, so that submitters get a receipt for their tuples and can
+// later check that the server did not drop them from its history. Deleting a
+// tuple does not remove it from the log. A submission that cannot be appended
+// is removed again. For a log to survive restarts, as a DiskTupleStore does,
+// give it a transparency.LeafStore kept with the tuple store.
+func WithTransparencyLog(log *transparency.Log) Option {
+	return func(s *CallistoServer) {
+		s.transparency = log
+	}
+}
+
+// NewCallistoServer returns a CallistoServer that evaluates the OPRF with
+// evaluator and keeps submitted tuples in store. If store is a
+// RecordTupleStore, matching sorts its match records on disk instead of
+// loading every tuple.
+func NewCallistoServer(evaluator oprf.OPRFEvaluator, store TupleStore, opts ...Option) (*CallistoServer, error) {
+	if evaluator == nil {
+		return nil, fmt.Errorf("evaluator cannot be nil")
+	}
+	if store == nil {
+		return nil, fmt.Errorf("store cannot be nil")
+	}
+	s := &CallistoServer{evaluator: oprf.WithContext(evaluator), store: store}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.signedMatchesOnly && s.registry == nil {
+		return nil, fmt.Errorf("signed matches require a key registry")
+	}
+	if s.matcher == nil {
+		s.matcher = protocol.NewMatcher()
+	}
+	if s.signedMatchesOnly {
+		s.matcher = s.matcher.With(protocol.WithEntryFilter(s.hasValidSignature))
+	}
+	return s, nil
+}
+
+// EvaluateOPRF evaluates the OPRF on blinded inputs sent by a client. A
+// CallistoServer can therefore be passed to oprf.NewPHatComputer, with the
+// config of its evaluator.
+func (s *CallistoServer) EvaluateOPRF(req oprf.EvaluationRequest) (oprf.Evaluation, error) {
+	return s.EvaluateOPRFContext(context.Background(), req)
+}
+
+// EvaluateOPRFContext is like EvaluateOPRF but passes ctx on to the server's
+// evaluator
+func (s *CallistoServer) EvaluateOPRFContext(ctx context.Context, req oprf.EvaluationRequest) (oprf.Evaluation, error) {
+	return s.evaluator.EvaluateOPRFContext(ctx, req)
+}
+
+// Submit stores a tuple and returns the ID assigned to it
+func (s *CallistoServer) Submit(tuple types.CallistoTuple) (TupleID, error) {
+	id, _, err := s.submit(tuple)
+	return id, err
+}
+
+// SubmitWithReceipt is like Submit but also returns the transparency log's
+// receipt for the tuple, which transparency.Verifier.VerifyReceipt checks
+// against the tuple's Hash. It requires WithTransparencyLog.
+func (s *CallistoServer) SubmitWithReceipt(tuple types.CallistoTuple) (TupleID, transparency.Receipt, error) {
+	if s.transparency == nil {
+		return "", transparency.Receipt{}, fmt.Errorf("server has no transparency log")
+	}
+	return s.submit(tuple)
+}
+
+func (s *CallistoServer) submit(tuple types.CallistoTuple) (TupleID, transparency.Receipt, error) {
+	if tuple.UserID() == nil || tuple.Pi() == nil {
+		return "", transparency.Receipt{}, fmt.Errorf("%w: tuple is missing a user ID or pi value", types.ErrInvalidTuple)
+	}
+	if len(tuple.Pi()) != protocol.PiSize {
+		return "", transparency.Receipt{}, fmt.Errorf("%w: pi value must be %v bytes", types.ErrInvalidTuple, protocol.PiSize)
+	}
+	if _, err := suite.Lookup(tuple.Suite()); err != nil {
+		return "", transparency.Receipt{}, fmt.Errorf("%w: %v", types.ErrInvalidTuple, err)
+	}
+	if s.verifier != nil {
+		holderKey, err := s.verifier.Verify(tuple.UserID(), tuple.UserCertificate())
+		if err != nil {
+			return "", transparency.Receipt{}, fmt.Errorf("failed to verify user certificate: %w", err)
+		}
+		// The holder proves possession of the certified key by signing
+		if err := tuple.VerifySignature(holderKey); err != nil {
+			return "", transparency.Receipt{}, fmt.Errorf("tuple is not signed by the certificate's holder: %w", err)
+		}
+	}
+	if err := s.verifySignature(tuple); err != nil {
+		return "", transparency.Receipt{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, err := s.store.Add(tuple)
+	if err != nil {
+		return "", transparency.Receipt{}, fmt.Errorf("failed to store tuple: %w", err)
+	}
+	err = s.record(audit.Submit, map[string]string{
+		"tuple": string(id),
+		"user":  hex.EncodeToString(tuple.UserID()),
+		"suite": tuple.Suite().String(),
+	})
+	if err != nil {
+		if _, removeErr := s.store.Remove(id); removeErr != nil {
+			return "", transparency.Receipt{}, fmt.Errorf("%v, and failed to remove unrecorded tuple %v: %w", err, id, removeErr)
+		}
+		return "", transparency.Receipt{}, err
+	}
+	var receipt transparency.Receipt
+	if s.transparency != nil {
+		// The receipt promises that the tuple is kept
+		if syncStore, ok := s.store.(SyncTupleStore); ok {
+			if err := syncStore.Sync(); err != nil {
+				return "", transparency.Receipt{}, s.discard(id, fmt.Errorf("failed to sync tuple store: %w", err))
+			}
+		}
+		tupleHash := tuple.Hash()
+		if receipt, err = s.transparency.Append(tupleHash[:]); err != nil {
+			return "", transparency.Receipt{}, s.discard(id, fmt.Errorf("failed to append tuple to transparency log: %w", err))
+		}
+	}
+	return id, receipt, nil
+}
+
+// discard removes a recorded submission that failed with err and records the
+// removal, so that the audit log shows no tuple as kept that was not. It
+// returns err, along with any error removing the tuple.
+func (s *CallistoServer) discard(id TupleID, err error) error {
+	if _, removeErr := s.store.Remove(id); removeErr != nil {
+		return fmt.Errorf("%v, and failed to remove tuple %v: %w", err, id, removeErr)
+	}
+	if syncStore, ok := s.store.(SyncTupleStore); ok {
+		if syncErr := syncStore.Sync(); syncErr != nil {
+			return fmt.Errorf("%v, and failed to sync removal of tuple %v: %w", err, id, syncErr)
+		}
+	}
+	recordErr := s.record(audit.Delete, map[string]string{"tuple": string(id), "reason": "submission failed"})
+	if recordErr != nil {
+		return fmt.Errorf("%v, and failed to record removal of tuple %v: %w", err, id, recordErr)
+	}
+	return err
+}
+
+// TreeHead returns the signed head of the server's transparency log
+func (s *CallistoServer) TreeHead() (transparency.TreeHead, error) {
+	if s.transparency == nil {
+		return transparency.TreeHead{}, fmt.Errorf("server has no transparency log")
+	}
+	return s.transparency.TreeHead(), nil
+}
+
+// InclusionProof returns the proof that the tree of the first size tuples of
+// the server's transparency log includes the tuple at index
+func (s *CallistoServer) InclusionProof(index, size uint64) ([]transparency.Hash, error) {
+	if s.transparency == nil {
+		return nil, fmt.Errorf("server has no transparency log")
+	}
+	return s.transparency.InclusionProof(index, size)
+}
+
+// ConsistencyProof returns the proof that the tree of the first size2 tuples
+// of the server's transparency log extends the tree of the first size1
+func (s *CallistoServer) ConsistencyProof(size1, size2 uint64) ([]transparency.Hash, error) {
+	if s.transparency == nil {
+		return nil, fmt.Errorf("server has no transparency log")
+	}
+	return s.transparency.ConsistencyProof(size1, size2)
+}
+
+// record records an operation in the server's audit log, if it has one
+func (s *CallistoServer) record(action audit.Action, details map[string]string) error {
+	if s.audit == nil {
+		return nil
+	}
+	if err := s.audit.Record(audit.Event{Action: action, Details: details}); err != nil {
+		return fmt.Errorf("failed to record %v in audit log: %w", action, err)
+	}
+	return nil
+}
+
+// RegisterSigningKey registers the key userID signs its tuples with. proof
+// must be the key's proof from identity.ProveKey, so that nobody registers a
+// key they do not hold. Without a CertificateVerifier, certificate is ignored
+// and the first key registered for userID stays: registering another fails
+// with ErrKeyConflict. With one, certificate must certify userID with key as
+// its holder key, so that nobody can register a key for another user's ID,
+// and the certified key replaces any registered one, e.g. after the user
+// enrolled again with a new key.
+func (s *CallistoServer) RegisterSigningKey(userID, certificate []byte, key ed25519.PublicKey, proof []byte) error {
+	if s.registry == nil {
+		return fmt.Errorf("server has no key registry")
+	}
+	if err := identity.VerifyKeyProof(userID, key, proof); err != nil {
+		return err
+	}
+	if s.verifier != nil {
+		holderKey, err := s.verifier.Verify(userID, certificate)
+		if err != nil {
+			return fmt.Errorf("failed to verify user certificate: %w", err)
+		}
+		if !holderKey.Equal(key) {
+			return fmt.Errorf("%w: key is not the certificate's holder key", identity.ErrUncertified)
+		}
+	}
+	err := s.record(audit.KeyRegister, map[string]string{
+		"user": hex.EncodeToString(userID),
+		"key":  hex.EncodeToString(key),
+	})
+	if err != nil {
+		return err
+	}
+	if s.verifier != nil {
+		return s.registry.Replace(userID, key)
+	}
+	return s.registry.Register(userID, key)
+}
+
+// verifySignature checks the signature of a submitted tuple, if the server
+// verifies signatures
+func (s *CallistoServer) verifySignature(tuple types.CallistoTuple) error {
+	if s.registry == nil || (tuple.Signature() == nil && !s.signedMatchesOnly) {
+		return nil
+	}
+	key, err := s.registry.SigningKey(tuple.UserID())
+	if err != nil {
+		return fmt.Errorf("%w: %v", types.ErrInvalidSignature, err)
+	}
+	return tuple.VerifySignature(key)
+}
+
+// hasValidSignature reports whether a stored tuple is signed with its user's
+// registered key
+func (s *CallistoServer) hasValidSignature(entry protocol.Matchable) bool {
+	tuple, ok := entry.(types.CallistoTuple)
+	return ok && s.verifySignature(tuple) == nil
+}
+
+// matchable reports whether a stored tuple may be part of a match
+func (s *CallistoServer) matchable(tuple types.CallistoTuple) bool {
+	return !s.signedMatchesOnly || s.hasValidSignature(tuple)
+}
+
+// Delete removes a stored tuple. It returns ErrTupleNotFound if no tuple has
+// the given ID.
+func (s *CallistoServer) Delete(id TupleID) error {
+	if err := s.record(audit.Delete, map[string]string{"tuple": string(id)}); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed, err := s.store.Remove(id)
+	if err != nil {
+		return fmt.Errorf("failed to remove tuple %v: %w", id, err)
+	}
+	if !removed {
+		return ErrTupleNotFound
+	}
+	return nil
+}
+
+// Matches returns every match among the stored tuples (see
+// protocol.FindMatches). Matched entries are types.CallistoTuple values.
+func (s *CallistoServer) Matches() ([]protocol.PiMatch, error) {
+	if err := s.record(audit.MatchQuery, map[string]string{"query": "matches"}); err != nil {
+		return nil, err
+	}
+	if recordStore, ok := s.store.(RecordTupleStore); ok {
+		// Submissions wait until the store has been read
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return s.matcher.FindMatchesExternal(recordStore)
+	}
+
+	stored, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	matchables := make([]protocol.Matchable, len(stored))
+	for i, st := range stored {
+		matchables[i] = st.Tuple
+	}
+	return s.matcher.FindMatches(matchables)
+}
+
+// TuplesForMatch returns the stored tuples sharing the pi value of a match, to
+// be handed to the LOCs for decryption. It returns ErrNoMatch if those tuples
+// do not form a match.
+func (s *CallistoServer) TuplesForMatch(pi []byte) ([]StoredTuple, error) {
+	err := s.record(audit.MatchQuery, map[string]string{
+		"query": "tuples_for_match",
+		"pi":    hex.EncodeToString(pi),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if recordStore, ok := s.store.(RecordTupleStore); ok {
+		return s.recordTuplesForMatch(recordStore, pi)
+	}
+
+	stored, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	var tuples []StoredTuple
+	users := make(map[string]struct{})
+	for _, st := range stored {
+		if bytes.Equal(st.Tuple.Pi(), pi) && s.matchable(st.Tuple) {
+			tuples = append(tuples, st)
+			users[string(st.Tuple.UserID())] = struct{}{}
+		}
+	}
+	if len(users) < 2 {
+		return nil, ErrNoMatch
+	}
+	return tuples, nil
+}
+
+// recordTuplesForMatch is TuplesForMatch for a RecordTupleStore. Only the
+// tuples sharing pi are loaded.
+func (s *CallistoServer) recordTuplesForMatch(store RecordTupleStore, pi []byte) ([]StoredTuple, error) {
+	key, err := protocol.NewPiKey(pi)
+	if err != nil {
+		return nil, ErrNoMatch
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var refs []uint64
+	users := make(map[[sha256.Size]byte]struct{})
+	err = store.MatchRecords(func(r protocol.MatchRecord) error {
+		if r.Pi == key {
+			refs = append(refs, r.Ref)
+			users[r.User] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stored tuples: %w", err)
+	}
+	if len(users) < 2 {
+		return nil, ErrNoMatch
+	}
+	tuples, err := store.Load(refs)
+	if err != nil || !s.signedMatchesOnly {
+		return tuples, err
+	}
+
+	// Check the distinct users again among the tuples that are matchable
+	kept := tuples[:0]
+	users = make(map[[sha256.Size]byte]struct{})
+	for _, st := range tuples {
+		if s.matchable(st.Tuple) {
+			kept = append(kept, st)
+			users[sha256.Sum256(st.Tuple.UserID())] = struct{}{}
+		}
+	}
+	if len(users) < 2 {
+		return nil, ErrNoMatch
+	}
+	return kept, nil
+}
+
+// snapshot returns a copy of the stored tuples so that matching can run
+// without holding the lock
+func (s *CallistoServer) snapshot() ([]StoredTuple, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stored, err := s.store.All()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stored tuples: %w", err)
+	}
+	return stored, nil
+}
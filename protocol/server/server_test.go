@@ -0,0 +1,587 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ymarcus93/gallisto/audit"
+	"github.com/ymarcus93/gallisto/identity"
+	helper "github.com/ymarcus93/gallisto/internal/test"
+	"github.com/ymarcus93/gallisto/oprf"
+	"github.com/ymarcus93/gallisto/transparency"
+	"github.com/ymarcus93/gallisto/types"
+)
+
+func createServer(t *testing.T) *CallistoServer {
+	return createServerWithStore(t, NewMemoryTupleStore())
+}
+
+func createServerWithStore(t *testing.T, store TupleStore, opts ...Option) *CallistoServer {
+	key, err := oprf.GenerateKey(types.OPRF_CIPHERSUITE)
+	if err != nil {
+		t.Fatalf("failed to generate OPRF key: %v", err)
+	}
+	oprfServer, err := oprf.NewOPRFServer(oprf.Config{Ciphersuite: types.OPRF_CIPHERSUITE}, key)
+	if err != nil {
+		t.Fatalf("failed to create OPRF server: %v", err)
+	}
+	server, err := NewCallistoServer(oprfServer, store, opts...)
+	if err != nil {
+		t.Fatalf("failed to create callisto server: %v", err)
+	}
+	return server
+}
+
+func createTuple(t *testing.T, userID, pi []byte) types.CallistoTuple {
+	tuple, err := types.NewCallistoTuple(
+		userID,
+		pi,
+		helper.GenerateRandomBytes(32, t),
+		helper.GenerateRandomBytes(32, t),
+		helper.CreateGCMCiphertext(t),
+		helper.CreateGCMCiphertext(t),
+		helper.CreateGCMCiphertext(t),
+	)
+	if err != nil {
+		t.Fatalf("failed to create tuple: %v", err)
+	}
+	return tuple
+}
+
+// createStores returns every TupleStore implementation, empty
+func createStores(t *testing.T) map[string]TupleStore {
+	return map[string]TupleStore{
+		"memory store": NewMemoryTupleStore(),
+		"disk store":   createDiskStore(t),
+	}
+}
+
+func TestCallistoServer(t *testing.T) {
+	for storeName, store := range createStores(t) {
+		t.Run(storeName, func(t *testing.T) {
+			testCallistoServer(t, createServerWithStore(t, store))
+		})
+	}
+}
+
+func testCallistoServer(t *testing.T, server *CallistoServer) {
+	pi := helper.GenerateRandomBytes(32, t)
+
+	firstID, err := server.Submit(createTuple(t, []byte("user1"), pi))
+	assert.Nil(t, err)
+	_, err = server.Submit(createTuple(t, []byte("user1"), pi))
+	assert.Nil(t, err)
+
+	// Tuples from a single user never match
+	matches, err := server.Matches()
+	assert.Nil(t, err)
+	assert.Empty(t, matches)
+	_, err = server.TuplesForMatch(pi)
+	assert.Equal(t, ErrNoMatch, err)
+
+	secondID, err := server.Submit(createTuple(t, []byte("user2"), pi))
+	assert.Nil(t, err)
+	assert.NotEqual(t, firstID, secondID)
+
+	matches, err = server.Matches()
+	assert.Nil(t, err)
+	if assert.Len(t, matches, 1) {
+		assert.Equal(t, pi, matches[0].SharedPiValue)
+		assert.Len(t, matches[0].MatchedEntries, 3)
+	}
+	tuples, err := server.TuplesForMatch(pi)
+	assert.Nil(t, err)
+	assert.Len(t, tuples, 3)
+	assert.Equal(t, firstID, tuples[0].ID)
+
+	// Deleting the second user's tuple removes the match
+	assert.Nil(t, server.Delete(secondID))
+	assert.Equal(t, ErrTupleNotFound, server.Delete(secondID))
+	matches, err = server.Matches()
+	assert.Nil(t, err)
+	assert.Empty(t, matches)
+}
+
+func TestCallistoServer_InvalidSubmission(t *testing.T) {
+	server := createServer(t)
+	_, err := server.Submit(types.CallistoTuple{})
+	assert.NotNil(t, err)
+	_, err = server.Submit(createTuple(t, []byte("user1"), []byte("short pi")))
+	assert.True(t, errors.Is(err, types.ErrInvalidTuple))
+	_, err = server.Submit(createTuple(t, []byte("user1"), helper.GenerateRandomBytes(32, t)).WithSuite(255))
+	assert.True(t, errors.Is(err, types.ErrInvalidTuple))
+}
+
+func TestCallistoServer_CertifiedUsers(t *testing.T) {
+	_, signingKey, err := ed25519.GenerateKey(nil)
+	assert.Nil(t, err)
+	authority, err := identity.NewAuthority(signingKey)
+	assert.Nil(t, err)
+	verifier, err := identity.NewVerifier(authority.PublicKey())
+	assert.Nil(t, err)
+	server := createServerWithStore(t, NewMemoryTupleStore(), WithCertificateVerifier(verifier))
+
+	pi := helper.GenerateRandomBytes(32, t)
+	type holder struct {
+		certificate []byte
+		key         ed25519.PrivateKey
+	}
+	enroll := func(subject string) holder {
+		publicKey, key, err := ed25519.GenerateKey(nil)
+		assert.Nil(t, err)
+		certificate, err := authority.Enroll(subject, publicKey)
+		assert.Nil(t, err)
+		encoded, err := certificate.MarshalBinary()
+		assert.Nil(t, err)
+		return holder{certificate: encoded, key: key}
+	}
+	signedTuple := func(userID []byte, certificate []byte, key ed25519.PrivateKey) types.CallistoTuple {
+		tuple, err := createTuple(t, userID, pi).WithUserCertificate(certificate).Sign(key)
+		assert.Nil(t, err)
+		return tuple
+	}
+	certify := func(subject string) types.CallistoTuple {
+		h := enroll(subject)
+		certificate, err := identity.ParseCertificate(h.certificate)
+		assert.Nil(t, err)
+		return signedTuple(certificate.UserID, h.certificate, h.key)
+	}
+
+	// Enrolling the same subject twice gives the same user ID, so one person
+	// cannot complete a match alone
+	_, err = server.Submit(certify("alice@example.edu"))
+	assert.Nil(t, err)
+	_, err = server.Submit(certify("alice@example.edu"))
+	assert.Nil(t, err)
+	matches, err := server.Matches()
+	assert.Nil(t, err)
+	assert.Empty(t, matches)
+	_, err = server.Submit(certify("bob@example.edu"))
+	assert.Nil(t, err)
+	matches, err = server.Matches()
+	assert.Nil(t, err)
+	assert.Len(t, matches, 1)
+
+	// Uncertified user IDs, and certificates of other user IDs, are rejected
+	_, err = server.Submit(createTuple(t, helper.GenerateRandomBytes(16, t), pi))
+	assert.True(t, errors.Is(err, identity.ErrUncertified))
+	carol := enroll("carol@example.edu")
+	stolen := certify("carol@example.edu")
+	_, err = server.Submit(signedTuple(helper.GenerateRandomBytes(16, t), stolen.UserCertificate(), carol.key))
+	assert.True(t, errors.Is(err, identity.ErrUncertified))
+
+	// A certificate copied from a stored tuple is useless without its holder's
+	// key
+	_, thiefKey, err := ed25519.GenerateKey(nil)
+	assert.Nil(t, err)
+	_, err = server.Submit(signedTuple(stolen.UserID(), stolen.UserCertificate(), thiefKey))
+	assert.True(t, errors.Is(err, types.ErrInvalidSignature))
+	_, err = server.Submit(createTuple(t, stolen.UserID(), pi).WithUserCertificate(stolen.UserCertificate()))
+	assert.True(t, errors.Is(err, types.ErrInvalidSignature))
+
+	// Signing keys are only registered for certified user IDs and their holder
+	// keys, by their holders
+	registry := NewMemoryKeyRegistry()
+	server = createServerWithStore(t, NewMemoryTupleStore(), WithCertificateVerifier(verifier), WithKeyRegistry(registry))
+	certificate, err := identity.ParseCertificate(carol.certificate)
+	assert.Nil(t, err)
+	userID := certificate.UserID
+	err = server.RegisterSigningKey(userID, carol.certificate, thiefKey.Public().(ed25519.PublicKey), proveKey(t, userID, thiefKey))
+	assert.True(t, errors.Is(err, identity.ErrUncertified))
+	otherID := helper.GenerateRandomBytes(16, t)
+	err = server.RegisterSigningKey(otherID, carol.certificate, certificate.HolderKey, proveKey(t, otherID, carol.key))
+	assert.True(t, errors.Is(err, identity.ErrUncertified))
+	err = server.RegisterSigningKey(userID, carol.certificate, certificate.HolderKey, proveKey(t, otherID, carol.key))
+	assert.True(t, errors.Is(err, identity.ErrInvalidKeyProof))
+	assert.Nil(t, server.RegisterSigningKey(userID, carol.certificate, certificate.HolderKey, proveKey(t, userID, carol.key)))
+
+	// Enrolling again with a new key replaces the registered key
+	reenrolled := enroll("carol@example.edu")
+	newKey := reenrolled.key.Public().(ed25519.PublicKey)
+	assert.Nil(t, server.RegisterSigningKey(userID, reenrolled.certificate, newKey, proveKey(t, userID, reenrolled.key)))
+	registered, err := registry.SigningKey(userID)
+	assert.Nil(t, err)
+	assert.Equal(t, newKey, registered)
+	_, err = server.Submit(signedTuple(userID, carol.certificate, carol.key))
+	assert.True(t, errors.Is(err, types.ErrInvalidSignature))
+	_, err = server.Submit(signedTuple(userID, reenrolled.certificate, reenrolled.key))
+	assert.Nil(t, err)
+}
+
+// proveKey proves that the holder of key claims it for userID
+func proveKey(t *testing.T, userID []byte, key ed25519.PrivateKey) []byte {
+	proof, err := identity.ProveKey(userID, key)
+	if err != nil {
+		t.Fatalf("failed to prove key: %v", err)
+	}
+	return proof
+}
+
+func TestCallistoServer_SignedTuples(t *testing.T) {
+	for storeName, store := range createStores(t) {
+		t.Run(storeName, func(t *testing.T) {
+			server := createServerWithStore(t, store, WithKeyRegistry(NewMemoryKeyRegistry()), WithSignedMatchesOnly())
+			keys := make(map[string]ed25519.PrivateKey)
+			for _, user := range []string{"user1", "user2", "user3"} {
+				publicKey, signingKey, err := ed25519.GenerateKey(nil)
+				assert.Nil(t, err)
+				assert.Nil(t, server.RegisterSigningKey([]byte(user), nil, publicKey, proveKey(t, []byte(user), signingKey)))
+				keys[user] = signingKey
+			}
+			// Without certificates, the first key registered for a user ID
+			// stays
+			otherKey, otherSigningKey, err := ed25519.GenerateKey(nil)
+			assert.Nil(t, err)
+			err = server.RegisterSigningKey([]byte("user1"), nil, otherKey, proveKey(t, []byte("user1"), otherSigningKey))
+			assert.True(t, errors.Is(err, ErrKeyConflict))
+			// Nobody registers a key they do not hold
+			err = server.RegisterSigningKey([]byte("user4"), nil, otherKey, proveKey(t, []byte("user4"), keys["user1"]))
+			assert.True(t, errors.Is(err, identity.ErrInvalidKeyProof))
+			err = server.RegisterSigningKey([]byte("user4"), nil, otherKey, nil)
+			assert.True(t, errors.Is(err, identity.ErrInvalidKeyProof))
+
+			pi := helper.GenerateRandomBytes(32, t)
+			sign := func(user string, key ed25519.PrivateKey) types.CallistoTuple {
+				tuple, err := createTuple(t, []byte(user), pi).Sign(key)
+				assert.Nil(t, err)
+				return tuple
+			}
+
+			_, err = server.Submit(sign("user1", keys["user1"]))
+			assert.Nil(t, err)
+			for name, tuple := range map[string]types.CallistoTuple{
+				"unsigned":         createTuple(t, []byte("user2"), pi),
+				"other user's key": sign("user2", keys["user1"]),
+				"unknown signer":   sign("user4", keys["user1"]),
+			} {
+				_, err = server.Submit(tuple)
+				assert.True(t, errors.Is(err, types.ErrInvalidSignature), name)
+			}
+			matches, err := server.Matches()
+			assert.Nil(t, err)
+			assert.Empty(t, matches)
+
+			// Tuples altered in storage are ignored when matching
+			_, err = store.Add(sign("user2", keys["user2"]).WithMatchClass(types.MatchVariant))
+			assert.Nil(t, err)
+			matches, err = server.Matches()
+			assert.Nil(t, err)
+			assert.Empty(t, matches)
+			_, err = server.TuplesForMatch(pi)
+			assert.Equal(t, ErrNoMatch, err)
+
+			_, err = server.Submit(sign("user3", keys["user3"]))
+			assert.Nil(t, err)
+			matches, err = server.Matches()
+			assert.Nil(t, err)
+			if assert.Len(t, matches, 1) {
+				assert.Len(t, matches[0].MatchedEntries, 2)
+			}
+			tuples, err := server.TuplesForMatch(pi)
+			assert.Nil(t, err)
+			assert.Len(t, tuples, 2)
+		})
+	}
+
+	_, err := NewCallistoServer(createServer(t), NewMemoryTupleStore(), WithSignedMatchesOnly())
+	assert.NotNil(t, err)
+
+	// Without WithSignedMatchesOnly, unsigned tuples are accepted and matched
+	server := createServerWithStore(t, NewMemoryTupleStore(), WithKeyRegistry(NewMemoryKeyRegistry()))
+	pi := helper.GenerateRandomBytes(32, t)
+	_, err = server.Submit(createTuple(t, []byte("user1"), pi))
+	assert.Nil(t, err)
+	_, err = server.Submit(createTuple(t, []byte("user2"), pi))
+	assert.Nil(t, err)
+	matches, err := server.Matches()
+	assert.Nil(t, err)
+	assert.Len(t, matches, 1)
+}
+
+// TestCallistoServer_Concurrent hammers a server from many goroutines. Run it
+// with -race.
+// eventLog records events in memory and fails once failAfter events are
+// recorded, if it is positive
+type eventLog struct {
+	events    []audit.Event
+	failAfter int
+}
+
+func (l *eventLog) Record(event audit.Event) error {
+	if l.failAfter > 0 && len(l.events) >= l.failAfter {
+		return errors.New("audit log unavailable")
+	}
+	l.events = append(l.events, event)
+	return nil
+}
+
+func (l *eventLog) actions() []audit.Action {
+	actions := make([]audit.Action, len(l.events))
+	for i, e := range l.events {
+		actions[i] = e.Action
+	}
+	return actions
+}
+
+func TestCallistoServer_AuditLog(t *testing.T) {
+	for storeName, store := range createStores(t) {
+		t.Run(storeName, func(t *testing.T) {
+			log := &eventLog{}
+			server := createServerWithStore(t, store, WithAuditLog(audit.WithActor(log, "server")), WithKeyRegistry(NewMemoryKeyRegistry()))
+			pi := helper.GenerateRandomBytes(32, t)
+
+			firstID, err := server.Submit(createTuple(t, []byte("user1"), pi))
+			assert.Nil(t, err)
+			_, err = server.Submit(createTuple(t, []byte("user2"), pi))
+			assert.Nil(t, err)
+			_, err = server.Matches()
+			assert.Nil(t, err)
+			_, err = server.TuplesForMatch(pi)
+			assert.Nil(t, err)
+			assert.Nil(t, server.Delete(firstID))
+			publicKey, signingKey, err := ed25519.GenerateKey(nil)
+			assert.Nil(t, err)
+			assert.Nil(t, server.RegisterSigningKey([]byte("user2"), nil, publicKey, proveKey(t, []byte("user2"), signingKey)))
+
+			assert.Equal(t, []audit.Action{audit.Submit, audit.Submit, audit.MatchQuery, audit.MatchQuery, audit.Delete, audit.KeyRegister}, log.actions())
+			assert.Equal(t, "server", log.events[0].Actor)
+			assert.Equal(t, string(firstID), log.events[0].Details["tuple"])
+			assert.Equal(t, "7573657231", log.events[0].Details["user"])
+			assert.Equal(t, string(firstID), log.events[4].Details["tuple"])
+
+			// Nothing happens unrecorded
+			log.failAfter = len(log.events)
+			_, err = server.Submit(createTuple(t, []byte("user3"), pi))
+			assert.NotNil(t, err)
+			_, err = server.Matches()
+			assert.NotNil(t, err)
+			_, err = server.TuplesForMatch(pi)
+			assert.NotNil(t, err)
+
+			// The unrecorded submission was not kept, so only user2 is left
+			log.failAfter = 0
+			_, err = server.TuplesForMatch(pi)
+			assert.True(t, errors.Is(err, ErrNoMatch))
+		})
+	}
+}
+
+func TestCallistoServer_TransparencyLog(t *testing.T) {
+	for storeName, store := range createStores(t) {
+		t.Run(storeName, func(t *testing.T) {
+			_, logKey, err := ed25519.GenerateKey(nil)
+			if err != nil {
+				t.Fatalf("failed to generate log key: %v", err)
+			}
+			log, err := transparency.NewLog(logKey)
+			if err != nil {
+				t.Fatalf("failed to create transparency log: %v", err)
+			}
+			verifier, err := transparency.NewVerifier(log.PublicKey())
+			if err != nil {
+				t.Fatalf("failed to create verifier: %v", err)
+			}
+			server := createServerWithStore(t, store, WithTransparencyLog(log))
+			pi := helper.GenerateRandomBytes(32, t)
+
+			tuple := createTuple(t, []byte("user1"), pi)
+			id, receipt, err := server.SubmitWithReceipt(tuple)
+			assert.Nil(t, err)
+			tupleHash := tuple.Hash()
+			assert.Nil(t, verifier.VerifyReceipt(receipt, tupleHash[:]))
+
+			// Later submissions and deletions keep the tuple in the log
+			_, err = server.Submit(createTuple(t, []byte("user2"), pi))
+			assert.Nil(t, err)
+			_, _, err = server.SubmitWithReceipt(types.CallistoTuple{})
+			assert.NotNil(t, err)
+			assert.Nil(t, server.Delete(id))
+
+			head, err := server.TreeHead()
+			assert.Nil(t, err)
+			assert.Equal(t, uint64(2), head.Size)
+			consistency, err := server.ConsistencyProof(receipt.TreeHead.Size, head.Size)
+			assert.Nil(t, err)
+			assert.Nil(t, verifier.VerifyConsistency(receipt.TreeHead, head, consistency))
+			inclusion, err := server.InclusionProof(receipt.LeafIndex, head.Size)
+			assert.Nil(t, err)
+			assert.Nil(t, verifier.VerifyInclusion(receipt.LeafHash, receipt.LeafIndex, head, inclusion))
+		})
+	}
+
+	// Receipts require a transparency log
+	server := createServerWithStore(t, NewMemoryTupleStore())
+	_, _, err := server.SubmitWithReceipt(createTuple(t, []byte("user1"), helper.GenerateRandomBytes(32, t)))
+	assert.NotNil(t, err)
+	_, err = server.TreeHead()
+	assert.NotNil(t, err)
+
+	// Submissions the log cannot store are not kept
+	_, logKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("failed to generate log key: %v", err)
+	}
+	log, err := transparency.NewLog(logKey, transparency.WithLeafStore(failingLeafStore{}))
+	if err != nil {
+		t.Fatalf("failed to create transparency log: %v", err)
+	}
+	events := &eventLog{}
+	server = createServerWithStore(t, NewMemoryTupleStore(), WithTransparencyLog(log), WithAuditLog(events))
+	pi := helper.GenerateRandomBytes(32, t)
+	_, _, err = server.SubmitWithReceipt(createTuple(t, []byte("user1"), pi))
+	assert.NotNil(t, err)
+	_, err = server.TuplesForMatch(pi)
+	assert.True(t, errors.Is(err, ErrNoMatch))
+	// The audit log records the removal
+	assert.Equal(t, []audit.Action{audit.Submit, audit.Delete, audit.MatchQuery}, events.actions())
+	assert.Equal(t, events.events[0].Details["tuple"], events.events[1].Details["tuple"])
+}
+
+// failingLeafStore is a transparency.LeafStore that cannot store leaves
+type failingLeafStore struct{}
+
+func (failingLeafStore) Append(transparency.Hash) error {
+	return errors.New("disk full")
+}
+
+func (failingLeafStore) Leaves() ([]transparency.Hash, error) {
+	return nil, nil
+}
+
+// syncingStore is a SyncTupleStore that notes its syncs in steps
+type syncingStore struct {
+	*MemoryTupleStore
+	steps   *[]string
+	syncErr error
+}
+
+func (s syncingStore) Sync() error {
+	*s.steps = append(*s.steps, "sync store")
+	return s.syncErr
+}
+
+// stepLeafStore is a transparency.LeafStore that notes its appends in steps
+type stepLeafStore struct {
+	steps *[]string
+}
+
+func (s stepLeafStore) Append(transparency.Hash) error {
+	*s.steps = append(*s.steps, "append leaf")
+	return nil
+}
+
+func (stepLeafStore) Leaves() ([]transparency.Hash, error) {
+	return nil, nil
+}
+
+func TestCallistoServer_SyncBeforeLogging(t *testing.T) {
+	_, logKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("failed to generate log key: %v", err)
+	}
+	var steps []string
+	log, err := transparency.NewLog(logKey, transparency.WithLeafStore(stepLeafStore{steps: &steps}))
+	if err != nil {
+		t.Fatalf("failed to create transparency log: %v", err)
+	}
+	store := syncingStore{MemoryTupleStore: NewMemoryTupleStore(), steps: &steps}
+	server := createServerWithStore(t, store, WithTransparencyLog(log))
+	pi := helper.GenerateRandomBytes(32, t)
+
+	// A tuple is on stable storage before it is logged
+	_, _, err = server.SubmitWithReceipt(createTuple(t, []byte("user1"), pi))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"sync store", "append leaf"}, steps)
+
+	// Tuples that cannot be synced are neither logged nor kept
+	steps = nil
+	store.syncErr = errors.New("disk full")
+	server = createServerWithStore(t, store, WithTransparencyLog(log))
+	_, _, err = server.SubmitWithReceipt(createTuple(t, []byte("user2"), pi))
+	assert.NotNil(t, err)
+	assert.NotContains(t, steps, "append leaf")
+	stored, err := store.All()
+	assert.Nil(t, err)
+	assert.Len(t, stored, 1)
+}
+
+func TestCallistoServer_Concurrent(t *testing.T) {
+	for storeName, store := range createStores(t) {
+		t.Run(storeName, func(t *testing.T) {
+			testCallistoServerConcurrent(t, createServerWithStore(t, store))
+		})
+	}
+}
+
+func testCallistoServerConcurrent(t *testing.T, server *CallistoServer) {
+	const (
+		numWorkers   = 16
+		numPerWorker = 25
+	)
+	sharedPi := helper.GenerateRandomBytes(32, t)
+
+	// Create tuples up front so that workers only exercise the server
+	tuples := make([][]types.CallistoTuple, numWorkers)
+	for w := range tuples {
+		userID := []byte("user" + strconv.Itoa(w))
+		tuples[w] = make([]types.CallistoTuple, numPerWorker)
+		for i := range tuples[w] {
+			tuples[w][i] = createTuple(t, userID, sharedPi)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, numWorkers*numPerWorker*4)
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			pHatComputer, err := oprf.NewPHatComputer(oprf.Config{Ciphersuite: types.OPRF_CIPHERSUITE}, server)
+			if err != nil {
+				errs <- err
+				return
+			}
+			for i, tuple := range tuples[w] {
+				id, err := server.Submit(tuple)
+				if err != nil {
+					errs <- err
+					continue
+				}
+				if _, err := server.Matches(); err != nil {
+					errs <- err
+				}
+				if _, err := server.TuplesForMatch(sharedPi); err != nil && err != ErrNoMatch {
+					errs <- err
+				}
+				// Delete every other tuple
+				if i%2 == 1 {
+					if err := server.Delete(id); err != nil {
+						errs <- err
+					}
+				}
+				if i%5 == 0 {
+					if _, err := pHatComputer.GetPHatValue([]byte("perp" + strconv.Itoa(i))); err != nil {
+						errs <- err
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	stored, err := server.TuplesForMatch(sharedPi)
+	assert.Nil(t, err)
+	assert.Len(t, stored, numWorkers*((numPerWorker+1)/2))
+	matches, err := server.Matches()
+	assert.Nil(t, err)
+	assert.Len(t, matches, 1)
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"github.com/google/uuid"
+	"github.com/ymarcus93/gallisto/types"
+)
+
+// TupleID identifies a tuple held by a TupleStore. IDs are assigned by the
+// store when a tuple is added.
+type TupleID string
+
+// StoredTuple is a tuple along with the ID its store assigned to it
+type StoredTuple struct {
+	ID    TupleID
+	Tuple types.CallistoTuple
+}
+
+// TupleStore persists the tuples submitted to a CallistoServer. A CallistoServer
+// never calls Add or Remove concurrently with any other method, so
+// implementations only need to allow concurrent calls to All.
+type TupleStore interface {
+	// Add stores a tuple and returns the ID assigned to it
+	Add(tuple types.CallistoTuple) (TupleID, error)
+	// Remove deletes a stored tuple. It returns false if no tuple has the ID.
+	Remove(id TupleID) (bool, error)
+	// All returns every stored tuple in insertion order
+	All() ([]StoredTuple, error)
+}
+
+// SyncTupleStore is a TupleStore that buffers writes until it is synced. A
+// CallistoServer with a transparency log syncs it before logging a tuple, so
+// that a crash cannot lose a tuple it has issued a receipt for.
+// DiskTupleStore implements it.
+type SyncTupleStore interface {
+	TupleStore
+	// Sync flushes the store to stable storage
+	Sync() error
+}
+
+// MemoryTupleStore is a TupleStore that keeps tuples in memory
+type MemoryTupleStore struct {
+	tuples []StoredTuple
+	index  map[TupleID]int
+}
+
+// NewMemoryTupleStore returns an empty in-memory TupleStore
+func NewMemoryTupleStore() *MemoryTupleStore {
+	return &MemoryTupleStore{index: make(map[TupleID]int)}
+}
+
+// Add stores a tuple under a random ID
+func (s *MemoryTupleStore) Add(tuple types.CallistoTuple) (TupleID, error) {
+	id := TupleID(uuid.New().String())
+	s.index[id] = len(s.tuples)
+	s.tuples = append(s.tuples, StoredTuple{ID: id, Tuple: tuple})
+	return id, nil
+}
+
+// Remove deletes the tuple with the given ID, preserving the order of the
+// remaining tuples
+func (s *MemoryTupleStore) Remove(id TupleID) (bool, error) {
+	i, ok := s.index[id]
+	if !ok {
+		return false, nil
+	}
+	s.tuples = append(s.tuples[:i], s.tuples[i+1:]...)
+	delete(s.index, id)
+	for j := i; j < len(s.tuples); j++ {
+		s.index[s.tuples[j].ID] = j
+	}
+	return true, nil
+}
+
+// All returns a copy of the stored tuples
+func (s *MemoryTupleStore) All() ([]StoredTuple, error) {
+	tuples := make([]StoredTuple, len(s.tuples))
+	copy(tuples, s.tuples)
+	return tuples, nil
+}
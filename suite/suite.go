@@ -0,0 +1,332 @@
+// Package suite names the algorithms a tuple is created with. Every tuple and
+// LOC payload carries the ID of its protocol suite, so that decoders can pick
+// the algorithms it was produced with and the protocol can evolve without
+// breaking stored tuples.
+package suite
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+
+	"github.com/ymarcus93/gallisto/crypto"
+	"github.com/ymarcus93/gallisto/oprf"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// ErrUnknownSuite is returned when a suite ID is not in the registry
+var ErrUnknownSuite = errors.New("unknown protocol suite")
+
+// ID identifies a protocol suite. IDs are never reused.
+type ID uint8
+
+const (
+	// Legacy is the suite of tuples created before suites were introduced.
+	// Payloads without a suite ID belong to it.
+	Legacy ID = 0
+	// V1 derives a, k and pi with versioned HKDF info labels
+	V1 ID = 1
+	// V2P256 evaluates p-hat with the RFC 9497 P256-SHA256 OPRF
+	V2P256 ID = 2
+	// V2P384 evaluates p-hat with the RFC 9497 P384-SHA384 OPRF
+	V2P384 ID = 3
+	// V2P521 evaluates p-hat with the RFC 9497 P521-SHA512 OPRF
+	V2P521 ID = 4
+	// V2Ristretto255 evaluates p-hat with the RFC 9497 ristretto255-SHA512
+	// OPRF
+	V2Ristretto255 ID = 5
+	// V3P256 is V2P256 with every ciphertext bound to its tuple (see
+	// Suite.AssociatedData)
+	V3P256 ID = 6
+	// V3P384 is V2P384 with every ciphertext bound to its tuple
+	V3P384 ID = 7
+	// V3P521 is V2P521 with every ciphertext bound to its tuple
+	V3P521 ID = 8
+	// V3Ristretto255 is V2Ristretto255 with every ciphertext bound to its
+	// tuple
+	V3Ristretto255 ID = 9
+
+	// Default is the suite new tuples are created with
+	Default = V3P256
+)
+
+// OPRFDraftP521 is the OPRF ciphersuite of the Legacy and V1 suites, from an
+// early draft of RFC 9497. It is no longer implemented: tuples of these suites
+// can still be decoded, matched and decrypted, but not created.
+const OPRFDraftP521 = "OPRF-P521-HKDF-SHA512-SSWU-RO"
+
+// DefaultOPRFCiphersuite is the OPRF ciphersuite of the Default suite
+const DefaultOPRFCiphersuite = oprf.P256SHA256
+
+// Suite is the set of algorithms a tuple is created with
+type Suite struct {
+	ID              ID
+	Name            string
+	OPRFCiphersuite string // Evaluates p-hat from the perpetrator ID
+	KDF             KDF    // Derives a, k and pi from p-hat
+	AEAD            AEAD   // Encrypts entry data, assignment data and their keys
+	LOCEncryption   LOCEncryption
+	// BindsTuple is set if the associated data of every ciphertext binds it
+	// to its component, the suite, the user ID and the tuple ID. Otherwise pi
+	// is the associated data of every ciphertext.
+	BindsTuple bool
+	// Creatable is set if new tuples can be created with the suite. Tuples of
+	// other suites can only be decoded, matched and decrypted.
+	Creatable bool
+}
+
+// Component names a ciphertext of a tuple
+type Component string
+
+const (
+	EntryData            Component = "e_entry"  // Entry data under k_e
+	AssignmentData       Component = "e_assign" // Assignment data under k_a
+	EntryDataKey         Component = "c_e"      // k_e under k
+	AssignmentDataKey    Component = "c_a"      // k_a under k
+	EntryDataKeyForOwner Component = "c_U"      // k_e under the user key
+)
+
+// Binding identifies the tuple a ciphertext belongs to
+type Binding struct {
+	UserID  []byte
+	TupleID []byte
+}
+
+// associatedDataLabel separates bound associated data from other messages
+var associatedDataLabel = []byte("gallisto/v3/associated-data")
+
+// KDF derives the a, k and pi values from p-hat with HKDF
+type KDF struct {
+	Hash func() hash.Hash
+	// Info labels of a, k and pi. If Labels is nil, the values are instead read
+	// in turn from a single HKDF output without info.
+	Labels *Labels
+}
+
+// Labels are the HKDF info labels of a, k and pi
+type Labels struct {
+	A  []byte
+	K  []byte
+	Pi []byte
+}
+
+// AEAD encrypts data under a symmetric key
+type AEAD struct {
+	Name    string
+	KeySize int
+	Encrypt func(random io.Reader, key, plaintext, associatedData []byte) (crypto.GCMCiphertext, error)
+	// NewOpener sets up decryption under key, for decrypting several
+	// ciphertexts
+	NewOpener func(key []byte) (Opener, error)
+}
+
+// Opener decrypts ciphertexts under one key
+type Opener interface {
+	Decrypt(ciphertext crypto.GCMCiphertext) ([]byte, error)
+}
+
+// Decrypt decrypts a single ciphertext under key
+func (a AEAD) Decrypt(key []byte, ciphertext crypto.GCMCiphertext) ([]byte, error) {
+	opener, err := a.NewOpener(key)
+	if err != nil {
+		return nil, err
+	}
+	return opener.Decrypt(ciphertext)
+}
+
+// LOCEncryption encrypts LOC payloads to a LOC's public key
+type LOCEncryption struct {
+	Name    string
+	Encrypt func(random io.Reader, plaintext []byte, publicKey *rsa.PublicKey) ([]byte, error)
+	Decrypt func(ciphertext []byte, privateKey *rsa.PrivateKey) ([]byte, error)
+}
+
+var aes256GCM = AEAD{
+	Name:    "AES-256-GCM",
+	KeySize: 32,
+	Encrypt: crypto.EncryptAESWithRand,
+	NewOpener: func(key []byte) (Opener, error) {
+		return crypto.NewAESGCM(key)
+	},
+}
+
+var rsaOAEPSHA256 = LOCEncryption{
+	Name:    "RSA-OAEP-SHA256",
+	Encrypt: crypto.EncryptRSAWithRand,
+	Decrypt: crypto.DecryptRSA,
+}
+
+// v2KDF derives a, k and pi in every V2 suite. The suites differ in their
+// OPRF only.
+var v2KDF = KDF{
+	Hash: sha256.New,
+	Labels: &Labels{
+		A:  []byte("gallisto/v2/a"),
+		K:  []byte("gallisto/v2/k"),
+		Pi: []byte("gallisto/v2/pi"),
+	},
+}
+
+// v3KDF derives a, k and pi in every V3 suite
+var v3KDF = KDF{
+	Hash: sha256.New,
+	Labels: &Labels{
+		A:  []byte("gallisto/v3/a"),
+		K:  []byte("gallisto/v3/k"),
+		Pi: []byte("gallisto/v3/pi"),
+	},
+}
+
+var registry = map[ID]*Suite{
+	Legacy: {
+		ID:              Legacy,
+		Name:            "GALLISTO-LEGACY",
+		OPRFCiphersuite: OPRFDraftP521,
+		KDF:             KDF{Hash: sha256.New},
+		AEAD:            aes256GCM,
+		LOCEncryption:   rsaOAEPSHA256,
+	},
+	V1: {
+		ID:              V1,
+		Name:            "GALLISTO-V1",
+		OPRFCiphersuite: OPRFDraftP521,
+		KDF: KDF{
+			Hash: sha256.New,
+			Labels: &Labels{
+				A:  []byte("gallisto/v1/a"),
+				K:  []byte("gallisto/v1/k"),
+				Pi: []byte("gallisto/v1/pi"),
+			},
+		},
+		AEAD:          aes256GCM,
+		LOCEncryption: rsaOAEPSHA256,
+	},
+	V2P256:         v2Suite(V2P256, "GALLISTO-V2-P256", oprf.P256SHA256),
+	V2P384:         v2Suite(V2P384, "GALLISTO-V2-P384", oprf.P384SHA384),
+	V2P521:         v2Suite(V2P521, "GALLISTO-V2-P521", oprf.P521SHA512),
+	V2Ristretto255: v2Suite(V2Ristretto255, "GALLISTO-V2-RISTRETTO255", oprf.Ristretto255SHA512),
+	V3P256:         v3Suite(V3P256, "GALLISTO-V3-P256", oprf.P256SHA256),
+	V3P384:         v3Suite(V3P384, "GALLISTO-V3-P384", oprf.P384SHA384),
+	V3P521:         v3Suite(V3P521, "GALLISTO-V3-P521", oprf.P521SHA512),
+	V3Ristretto255: v3Suite(V3Ristretto255, "GALLISTO-V3-RISTRETTO255", oprf.Ristretto255SHA512),
+}
+
+func v2Suite(id ID, name, oprfCiphersuite string) *Suite {
+	return &Suite{
+		ID:              id,
+		Name:            name,
+		OPRFCiphersuite: oprfCiphersuite,
+		KDF:             v2KDF,
+		AEAD:            aes256GCM,
+		LOCEncryption:   rsaOAEPSHA256,
+		Creatable:       true,
+	}
+}
+
+// v3Suite returns a suite binding its ciphertexts to their tuple. Its KDF
+// labels differ from V2, so that V2 and V3 tuples never match each other.
+func v3Suite(id ID, name, oprfCiphersuite string) *Suite {
+	return &Suite{
+		ID:              id,
+		Name:            name,
+		OPRFCiphersuite: oprfCiphersuite,
+		KDF:             v3KDF,
+		AEAD:            aes256GCM,
+		LOCEncryption:   rsaOAEPSHA256,
+		BindsTuple:      true,
+		Creatable:       true,
+	}
+}
+
+// Lookup returns the suite with the given ID. It returns ErrUnknownSuite if
+// there is none.
+func Lookup(id ID) (*Suite, error) {
+	s, ok := registry[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownSuite, uint8(id))
+	}
+	return s, nil
+}
+
+// IDs returns the IDs of every suite in the registry, in ascending order
+func IDs() []ID {
+	ids := make([]ID, 0, len(registry))
+	for id := range registry {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// Parse returns the ID of the suite with the given name
+func Parse(name string) (ID, error) {
+	for id, s := range registry {
+		if s.Name == name {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q", ErrUnknownSuite, name)
+}
+
+// String returns the name of the suite, or its number if it is unknown
+func (id ID) String() string {
+	if s, ok := registry[id]; ok {
+		return s.Name
+	}
+	return fmt.Sprintf("suite %v", uint8(id))
+}
+
+// AssociatedData returns the associated data a component of a tuple is
+// encrypted with. Suites that do not bind tuples use pi. Suites that do use a
+// label followed by the suite ID and the component, user ID and tuple ID of
+// binding, each as a 4-byte big-endian length and its bytes. A ciphertext
+// moved to another tuple or component then no longer matches the associated
+// data its new place expects.
+func (s *Suite) AssociatedData(component Component, pi []byte, binding Binding) []byte {
+	if !s.BindsTuple {
+		return pi
+	}
+	b := append([]byte(nil), associatedDataLabel...)
+	b = append(b, byte(s.ID))
+	for _, field := range [][]byte{[]byte(component), binding.UserID, binding.TupleID} {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(field)))
+		b = append(b, field...)
+	}
+	return b
+}
+
+// Derive derives the 32-byte a, k and pi values from p-hat
+func (k KDF) Derive(pHat []byte) (a, kValue, pi []byte, err error) {
+	values := make([][]byte, 3)
+	if k.Labels == nil {
+		stream := hkdf.New(k.Hash, pHat, nil, nil)
+		for i := range values {
+			if values[i], err = readKey(stream); err != nil {
+				return nil, nil, nil, fmt.Errorf("failed to derive value %v: %w", i, err)
+			}
+		}
+		return values[0], values[1], values[2], nil
+	}
+
+	prk := hkdf.Extract(k.Hash, pHat, nil)
+	for i, label := range [][]byte{k.Labels.A, k.Labels.K, k.Labels.Pi} {
+		if values[i], err = readKey(hkdf.Expand(k.Hash, prk, label)); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to derive %s: %w", label, err)
+		}
+	}
+	return values[0], values[1], values[2], nil
+}
+
+func readKey(r io.Reader) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(r, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
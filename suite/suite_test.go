@@ -0,0 +1,101 @@
+package suite
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+func TestLookup(t *testing.T) {
+	for _, id := range IDs() {
+		s, err := Lookup(id)
+		if assert.NoError(t, err) {
+			assert.Equal(t, id, s.ID)
+		}
+		parsed, err := Parse(id.String())
+		assert.NoError(t, err)
+		assert.Equal(t, id, parsed)
+		// LOC payloads name their suite, so they are decrypted before it is
+		// known
+		assert.Equal(t, rsaOAEPSHA256.Name, s.LOCEncryption.Name, id)
+		assert.Equal(t, s.OPRFCiphersuite != OPRFDraftP521, s.Creatable, id)
+	}
+	assert.Equal(t, []ID{Legacy, V1, V2P256, V2P384, V2P521, V2Ristretto255, V3P256, V3P384, V3P521, V3Ristretto255}, IDs())
+
+	s, err := Lookup(Default)
+	if assert.NoError(t, err) {
+		assert.Equal(t, DefaultOPRFCiphersuite, s.OPRFCiphersuite)
+	}
+	_, err = Parse("GALLISTO-V4")
+	assert.True(t, errors.Is(err, ErrUnknownSuite))
+
+	_, err = Lookup(255)
+	assert.True(t, errors.Is(err, ErrUnknownSuite))
+	assert.Equal(t, "suite 255", ID(255).String())
+	assert.Equal(t, "GALLISTO-V1", V1.String())
+}
+
+func TestKDF_Derive(t *testing.T) {
+	pHat := bytes.Repeat([]byte{0x42}, 64)
+
+	// The legacy suite reads a, k and pi in turn from HKDF without info
+	expected := make([]byte, 96)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, pHat, nil, nil), expected); err != nil {
+		t.Fatalf("failed to read HKDF: %v", err)
+	}
+	legacy, _ := Lookup(Legacy)
+	a, k, pi, err := legacy.KDF.Derive(pHat)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, bytes.Join([][]byte{a, k, pi}, nil))
+
+	// Versioned labels derive different, independent values
+	v1, _ := Lookup(V1)
+	a1, k1, pi1, err := v1.KDF.Derive(pHat)
+	assert.NoError(t, err)
+	for _, value := range [][]byte{a1, k1, pi1} {
+		assert.Len(t, value, 32)
+	}
+	assert.NotEqual(t, a, a1)
+	assert.NotEqual(t, k, k1)
+	assert.NotEqual(t, pi, pi1)
+	assert.NotEqual(t, a1, k1)
+	assert.NotEqual(t, k1, pi1)
+
+	again, _, _, err := v1.KDF.Derive(pHat)
+	assert.NoError(t, err)
+	assert.Equal(t, a1, again)
+}
+
+func TestSuite_AssociatedData(t *testing.T) {
+	pi := bytes.Repeat([]byte{0x01}, 32)
+	binding := Binding{UserID: bytes.Repeat([]byte{0x02}, 16), TupleID: bytes.Repeat([]byte{0x03}, 16)}
+
+	// Suites before V3 use pi for every component
+	v2, _ := Lookup(V2P256)
+	assert.Equal(t, pi, v2.AssociatedData(EntryData, pi, binding))
+	assert.Equal(t, pi, v2.AssociatedData(AssignmentDataKey, pi, Binding{}))
+
+	v3, _ := Lookup(V3P256)
+	ad := v3.AssociatedData(EntryData, pi, binding)
+	assert.Equal(t, ad, v3.AssociatedData(EntryData, nil, binding), "pi is not bound")
+
+	others := map[string][]byte{
+		"component": v3.AssociatedData(EntryDataKey, pi, binding),
+		"user ID":   v3.AssociatedData(EntryData, pi, Binding{UserID: bytes.Repeat([]byte{0x04}, 16), TupleID: binding.TupleID}),
+		"tuple ID":  v3.AssociatedData(EntryData, pi, Binding{UserID: binding.UserID, TupleID: bytes.Repeat([]byte{0x04}, 16)}),
+		// Fields are length-prefixed, so moving bytes between them changes
+		// the result
+		"shifted": v3.AssociatedData(EntryData, pi, Binding{UserID: binding.UserID[:15], TupleID: append([]byte{0x02}, binding.TupleID...)}),
+	}
+	for name, other := range others {
+		assert.NotEqual(t, ad, other, name)
+	}
+	v3P384, _ := Lookup(V3P384)
+	assert.NotEqual(t, ad, v3P384.AssociatedData(EntryData, pi, binding), "suite")
+}
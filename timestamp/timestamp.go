@@ -0,0 +1,200 @@
+// Package timestamp implements a timestamping service in the style of RFC
+// 3161, so that a victim can show that an entry existed at a given time.
+//
+// A client sends an Authority the SHA-256 imprint of the data to timestamp,
+// usually the hash of a tuple, and a random nonce. The authority returns a
+// Token binding the imprint to the time it received it, signed with its
+// Ed25519 key. Anyone with the authority's public key can check the token
+// later; the data itself never reaches the authority.
+package timestamp
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ymarcus93/gallisto/internal/detrand"
+)
+
+// ErrInvalidToken is returned when a timestamp token is not signed by the
+// authority or is not for the expected imprint or request
+var ErrInvalidToken = errors.New("invalid timestamp token")
+
+// NonceSize is the size of the nonces NewRequest generates
+const NonceSize = 16
+
+// maxNonceSize bounds the nonces an authority accepts
+const maxNonceSize = 64
+
+// Hash is a SHA-256 imprint of timestamped data, encoded as hex in JSON
+type Hash [sha256.Size]byte
+
+// MarshalText encodes the hash as hex
+func (h Hash) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(h[:])), nil
+}
+
+// UnmarshalText decodes a hex-encoded hash
+func (h *Hash) UnmarshalText(text []byte) error {
+	decoded, err := hex.DecodeString(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid hex: %w", err)
+	}
+	if len(decoded) != len(h) {
+		return fmt.Errorf("hash must be %v bytes, got %v", len(h), len(decoded))
+	}
+	copy(h[:], decoded)
+	return nil
+}
+
+// Request asks an authority to timestamp an imprint. The nonce lets the
+// requester check that the token was issued for its request, not replayed.
+type Request struct {
+	Imprint Hash
+	Nonce   []byte
+}
+
+// NewRequest returns a request to timestamp imprint with a fresh nonce from
+// crypto/rand
+func NewRequest(imprint Hash) (Request, error) {
+	return NewRequestWithRand(rand.Reader, imprint)
+}
+
+// NewRequestWithRand is like NewRequest but reads the nonce from random.
+// Outside of tests, random must be crypto/rand's reader.
+func NewRequestWithRand(random io.Reader, imprint Hash) (Request, error) {
+	if err := detrand.Check(random); err != nil {
+		return Request{}, fmt.Errorf("invalid source of randomness: %w", err)
+	}
+	nonce := make([]byte, NonceSize)
+	if _, err := io.ReadFull(random, nonce); err != nil {
+		return Request{}, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return Request{Imprint: imprint, Nonce: nonce}, nil
+}
+
+// Token is an authority's signed statement that it saw an imprint at a time
+type Token struct {
+	Imprint   Hash      `json:"imprint"`
+	Serial    uint64    `json:"serial"` // Unique among the tokens of an authority
+	Time      time.Time `json:"time"`
+	Nonce     []byte    `json:"nonce"`
+	Signature []byte    `json:"signature"`
+}
+
+// tokenLabel separates timestamp tokens from other signed messages
+var tokenLabel = []byte("gallisto/timestamp/v1/token")
+
+// SignedContent returns the encoding of the token that the authority signs: a
+// label, the imprint, the serial number and the time in Unix nanoseconds as
+// 8-byte big-endian integers, and the nonce as a 4-byte big-endian length and
+// its bytes
+func (t Token) SignedContent() []byte {
+	b := append([]byte(nil), tokenLabel...)
+	b = append(b, t.Imprint[:]...)
+	b = binary.BigEndian.AppendUint64(b, t.Serial)
+	b = binary.BigEndian.AppendUint64(b, uint64(t.Time.UnixNano()))
+	b = binary.BigEndian.AppendUint32(b, uint32(len(t.Nonce)))
+	b = append(b, t.Nonce...)
+	return b
+}
+
+// Authority issues timestamp tokens. It is safe for concurrent use.
+type Authority struct {
+	key ed25519.PrivateKey
+	now func() time.Time
+
+	mu     sync.Mutex // guards serial
+	serial uint64
+}
+
+// AuthorityOption configures optional Authority behaviour
+type AuthorityOption func(*Authority)
+
+// WithClock makes the authority read the time it timestamps from now instead
+// of time.Now
+func WithClock(now func() time.Time) AuthorityOption {
+	return func(a *Authority) {
+		a.now = now
+	}
+}
+
+// NewAuthority returns an authority that signs tokens with key
+func NewAuthority(key ed25519.PrivateKey, opts ...AuthorityOption) (*Authority, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("signing key must be %v bytes, got %v", ed25519.PrivateKeySize, len(key))
+	}
+	a := &Authority{key: key, now: time.Now}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a, nil
+}
+
+// PublicKey returns the key the authority's tokens verify under
+func (a *Authority) PublicKey() ed25519.PublicKey {
+	return a.key.Public().(ed25519.PublicKey)
+}
+
+// Timestamp returns a token for the request's imprint at the current time
+func (a *Authority) Timestamp(req Request) (Token, error) {
+	if len(req.Nonce) > maxNonceSize {
+		return Token{}, fmt.Errorf("nonce must be at most %v bytes, got %v", maxNonceSize, len(req.Nonce))
+	}
+	a.mu.Lock()
+	a.serial++
+	serial := a.serial
+	a.mu.Unlock()
+
+	token := Token{
+		Imprint: req.Imprint,
+		Serial:  serial,
+		Time:    a.now().UTC(),
+		Nonce:   append([]byte(nil), req.Nonce...),
+	}
+	token.Signature = ed25519.Sign(a.key, token.SignedContent())
+	return token, nil
+}
+
+// Verifier checks the tokens of an authority
+type Verifier struct {
+	publicKey ed25519.PublicKey
+}
+
+// NewVerifier returns a Verifier of the authority whose tokens verify under
+// publicKey
+func NewVerifier(publicKey ed25519.PublicKey) (*Verifier, error) {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be %v bytes, got %v", ed25519.PublicKeySize, len(publicKey))
+	}
+	return &Verifier{publicKey: publicKey}, nil
+}
+
+// Verify checks that token is signed by the authority and timestamps
+// imprint. It returns an error wrapping ErrInvalidToken if it does not.
+func (v *Verifier) Verify(token Token, imprint Hash) error {
+	if token.Imprint != imprint {
+		return fmt.Errorf("%w: token is for another imprint", ErrInvalidToken)
+	}
+	if !ed25519.Verify(v.publicKey, token.SignedContent(), token.Signature) {
+		return fmt.Errorf("%w: signature does not verify", ErrInvalidToken)
+	}
+	return nil
+}
+
+// VerifyResponse checks that token is the authority's answer to req: it
+// verifies for the request's imprint and carries its nonce
+func (v *Verifier) VerifyResponse(req Request, token Token) error {
+	if !bytes.Equal(token.Nonce, req.Nonce) {
+		return fmt.Errorf("%w: token is for another request", ErrInvalidToken)
+	}
+	return v.Verify(token, req.Imprint)
+}
@@ -0,0 +1,107 @@
+package timestamp
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAuthority(t *testing.T, now time.Time) (*Authority, *Verifier) {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("failed to generate authority key: %v", err)
+	}
+	authority, err := NewAuthority(key, WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatalf("failed to create authority: %v", err)
+	}
+	verifier, err := NewVerifier(authority.PublicKey())
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+	return authority, verifier
+}
+
+func TestAuthority_Timestamp(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	authority, verifier := newTestAuthority(t, now)
+	imprint := Hash(sha256.Sum256([]byte("tuple")))
+
+	req, err := NewRequest(imprint)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	token, err := authority.Timestamp(req)
+	assert.Nil(t, err)
+	assert.Equal(t, now, token.Time)
+	assert.Equal(t, imprint, token.Imprint)
+	assert.Nil(t, verifier.VerifyResponse(req, token))
+	assert.Nil(t, verifier.Verify(token, imprint))
+
+	// Every token has its own serial number
+	another, err := authority.Timestamp(req)
+	assert.Nil(t, err)
+	assert.NotEqual(t, token.Serial, another.Serial)
+
+	_, err = authority.Timestamp(Request{Imprint: imprint, Nonce: make([]byte, maxNonceSize+1)})
+	assert.NotNil(t, err)
+}
+
+func TestVerifier_Invalid(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	authority, verifier := newTestAuthority(t, now)
+	otherAuthority, _ := newTestAuthority(t, now)
+	imprint := Hash(sha256.Sum256([]byte("tuple")))
+	req, err := NewRequest(imprint)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	token, err := authority.Timestamp(req)
+	if err != nil {
+		t.Fatalf("failed to timestamp: %v", err)
+	}
+	otherToken, err := otherAuthority.Timestamp(req)
+	if err != nil {
+		t.Fatalf("failed to timestamp: %v", err)
+	}
+	backdated := token
+	backdated.Time = token.Time.Add(-24 * time.Hour)
+	otherRequest, err := NewRequest(imprint)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+
+	tests := map[string]struct {
+		verify func() error
+	}{
+		"other imprint": {
+			verify: func() error { return verifier.Verify(token, Hash(sha256.Sum256([]byte("other tuple")))) },
+		},
+		"backdated": {
+			verify: func() error { return verifier.Verify(backdated, imprint) },
+		},
+		"other authority": {
+			verify: func() error { return verifier.Verify(otherToken, imprint) },
+		},
+		"replayed for another request": {
+			verify: func() error { return verifier.VerifyResponse(otherRequest, token) },
+		},
+	}
+
+	for testName, tc := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.True(t, errors.Is(tc.verify(), ErrInvalidToken))
+		})
+	}
+}
+
+func TestNewAuthority_InvalidKey(t *testing.T) {
+	_, err := NewAuthority(ed25519.PrivateKey{1, 2, 3})
+	assert.NotNil(t, err)
+	_, err = NewVerifier(ed25519.PublicKey{1, 2, 3})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,77 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+
+	"github.com/ymarcus93/gallisto/oprf"
+)
+
+// Client obtains tokens from an issuer
+type Client struct {
+	computer *oprf.PHatComputer
+	keyID    []byte
+	random   io.Reader // Source of nonces and blinds
+}
+
+// ClientOption configures optional Client behaviour
+type ClientOption func(*Client)
+
+// WithRand makes the client draw token nonces and OPRF blinds from random
+// instead of crypto/rand. Outside of tests, NewClient refuses any reader but
+// crypto/rand's.
+func WithRand(random io.Reader) ClientOption {
+	return func(c *Client) {
+		c.random = random
+	}
+}
+
+// NewClient returns a client that obtains tokens from issuer, such as an Issuer
+// or a remote issuance endpoint. Issuances that do not prove the use of the
+// secret key of publicKey are rejected, so the issuer cannot tag a client's
+// tokens with a key of its own.
+func NewClient(publicKey oprf.PublicKey, issuer oprf.ContextOPRFEvaluator, opts ...ClientOption) (*Client, error) {
+	keyID, err := KeyID(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{keyID: keyID, random: rand.Reader}
+	for _, opt := range opts {
+		opt(c)
+	}
+	config := oprf.Config{Ciphersuite: publicKey.Ciphersuite, Mode: oprf.VerifiableMode}
+	c.computer, err = oprf.NewPHatComputerContext(config, issuer, oprf.WithPublicKey(publicKey), oprf.WithRand(c.random))
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Issue obtains n tokens with a single issuance request. The issuer learns
+// how many tokens were issued to the caller of ctx, but not the tokens.
+func (c *Client) Issue(ctx context.Context, n int) ([]Token, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("cannot issue %v tokens", n)
+	}
+	nonces := make([][]byte, n)
+	inputs := make([][]byte, n)
+	for i := range nonces {
+		nonces[i] = make([]byte, NonceSize)
+		if _, err := io.ReadFull(c.random, nonces[i]); err != nil {
+			return nil, fmt.Errorf("failed to generate nonce: %w", err)
+		}
+		inputs[i] = tokenInput(nonces[i], c.keyID)
+	}
+
+	authenticators, err := c.computer.GetPHatValuesContext(ctx, inputs)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]Token, n)
+	for i := range tokens {
+		tokens[i] = Token{Nonce: nonces[i], KeyID: c.keyID, Authenticator: authenticators[i]}
+	}
+	return tokens, nil
+}
@@ -0,0 +1,133 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ymarcus93/gallisto/oprf"
+)
+
+// Issuer issues tokens to authenticated callers by evaluating their blinded
+// token inputs in the verifiable OPRF mode. It implements
+// oprf.ContextOPRFEvaluator; the caller is identified by the request context,
+// as set with oprf.WithCallerID.
+type Issuer struct {
+	server *oprf.OPRFServer
+	limit  int           // Tokens per caller and window; 0 is unlimited
+	window time.Duration // Length of a limit window
+	now    func() time.Time
+
+	mu     sync.Mutex
+	issued map[string]issuance // Tokens issued to each caller in its window
+}
+
+type issuance struct {
+	window int64 // Index of the window since the Unix epoch
+	count  int
+}
+
+// IssuerOption configures optional Issuer behaviour
+type IssuerOption func(*Issuer)
+
+// WithIssuanceLimit lets each caller obtain at most limit tokens per window.
+// Windows are consecutive periods of the given length. Without a limit callers
+// may obtain any number of tokens.
+func WithIssuanceLimit(limit int, window time.Duration) IssuerOption {
+	return func(i *Issuer) {
+		i.limit = limit
+		i.window = window
+	}
+}
+
+// WithClock makes the issuer read the current time from now instead of
+// time.Now
+func WithClock(now func() time.Time) IssuerOption {
+	return func(i *Issuer) {
+		i.now = now
+	}
+}
+
+// NewIssuer returns an issuer of tokens with key. Tokens can be redeemed with
+// a Redeemer holding the same key.
+func NewIssuer(key oprf.SecretKey, opts ...IssuerOption) (*Issuer, error) {
+	server, err := oprf.NewOPRFServer(oprf.Config{Ciphersuite: key.Ciphersuite, Mode: oprf.VerifiableMode}, key)
+	if err != nil {
+		return nil, err
+	}
+	i := &Issuer{
+		server: server,
+		now:    time.Now,
+		issued: make(map[string]issuance),
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	if i.limit < 0 || (i.limit > 0 && i.window <= 0) {
+		return nil, fmt.Errorf("invalid issuance limit of %v tokens per %v", i.limit, i.window)
+	}
+	return i, nil
+}
+
+// PublicKey returns the issuer's public key, which clients check issuance
+// against
+func (i *Issuer) PublicKey() oprf.PublicKey {
+	return i.server.PublicKey()
+}
+
+// EvaluateOPRFContext issues one token for every blinded token input of req.
+// It fails with ErrIssuanceLimit, without evaluating, if the caller would
+// exceed its limit.
+func (i *Issuer) EvaluateOPRFContext(ctx context.Context, req oprf.EvaluationRequest) (oprf.Evaluation, error) {
+	callerID, ok := oprf.CallerIDFromContext(ctx)
+	if !ok || callerID == "" {
+		return oprf.Evaluation{}, &oprf.EvaluationError{Err: fmt.Errorf("tokens are only issued to authenticated callers")}
+	}
+	if err := i.reserve(callerID, len(req.Elements)); err != nil {
+		return oprf.Evaluation{}, &oprf.EvaluationError{Err: err}
+	}
+	eval, err := i.server.EvaluateOPRFContext(ctx, req)
+	if err != nil {
+		i.release(callerID, len(req.Elements))
+		return oprf.Evaluation{}, err
+	}
+	return eval, nil
+}
+
+// reserve counts n tokens against the caller's limit
+func (i *Issuer) reserve(callerID string, n int) error {
+	if i.limit == 0 {
+		return nil
+	}
+	window := i.now().UnixNano() / int64(i.window)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	current := i.issued[callerID]
+	if current.window != window {
+		current = issuance{window: window}
+	}
+	if current.count+n > i.limit {
+		return fmt.Errorf("%w: caller %q has %v of %v tokens left", ErrIssuanceLimit, callerID, i.limit-current.count, i.limit)
+	}
+	current.count += n
+	i.issued[callerID] = current
+	return nil
+}
+
+// release returns n tokens reserved for a failed issuance
+func (i *Issuer) release(callerID string, n int) {
+	if i.limit == 0 {
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if current, ok := i.issued[callerID]; ok {
+		current.count -= n
+		if current.count < 0 {
+			current.count = 0
+		}
+		i.issued[callerID] = current
+	}
+}
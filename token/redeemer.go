@@ -0,0 +1,77 @@
+package token
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/ymarcus93/gallisto/oprf"
+)
+
+// Redeemer checks and spends tokens issued with an issuer key. It is an
+// oprf.RequestChecker that requires one token per evaluation request; pass it
+// to oprf.WithRequestChecker.
+type Redeemer struct {
+	server *oprf.OPRFServer
+	keyID  []byte
+	store  SpentStore
+}
+
+// NewRedeemer returns a redeemer of tokens issued with key. Spent tokens are
+// recorded in store, which must be shared by every redeemer of the key.
+func NewRedeemer(key oprf.SecretKey, store SpentStore) (*Redeemer, error) {
+	if store == nil {
+		return nil, fmt.Errorf("spent token store is not set")
+	}
+	server, err := oprf.NewOPRFServer(oprf.Config{Ciphersuite: key.Ciphersuite, Mode: oprf.VerifiableMode}, key)
+	if err != nil {
+		return nil, err
+	}
+	keyID, err := KeyID(server.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+	return &Redeemer{server: server, keyID: keyID, store: store}, nil
+}
+
+// Redeem checks that t was issued with the redeemer's key and records it as
+// spent. It returns an error wrapping ErrTokenRejected if t is invalid, or
+// ErrTokenSpent if t was already redeemed.
+func (r *Redeemer) Redeem(t Token) error {
+	if len(t.Nonce) != NonceSize {
+		return fmt.Errorf("%w: nonce must be %v bytes", ErrTokenRejected, NonceSize)
+	}
+	if !bytes.Equal(t.KeyID, r.keyID) {
+		return fmt.Errorf("%w: token was issued with another key", ErrTokenRejected)
+	}
+	if !r.server.VerifyOutput(tokenInput(t.Nonce, t.KeyID), nil, t.Authenticator) {
+		return fmt.Errorf("%w: invalid authenticator", ErrTokenRejected)
+	}
+
+	// Only record valid tokens, so that forged ones cannot fill the store
+	spent, err := r.store.Spend(t.Nonce)
+	if err != nil {
+		return fmt.Errorf("failed to record spent token: %w", err)
+	}
+	if !spent {
+		return ErrTokenSpent
+	}
+	return nil
+}
+
+// CheckRequest redeems the token attached to req. A token pays for the
+// evaluation of one blinded input.
+func (r *Redeemer) CheckRequest(ctx context.Context, req oprf.EvaluationRequest) error {
+	b, ok := req.Extensions[ExtensionName]
+	if !ok {
+		return fmt.Errorf("%w: request has no token", ErrTokenRejected)
+	}
+	if len(req.Elements) != 1 {
+		return fmt.Errorf("%w: a token pays for 1 blinded input, got %v", ErrTokenRejected, len(req.Elements))
+	}
+	t, err := ParseToken(b)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrTokenRejected, err)
+	}
+	return r.Redeem(t)
+}
@@ -0,0 +1,135 @@
+package token
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// SpentStore records the nonces of redeemed tokens to detect double spending
+type SpentStore interface {
+	// Spend records nonce as spent. It returns false if it already was.
+	Spend(nonce []byte) (bool, error)
+}
+
+// MemorySpentStore is a SpentStore that keeps nonces in memory. It is safe for
+// concurrent use.
+type MemorySpentStore struct {
+	mu    sync.Mutex
+	spent map[[NonceSize]byte]struct{}
+}
+
+// NewMemorySpentStore returns an empty MemorySpentStore
+func NewMemorySpentStore() *MemorySpentStore {
+	return &MemorySpentStore{spent: make(map[[NonceSize]byte]struct{})}
+}
+
+// Spend implements SpentStore
+func (s *MemorySpentStore) Spend(nonce []byte) (bool, error) {
+	key, err := nonceKey(nonce)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.spent[key]; ok {
+		return false, nil
+	}
+	s.spent[key] = struct{}{}
+	return true, nil
+}
+
+// FileSpentStore is a SpentStore that appends nonces to a local file, so that
+// tokens stay spent across restarts. Every nonce is also held in memory. It is
+// safe for concurrent use.
+//
+// Spend returns once a nonce is flushed to stable storage, so a token cannot
+// be spent again after a crash.
+type FileSpentStore struct {
+	mu    sync.Mutex
+	file  *os.File
+	size  int64 // End of the last complete nonce
+	spent map[[NonceSize]byte]struct{}
+}
+
+// OpenFileSpentStore opens the spent token store at path, creating it if
+// needed. A nonce left incomplete by a crash while it was being recorded is
+// discarded; its token was not accepted.
+func OpenFileSpentStore(path string) (*FileSpentStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spent token store: %w", err)
+	}
+	s := &FileSpentStore{file: file, spent: make(map[[NonceSize]byte]struct{})}
+
+	for {
+		var key [NonceSize]byte
+		if _, err := io.ReadFull(file, key[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			file.Close()
+			return nil, fmt.Errorf("failed to read spent token store: %w", err)
+		}
+		s.spent[key] = struct{}{}
+		s.size += NonceSize
+	}
+	if err := file.Truncate(s.size); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to repair spent token store: %w", err)
+	}
+	if _, err := file.Seek(s.size, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open spent token store: %w", err)
+	}
+	return s, nil
+}
+
+// Spend implements SpentStore
+func (s *FileSpentStore) Spend(nonce []byte) (bool, error) {
+	key, err := nonceKey(nonce)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.spent[key]; ok {
+		return false, nil
+	}
+	if _, err := s.file.Write(key[:]); err != nil {
+		// Drop a partial write so that later nonces stay aligned
+		s.file.Truncate(s.size)
+		s.file.Seek(s.size, io.SeekStart)
+		return false, err
+	}
+	// The nonce is written, so it counts as spent even if it cannot be
+	// flushed
+	s.size += NonceSize
+	s.spent[key] = struct{}{}
+	if err := s.file.Sync(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Len returns the number of spent tokens
+func (s *FileSpentStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.spent)
+}
+
+// Close closes the store's file
+func (s *FileSpentStore) Close() error {
+	return s.file.Close()
+}
+
+func nonceKey(nonce []byte) ([NonceSize]byte, error) {
+	var key [NonceSize]byte
+	if len(nonce) != NonceSize {
+		return key, fmt.Errorf("nonce must be %v bytes, got %v", NonceSize, len(nonce))
+	}
+	copy(key[:], nonce)
+	return key, nil
+}
@@ -0,0 +1,70 @@
+package token
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpentStores(t *testing.T) {
+	fileStore, err := OpenFileSpentStore(filepath.Join(t.TempDir(), "spent.log"))
+	if err != nil {
+		t.Fatalf("failed to open spent token store: %v", err)
+	}
+	defer fileStore.Close()
+
+	for name, store := range map[string]SpentStore{"memory": NewMemorySpentStore(), "file": fileStore} {
+		t.Run(name, func(t *testing.T) {
+			nonce := bytes.Repeat([]byte{1}, NonceSize)
+			spent, err := store.Spend(nonce)
+			assert.Nil(t, err)
+			assert.True(t, spent)
+			spent, err = store.Spend(nonce)
+			assert.Nil(t, err)
+			assert.False(t, spent)
+
+			_, err = store.Spend(nonce[1:])
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestFileSpentStore_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spent.log")
+	store, err := OpenFileSpentStore(path)
+	if err != nil {
+		t.Fatalf("failed to open spent token store: %v", err)
+	}
+	for i := byte(0); i < 3; i++ {
+		_, err := store.Spend(bytes.Repeat([]byte{i}, NonceSize))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, store.Close())
+
+	// Simulate a crash while a nonce was being recorded
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	assert.Nil(t, err)
+	_, err = file.Write([]byte{9, 9, 9})
+	assert.Nil(t, err)
+	assert.Nil(t, file.Close())
+
+	store, err = OpenFileSpentStore(path)
+	if err != nil {
+		t.Fatalf("failed to reopen spent token store: %v", err)
+	}
+	defer store.Close()
+	assert.Equal(t, 3, store.Len())
+	spent, err := store.Spend(bytes.Repeat([]byte{1}, NonceSize))
+	assert.Nil(t, err)
+	assert.False(t, spent)
+	spent, err = store.Spend(bytes.Repeat([]byte{9}, NonceSize))
+	assert.Nil(t, err)
+	assert.True(t, spent)
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4*NonceSize), info.Size())
+}
@@ -0,0 +1,105 @@
+// Package token implements anonymous rate-limit tokens for OPRF evaluations,
+// after the privately verifiable tokens of Privacy Pass (RFC 9578).
+//
+// An Issuer limits how many tokens each authenticated caller obtains. A
+// Client obtains a batch of tokens by having the issuer evaluate blinded token
+// inputs in the verifiable OPRF mode, so the issuer never sees the tokens it
+// signs. Each evaluation request to the OPRF server then carries one token,
+// which a Redeemer checks against the issuer key and records as spent. Tokens
+// cannot be linked to the issuance they came from, so the OPRF server learns
+// that a request is paid for but not who sent it.
+package token
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/ymarcus93/gallisto/oprf"
+)
+
+const (
+	// Ciphersuite is the recommended OPRF ciphersuite of issuer keys, as in
+	// RFC 9578
+	Ciphersuite = oprf.P384SHA384
+
+	// ExtensionName is the oprf.EvaluationRequest extension a token is sent in
+	ExtensionName = "gallisto-token"
+
+	// NonceSize is the size of a token's nonce
+	NonceSize = 32
+	// KeyIDSize is the size of an issuer key ID
+	KeyIDSize = sha256.Size
+)
+
+var (
+	// ErrTokenRejected is returned when an evaluation request carries no
+	// token, or a token that was not issued with the redeemer's key
+	ErrTokenRejected = errors.New("rate-limit token rejected")
+	// ErrTokenSpent is returned when a token has already been redeemed
+	ErrTokenSpent = errors.New("rate-limit token already spent")
+	// ErrIssuanceLimit is returned when a caller asks for more tokens than an
+	// Issuer allows
+	ErrIssuanceLimit = errors.New("token issuance limit exceeded")
+	// ErrNoTokens is returned by a Wallet that has no tokens left
+	ErrNoTokens = errors.New("no rate-limit tokens left")
+)
+
+// tokenInputPrefix separates token inputs from other OPRF inputs
+var tokenInputPrefix = []byte("gallisto/token/v1")
+
+// Token is a rate-limit token. The authenticator is the issuer's OPRF output of
+// the nonce and key ID, which only the issuer key can compute.
+type Token struct {
+	Nonce         []byte
+	KeyID         []byte
+	Authenticator []byte
+}
+
+// KeyID returns the ID of an issuer key: the SHA-256 digest of the serialized
+// public key
+func KeyID(publicKey oprf.PublicKey) ([]byte, error) {
+	b, err := publicKey.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	id := sha256.Sum256(b)
+	return id[:], nil
+}
+
+// MarshalBinary returns the nonce, key ID and authenticator of t concatenated
+func (t Token) MarshalBinary() ([]byte, error) {
+	if len(t.Nonce) != NonceSize {
+		return nil, fmt.Errorf("nonce must be %v bytes, got %v", NonceSize, len(t.Nonce))
+	}
+	if len(t.KeyID) != KeyIDSize {
+		return nil, fmt.Errorf("key ID must be %v bytes, got %v", KeyIDSize, len(t.KeyID))
+	}
+	if len(t.Authenticator) == 0 {
+		return nil, fmt.Errorf("authenticator is empty")
+	}
+	b := make([]byte, 0, NonceSize+KeyIDSize+len(t.Authenticator))
+	b = append(b, t.Nonce...)
+	b = append(b, t.KeyID...)
+	return append(b, t.Authenticator...), nil
+}
+
+// ParseToken decodes a token serialized by Token.MarshalBinary
+func ParseToken(b []byte) (Token, error) {
+	if len(b) <= NonceSize+KeyIDSize {
+		return Token{}, fmt.Errorf("token of %v bytes is too short", len(b))
+	}
+	return Token{
+		Nonce:         append([]byte(nil), b[:NonceSize]...),
+		KeyID:         append([]byte(nil), b[NonceSize:NonceSize+KeyIDSize]...),
+		Authenticator: append([]byte(nil), b[NonceSize+KeyIDSize:]...),
+	}, nil
+}
+
+// tokenInput returns the OPRF input a token's authenticator is computed from
+func tokenInput(nonce, keyID []byte) []byte {
+	input := make([]byte, 0, len(tokenInputPrefix)+len(nonce)+len(keyID))
+	input = append(input, tokenInputPrefix...)
+	input = append(input, nonce...)
+	return append(input, keyID...)
+}
@@ -0,0 +1,158 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ymarcus93/gallisto/oprf"
+)
+
+func generateKey(t *testing.T) oprf.SecretKey {
+	key, err := oprf.GenerateKey(Ciphersuite)
+	if err != nil {
+		t.Fatalf("failed to generate issuer key: %v", err)
+	}
+	return key
+}
+
+func issue(t *testing.T, issuer *Issuer, callerID string, n int) ([]Token, error) {
+	client, err := NewClient(issuer.PublicKey(), issuer)
+	if err != nil {
+		t.Fatalf("failed to create token client: %v", err)
+	}
+	return client.Issue(oprf.WithCallerID(context.Background(), callerID), n)
+}
+
+func TestToken_MarshalBinary(t *testing.T) {
+	key := generateKey(t)
+	issuer, err := NewIssuer(key)
+	assert.Nil(t, err)
+	tokens, err := issue(t, issuer, "caller", 1)
+	assert.Nil(t, err)
+
+	b, err := tokens[0].MarshalBinary()
+	assert.Nil(t, err)
+	parsed, err := ParseToken(b)
+	assert.Nil(t, err)
+	assert.Equal(t, tokens[0], parsed)
+
+	_, err = ParseToken(b[:NonceSize+KeyIDSize])
+	assert.NotNil(t, err)
+	_, err = Token{Nonce: []byte("short"), KeyID: tokens[0].KeyID, Authenticator: []byte{1}}.MarshalBinary()
+	assert.NotNil(t, err)
+}
+
+func TestRedeemer_Redeem(t *testing.T) {
+	key := generateKey(t)
+	issuer, err := NewIssuer(key)
+	assert.Nil(t, err)
+	redeemer, err := NewRedeemer(key, NewMemorySpentStore())
+	assert.Nil(t, err)
+
+	tokens, err := issue(t, issuer, "caller", 3)
+	assert.Nil(t, err)
+	assert.Len(t, tokens, 3)
+	for _, token := range tokens {
+		assert.Nil(t, redeemer.Redeem(token))
+	}
+	assert.True(t, errors.Is(redeemer.Redeem(tokens[0]), ErrTokenSpent))
+
+	otherIssuer, err := NewIssuer(generateKey(t))
+	assert.Nil(t, err)
+	otherTokens, err := issue(t, otherIssuer, "caller", 1)
+	assert.Nil(t, err)
+
+	forged := otherTokens[0]
+	forged.KeyID = tokens[0].KeyID
+	tests := map[string]Token{
+		"other key":        otherTokens[0],
+		"forged":           forged,
+		"changed nonce":    {Nonce: make([]byte, NonceSize), KeyID: tokens[1].KeyID, Authenticator: tokens[1].Authenticator},
+		"no authenticator": {Nonce: tokens[2].Nonce, KeyID: tokens[2].KeyID},
+	}
+	for testName, token := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.True(t, errors.Is(redeemer.Redeem(token), ErrTokenRejected))
+		})
+	}
+}
+
+func TestIssuer_Limit(t *testing.T) {
+	now := time.Unix(0, 0)
+	issuer, err := NewIssuer(generateKey(t), WithIssuanceLimit(3, time.Hour), WithClock(func() time.Time { return now }))
+	assert.Nil(t, err)
+
+	_, err = issue(t, issuer, "caller", 2)
+	assert.Nil(t, err)
+	_, err = issue(t, issuer, "caller", 2)
+	assert.True(t, errors.Is(err, ErrIssuanceLimit))
+	assert.True(t, errors.Is(err, oprf.ErrOPRFEvaluation))
+	_, err = issue(t, issuer, "caller", 1)
+	assert.Nil(t, err)
+
+	// Callers have separate limits, which start over in every window
+	_, err = issue(t, issuer, "other caller", 3)
+	assert.Nil(t, err)
+	now = now.Add(time.Hour)
+	_, err = issue(t, issuer, "caller", 3)
+	assert.Nil(t, err)
+
+	// Tokens are only issued to authenticated callers
+	client, err := NewClient(issuer.PublicKey(), issuer)
+	assert.Nil(t, err)
+	_, err = client.Issue(context.Background(), 1)
+	assert.NotNil(t, err)
+
+	_, err = NewIssuer(generateKey(t), WithIssuanceLimit(3, 0))
+	assert.NotNil(t, err)
+}
+
+func TestRateLimitedEvaluation(t *testing.T) {
+	tokenKey := generateKey(t)
+	issuer, err := NewIssuer(tokenKey)
+	assert.Nil(t, err)
+	redeemer, err := NewRedeemer(tokenKey, NewMemorySpentStore())
+	assert.Nil(t, err)
+
+	config := oprf.Config{Ciphersuite: oprf.P256SHA256}
+	oprfKey, err := oprf.GenerateKey(config.Ciphersuite)
+	assert.Nil(t, err)
+	server, err := oprf.NewOPRFServer(config, oprfKey, oprf.WithRequestChecker(redeemer))
+	assert.Nil(t, err)
+
+	tokens, err := issue(t, issuer, "caller", 2)
+	assert.Nil(t, err)
+	wallet := NewWallet(tokens...)
+	computer, err := oprf.NewPHatComputer(config, server, oprf.WithRequestExtender(wallet))
+	assert.Nil(t, err)
+
+	// Every evaluation spends one token
+	for i := 0; i < 2; i++ {
+		_, err = computer.GetPHatValue([]byte("perp"))
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 0, wallet.Len())
+	_, err = computer.GetPHatValue([]byte("perp"))
+	assert.True(t, errors.Is(err, ErrNoTokens))
+
+	// Spent tokens are rejected
+	wallet.Add(tokens[0])
+	_, err = computer.GetPHatValue([]byte("perp"))
+	assert.True(t, errors.Is(err, ErrTokenSpent))
+
+	// Requests without a token, or with several inputs, are rejected
+	unpaid, err := oprf.NewPHatComputer(config, server)
+	assert.Nil(t, err)
+	_, err = unpaid.GetPHatValue([]byte("perp"))
+	assert.True(t, errors.Is(err, ErrTokenRejected))
+
+	more, err := issue(t, issuer, "caller", 1)
+	assert.Nil(t, err)
+	wallet.Add(more...)
+	_, err = computer.GetPHatValues([][]byte{[]byte("a"), []byte("b")})
+	assert.True(t, errors.Is(err, ErrTokenRejected))
+}
@@ -0,0 +1,61 @@
+package token
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ymarcus93/gallisto/oprf"
+)
+
+// Wallet holds a client's unspent tokens. It is an oprf.RequestExtender that
+// attaches one token to every evaluation request; pass it to
+// oprf.WithRequestExtender. It is safe for concurrent use.
+type Wallet struct {
+	mu     sync.Mutex
+	tokens []Token
+}
+
+// NewWallet returns a wallet holding tokens
+func NewWallet(tokens ...Token) *Wallet {
+	return &Wallet{tokens: append([]Token(nil), tokens...)}
+}
+
+// Add adds tokens to the wallet
+func (w *Wallet) Add(tokens ...Token) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.tokens = append(w.tokens, tokens...)
+}
+
+// Len returns the number of tokens in the wallet
+func (w *Wallet) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.tokens)
+}
+
+// ExtendRequest removes a token from the wallet and attaches it to req. It
+// returns ErrNoTokens if the wallet is empty. The token is not returned to the
+// wallet if the evaluation then fails, as the evaluator may have spent it.
+func (w *Wallet) ExtendRequest(ctx context.Context, req *oprf.EvaluationRequest) error {
+	w.mu.Lock()
+	if len(w.tokens) == 0 {
+		w.mu.Unlock()
+		return ErrNoTokens
+	}
+	t := w.tokens[0]
+	w.tokens = w.tokens[1:]
+	w.mu.Unlock()
+
+	b, err := t.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	extensions := make(map[string][]byte, len(req.Extensions)+1)
+	for name, value := range req.Extensions {
+		extensions[name] = value
+	}
+	extensions[ExtensionName] = b
+	req.Extensions = extensions
+	return nil
+}
@@ -0,0 +1,251 @@
+// Package transparency gives submitters evidence that the server accepted
+// their tuples and never removed them from its history.
+//
+// A Log is an append-only Merkle tree (RFC 9162) of tuple hashes. Appending a
+// tuple returns a Receipt: the tuple's position, a tree head signed by the
+// log, and a proof that the tree includes the tuple. Later, a submitter asks
+// for the current tree head, a consistency proof that it extends the tree head
+// of the receipt, and an inclusion proof of the tuple in it. A log that
+// dropped or rewrote the tuple cannot produce both proofs, and the signed tree
+// heads it gave out are evidence of what it committed to.
+package transparency
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidProof is returned when an inclusion or consistency proof does
+	// not verify
+	ErrInvalidProof = errors.New("invalid transparency proof")
+	// ErrInvalidTreeHead is returned when a tree head is not signed by the log
+	ErrInvalidTreeHead = errors.New("invalid tree head signature")
+)
+
+// TreeHead is the size and root hash of a log's tree at some time, signed by
+// the log
+type TreeHead struct {
+	Size      uint64    `json:"size"`
+	Root      Hash      `json:"root"`
+	Timestamp time.Time `json:"timestamp"`
+	Signature []byte    `json:"signature"`
+}
+
+// treeHeadLabel separates signed tree heads from other signed messages
+var treeHeadLabel = []byte("gallisto/transparency/v1/tree-head")
+
+// SignedContent returns the encoding of the tree head that the log signs: a
+// label, the size, the root and the timestamp in Unix nanoseconds, with
+// numbers as 8-byte big-endian integers
+func (h TreeHead) SignedContent() []byte {
+	b := append([]byte(nil), treeHeadLabel...)
+	b = binary.BigEndian.AppendUint64(b, h.Size)
+	b = append(b, h.Root[:]...)
+	b = binary.BigEndian.AppendUint64(b, uint64(h.Timestamp.UnixNano()))
+	return b
+}
+
+// Receipt is the log's evidence that it appended a leaf
+type Receipt struct {
+	LeafIndex      uint64   `json:"leafIndex"`
+	LeafHash       Hash     `json:"leafHash"`
+	TreeHead       TreeHead `json:"treeHead"` // First tree head including the leaf
+	InclusionProof []Hash   `json:"inclusionProof"`
+}
+
+// Log is an append-only Merkle tree whose tree heads are signed with an
+// Ed25519 key. It keeps every leaf hash and the hash of every complete subtree
+// in memory, so an append hashes O(log n) nodes, and is safe for concurrent
+// use. Give it a LeafStore with WithLeafStore to keep its leaves across
+// restarts.
+type Log struct {
+	key   ed25519.PrivateKey
+	now   func() time.Time
+	store LeafStore
+
+	mu   sync.RWMutex // guards tree and store
+	tree *tree
+}
+
+// LeafStore keeps the leaf hashes of a log. FileLeafStore implements it.
+type LeafStore interface {
+	// Append stores the hash of the log's next leaf
+	Append(leaf Hash) error
+	// Leaves returns the stored leaf hashes, in the order they were appended
+	Leaves() ([]Hash, error)
+}
+
+// LogOption configures optional Log behaviour
+type LogOption func(*Log)
+
+// WithClock makes the log read the time of tree heads from now instead of
+// time.Now
+func WithClock(now func() time.Time) LogOption {
+	return func(l *Log) {
+		l.now = now
+	}
+}
+
+// WithLeafStore makes the log store every leaf it appends in store, and start
+// from the leaves already stored there. Keep the store with the tuples the
+// log is of, so that a restarted server still proves the receipts it issued.
+func WithLeafStore(store LeafStore) LogOption {
+	return func(l *Log) {
+		l.store = store
+	}
+}
+
+// NewLog returns a log that signs its tree heads with key. It is empty unless
+// its LeafStore holds leaves.
+func NewLog(key ed25519.PrivateKey, opts ...LogOption) (*Log, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("signing key must be %v bytes, got %v", ed25519.PrivateKeySize, len(key))
+	}
+	l := &Log{key: key, now: time.Now}
+	for _, opt := range opts {
+		opt(l)
+	}
+	var leaves []Hash
+	if l.store != nil {
+		var err error
+		if leaves, err = l.store.Leaves(); err != nil {
+			return nil, fmt.Errorf("failed to load leaves: %w", err)
+		}
+	}
+	l.tree = newTree(leaves)
+	return l, nil
+}
+
+// PublicKey returns the key the log's tree heads verify under, to be given to
+// submitters
+func (l *Log) PublicKey() ed25519.PublicKey {
+	return l.key.Public().(ed25519.PublicKey)
+}
+
+// Append adds a leaf holding data and returns the receipt for it. It fails if
+// the leaf cannot be stored.
+func (l *Log) Append(data []byte) (Receipt, error) {
+	leafHash := HashLeaf(data)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.store != nil {
+		if err := l.store.Append(leafHash); err != nil {
+			return Receipt{}, fmt.Errorf("failed to store leaf: %w", err)
+		}
+	}
+	index := l.tree.size()
+	l.tree.append(leafHash)
+	return Receipt{
+		LeafIndex:      index,
+		LeafHash:       leafHash,
+		TreeHead:       l.treeHead(),
+		InclusionProof: l.tree.inclusionPath(index, index+1),
+	}, nil
+}
+
+// TreeHead returns a signed head of the log's current tree
+func (l *Log) TreeHead() TreeHead {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.treeHead()
+}
+
+func (l *Log) treeHead() TreeHead {
+	size := l.tree.size()
+	head := TreeHead{
+		Size:      size,
+		Root:      l.tree.root(size),
+		Timestamp: l.now().UTC(),
+	}
+	head.Signature = ed25519.Sign(l.key, head.SignedContent())
+	return head
+}
+
+// InclusionProof returns the proof that the leaf at index is in the tree of
+// the first size leaves
+func (l *Log) InclusionProof(index, size uint64) ([]Hash, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if size > l.tree.size() {
+		return nil, fmt.Errorf("log has %v leaves, not %v", l.tree.size(), size)
+	}
+	if index >= size {
+		return nil, fmt.Errorf("leaf %v is outside a tree of %v leaves", index, size)
+	}
+	return l.tree.inclusionPath(index, size), nil
+}
+
+// ConsistencyProof returns the proof that the tree of the first size1 leaves
+// is a prefix of the tree of the first size2 leaves
+func (l *Log) ConsistencyProof(size1, size2 uint64) ([]Hash, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if size2 > l.tree.size() {
+		return nil, fmt.Errorf("log has %v leaves, not %v", l.tree.size(), size2)
+	}
+	if size1 > size2 {
+		return nil, fmt.Errorf("a tree of %v leaves cannot extend one of %v", size2, size1)
+	}
+	if size1 == 0 {
+		return nil, nil
+	}
+	return l.tree.consistencyPath(size1, size2), nil
+}
+
+// Verifier checks tree heads, receipts and proofs of a log
+type Verifier struct {
+	publicKey ed25519.PublicKey
+}
+
+// NewVerifier returns a Verifier of the log whose tree heads verify under
+// publicKey
+func NewVerifier(publicKey ed25519.PublicKey) (*Verifier, error) {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be %v bytes, got %v", ed25519.PublicKeySize, len(publicKey))
+	}
+	return &Verifier{publicKey: publicKey}, nil
+}
+
+// VerifyTreeHead checks that head is signed by the log. It returns an error
+// wrapping ErrInvalidTreeHead if it is not.
+func (v *Verifier) VerifyTreeHead(head TreeHead) error {
+	if !ed25519.Verify(v.publicKey, head.SignedContent(), head.Signature) {
+		return fmt.Errorf("%w: tree head of size %v", ErrInvalidTreeHead, head.Size)
+	}
+	return nil
+}
+
+// VerifyReceipt checks that receipt is the log's receipt for a leaf holding
+// data: its tree head is signed by the log and includes the leaf
+func (v *Verifier) VerifyReceipt(receipt Receipt, data []byte) error {
+	if HashLeaf(data) != receipt.LeafHash {
+		return fmt.Errorf("%w: receipt is for another leaf", ErrInvalidProof)
+	}
+	return v.VerifyInclusion(receipt.LeafHash, receipt.LeafIndex, receipt.TreeHead, receipt.InclusionProof)
+}
+
+// VerifyInclusion checks that head is signed by the log and proof proves that
+// it includes the leaf with hash leafHash at index
+func (v *Verifier) VerifyInclusion(leafHash Hash, index uint64, head TreeHead, proof []Hash) error {
+	if err := v.VerifyTreeHead(head); err != nil {
+		return err
+	}
+	return VerifyInclusion(leafHash, index, head.Size, proof, head.Root)
+}
+
+// VerifyConsistency checks that both tree heads are signed by the log and
+// proof proves that the tree of newHead extends the tree of oldHead
+func (v *Verifier) VerifyConsistency(oldHead, newHead TreeHead, proof []Hash) error {
+	if err := v.VerifyTreeHead(oldHead); err != nil {
+		return err
+	}
+	if err := v.VerifyTreeHead(newHead); err != nil {
+		return err
+	}
+	return VerifyConsistency(oldHead.Size, newHead.Size, oldHead.Root, newHead.Root, proof)
+}
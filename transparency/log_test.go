@@ -0,0 +1,194 @@
+package transparency
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLog(t *testing.T) (*Log, *Verifier) {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("failed to generate log key: %v", err)
+	}
+	log, err := NewLog(key, WithClock(func() time.Time {
+		return time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	}))
+	if err != nil {
+		t.Fatalf("failed to create log: %v", err)
+	}
+	verifier, err := NewVerifier(log.PublicKey())
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+	return log, verifier
+}
+
+func appendLeaf(t *testing.T, log *Log, data []byte) Receipt {
+	receipt, err := log.Append(data)
+	if err != nil {
+		t.Fatalf("failed to append leaf: %v", err)
+	}
+	return receipt
+}
+
+func TestLog_Receipts(t *testing.T) {
+	log, verifier := newTestLog(t)
+
+	var receipts []Receipt
+	for i := 0; i < 11; i++ {
+		data := []byte(fmt.Sprintf("tuple %v", i))
+		receipt := appendLeaf(t, log, data)
+		assert.Equal(t, uint64(i), receipt.LeafIndex)
+		assert.Equal(t, uint64(i+1), receipt.TreeHead.Size)
+		assert.Nil(t, verifier.VerifyReceipt(receipt, data))
+		receipts = append(receipts, receipt)
+	}
+
+	// Every receipt is still included in the latest tree, which extends the
+	// tree of the receipt
+	head := log.TreeHead()
+	assert.Nil(t, verifier.VerifyTreeHead(head))
+	for _, receipt := range receipts {
+		consistency, err := log.ConsistencyProof(receipt.TreeHead.Size, head.Size)
+		assert.Nil(t, err)
+		assert.Nil(t, verifier.VerifyConsistency(receipt.TreeHead, head, consistency))
+
+		inclusion, err := log.InclusionProof(receipt.LeafIndex, head.Size)
+		assert.Nil(t, err)
+		assert.Nil(t, verifier.VerifyInclusion(receipt.LeafHash, receipt.LeafIndex, head, inclusion))
+	}
+
+	_, err := log.InclusionProof(0, head.Size+1)
+	assert.NotNil(t, err)
+	_, err = log.InclusionProof(head.Size, head.Size)
+	assert.NotNil(t, err)
+	_, err = log.ConsistencyProof(2, 1)
+	assert.NotNil(t, err)
+	_, err = log.ConsistencyProof(1, head.Size+1)
+	assert.NotNil(t, err)
+}
+
+func TestVerifier(t *testing.T) {
+	log, verifier := newTestLog(t)
+	otherLog, _ := newTestLog(t)
+	receipt := appendLeaf(t, log, []byte("tuple"))
+	appendLeaf(t, log, []byte("another tuple"))
+	head := log.TreeHead()
+
+	forgedHead := head
+	forgedHead.Size++
+	consistency, err := log.ConsistencyProof(1, 2)
+	if err != nil {
+		t.Fatalf("failed to get consistency proof: %v", err)
+	}
+
+	tests := map[string]struct {
+		verify      func() error
+		expectedErr error
+	}{
+		"receipt for other data": {
+			verify:      func() error { return verifier.VerifyReceipt(receipt, []byte("other tuple")) },
+			expectedErr: ErrInvalidProof,
+		},
+		"receipt of other log": {
+			verify: func() error {
+				return verifier.VerifyReceipt(appendLeaf(t, otherLog, []byte("tuple")), []byte("tuple"))
+			},
+			expectedErr: ErrInvalidTreeHead,
+		},
+		"forged tree head": {
+			verify:      func() error { return verifier.VerifyTreeHead(forgedHead) },
+			expectedErr: ErrInvalidTreeHead,
+		},
+		"consistency with forged tree head": {
+			verify:      func() error { return verifier.VerifyConsistency(receipt.TreeHead, forgedHead, consistency) },
+			expectedErr: ErrInvalidTreeHead,
+		},
+		"consistency in reverse": {
+			verify:      func() error { return verifier.VerifyConsistency(head, receipt.TreeHead, consistency) },
+			expectedErr: ErrInvalidProof,
+		},
+	}
+
+	for testName, tc := range tests {
+		t.Run(testName, func(t *testing.T) {
+			err := tc.verify()
+			assert.True(t, errors.Is(err, tc.expectedErr), err)
+		})
+	}
+
+	assert.Nil(t, verifier.VerifyConsistency(receipt.TreeHead, head, consistency))
+}
+
+func TestLog_Reopen(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("failed to generate log key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "leaves")
+	openLog := func() (*Log, *FileLeafStore) {
+		store, err := OpenFileLeafStore(path)
+		if err != nil {
+			t.Fatalf("failed to open leaf store: %v", err)
+		}
+		log, err := NewLog(key, WithLeafStore(store))
+		if err != nil {
+			t.Fatalf("failed to create log: %v", err)
+		}
+		return log, store
+	}
+	verifier, err := NewVerifier(key.Public().(ed25519.PublicKey))
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+
+	log, store := openLog()
+	var receipts []Receipt
+	for i := 0; i < 5; i++ {
+		receipts = append(receipts, appendLeaf(t, log, []byte(fmt.Sprintf("tuple %v", i))))
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("failed to close leaf store: %v", err)
+	}
+
+	// A crash while appending leaves part of a hash behind
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatalf("failed to open leaf file: %v", err)
+	}
+	if _, err := file.Write([]byte{1, 2, 3}); err != nil {
+		t.Fatalf("failed to write leaf file: %v", err)
+	}
+	file.Close()
+
+	// The reopened log continues the tree of the old receipts
+	log, store = openLog()
+	defer store.Close()
+	assert.Equal(t, uint64(len(receipts)), log.TreeHead().Size)
+	receipt := appendLeaf(t, log, []byte("tuple after restart"))
+	assert.Equal(t, uint64(len(receipts)), receipt.LeafIndex)
+	head := log.TreeHead()
+	for _, old := range receipts {
+		consistency, err := log.ConsistencyProof(old.TreeHead.Size, head.Size)
+		assert.Nil(t, err)
+		assert.Nil(t, verifier.VerifyConsistency(old.TreeHead, head, consistency))
+
+		inclusion, err := log.InclusionProof(old.LeafIndex, head.Size)
+		assert.Nil(t, err)
+		assert.Nil(t, verifier.VerifyInclusion(old.LeafHash, old.LeafIndex, head, inclusion))
+	}
+}
+
+func TestNewLog_InvalidKey(t *testing.T) {
+	_, err := NewLog(ed25519.PrivateKey{1, 2, 3})
+	assert.NotNil(t, err)
+	_, err = NewVerifier(ed25519.PublicKey{1, 2, 3})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,250 @@
+package transparency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+)
+
+// Hash is a SHA-256 hash of a tree node, encoded as hex in JSON
+type Hash [sha256.Size]byte
+
+// MarshalText encodes the hash as hex
+func (h Hash) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(h[:])), nil
+}
+
+// UnmarshalText decodes a hex-encoded hash
+func (h *Hash) UnmarshalText(text []byte) error {
+	decoded, err := hex.DecodeString(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid hex: %w", err)
+	}
+	if len(decoded) != len(h) {
+		return fmt.Errorf("hash must be %v bytes, got %v", len(h), len(decoded))
+	}
+	copy(h[:], decoded)
+	return nil
+}
+
+// Prefixes separating leaf hashes from interior node hashes, so that a leaf
+// cannot be passed off as a subtree
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+// HashLeaf returns the hash of a leaf holding data
+func HashLeaf(data []byte) Hash {
+	return sha256.Sum256(append([]byte{leafPrefix}, data...))
+}
+
+func hashChildren(left, right Hash) Hash {
+	b := make([]byte, 0, 1+2*len(left))
+	b = append(b, nodePrefix)
+	b = append(b, left[:]...)
+	b = append(b, right[:]...)
+	return sha256.Sum256(b)
+}
+
+// split returns the largest power of two smaller than n, for n > 1: the size
+// of the left subtree of a tree of n leaves
+func split(n uint64) uint64 {
+	return 1 << (bits.Len64(n-1) - 1)
+}
+
+// tree is a Merkle tree that caches the hash of every complete subtree:
+// levels[0] holds the leaves and levels[h][i] the hash of the 2^h leaves from
+// leaf i*2^h. Every subtree but those along the right edge of a tree is
+// complete, so appending a leaf and computing the root of the tree or the
+// inclusion proof of its last leaf hash O(log n) nodes.
+type tree struct {
+	levels [][]Hash
+}
+
+func newTree(leaves []Hash) *tree {
+	t := &tree{}
+	for _, leaf := range leaves {
+		t.append(leaf)
+	}
+	return t
+}
+
+// size returns the number of leaves of the tree
+func (t *tree) size() uint64 {
+	if len(t.levels) == 0 {
+		return 0
+	}
+	return uint64(len(t.levels[0]))
+}
+
+// append adds a leaf and caches the subtrees it completes
+func (t *tree) append(leaf Hash) {
+	node := leaf
+	for h := 0; ; h++ {
+		if h == len(t.levels) {
+			t.levels = append(t.levels, nil)
+		}
+		t.levels[h] = append(t.levels[h], node)
+		n := len(t.levels[h])
+		if n%2 == 1 {
+			return
+		}
+		node = hashChildren(t.levels[h][n-2], t.levels[h][n-1])
+	}
+}
+
+// hash returns the root hash of the subtree of the leaves from start to end,
+// exclusive, using the cached hash of complete subtrees
+func (t *tree) hash(start, end uint64) Hash {
+	n := end - start
+	if n == 1 {
+		return t.levels[0][start]
+	}
+	if h := bits.TrailingZeros64(n); n&(n-1) == 0 && start%n == 0 && h < len(t.levels) && start>>h < uint64(len(t.levels[h])) {
+		return t.levels[h][start>>h]
+	}
+	k := split(n)
+	return hashChildren(t.hash(start, start+k), t.hash(start+k, end))
+}
+
+// root returns the root hash of the tree of the first size leaves. The root
+// of an empty tree is the hash of the empty string.
+func (t *tree) root(size uint64) Hash {
+	if size == 0 {
+		return sha256.Sum256(nil)
+	}
+	return t.hash(0, size)
+}
+
+// inclusionPath returns the hashes needed to compute the root of the tree of
+// the first size leaves from leaf index, from the bottom up
+func (t *tree) inclusionPath(index, size uint64) []Hash {
+	return t.subtreeInclusionPath(index, 0, size)
+}
+
+func (t *tree) subtreeInclusionPath(index, start, end uint64) []Hash {
+	n := end - start
+	if n <= 1 {
+		return nil
+	}
+	k := split(n)
+	if index < start+k {
+		return append(t.subtreeInclusionPath(index, start, start+k), t.hash(start+k, end))
+	}
+	return append(t.subtreeInclusionPath(index, start+k, end), t.hash(start, start+k))
+}
+
+// consistencyPath returns the hashes needed to compute both the root of the
+// tree of the first size1 leaves and the root of the tree of the first size2
+// leaves, for 0 < size1 <= size2
+func (t *tree) consistencyPath(size1, size2 uint64) []Hash {
+	return t.subtreeConsistencyPath(size1, 0, size2, true)
+}
+
+// subtreeConsistencyPath returns the consistency path of the first tree within
+// the subtree of the leaves from start to end. complete is set while that
+// subtree is the whole first tree, whose root the verifier already knows.
+func (t *tree) subtreeConsistencyPath(size1, start, end uint64, complete bool) []Hash {
+	if size1 == end {
+		if complete {
+			return nil
+		}
+		return []Hash{t.hash(start, end)}
+	}
+	k := split(end - start)
+	if size1 <= start+k {
+		return append(t.subtreeConsistencyPath(size1, start, start+k, complete), t.hash(start+k, end))
+	}
+	return append(t.subtreeConsistencyPath(size1, start+k, end, false), t.hash(start, start+k))
+}
+
+// VerifyInclusion checks that proof proves that the leaf with hash leafHash is
+// at index in the tree of size leaves with hash root. It returns an error
+// wrapping ErrInvalidProof if it does not.
+func VerifyInclusion(leafHash Hash, index, size uint64, proof []Hash, root Hash) error {
+	if index >= size {
+		return fmt.Errorf("%w: leaf %v is outside a tree of %v leaves", ErrInvalidProof, index, size)
+	}
+	node, last := index, size-1
+	computed := leafHash
+	for _, sibling := range proof {
+		if last == 0 {
+			return fmt.Errorf("%w: inclusion proof is too long", ErrInvalidProof)
+		}
+		if node%2 == 1 || node == last {
+			computed = hashChildren(sibling, computed)
+			// Skip the levels where the node has no right sibling
+			for node%2 == 0 && node != 0 {
+				node, last = node>>1, last>>1
+			}
+		} else {
+			computed = hashChildren(computed, sibling)
+		}
+		node, last = node>>1, last>>1
+	}
+	if last != 0 {
+		return fmt.Errorf("%w: inclusion proof is too short", ErrInvalidProof)
+	}
+	if computed != root {
+		return fmt.Errorf("%w: inclusion proof does not lead to the root", ErrInvalidProof)
+	}
+	return nil
+}
+
+// VerifyConsistency checks that proof proves that the tree of size1 leaves
+// with hash root1 is a prefix of the tree of size2 leaves with hash root2,
+// i.e. that the log only appended leaves between the two. It returns an error
+// wrapping ErrInvalidProof if it does not.
+func VerifyConsistency(size1, size2 uint64, root1, root2 Hash, proof []Hash) error {
+	switch {
+	case size1 > size2:
+		return fmt.Errorf("%w: a tree of %v leaves cannot extend one of %v", ErrInvalidProof, size2, size1)
+	case size1 == size2 || size1 == 0:
+		// Every tree extends the empty tree and itself
+		if len(proof) != 0 {
+			return fmt.Errorf("%w: consistency proof must be empty", ErrInvalidProof)
+		}
+		if size1 == size2 && root1 != root2 {
+			return fmt.Errorf("%w: trees of the same size have different roots", ErrInvalidProof)
+		}
+		return nil
+	}
+
+	// The first tree's root is the start of the path if that tree is a
+	// complete subtree of the second
+	if size1&(size1-1) == 0 {
+		proof = append([]Hash{root1}, proof...)
+	}
+	if len(proof) == 0 {
+		return fmt.Errorf("%w: consistency proof is empty", ErrInvalidProof)
+	}
+	node, last := size1-1, size2-1
+	for node%2 == 1 {
+		node, last = node>>1, last>>1
+	}
+	computed1, computed2 := proof[0], proof[0]
+	for _, sibling := range proof[1:] {
+		if last == 0 {
+			return fmt.Errorf("%w: consistency proof is too long", ErrInvalidProof)
+		}
+		if node%2 == 1 || node == last {
+			computed1 = hashChildren(sibling, computed1)
+			computed2 = hashChildren(sibling, computed2)
+			for node%2 == 0 && node != 0 {
+				node, last = node>>1, last>>1
+			}
+		} else {
+			computed2 = hashChildren(computed2, sibling)
+		}
+		node, last = node>>1, last>>1
+	}
+	if last != 0 {
+		return fmt.Errorf("%w: consistency proof is too short", ErrInvalidProof)
+	}
+	if computed1 != root1 || computed2 != root2 {
+		return fmt.Errorf("%w: consistency proof does not lead to the roots", ErrInvalidProof)
+	}
+	return nil
+}
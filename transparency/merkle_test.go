@@ -0,0 +1,170 @@
+package transparency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ctLeaves are the leaves of the Certificate Transparency test tree (RFC 6962
+// reference implementation)
+var ctLeaves = []string{
+	"",
+	"00",
+	"10",
+	"2021",
+	"3031",
+	"40414243",
+	"5051525354555657",
+	"606162636465666768696a6b6c6d6e6f",
+}
+
+func leafHashes(t *testing.T, n int) []Hash {
+	hashes := make([]Hash, n)
+	for i := range hashes {
+		data := []byte(fmt.Sprintf("leaf %v", i))
+		if i < len(ctLeaves) {
+			var err error
+			if data, err = hex.DecodeString(ctLeaves[i]); err != nil {
+				t.Fatalf("failed to decode leaf: %v", err)
+			}
+		}
+		hashes[i] = HashLeaf(data)
+	}
+	return hashes
+}
+
+func TestTree_Root(t *testing.T) {
+	tests := map[string]struct {
+		size         int
+		expectedRoot string
+	}{
+		"empty": {
+			size:         0,
+			expectedRoot: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		},
+		"one leaf": {
+			size:         1,
+			expectedRoot: "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+		},
+		"two leaves": {
+			size:         2,
+			expectedRoot: "fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+		},
+		"eight leaves": {
+			size:         8,
+			expectedRoot: "5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+		},
+	}
+
+	for testName, tc := range tests {
+		t.Run(testName, func(t *testing.T) {
+			leaves := leafHashes(t, tc.size)
+			root := newTree(leaves).root(uint64(len(leaves)))
+			assert.Equal(t, tc.expectedRoot, hex.EncodeToString(root[:]))
+		})
+	}
+}
+
+func TestVerifyInclusion(t *testing.T) {
+	leaves := leafHashes(t, 19)
+	tree := newTree(leaves)
+	for size := uint64(1); size <= uint64(len(leaves)); size++ {
+		root := tree.root(size)
+		for index := uint64(0); index < size; index++ {
+			proof := tree.inclusionPath(index, size)
+			assert.Nil(t, VerifyInclusion(leaves[index], index, size, proof, root), "leaf %v of %v", index, size)
+
+			other := (index + 1) % size
+			if other != index {
+				err := VerifyInclusion(leaves[other], index, size, proof, root)
+				assert.True(t, errors.Is(err, ErrInvalidProof), "other leaf at %v of %v", index, size)
+			}
+			if len(proof) > 0 {
+				tampered := append([]Hash(nil), proof...)
+				tampered[0][0] ^= 1
+				err := VerifyInclusion(leaves[index], index, size, tampered, root)
+				assert.True(t, errors.Is(err, ErrInvalidProof), "tampered proof of %v of %v", index, size)
+
+				err = VerifyInclusion(leaves[index], index, size, proof[:len(proof)-1], root)
+				assert.True(t, errors.Is(err, ErrInvalidProof), "short proof of %v of %v", index, size)
+			}
+			err := VerifyInclusion(leaves[index], index, size, append(proof, root), root)
+			assert.True(t, errors.Is(err, ErrInvalidProof), "long proof of %v of %v", index, size)
+		}
+	}
+
+	err := VerifyInclusion(leaves[0], 1, 1, nil, leaves[0])
+	assert.True(t, errors.Is(err, ErrInvalidProof))
+}
+
+func TestVerifyConsistency(t *testing.T) {
+	leaves := leafHashes(t, 19)
+	tree := newTree(leaves)
+	for size2 := uint64(1); size2 <= uint64(len(leaves)); size2++ {
+		root2 := tree.root(size2)
+		for size1 := uint64(0); size1 <= size2; size1++ {
+			root1 := tree.root(size1)
+			var proof []Hash
+			if size1 > 0 {
+				proof = tree.consistencyPath(size1, size2)
+			}
+			assert.Nil(t, VerifyConsistency(size1, size2, root1, root2, proof), "%v to %v", size1, size2)
+
+			if size1 == 0 || size1 == size2 {
+				continue
+			}
+			wrongRoot := root1
+			wrongRoot[0] ^= 1
+			err := VerifyConsistency(size1, size2, wrongRoot, root2, proof)
+			assert.True(t, errors.Is(err, ErrInvalidProof), "wrong old root %v to %v", size1, size2)
+			err = VerifyConsistency(size1, size2, root1, wrongRoot, proof)
+			assert.True(t, errors.Is(err, ErrInvalidProof), "wrong new root %v to %v", size1, size2)
+			if len(proof) > 0 {
+				err = VerifyConsistency(size1, size2, root1, root2, proof[:len(proof)-1])
+				assert.True(t, errors.Is(err, ErrInvalidProof), "short proof %v to %v", size1, size2)
+			}
+		}
+	}
+
+	// A rewritten tree is not consistent
+	rewritten := append([]Hash(nil), leaves[:8]...)
+	rewritten[2] = HashLeaf([]byte("rewritten"))
+	rewrittenTree := newTree(rewritten)
+	proof := rewrittenTree.consistencyPath(3, 8)
+	err := VerifyConsistency(3, 8, tree.root(3), rewrittenTree.root(8), proof)
+	assert.True(t, errors.Is(err, ErrInvalidProof))
+
+	err = VerifyConsistency(4, 3, tree.root(4), tree.root(3), nil)
+	assert.True(t, errors.Is(err, ErrInvalidProof))
+}
+
+// referenceRoot computes the root of the tree of leaves as RFC 9162 defines
+// it, without caching subtrees
+func referenceRoot(leaves []Hash) Hash {
+	switch len(leaves) {
+	case 0:
+		return sha256.Sum256(nil)
+	case 1:
+		return leaves[0]
+	}
+	k := split(uint64(len(leaves)))
+	return hashChildren(referenceRoot(leaves[:k]), referenceRoot(leaves[k:]))
+}
+
+func TestTree_Cache(t *testing.T) {
+	// Every prefix of a grown tree has the root computed without the cache
+	leaves := leafHashes(t, 19)
+	tree := newTree(nil)
+	for i, leaf := range leaves {
+		tree.append(leaf)
+		assert.Equal(t, uint64(i+1), tree.size())
+		for size := uint64(0); size <= tree.size(); size++ {
+			assert.Equal(t, referenceRoot(leaves[:size]), tree.root(size), "tree of %v in tree of %v", size, i+1)
+		}
+	}
+}
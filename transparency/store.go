@@ -0,0 +1,64 @@
+package transparency
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// FileLeafStore is a LeafStore that keeps leaf hashes one after another in a
+// file. Append returns once a hash is flushed to stable storage, so a log
+// never issues a receipt for a leaf a crash could lose.
+type FileLeafStore struct {
+	file *os.File
+	size int64 // End of the last complete hash
+}
+
+// OpenFileLeafStore opens the leaf file at path, creating it if needed. A hash
+// left incomplete by a crash while it was being appended is discarded.
+func OpenFileLeafStore(path string) (*FileLeafStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open leaf file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read leaf file: %w", err)
+	}
+	size := info.Size() - info.Size()%int64(len(Hash{}))
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to discard incomplete leaf: %w", err)
+	}
+	return &FileLeafStore{file: file, size: size}, nil
+}
+
+// Append implements LeafStore
+func (s *FileLeafStore) Append(leaf Hash) error {
+	if _, err := s.file.WriteAt(leaf[:], s.size); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	s.size += int64(len(leaf))
+	return nil
+}
+
+// Leaves implements LeafStore
+func (s *FileLeafStore) Leaves() ([]Hash, error) {
+	leaves := make([]Hash, s.size/int64(len(Hash{})))
+	r := io.NewSectionReader(s.file, 0, s.size)
+	for i := range leaves {
+		if _, err := io.ReadFull(r, leaves[i][:]); err != nil {
+			return nil, fmt.Errorf("failed to read leaf %v: %w", i, err)
+		}
+	}
+	return leaves, nil
+}
+
+// Close closes the leaf file
+func (s *FileLeafStore) Close() error {
+	return s.file.Close()
+}
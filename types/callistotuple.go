@@ -3,7 +3,8 @@ package types
 import (
 	"fmt"
 
-	"github.com/ymarcus93/gallisto/internal/encryption"
+	"github.com/ymarcus93/gallisto/crypto"
+	"github.com/ymarcus93/gallisto/suite"
 )
 
 // CallistoTuple represents the 6-tuple record sent by Callisto clients and
@@ -11,39 +12,65 @@ import (
 type CallistoTuple struct {
 	userId                            []byte
 	pi                                []byte
-	locCiphertext                     []byte                   // c
-	dlocCiphertext                    []byte                   // c_assign
-	encryptedEntryDataKeyUnderUserKey encryption.GCMCiphertext // c_U
-	encryptedEntryData                encryption.GCMCiphertext // e_entry
-	encryptedAssignmentData           encryption.GCMCiphertext // e_assign
+	locCiphertext                     []byte               // c
+	dlocCiphertext                    []byte               // c_assign
+	encryptedEntryDataKeyUnderUserKey crypto.GCMCiphertext // c_U
+	encryptedEntryData                crypto.GCMCiphertext // e_entry
+	encryptedAssignmentData           crypto.GCMCiphertext // e_assign
+	matchClass                        MatchClass
+	suite                             suite.ID
+	userCertificate                   []byte
+	signature                         []byte // Over SignedContent
+	tupleID                           []byte
+}
+
+// TupleIDSize is the size of the random ID of tuples whose suite binds their
+// ciphertexts to the tuple (see suite.Suite.BindsTuple)
+const TupleIDSize = 16
+
+// CallistoTupleMsgPack encapsulates the same information as CallistoTuple but
+// is used for MessagePack encoding purposes
+type CallistoTupleMsgPack struct {
+	UserID                            []byte
+	Pi                                []byte
+	LOCCiphertext                     []byte
+	DLOCCiphertext                    []byte
+	EncryptedEntryDataKeyUnderUserKey crypto.GCMCiphertext
+	EncryptedEntryData                crypto.GCMCiphertext
+	EncryptedAssignmentData           crypto.GCMCiphertext
+	MatchClass                        MatchClass
+	Suite                             suite.ID // Absent before suites were introduced
+	UserCertificate                   []byte   `msgpack:",omitempty"`
+	Signature                         []byte   `msgpack:",omitempty"`
+	TupleID                           []byte   `msgpack:",omitempty"`
 }
 
 // NewCallistoTuple constructs a valid CallistoTuple. Returns a non-nil error if
 // provided input is invalid.
 func NewCallistoTuple(
 	userId, pi, locCiphertext, dlocCiphertext []byte,
-	encryptedEntryDataKeyUnderUserKey, encryptedEntryData, encryptedAssignmentData encryption.GCMCiphertext) (CallistoTuple, error) {
+	encryptedEntryDataKeyUnderUserKey, encryptedEntryData, encryptedAssignmentData crypto.GCMCiphertext) (CallistoTuple, error) {
 	// Validate inputs
 	if userId == nil {
-		return CallistoTuple{}, fmt.Errorf("userId cannot be nil")
+		return CallistoTuple{}, fmt.Errorf("%w: userId cannot be nil", ErrInvalidTuple)
 	}
 	if pi == nil {
-		return CallistoTuple{}, fmt.Errorf("pi cannot be nil")
+		return CallistoTuple{}, fmt.Errorf("%w: pi cannot be nil", ErrInvalidTuple)
 	}
 	if locCiphertext == nil {
-		return CallistoTuple{}, fmt.Errorf("locCiphertext cannot be nil")
+		return CallistoTuple{}, fmt.Errorf("%w: locCiphertext cannot be nil", ErrInvalidTuple)
 	}
 	if dlocCiphertext == nil {
-		return CallistoTuple{}, fmt.Errorf("dlocCiphertext cannot be nil")
+		return CallistoTuple{}, fmt.Errorf("%w: dlocCiphertext cannot be nil", ErrInvalidTuple)
 	}
 	if err := encryptedEntryDataKeyUnderUserKey.IsValid(); err != nil {
-		return CallistoTuple{}, fmt.Errorf("encryptedEntryDataKeyUnderUserKey is invalid: %v", err)
+		return CallistoTuple{}, fmt.Errorf("%w: encryptedEntryDataKeyUnderUserKey is invalid: %v", ErrInvalidTuple, err)
 	}
 	if err := encryptedEntryData.IsValid(); err != nil {
-		return CallistoTuple{}, fmt.Errorf("encryptedEntryData is invalid: %v", err)
+		return CallistoTuple{}, fmt.Errorf("%w: encryptedEntryData is invalid: %v", ErrInvalidTuple, err)
 	}
 	if err := encryptedAssignmentData.IsValid(); err != nil {
-		return CallistoTuple{}, fmt.Errorf("encryptedAssignmentData is invalid: %v", err)
+		return CallistoTuple{}, fmt.Errorf("%w: encryptedAssignmentData is invalid: %v", ErrInvalidTuple, err)
 	}
 
 	return CallistoTuple{
@@ -57,6 +84,73 @@ func NewCallistoTuple(
 	}, nil
 }
 
+// WithMatchClass returns a copy of the tuple tagged with the given match class.
+// Tuples are exact matches unless tagged otherwise.
+func (c CallistoTuple) WithMatchClass(matchClass MatchClass) CallistoTuple {
+	c.matchClass = matchClass
+	return c
+}
+
+// WithSuite returns a copy of the tuple tagged with the protocol suite it was
+// created with. Untagged tuples belong to suite.Legacy.
+func (c CallistoTuple) WithSuite(id suite.ID) CallistoTuple {
+	c.suite = id
+	return c
+}
+
+// WithUserCertificate returns a copy of the tuple carrying a certificate of
+// its user ID (see identity.Certificate)
+func (c CallistoTuple) WithUserCertificate(certificate []byte) CallistoTuple {
+	c.userCertificate = certificate
+	return c
+}
+
+// WithTupleID returns a copy of the tuple carrying the ID its ciphertexts are
+// bound to
+func (c CallistoTuple) WithTupleID(tupleID []byte) CallistoTuple {
+	c.tupleID = tupleID
+	return c
+}
+
+// ToCallistoTupleMsgPack converts a CallistoTuple into a struct that can be
+// MessagePack encoded
+func (c CallistoTuple) ToCallistoTupleMsgPack() CallistoTupleMsgPack {
+	return CallistoTupleMsgPack{
+		UserID:                            c.userId,
+		Pi:                                c.pi,
+		LOCCiphertext:                     c.locCiphertext,
+		DLOCCiphertext:                    c.dlocCiphertext,
+		EncryptedEntryDataKeyUnderUserKey: c.encryptedEntryDataKeyUnderUserKey,
+		EncryptedEntryData:                c.encryptedEntryData,
+		EncryptedAssignmentData:           c.encryptedAssignmentData,
+		MatchClass:                        c.matchClass,
+		Suite:                             c.suite,
+		UserCertificate:                   c.userCertificate,
+		Signature:                         c.signature,
+		TupleID:                           c.tupleID,
+	}
+}
+
+// FromCallistoTupleMsgPack converts a message-pack encoded tuple into a
+// CallistoTuple
+func FromCallistoTupleMsgPack(m CallistoTupleMsgPack) (CallistoTuple, error) {
+	tuple, err := NewCallistoTuple(
+		m.UserID,
+		m.Pi,
+		m.LOCCiphertext,
+		m.DLOCCiphertext,
+		m.EncryptedEntryDataKeyUnderUserKey,
+		m.EncryptedEntryData,
+		m.EncryptedAssignmentData,
+	)
+	if err != nil {
+		return CallistoTuple{}, err
+	}
+	tuple = tuple.WithMatchClass(m.MatchClass).WithSuite(m.Suite).WithUserCertificate(m.UserCertificate).WithTupleID(m.TupleID)
+	tuple.signature = m.Signature
+	return tuple, nil
+}
+
 // Getters
 
 // UserID returns the userID of this tuple's submitter
@@ -75,14 +169,32 @@ func (c CallistoTuple) DLOCCiphertext() []byte { return c.dlocCiphertext }
 // EncryptedEntryDataKeyUnderUserKey returns the ciphertext protecting the
 // random entry data key created for encrypting entry data. It can only be
 // decrypted with the user's key.
-func (c CallistoTuple) EncryptedEntryDataKeyUnderUserKey() encryption.GCMCiphertext {
+func (c CallistoTuple) EncryptedEntryDataKeyUnderUserKey() crypto.GCMCiphertext {
 	return c.encryptedEntryDataKeyUnderUserKey
 }
 
 // EncryptedEntryData returns the encrypted entry data under k_e
-func (c CallistoTuple) EncryptedEntryData() encryption.GCMCiphertext { return c.encryptedEntryData }
+func (c CallistoTuple) EncryptedEntryData() crypto.GCMCiphertext { return c.encryptedEntryData }
 
 // EncryptedAssignmentData returns the encrypted assignment data under k_a
-func (c CallistoTuple) EncryptedAssignmentData() encryption.GCMCiphertext {
+func (c CallistoTuple) EncryptedAssignmentData() crypto.GCMCiphertext {
 	return c.encryptedAssignmentData
 }
+
+// MatchClass returns how confidently this tuple identifies its perpetrator
+func (c CallistoTuple) MatchClass() MatchClass { return c.matchClass }
+
+// Suite returns the ID of the protocol suite this tuple was created with
+func (c CallistoTuple) Suite() suite.ID { return c.suite }
+
+// UserCertificate returns the certificate of the tuple's user ID, or nil if
+// the user is not certified
+func (c CallistoTuple) UserCertificate() []byte { return c.userCertificate }
+
+// Signature returns the submitter's signature over the tuple, or nil if it is
+// unsigned
+func (c CallistoTuple) Signature() []byte { return c.signature }
+
+// TupleID returns the ID the tuple's ciphertexts are bound to, or nil if its
+// suite does not bind them
+func (c CallistoTuple) TupleID() []byte { return c.tupleID }
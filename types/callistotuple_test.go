@@ -1,10 +1,12 @@
 package types
 
 import (
+	"crypto/ed25519"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
-	"github.com/ymarcus93/gallisto/internal/encryption"
+	"github.com/ymarcus93/gallisto/crypto"
 	helper "github.com/ymarcus93/gallisto/internal/test"
 )
 
@@ -12,7 +14,7 @@ func TestNewCallistoTuple_Invalid(t *testing.T) {
 	invalidCtxs := helper.CreateInvalidGCMCiphertexts(t)
 	tests := map[string]struct {
 		userId, pi, locCiphertext, dlocCiphertext                                      []byte
-		encryptedEntryDataKeyUnderUserKey, encryptedEntryData, encryptedAssignmentData encryption.GCMCiphertext
+		encryptedEntryDataKeyUnderUserKey, encryptedEntryData, encryptedAssignmentData crypto.GCMCiphertext
 	}{
 		"invalid userId (nil)": {
 			userId:                            nil,
@@ -167,3 +169,72 @@ func TestNewCallistoTuple_Valid(t *testing.T) {
 		assert.Equal(t, encryptedAssignmentData, actual.encryptedAssignmentData)
 	}
 }
+
+func TestCallistoTuple_Sign(t *testing.T) {
+	publicKey, signingKey, err := ed25519.GenerateKey(nil)
+	assert.Nil(t, err)
+	otherKey, _, err := ed25519.GenerateKey(nil)
+	assert.Nil(t, err)
+
+	tuple, err := NewCallistoTuple(
+		helper.GenerateRandomBytes(16, t),
+		helper.GenerateRandomBytes(32, t),
+		helper.GenerateRandomBytes(32, t),
+		helper.GenerateRandomBytes(32, t),
+		helper.CreateGCMCiphertext(t),
+		helper.CreateGCMCiphertext(t),
+		helper.CreateGCMCiphertext(t),
+	)
+	assert.Nil(t, err)
+	assert.True(t, errors.Is(tuple.VerifySignature(publicKey), ErrInvalidSignature))
+
+	signed, err := tuple.WithSuite(2).Sign(signingKey)
+	assert.Nil(t, err)
+	assert.Nil(t, signed.VerifySignature(publicKey))
+	assert.True(t, errors.Is(signed.VerifySignature(otherKey), ErrInvalidSignature))
+
+	// Changing any component invalidates the signature
+	for name, altered := range map[string]CallistoTuple{
+		"match class": signed.WithMatchClass(MatchPhonetic),
+		"suite":       signed.WithSuite(3),
+		"certificate": signed.WithUserCertificate([]byte("certificate")),
+		"tuple ID":    signed.WithTupleID(helper.GenerateRandomBytes(TupleIDSize, t)),
+	} {
+		assert.True(t, errors.Is(altered.VerifySignature(publicKey), ErrInvalidSignature), name)
+	}
+	spliced, err := NewCallistoTuple(signed.UserID(), signed.Pi(), signed.LOCCiphertext(), signed.DLOCCiphertext(),
+		signed.EncryptedEntryDataKeyUnderUserKey(), helper.CreateGCMCiphertext(t), signed.EncryptedAssignmentData())
+	assert.Nil(t, err)
+	spliced.signature = signed.Signature()
+	assert.True(t, errors.Is(spliced.VerifySignature(publicKey), ErrInvalidSignature))
+
+	_, err = tuple.Sign(signingKey[:10])
+	assert.NotNil(t, err)
+}
+
+func TestCallistoTuple_Hash(t *testing.T) {
+	_, signingKey, err := ed25519.GenerateKey(nil)
+	assert.Nil(t, err)
+	tuple, err := NewCallistoTuple(
+		helper.GenerateRandomBytes(16, t),
+		helper.GenerateRandomBytes(32, t),
+		helper.GenerateRandomBytes(32, t),
+		helper.GenerateRandomBytes(32, t),
+		helper.CreateGCMCiphertext(t),
+		helper.CreateGCMCiphertext(t),
+		helper.CreateGCMCiphertext(t),
+	)
+	assert.Nil(t, err)
+	signed, err := tuple.Sign(signingKey)
+	assert.Nil(t, err)
+
+	assert.Equal(t, tuple.Hash(), tuple.WithSuite(tuple.Suite()).Hash())
+	for name, altered := range map[string]CallistoTuple{
+		"match class": tuple.WithMatchClass(MatchPhonetic),
+		"suite":       tuple.WithSuite(3),
+		"tuple ID":    tuple.WithTupleID(helper.GenerateRandomBytes(TupleIDSize, t)),
+		"signature":   signed,
+	} {
+		assert.NotEqual(t, tuple.Hash(), altered.Hash(), name)
+	}
+}
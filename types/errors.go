@@ -0,0 +1,17 @@
+package types
+
+import "errors"
+
+var (
+	// ErrDecode is returned when an encoded entry, assignment or LOC payload
+	// cannot be decoded
+	ErrDecode = errors.New("failed to decode payload")
+	// ErrUnsupportedVersion is returned when a payload was produced by a schema
+	// version this implementation does not know
+	ErrUnsupportedVersion = errors.New("unsupported schema version")
+	// ErrInvalidTuple is returned when a CallistoTuple is missing components
+	ErrInvalidTuple = errors.New("invalid tuple")
+	// ErrInvalidLOCData is returned when LOC data is missing components or has
+	// an unknown LOC type
+	ErrInvalidLOCData = errors.New("invalid LOC data")
+)
@@ -3,10 +3,9 @@ package types
 import (
 	"fmt"
 
-	"github.com/ymarcus93/gallisto/internal/encryption"
+	"github.com/ymarcus93/gallisto/crypto"
 	"github.com/ymarcus93/gallisto/internal/shamir"
-
-	ff "github.com/superarius/shamir/modular"
+	"github.com/ymarcus93/gallisto/suite"
 )
 
 // LOCDataMsgPack encapsulates the same information as LOCData but is used for
@@ -15,7 +14,10 @@ type LOCDataMsgPack struct {
 	LocType      LOCType
 	U            []byte
 	S            []byte
-	EncryptedKey encryption.GCMCiphertext
+	EncryptedKey crypto.GCMCiphertext
+	Suite        suite.ID // Absent before suites were introduced
+	UserID       []byte   `msgpack:",omitempty"`
+	TupleID      []byte   `msgpack:",omitempty"`
 }
 
 // LOCData encapsulates the 3-tuple plaintext that is encrypted for LOCs
@@ -23,23 +25,26 @@ type LOCData struct {
 	locType      LOCType
 	u            []byte
 	s            []byte
-	encryptedKey encryption.GCMCiphertext
+	encryptedKey crypto.GCMCiphertext
+	suite        suite.ID
+	userID       []byte
+	tupleID      []byte
 }
 
 // NewLOCData constructs a valid LOCData. Returns a non-nil error if provided
 // input is invalid.
-func NewLOCData(locType LOCType, shamirShare *shamir.ShamirShare, encryptedKey encryption.GCMCiphertext) (LOCData, error) {
+func NewLOCData(locType LOCType, shamirShare *shamir.ShamirShare, encryptedKey crypto.GCMCiphertext) (LOCData, error) {
 	if locType != Director && locType != Counselor {
-		return LOCData{}, fmt.Errorf("locType must be either Director or Counselor")
+		return LOCData{}, fmt.Errorf("%w: locType must be either Director or Counselor", ErrInvalidLOCData)
 	}
 	if shamirShare.X == nil {
-		return LOCData{}, fmt.Errorf("shamir share x value cannot be nil")
+		return LOCData{}, fmt.Errorf("%w: shamir share x value cannot be nil", ErrInvalidLOCData)
 	}
 	if shamirShare.Y == nil {
-		return LOCData{}, fmt.Errorf("shamir share y value cannot be nil")
+		return LOCData{}, fmt.Errorf("%w: shamir share y value cannot be nil", ErrInvalidLOCData)
 	}
 	if err := encryptedKey.IsValid(); err != nil {
-		return LOCData{}, fmt.Errorf("encryptedKey is invalid: %v", err)
+		return LOCData{}, fmt.Errorf("%w: encryptedKey is invalid: %v", ErrInvalidLOCData, err)
 	}
 
 	return LOCData{
@@ -50,6 +55,21 @@ func NewLOCData(locType LOCType, shamirShare *shamir.ShamirShare, encryptedKey e
 	}, nil
 }
 
+// WithSuite returns a copy of the LOC data tagged with the protocol suite it
+// was created with. Untagged LOC data belongs to suite.Legacy.
+func (d LOCData) WithSuite(id suite.ID) LOCData {
+	d.suite = id
+	return d
+}
+
+// WithBinding returns a copy of the LOC data naming the user ID and tuple ID
+// its tuple's ciphertexts are bound to, so that the (D)LOC can check them
+func (d LOCData) WithBinding(userID, tupleID []byte) LOCData {
+	d.userID = userID
+	d.tupleID = tupleID
+	return d
+}
+
 // ToLOCDataMsgPack converts LOCData into a struct that can be MessagePack
 // encoded
 func (d LOCData) ToLOCDataMsgPack() LOCDataMsgPack {
@@ -58,6 +78,9 @@ func (d LOCData) ToLOCDataMsgPack() LOCDataMsgPack {
 		U:            d.u,
 		S:            d.s,
 		EncryptedKey: d.encryptedKey,
+		Suite:        d.suite,
+		UserID:       d.userID,
+		TupleID:      d.tupleID,
 	}
 }
 
@@ -65,7 +88,11 @@ func (d LOCData) ToLOCDataMsgPack() LOCDataMsgPack {
 // LOCData struct
 func FromLOCDataMsgPack(msgPackEncodedData LOCDataMsgPack) (LOCData, error) {
 	share := getShamirShare(msgPackEncodedData.U, msgPackEncodedData.S)
-	return NewLOCData(msgPackEncodedData.LocType, share, msgPackEncodedData.EncryptedKey)
+	locData, err := NewLOCData(msgPackEncodedData.LocType, share, msgPackEncodedData.EncryptedKey)
+	if err != nil {
+		return LOCData{}, err
+	}
+	return locData.WithSuite(msgPackEncodedData.Suite).WithBinding(msgPackEncodedData.UserID, msgPackEncodedData.TupleID), nil
 }
 
 // Getters
@@ -77,7 +104,16 @@ func (d LOCData) LocType() LOCType { return d.locType }
 // EncryptedKey returns the encrypted entry or assignment key. If LocType() is
 // Director, then the encrypted assignment key is returned. If LocType() is
 // Counselor, then the encrypted entry key is returned.
-func (d LOCData) EncryptedKey() encryption.GCMCiphertext { return d.encryptedKey }
+func (d LOCData) EncryptedKey() crypto.GCMCiphertext { return d.encryptedKey }
+
+// Suite returns the ID of the protocol suite this data was created with
+func (d LOCData) Suite() suite.ID { return d.suite }
+
+// Binding returns the user ID and tuple ID of the tuple this data belongs to.
+// They are only set in suites that bind ciphertexts to their tuple.
+func (d LOCData) Binding() suite.Binding {
+	return suite.Binding{UserID: d.userID, TupleID: d.tupleID}
+}
 
 // GetShamirShare returns the (U, s) shamir share contained within LOCData
 func (d LOCData) GetShamirShare() *shamir.ShamirShare {
@@ -86,7 +122,7 @@ func (d LOCData) GetShamirShare() *shamir.ShamirShare {
 
 func getShamirShare(x, y []byte) *shamir.ShamirShare {
 	return &shamir.ShamirShare{
-		X: ff.IntFromBytes(x),
-		Y: ff.IntFromBytes(y),
+		X: shamir.ElementFromBytes(x),
+		Y: shamir.ElementFromBytes(y),
 	}
 }
@@ -5,16 +5,18 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
-	ff "github.com/superarius/shamir/modular"
-
-	"github.com/ymarcus93/gallisto/internal/encryption"
+	"github.com/ymarcus93/gallisto/crypto"
 	"github.com/ymarcus93/gallisto/internal/shamir"
 	helper "github.com/ymarcus93/gallisto/internal/test"
 )
 
+func createElement(t *testing.T) *shamir.Element {
+	return shamir.ElementFromBytes(helper.GenerateRandomBytes(64, t))
+}
+
 func createShamirShare(t *testing.T) *shamir.ShamirShare {
-	x := helper.GenerateRandomModularInt(t)
-	y := helper.GenerateRandomModularInt(t)
+	x := createElement(t)
+	y := createElement(t)
 	return &shamir.ShamirShare{X: x, Y: y}
 }
 
@@ -23,7 +25,7 @@ func TestNewLOCData_Invalid(t *testing.T) {
 	tests := map[string]struct {
 		locType      LOCType
 		shamirShare  *shamir.ShamirShare
-		encryptedKey encryption.GCMCiphertext
+		encryptedKey crypto.GCMCiphertext
 	}{
 		"invalid locType (default)": {
 			locType:      0,
@@ -37,12 +39,12 @@ func TestNewLOCData_Invalid(t *testing.T) {
 		},
 		"invalid x point on shamir share": {
 			locType:      Director,
-			shamirShare:  &shamir.ShamirShare{X: nil, Y: helper.GenerateRandomModularInt(t)},
+			shamirShare:  &shamir.ShamirShare{X: nil, Y: createElement(t)},
 			encryptedKey: helper.CreateGCMCiphertext(t),
 		},
 		"invalid y point on shamir share": {
 			locType:      Director,
-			shamirShare:  &shamir.ShamirShare{X: helper.GenerateRandomModularInt(t), Y: nil},
+			shamirShare:  &shamir.ShamirShare{X: createElement(t), Y: nil},
 			encryptedKey: helper.CreateGCMCiphertext(t),
 		},
 		"invalid ciphertext (nil nonce)": {
@@ -74,7 +76,7 @@ func TestNewLOCData_Valid(t *testing.T) {
 	tests := map[string]struct {
 		locType      LOCType
 		shamirShare  *shamir.ShamirShare
-		encryptedKey encryption.GCMCiphertext
+		encryptedKey crypto.GCMCiphertext
 	}{
 		"valid director input": {
 			locType:      Director,
@@ -108,7 +110,7 @@ func TestGetShamirShare(t *testing.T) {
 		t.Error(err)
 	}
 	actual := locData.GetShamirShare()
-	expected := &shamir.ShamirShare{X: ff.IntFromBytes(shamirShare.X.Bytes()), Y: ff.IntFromBytes(shamirShare.Y.Bytes())}
+	expected := &shamir.ShamirShare{X: shamir.ElementFromBytes(shamirShare.X.Bytes()), Y: shamir.ElementFromBytes(shamirShare.Y.Bytes())}
 	assert.Equal(t, expected, actual)
 }
 
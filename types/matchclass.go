@@ -0,0 +1,37 @@
+package types
+
+import "fmt"
+
+// An enum that represents how confidently a tuple identifies its perpetrator.
+// Tuples derived from the perpetrator ID as entered are exact; tuples derived
+// from fuzzy variants of it (see canonical.Variants) are lower confidence.
+// Higher values are less confident.
+type MatchClass int
+
+const (
+	MatchExact    MatchClass = iota // Default case
+	MatchPhonetic                   // Derived from a phonetic encoding
+	MatchVariant                    // Derived from a spelling variant
+)
+
+var matchClassNames = map[MatchClass]string{
+	MatchExact:    "exact",
+	MatchPhonetic: "phonetic",
+	MatchVariant:  "variant",
+}
+
+// String returns the human readable name of the match class
+func (m MatchClass) String() string {
+	if name, ok := matchClassNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("MatchClass(%d)", int(m))
+}
+
+// IsValid returns a non-nil error if m is not a known match class
+func (m MatchClass) IsValid() error {
+	if _, ok := matchClassNames[m]; !ok {
+		return fmt.Errorf("unknown match class: %d", int(m))
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// An enum that represents a category of sexual misconduct
+type MisconductCategory int
+
+const (
+	MisconductUnknown MisconductCategory = iota // Default case
+	MisconductRape
+	MisconductAbuse
+	MisconductAssault
+	MisconductStalking
+	MisconductIndecentExposure
+	MisconductPedophilia
+	MisconductHarassment
+)
+
+var misconductCategoryNames = map[MisconductCategory]string{
+	MisconductRape:             "Rape",
+	MisconductAbuse:            "Abuse",
+	MisconductAssault:          "Assault",
+	MisconductStalking:         "Stalking",
+	MisconductIndecentExposure: "Indecent exposure",
+	MisconductPedophilia:       "Pedophilia",
+	MisconductHarassment:       "Harassment",
+}
+
+// MisconductCategories returns every known category in display order
+func MisconductCategories() []MisconductCategory {
+	return []MisconductCategory{
+		MisconductRape,
+		MisconductAbuse,
+		MisconductAssault,
+		MisconductStalking,
+		MisconductIndecentExposure,
+		MisconductPedophilia,
+		MisconductHarassment,
+	}
+}
+
+// String returns the human readable name of the category
+func (m MisconductCategory) String() string {
+	if name, ok := misconductCategoryNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("MisconductCategory(%d)", int(m))
+}
+
+// IsValid returns a non-nil error if m is not a known category
+func (m MisconductCategory) IsValid() error {
+	if _, ok := misconductCategoryNames[m]; !ok {
+		return fmt.Errorf("unknown misconduct category: %d", int(m))
+	}
+	return nil
+}
+
+// ParseMisconductCategory returns the category whose name matches s. Matching
+// ignores case and surrounding whitespace.
+func ParseMisconductCategory(s string) (MisconductCategory, error) {
+	trimmed := strings.TrimSpace(s)
+	for category, name := range misconductCategoryNames {
+		if strings.EqualFold(name, trimmed) {
+			return category, nil
+		}
+	}
+	return MisconductUnknown, fmt.Errorf("unknown misconduct category: %q", s)
+}
+
+// MarshalText implements encoding.TextMarshaler so categories are rendered by
+// name in human readable encodings such as JSON
+func (m MisconductCategory) MarshalText() ([]byte, error) {
+	if err := m.IsValid(); err != nil {
+		return nil, err
+	}
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (m *MisconductCategory) UnmarshalText(text []byte) error {
+	category, err := ParseMisconductCategory(string(text))
+	if err != nil {
+		return err
+	}
+	*m = category
+	return nil
+}
@@ -0,0 +1,93 @@
+package types
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ymarcus93/gallisto/crypto"
+)
+
+// ErrInvalidSignature is returned when a tuple is unsigned or its signature
+// does not verify under its submitter's signing key
+var ErrInvalidSignature = errors.New("invalid tuple signature")
+
+// signatureLabel separates signed tuples from other signed messages
+var signatureLabel = []byte("gallisto/tuple/v1/signature")
+
+// SignedContent returns the canonical encoding of the tuple that its
+// submitter signs: a label followed by every component except the signature,
+// each as a 4-byte big-endian length and its bytes. Numbers are encoded as
+// 8-byte big-endian integers. The tuple ID comes last and only if it is set,
+// which keeps the content of tuples without one unchanged.
+func (c CallistoTuple) SignedContent() []byte {
+	var b []byte
+	appendField := func(field []byte) {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(field)))
+		b = append(b, field...)
+	}
+	appendCiphertext := func(ciphertext crypto.GCMCiphertext) {
+		appendField(ciphertext.Nonce)
+		appendField(ciphertext.Ciphertext)
+		appendField(ciphertext.AssociatedData)
+	}
+
+	appendField(signatureLabel)
+	appendField(c.userId)
+	appendField(c.pi)
+	appendField(c.locCiphertext)
+	appendField(c.dlocCiphertext)
+	appendCiphertext(c.encryptedEntryDataKeyUnderUserKey)
+	appendCiphertext(c.encryptedEntryData)
+	appendCiphertext(c.encryptedAssignmentData)
+	b = binary.BigEndian.AppendUint64(b, uint64(c.matchClass))
+	b = binary.BigEndian.AppendUint64(b, uint64(c.suite))
+	appendField(c.userCertificate)
+	if len(c.tupleID) > 0 {
+		appendField(c.tupleID)
+	}
+	return b
+}
+
+// hashLabel separates tuple hashes from other hashes
+var hashLabel = []byte("gallisto/tuple/v1/hash")
+
+// Hash returns the SHA-256 of a label, the tuple's signed content and its
+// signature. It identifies the tuple as it was submitted, e.g. in a
+// transparency log.
+func (c CallistoTuple) Hash() [sha256.Size]byte {
+	b := append([]byte(nil), hashLabel...)
+	b = append(b, c.SignedContent()...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(c.signature)))
+	b = append(b, c.signature...)
+	return sha256.Sum256(b)
+}
+
+// Sign returns a copy of the tuple signed with the submitter's Ed25519 key.
+// Sign after every other component is set: changing one invalidates the
+// signature.
+func (c CallistoTuple) Sign(key ed25519.PrivateKey) (CallistoTuple, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return CallistoTuple{}, fmt.Errorf("signing key must be %v bytes, got %v", ed25519.PrivateKeySize, len(key))
+	}
+	c.signature = ed25519.Sign(key, c.SignedContent())
+	return c, nil
+}
+
+// VerifySignature checks the tuple's signature under the submitter's public
+// key. It returns an error wrapping ErrInvalidSignature if the tuple is
+// unsigned or the signature does not verify.
+func (c CallistoTuple) VerifySignature(publicKey ed25519.PublicKey) error {
+	if len(c.signature) == 0 {
+		return fmt.Errorf("%w: tuple is unsigned", ErrInvalidSignature)
+	}
+	if len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: public key must be %v bytes", ErrInvalidSignature, ed25519.PublicKeySize)
+	}
+	if !ed25519.Verify(publicKey, c.SignedContent(), c.signature) {
+		return fmt.Errorf("%w: signature does not verify", ErrInvalidSignature)
+	}
+	return nil
+}
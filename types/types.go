@@ -1,7 +1,23 @@
 package types
 
-// The ciphersuite used for the OPRF protocol
-const OPRF_CIPHERSUITE string = "OPRF-P521-HKDF-SHA512-SSWU-RO"
+import (
+	"time"
+
+	"github.com/ymarcus93/gallisto/suite"
+)
+
+// The ciphersuite used for the OPRF protocol by the default protocol suite
+const OPRF_CIPHERSUITE string = suite.DefaultOPRFCiphersuite
+
+// SchemaVersion is the version of the EntryData and AssignmentData schema
+// written by this implementation. Payloads written before versioning was
+// introduced carry no version and are treated as version 1.
+const (
+	SchemaVersion1 uint8 = 1
+	SchemaVersion2 uint8 = 2
+
+	SchemaVersion = SchemaVersion2
+)
 
 // EntryData encapsulates information about the perpetrator and the victim.
 // EntryData is only meant to be viewed by LOCs.
@@ -11,13 +27,23 @@ type EntryData struct {
 	VictimName                 string
 	VictimPhoneNumber          string
 	VictimEmail                string
+	Incident                   *Incident // Optional
+}
+
+// Incident holds optional details about when and where the reported misconduct
+// took place. Zero values mean the victim did not provide that detail.
+type Incident struct {
+	StartDate time.Time
+	EndDate   time.Time
+	Location  string
+	Narrative string
 }
 
 // AssignmentData encapsulates the necessary information for DLOCs to assign
 // matched users to LOCs. AssignmentData is onnly meant to be viewed by DLOCs.
 type AssignmentData struct {
 	VictimStateOfCurrentResidence    string
-	CategorizationOfSexualMisconduct string
+	CategorizationOfSexualMisconduct []MisconductCategory
 	IndustryOfPerpetrator            string
 }
 
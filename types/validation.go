@@ -0,0 +1,192 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ymarcus93/gallisto/canonical"
+)
+
+// Maximum lengths, in characters, of free text fields
+const (
+	MaxShortFieldLength = 256
+	MaxNarrativeLength  = 20000
+)
+
+// FieldError describes why a single field failed validation
+type FieldError struct {
+	Field  string
+	Reason string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Field, e.Reason)
+}
+
+// ValidationErrors holds every field error found while validating a value
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	reasons := make([]string, len(e))
+	for i, fieldError := range e {
+		reasons[i] = fieldError.Error()
+	}
+	return "invalid fields: " + strings.Join(reasons, "; ")
+}
+
+// validator accumulates field errors
+type validator struct {
+	errs ValidationErrors
+}
+
+func (v *validator) check(field string, err error) {
+	if err != nil {
+		v.errs = append(v.errs, FieldError{Field: field, Reason: err.Error()})
+	}
+}
+
+func (v *validator) fail(field, reason string) {
+	v.errs = append(v.errs, FieldError{Field: field, Reason: reason})
+}
+
+// err returns nil if no field errors were found
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// Validate checks every field of the entry data and returns ValidationErrors
+// describing all invalid fields, or nil if the entry data is valid. A
+// perpetrator must be identified by name or Twitter user name, and the victim
+// must provide a phone number or email so that a LOC can reach them.
+func (d EntryData) Validate() error {
+	v := &validator{}
+	if strings.TrimSpace(d.PerpetratorName) == "" && strings.TrimSpace(d.PerpetratorTwitterUserName) == "" {
+		v.fail("PerpetratorName", "either a perpetrator name or twitter user name is required")
+	}
+	v.check("PerpetratorName", ValidateShortText(d.PerpetratorName))
+	if d.PerpetratorTwitterUserName != "" {
+		v.check("PerpetratorTwitterUserName", ValidateTwitterHandle(d.PerpetratorTwitterUserName))
+	}
+	v.check("VictimName", ValidateShortText(d.VictimName))
+	if strings.TrimSpace(d.VictimPhoneNumber) == "" && strings.TrimSpace(d.VictimEmail) == "" {
+		v.fail("VictimPhoneNumber", "either a victim phone number or email is required")
+	}
+	if d.VictimPhoneNumber != "" {
+		v.check("VictimPhoneNumber", ValidatePhoneNumber(d.VictimPhoneNumber))
+	}
+	if d.VictimEmail != "" {
+		v.check("VictimEmail", ValidateEmail(d.VictimEmail))
+	}
+	if d.Incident != nil {
+		d.Incident.validate(v)
+	}
+	return v.err()
+}
+
+func (i Incident) validate(v *validator) {
+	now := time.Now()
+	if i.StartDate.After(now) {
+		v.fail("Incident.StartDate", "cannot be in the future")
+	}
+	if i.EndDate.After(now) {
+		v.fail("Incident.EndDate", "cannot be in the future")
+	}
+	if !i.StartDate.IsZero() && !i.EndDate.IsZero() && i.EndDate.Before(i.StartDate) {
+		v.fail("Incident.EndDate", "cannot be before the start date")
+	}
+	v.check("Incident.Location", ValidateShortText(i.Location))
+	v.check("Incident.Narrative", validateText(i.Narrative, MaxNarrativeLength, true))
+}
+
+// Validate checks every field of the assignment data and returns
+// ValidationErrors describing all invalid fields, or nil if the assignment
+// data is valid. The set of allowed states or regions is deployment specific,
+// so VictimStateOfCurrentResidence is only checked to be present and well
+// formed.
+func (d AssignmentData) Validate() error {
+	v := &validator{}
+	if strings.TrimSpace(d.VictimStateOfCurrentResidence) == "" {
+		v.fail("VictimStateOfCurrentResidence", "is required")
+	}
+	v.check("VictimStateOfCurrentResidence", ValidateShortText(d.VictimStateOfCurrentResidence))
+
+	seen := make(map[MisconductCategory]struct{})
+	for _, category := range d.CategorizationOfSexualMisconduct {
+		if err := category.IsValid(); err != nil {
+			v.check("CategorizationOfSexualMisconduct", err)
+			continue
+		}
+		if _, ok := seen[category]; ok {
+			v.fail("CategorizationOfSexualMisconduct", fmt.Sprintf("%v is listed more than once", category))
+		}
+		seen[category] = struct{}{}
+	}
+
+	v.check("IndustryOfPerpetrator", ValidateShortText(d.IndustryOfPerpetrator))
+	return v.err()
+}
+
+// ValidatePhoneNumber returns a non-nil error if s cannot be read as a phone
+// number
+func ValidatePhoneNumber(s string) error {
+	_, err := canonical.Phone(s, canonical.DefaultCountryCode)
+	return err
+}
+
+// ValidateEmail returns a non-nil error if s is not a plausible email address
+func ValidateEmail(s string) error {
+	email, err := canonical.Email(s)
+	if err != nil {
+		return err
+	}
+	domain := email[strings.LastIndex(email, "@")+1:]
+	if !strings.Contains(domain, ".") {
+		return fmt.Errorf("invalid email domain: %q", domain)
+	}
+	return ValidateShortText(s)
+}
+
+// ValidateTwitterHandle returns a non-nil error if s is not a valid Twitter user
+// name. A leading "@" is allowed.
+func ValidateTwitterHandle(s string) error {
+	handle := strings.TrimPrefix(strings.TrimSpace(s), "@")
+	if handle == "" || utf8.RuneCountInString(handle) > 15 {
+		return fmt.Errorf("twitter user names must be between 1 and 15 characters")
+	}
+	for _, r := range handle {
+		if !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') && r != '_' {
+			return fmt.Errorf("twitter user names may only contain letters, digits and underscores")
+		}
+	}
+	return nil
+}
+
+// ValidateShortText returns a non-nil error if s is too long or spans multiple
+// lines. Empty strings are valid.
+func ValidateShortText(s string) error {
+	return validateText(s, MaxShortFieldLength, false)
+}
+
+func validateText(s string, maxLength int, allowNewlines bool) error {
+	if !utf8.ValidString(s) {
+		return fmt.Errorf("must be valid UTF-8")
+	}
+	if utf8.RuneCountInString(s) > maxLength {
+		return fmt.Errorf("cannot be longer than %v characters", maxLength)
+	}
+	for _, r := range s {
+		if allowNewlines && (r == '\n' || r == '\r' || r == '\t') {
+			continue
+		}
+		if unicode.IsControl(r) {
+			return fmt.Errorf("cannot contain control characters")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package types
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func validEntryData() EntryData {
+	return EntryData{
+		PerpetratorName:            "Foo",
+		PerpetratorTwitterUserName: "@foo",
+		VictimName:                 "Bar",
+		VictimPhoneNumber:          "111-234-5678",
+		VictimEmail:                "bar@mail.com",
+	}
+}
+
+func validAssignmentData() AssignmentData {
+	return AssignmentData{
+		VictimStateOfCurrentResidence:    "Massachusetts",
+		CategorizationOfSexualMisconduct: []MisconductCategory{MisconductAbuse},
+		IndustryOfPerpetrator:            "Foo industry",
+	}
+}
+
+func fieldsOf(err error) []string {
+	var validationErrors ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return nil
+	}
+	fields := make([]string, len(validationErrors))
+	for i, fieldError := range validationErrors {
+		fields[i] = fieldError.Field
+	}
+	return fields
+}
+
+func TestEntryDataValidate(t *testing.T) {
+	yesterday := time.Now().Add(-24 * time.Hour)
+	tomorrow := time.Now().Add(24 * time.Hour)
+
+	tests := map[string]struct {
+		modify         func(d *EntryData)
+		expectedFields []string
+	}{
+		"valid": {
+			modify: func(d *EntryData) {},
+		},
+		"valid with only a twitter user name": {
+			modify: func(d *EntryData) { d.PerpetratorName = "" },
+		},
+		"valid with only an email": {
+			modify: func(d *EntryData) { d.VictimPhoneNumber = "" },
+		},
+		"valid incident": {
+			modify: func(d *EntryData) {
+				d.Incident = &Incident{StartDate: yesterday, EndDate: yesterday, Location: "Boston", Narrative: "line one\nline two"}
+			},
+		},
+		"no perpetrator": {
+			modify: func(d *EntryData) {
+				d.PerpetratorName = ""
+				d.PerpetratorTwitterUserName = ""
+			},
+			expectedFields: []string{"PerpetratorName"},
+		},
+		"no victim contact": {
+			modify: func(d *EntryData) {
+				d.VictimPhoneNumber = ""
+				d.VictimEmail = ""
+			},
+			expectedFields: []string{"VictimPhoneNumber"},
+		},
+		"invalid contact details": {
+			modify: func(d *EntryData) {
+				d.PerpetratorTwitterUserName = "foo bar"
+				d.VictimPhoneNumber = "call me"
+				d.VictimEmail = "bar"
+			},
+			expectedFields: []string{"PerpetratorTwitterUserName", "VictimPhoneNumber", "VictimEmail"},
+		},
+		"multi-line name": {
+			modify:         func(d *EntryData) { d.VictimName = "Bar\nBaz" },
+			expectedFields: []string{"VictimName"},
+		},
+		"too long name": {
+			modify:         func(d *EntryData) { d.PerpetratorName = strings.Repeat("a", MaxShortFieldLength+1) },
+			expectedFields: []string{"PerpetratorName"},
+		},
+		"incident in the future": {
+			modify:         func(d *EntryData) { d.Incident = &Incident{StartDate: tomorrow} },
+			expectedFields: []string{"Incident.StartDate"},
+		},
+		"incident ends before it starts": {
+			modify: func(d *EntryData) {
+				d.Incident = &Incident{StartDate: yesterday, EndDate: yesterday.Add(-time.Hour)}
+			},
+			expectedFields: []string{"Incident.EndDate"},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			entryData := validEntryData()
+			test.modify(&entryData)
+			err := entryData.Validate()
+			if test.expectedFields == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.Equal(t, test.expectedFields, fieldsOf(err))
+			}
+		})
+	}
+}
+
+func TestAssignmentDataValidate(t *testing.T) {
+	tests := map[string]struct {
+		modify         func(d *AssignmentData)
+		expectedFields []string
+	}{
+		"valid": {
+			modify: func(d *AssignmentData) {},
+		},
+		"valid without categories": {
+			modify: func(d *AssignmentData) { d.CategorizationOfSexualMisconduct = nil },
+		},
+		"missing state": {
+			modify:         func(d *AssignmentData) { d.VictimStateOfCurrentResidence = " " },
+			expectedFields: []string{"VictimStateOfCurrentResidence"},
+		},
+		"unknown category": {
+			modify: func(d *AssignmentData) {
+				d.CategorizationOfSexualMisconduct = []MisconductCategory{MisconductUnknown}
+			},
+			expectedFields: []string{"CategorizationOfSexualMisconduct"},
+		},
+		"duplicate category": {
+			modify: func(d *AssignmentData) {
+				d.CategorizationOfSexualMisconduct = []MisconductCategory{MisconductAbuse, MisconductAbuse}
+			},
+			expectedFields: []string{"CategorizationOfSexualMisconduct"},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assignmentData := validAssignmentData()
+			test.modify(&assignmentData)
+			err := assignmentData.Validate()
+			if test.expectedFields == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.Equal(t, test.expectedFields, fieldsOf(err))
+			}
+		})
+	}
+}
+
+func TestValidateTwitterHandle(t *testing.T) {
+	for _, valid := range []string{"foo", "@foo", "Foo_99", "@abcdefghijklmno"} {
+		assert.NoError(t, ValidateTwitterHandle(valid), valid)
+	}
+	for _, invalid := range []string{"", "@", "foo bar", "foo-bar", "@abcdefghijklmnop"} {
+		assert.Error(t, ValidateTwitterHandle(invalid), invalid)
+	}
+}
+
+func TestValidateEmail(t *testing.T) {
+	for _, valid := range []string{"bar@mail.com", "Bar@Mail.COM"} {
+		assert.NoError(t, ValidateEmail(valid), valid)
+	}
+	for _, invalid := range []string{"", "bar", "bar@", "bar@localhost"} {
+		assert.Error(t, ValidateEmail(invalid), invalid)
+	}
+}
@@ -0,0 +1,331 @@
+package vectors
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ymarcus93/gallisto/canonical"
+	"github.com/ymarcus93/gallisto/crypto"
+	"github.com/ymarcus93/gallisto/internal/detrand"
+	"github.com/ymarcus93/gallisto/oprf"
+	"github.com/ymarcus93/gallisto/protocol/client"
+	"github.com/ymarcus93/gallisto/suite"
+	"github.com/ymarcus93/gallisto/types"
+)
+
+// Spec describes a vector to generate
+type Spec struct {
+	Name           string
+	PerpetratorID  Identifier
+	EntryData      types.EntryData
+	AssignmentData types.AssignmentData
+}
+
+// DefaultSpecs returns the specs of the published test vectors
+func DefaultSpecs() []Spec {
+	return []Spec{
+		{
+			Name:          "name",
+			PerpetratorID: Identifier{Type: canonical.TypeName.String(), Value: "  John   SMITH "},
+			EntryData: types.EntryData{
+				PerpetratorName: "John Smith",
+				VictimName:      "Jane Doe",
+				VictimEmail:     "jane.doe@example.com",
+			},
+			AssignmentData: types.AssignmentData{
+				VictimStateOfCurrentResidence:    "Massachusetts",
+				CategorizationOfSexualMisconduct: []types.MisconductCategory{types.MisconductHarassment, types.MisconductStalking},
+			},
+		},
+		{
+			Name:          "email",
+			PerpetratorID: Identifier{Type: canonical.TypeEmail.String(), Value: "J.Smith@Example.COM"},
+			EntryData: types.EntryData{
+				PerpetratorName:            "John Smith",
+				PerpetratorTwitterUserName: "@jsmith",
+				VictimPhoneNumber:          "+1 617 555 0100",
+				Incident: &types.Incident{
+					StartDate: time.Date(2019, time.October, 1, 0, 0, 0, 0, time.UTC),
+					EndDate:   time.Date(2019, time.October, 3, 0, 0, 0, 0, time.UTC),
+					Location:  "Boston, MA",
+					Narrative: "Zoë described the incident in detail.",
+				},
+			},
+			AssignmentData: types.AssignmentData{
+				VictimStateOfCurrentResidence:    "New York",
+				CategorizationOfSexualMisconduct: []types.MisconductCategory{types.MisconductAssault},
+				IndustryOfPerpetrator:            "Technology",
+			},
+		},
+		{
+			Name:          "phone",
+			PerpetratorID: Identifier{Type: canonical.TypePhone.String(), Value: "(617) 555-0123"},
+			EntryData: types.EntryData{
+				PerpetratorName: "José Álvarez",
+				VictimEmail:     "victim@example.org",
+			},
+			AssignmentData: types.AssignmentData{
+				VictimStateOfCurrentResidence: "California",
+			},
+		},
+	}
+}
+
+// Generate creates a new test vector file for specs. Keys and all randomness
+// are drawn from crypto/rand and recorded in the file.
+func Generate(description string, specs []Spec) (File, error) {
+	oprfKey, err := oprf.GenerateKey(types.OPRF_CIPHERSUITE)
+	if err != nil {
+		return File{}, err
+	}
+	oprfKeyBytes, err := oprfKey.MarshalBinary()
+	if err != nil {
+		return File{}, err
+	}
+	locKeys, err := crypto.GenerateRSAKeyPair()
+	if err != nil {
+		return File{}, err
+	}
+	dlocKeys, err := crypto.GenerateRSAKeyPair()
+	if err != nil {
+		return File{}, err
+	}
+
+	oprfPublicKey, err := oprfKey.PublicKey().MarshalBinary()
+	if err != nil {
+		return File{}, fmt.Errorf("failed to serialize OPRF public key: %w", err)
+	}
+	locPEM, err := crypto.MarshalRSAPrivateKey(locKeys.PrivateKey)
+	if err != nil {
+		return File{}, err
+	}
+	dlocPEM, err := crypto.MarshalRSAPrivateKey(dlocKeys.PrivateKey)
+	if err != nil {
+		return File{}, err
+	}
+	file := File{
+		Description:     description,
+		FormatVersion:   FormatVersion,
+		Suite:           suite.Default,
+		OPRFCiphersuite: types.OPRF_CIPHERSUITE,
+		OPRFMode:        oprf.BaseMode.String(),
+		OPRFKey:         oprfKeyBytes,
+		OPRFPublicKey:   oprfPublicKey,
+		LOCPrivateKey:   string(locPEM),
+		DLOCPrivateKey:  string(dlocPEM),
+	}
+
+	keys, err := file.keys()
+	if err != nil {
+		return File{}, err
+	}
+	for _, spec := range specs {
+		vector, err := generateVector(keys, spec)
+		if err != nil {
+			return File{}, fmt.Errorf("failed to generate vector %q: %w", spec.Name, err)
+		}
+		file.Vectors = append(file.Vectors, vector)
+	}
+	return file, nil
+}
+
+func generateVector(keys pipelineKeys, spec Spec) (Vector, error) {
+	inputs := Inputs{
+		PerpetratorID:  spec.PerpetratorID,
+		EntryData:      spec.EntryData,
+		AssignmentData: spec.AssignmentData,
+	}
+	clientRandom := &recorder{source: rand.Reader}
+	blindRandom := &recorder{source: rand.Reader}
+	vector, err := run(keys, inputs, detrand.Allow(clientRandom), detrand.Allow(blindRandom))
+	if err != nil {
+		return Vector{}, err
+	}
+
+	// The client reads its user key and user ID before anything else
+	if len(clientRandom.chunks) != 2+len(ClientRandomnessLabels) {
+		return Vector{}, fmt.Errorf("client consumed %v random values, expected %v", len(clientRandom.chunks), 2+len(ClientRandomnessLabels))
+	}
+	vector.Name = spec.Name
+	vector.Inputs.UserKey = clientRandom.chunks[0]
+	vector.Inputs.UserID = vector.Tuple.UserID
+	vector.Inputs.BlindRandomness = bytes.Join(blindRandom.chunks, nil)
+	for i, label := range ClientRandomnessLabels {
+		vector.Inputs.ClientRandomness = append(vector.Inputs.ClientRandomness, Chunk{Label: label, Value: clientRandom.chunks[2+i]})
+	}
+	return vector, nil
+}
+
+// Compute runs the pipeline on the inputs of every vector in file, replaying
+// the recorded randomness, and returns the resulting vectors
+func Compute(file File) ([]Vector, error) {
+	keys, err := file.keys()
+	if err != nil {
+		return nil, err
+	}
+
+	vectors := make([]Vector, len(file.Vectors))
+	for i, expected := range file.Vectors {
+		inputs := expected.Inputs
+		clientRandomness := [][]byte{inputs.UserKey, inputs.UserID}
+		for _, chunk := range inputs.ClientRandomness {
+			clientRandomness = append(clientRandomness, chunk.Value)
+		}
+		clientRandom := bytes.NewReader(bytes.Join(clientRandomness, nil))
+		blindRandom := bytes.NewReader(inputs.BlindRandomness)
+
+		// The randomness was recorded from crypto/rand when the vector was
+		// generated
+		vector, err := run(keys, inputs, detrand.Allow(clientRandom), detrand.Allow(blindRandom))
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute vector %q: %w", expected.Name, err)
+		}
+		if clientRandom.Len() != 0 || blindRandom.Len() != 0 {
+			return nil, fmt.Errorf("vector %q has more randomness than the pipeline consumed", expected.Name)
+		}
+		vector.Name = expected.Name
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+type pipelineKeys struct {
+	suite      suite.ID
+	oprfConfig oprf.Config
+	oprfKey    oprf.SecretKey
+	locKeys    client.LOCPublicKeys
+}
+
+func (f File) keys() (pipelineKeys, error) {
+	protocolSuite, err := suite.Lookup(f.Suite)
+	if err != nil {
+		return pipelineKeys{}, err
+	}
+	if f.OPRFCiphersuite != protocolSuite.OPRFCiphersuite {
+		return pipelineKeys{}, fmt.Errorf("OPRF ciphersuite %v does not belong to %v", f.OPRFCiphersuite, protocolSuite.Name)
+	}
+	oprfMode, err := oprf.ParseMode(f.OPRFMode)
+	if err != nil {
+		return pipelineKeys{}, err
+	}
+	oprfKey, err := oprf.KeyFromHex(f.OPRFCiphersuite, hex.EncodeToString(f.OPRFKey), hex.EncodeToString(f.OPRFPublicKey))
+	if err != nil {
+		return pipelineKeys{}, err
+	}
+	locKeys, err := crypto.ParseRSAKeyPair([]byte(f.LOCPrivateKey))
+	if err != nil {
+		return pipelineKeys{}, fmt.Errorf("invalid LOC key: %w", err)
+	}
+	dlocKeys, err := crypto.ParseRSAKeyPair([]byte(f.DLOCPrivateKey))
+	if err != nil {
+		return pipelineKeys{}, fmt.Errorf("invalid DLOC key: %w", err)
+	}
+	return pipelineKeys{
+		suite:      f.Suite,
+		oprfConfig: oprf.Config{Ciphersuite: f.OPRFCiphersuite, Mode: oprfMode},
+		oprfKey:    oprfKey,
+		locKeys:    client.LOCPublicKeys{LOCPublicKey: locKeys.PublicKey, DLOCPublicKey: dlocKeys.PublicKey},
+	}, nil
+}
+
+// run creates a tuple for inputs and returns the vector describing it, minus
+// its name and randomness
+func run(keys pipelineKeys, inputs Inputs, clientRandom, blindRandom io.Reader) (Vector, error) {
+	identifierType, err := canonical.ParseIdentifierType(inputs.PerpetratorID.Type)
+	if err != nil {
+		return Vector{}, err
+	}
+	perpID := canonical.Identifier{Type: identifierType, Value: inputs.PerpetratorID.Value}
+
+	oprfServer, err := oprf.NewOPRFServer(keys.oprfConfig, keys.oprfKey)
+	if err != nil {
+		return Vector{}, err
+	}
+	evaluator := &recordingEvaluator{evaluator: oprfServer}
+	pHatComputer, err := oprf.NewPHatComputerContext(keys.oprfConfig, evaluator, oprf.WithRand(blindRandom), oprf.WithPublicKey(oprfServer.PublicKey()))
+	if err != nil {
+		return Vector{}, err
+	}
+	var trace client.Trace
+	callistoClient, err := client.NewCallistoClient(pHatComputer, client.WithRand(clientRandom), client.WithTrace(&trace), client.WithSuite(keys.suite))
+	if err != nil {
+		return Vector{}, err
+	}
+
+	entry := client.CallistoEntry{EntryData: inputs.EntryData, AssignmentData: inputs.AssignmentData}
+	tuple, err := callistoClient.CreateCallistoTuple(perpID, entry, keys.locKeys)
+	if err != nil {
+		return Vector{}, err
+	}
+
+	return Vector{
+		Inputs: inputs,
+		Intermediates: Intermediates{
+			PerpID:                 trace.PerpID,
+			BlindedElement:         evaluator.blinded,
+			EvaluatedElement:       evaluator.evaluated,
+			PHat:                   trace.PHat,
+			A:                      trace.A,
+			K:                      trace.K,
+			Pi:                     trace.Pi,
+			ShamirShareX:           trace.ShamirShareX,
+			ShamirShareY:           trace.ShamirShareY,
+			EntryDataEncoding:      trace.EntryDataEncoding,
+			AssignmentDataEncoding: trace.AssignmentDataEncoding,
+			LOCDataEncoding:        trace.LOCDataEncoding,
+			DLOCDataEncoding:       trace.DLOCDataEncoding,
+		},
+		Tuple: Tuple{
+			Suite:                             tuple.Suite(),
+			UserID:                            tuple.UserID(),
+			Pi:                                tuple.Pi(),
+			TupleID:                           tuple.TupleID(),
+			LOCCiphertext:                     tuple.LOCCiphertext(),
+			DLOCCiphertext:                    tuple.DLOCCiphertext(),
+			EncryptedEntryDataKeyUnderUserKey: fromGCMCiphertext(tuple.EncryptedEntryDataKeyUnderUserKey()),
+			EncryptedEntryData:                fromGCMCiphertext(tuple.EncryptedEntryData()),
+			EncryptedAssignmentData:           fromGCMCiphertext(tuple.EncryptedAssignmentData()),
+		},
+	}, nil
+}
+
+// recorder reads from source and records every read as a separate chunk
+type recorder struct {
+	source io.Reader
+	chunks [][]byte
+}
+
+func (r *recorder) Read(p []byte) (int, error) {
+	n, err := r.source.Read(p)
+	if n > 0 {
+		r.chunks = append(r.chunks, append([]byte(nil), p[:n]...))
+	}
+	return n, err
+}
+
+// recordingEvaluator records the serialized blinded and evaluated elements of
+// the last evaluation
+type recordingEvaluator struct {
+	evaluator oprf.ContextOPRFEvaluator
+	blinded   []byte
+	evaluated []byte
+}
+
+func (r *recordingEvaluator) EvaluateOPRFContext(ctx context.Context, req oprf.EvaluationRequest) (oprf.Evaluation, error) {
+	eval, err := r.evaluator.EvaluateOPRFContext(ctx, req)
+	if err != nil {
+		return oprf.Evaluation{}, err
+	}
+	if r.blinded, err = req.Elements[0].MarshalBinaryCompress(); err != nil {
+		return oprf.Evaluation{}, err
+	}
+	if r.evaluated, err = eval.Elements[0].MarshalBinaryCompress(); err != nil {
+		return oprf.Evaluation{}, err
+	}
+	return eval, nil
+}
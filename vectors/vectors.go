@@ -0,0 +1,172 @@
+// Package vectors produces and checks test vectors for the whole client
+// pipeline, from the perpetrator ID to the final tuple. Independent
+// implementations, such as mobile clients, can use them to check that they
+// produce tuples compatible with this one.
+//
+// Every random value the pipeline consumes is recorded as an input, so that a
+// vector can be replayed exactly. The client consumes its randomness in the
+// order given by ClientRandomnessLabels, after the user key and user ID.
+package vectors
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ymarcus93/gallisto/crypto"
+	"github.com/ymarcus93/gallisto/suite"
+	"github.com/ymarcus93/gallisto/types"
+)
+
+// FormatVersion is the version of the test vector file format. Version 2 added
+// the protocol suite, version 3 the OPRF mode, version 4 the tuple ID.
+const FormatVersion = 4
+
+// ClientRandomnessLabels names, in order, the random values a client consumes
+// while creating a tuple of suite.Default
+var ClientRandomnessLabels = []string{
+	"tupleID",           // ID the ciphertexts are bound to
+	"entryDataKey",      // k_e
+	"nonce:e_entry",     // Nonce of the encrypted entry data
+	"nonce:c_e",         // Nonce of k_e encrypted under k
+	"nonce:c_u",         // Nonce of k_e encrypted under the user key
+	"assignmentDataKey", // k_a
+	"nonce:e_assign",    // Nonce of the encrypted assignment data
+	"nonce:c_a",         // Nonce of k_a encrypted under k
+	"oaepSeed:loc",      // RSA-OAEP seed of the LOC ciphertext
+	"oaepSeed:dloc",     // RSA-OAEP seed of the DLOC ciphertext
+}
+
+// HexBytes is a byte string encoded as hex in JSON
+type HexBytes []byte
+
+// MarshalText encodes the bytes as hex
+func (h HexBytes) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(h)), nil
+}
+
+// UnmarshalText decodes hex-encoded bytes
+func (h *HexBytes) UnmarshalText(text []byte) error {
+	decoded, err := hex.DecodeString(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid hex: %w", err)
+	}
+	*h = decoded
+	return nil
+}
+
+// File is a set of test vectors sharing the same keys
+type File struct {
+	Description     string   `json:"description"`
+	FormatVersion   int      `json:"formatVersion"`
+	Suite           suite.ID `json:"suite"`
+	OPRFCiphersuite string   `json:"oprfCiphersuite"`
+	OPRFMode        string   `json:"oprfMode"` // See oprf.ParseMode
+	OPRFKey         HexBytes `json:"oprfKey"`
+	OPRFPublicKey   HexBytes `json:"oprfPublicKey"`
+	LOCPrivateKey   string   `json:"locPrivateKey"`  // PEM encoded PKCS #8
+	DLOCPrivateKey  string   `json:"dlocPrivateKey"` // PEM encoded PKCS #8
+	Vectors         []Vector `json:"vectors"`
+}
+
+// Vector is a single run of the client pipeline
+type Vector struct {
+	Name          string        `json:"name"`
+	Inputs        Inputs        `json:"inputs"`
+	Intermediates Intermediates `json:"intermediates"`
+	Tuple         Tuple         `json:"tuple"`
+}
+
+// Identifier is a perpetrator identifier, with its type given by tag (e.g.
+// "name" or "email")
+type Identifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Chunk is a labeled random value
+type Chunk struct {
+	Label string   `json:"label"`
+	Value HexBytes `json:"value"`
+}
+
+// Inputs are the values a vector is computed from
+type Inputs struct {
+	PerpetratorID    Identifier           `json:"perpetratorID"`
+	EntryData        types.EntryData      `json:"entryData"`
+	AssignmentData   types.AssignmentData `json:"assignmentData"`
+	UserKey          HexBytes             `json:"userKey"`
+	UserID           HexBytes             `json:"userID"`
+	BlindRandomness  HexBytes             `json:"blindRandomness"` // Bytes read to sample the OPRF blind
+	ClientRandomness []Chunk              `json:"clientRandomness"`
+}
+
+// Intermediates are the values computed on the way to the tuple
+type Intermediates struct {
+	PerpID                 HexBytes `json:"perpID"` // Canonical perpetrator ID
+	BlindedElement         HexBytes `json:"blindedElement"`
+	EvaluatedElement       HexBytes `json:"evaluatedElement"`
+	PHat                   HexBytes `json:"pHat"`
+	A                      HexBytes `json:"a"`
+	K                      HexBytes `json:"k"`
+	Pi                     HexBytes `json:"pi"`
+	ShamirShareX           HexBytes `json:"shamirShareX"`
+	ShamirShareY           HexBytes `json:"shamirShareY"`
+	EntryDataEncoding      HexBytes `json:"entryDataEncoding"`
+	AssignmentDataEncoding HexBytes `json:"assignmentDataEncoding"`
+	LOCDataEncoding        HexBytes `json:"locDataEncoding"`
+	DLOCDataEncoding       HexBytes `json:"dlocDataEncoding"`
+}
+
+// Ciphertext is an AES-GCM ciphertext
+type Ciphertext struct {
+	Nonce          HexBytes `json:"nonce"`
+	Ciphertext     HexBytes `json:"ciphertext"`
+	AssociatedData HexBytes `json:"associatedData"`
+}
+
+// Tuple holds the components of the resulting CallistoTuple
+type Tuple struct {
+	Suite                             suite.ID   `json:"suite"`
+	UserID                            HexBytes   `json:"userID"`
+	Pi                                HexBytes   `json:"pi"`
+	TupleID                           HexBytes   `json:"tupleID,omitempty"`
+	LOCCiphertext                     HexBytes   `json:"locCiphertext"`
+	DLOCCiphertext                    HexBytes   `json:"dlocCiphertext"`
+	EncryptedEntryDataKeyUnderUserKey Ciphertext `json:"encryptedEntryDataKeyUnderUserKey"`
+	EncryptedEntryData                Ciphertext `json:"encryptedEntryData"`
+	EncryptedAssignmentData           Ciphertext `json:"encryptedAssignmentData"`
+}
+
+func fromGCMCiphertext(c crypto.GCMCiphertext) Ciphertext {
+	return Ciphertext{Nonce: c.Nonce, Ciphertext: c.Ciphertext, AssociatedData: c.AssociatedData}
+}
+
+// Load reads a test vector file
+func Load(path string) (File, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return File{}, fmt.Errorf("failed to read test vectors: %w", err)
+	}
+	var file File
+	if err := json.Unmarshal(data, &file); err != nil {
+		return File{}, fmt.Errorf("failed to parse test vectors %v: %w", path, err)
+	}
+	if file.FormatVersion != FormatVersion {
+		return File{}, fmt.Errorf("unsupported test vector format version: %v", file.FormatVersion)
+	}
+	return file, nil
+}
+
+// Save writes a test vector file
+func Save(path string, file File) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode test vectors: %w", err)
+	}
+	if err := ioutil.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write test vectors: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package vectors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const publishedVectors = "testdata/vectors.json"
+
+// TestPublishedVectors checks that this implementation still produces the
+// published test vectors. If a change to the pipeline is intended, regenerate
+// them with `gallisto vectors generate`.
+func TestPublishedVectors(t *testing.T) {
+	file, err := Load(publishedVectors)
+	if err != nil {
+		t.Fatalf("failed to load test vectors: %v", err)
+	}
+	assert.Len(t, file.Vectors, len(DefaultSpecs()))
+
+	mismatches, err := Verify(file)
+	assert.NoError(t, err)
+	for _, m := range mismatches {
+		t.Error(m)
+	}
+}
+
+func TestVerify_DetectsMismatch(t *testing.T) {
+	file, err := Load(publishedVectors)
+	if err != nil {
+		t.Fatalf("failed to load test vectors: %v", err)
+	}
+
+	// Tamper with an intermediate value and a tuple component
+	tampered := file
+	tampered.Vectors = append([]Vector(nil), file.Vectors...)
+	tampered.Vectors[0].Intermediates.PHat = HexBytes{0x00}
+	tampered.Vectors[0].Tuple.LOCCiphertext = HexBytes{0x00}
+
+	mismatches := CompareVectors(file.Vectors, tampered.Vectors)
+	if assert.Len(t, mismatches, 2) {
+		assert.Equal(t, "intermediates.pHat", mismatches[0].Field)
+		assert.Equal(t, "tuple.locCiphertext", mismatches[1].Field)
+	}
+
+	// Missing vectors are reported
+	mismatches = CompareVectors(file.Vectors, file.Vectors[1:])
+	if assert.Len(t, mismatches, 1) {
+		assert.Equal(t, file.Vectors[0].Name, mismatches[0].Vector)
+	}
+
+	// Extra randomness means the pipeline changed
+	tampered.Vectors[0].Inputs.BlindRandomness = append(append(HexBytes(nil), file.Vectors[0].Inputs.BlindRandomness...), 0x00)
+	_, err = Verify(tampered)
+	assert.Error(t, err)
+}
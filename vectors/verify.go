@@ -0,0 +1,112 @@
+package vectors
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Mismatch describes a computed value that differs from a test vector
+type Mismatch struct {
+	Vector   string
+	Field    string
+	Expected HexBytes
+	Actual   HexBytes
+}
+
+func (m Mismatch) String() string {
+	if m.Field == "" {
+		return fmt.Sprintf("%v: vector is missing", m.Vector)
+	}
+	expected, _ := m.Expected.MarshalText()
+	actual, _ := m.Actual.MarshalText()
+	return fmt.Sprintf("%v: %v differs\n  expected: %s\n  actual:   %s", m.Vector, m.Field, expected, actual)
+}
+
+type field struct {
+	name  string
+	value []byte
+}
+
+// outputs lists every computed value of a vector, in pipeline order
+func (v Vector) outputs() []field {
+	i, t := v.Intermediates, v.Tuple
+	return []field{
+		{"intermediates.perpID", i.PerpID},
+		{"intermediates.blindedElement", i.BlindedElement},
+		{"intermediates.evaluatedElement", i.EvaluatedElement},
+		{"intermediates.pHat", i.PHat},
+		{"intermediates.a", i.A},
+		{"intermediates.k", i.K},
+		{"intermediates.pi", i.Pi},
+		{"intermediates.shamirShareX", i.ShamirShareX},
+		{"intermediates.shamirShareY", i.ShamirShareY},
+		{"intermediates.entryDataEncoding", i.EntryDataEncoding},
+		{"intermediates.assignmentDataEncoding", i.AssignmentDataEncoding},
+		{"intermediates.locDataEncoding", i.LOCDataEncoding},
+		{"intermediates.dlocDataEncoding", i.DLOCDataEncoding},
+		{"tuple.suite", []byte{byte(t.Suite)}},
+		{"tuple.userID", t.UserID},
+		{"tuple.pi", t.Pi},
+		{"tuple.tupleID", t.TupleID},
+		{"tuple.locCiphertext", t.LOCCiphertext},
+		{"tuple.dlocCiphertext", t.DLOCCiphertext},
+		{"tuple.encryptedEntryDataKeyUnderUserKey.nonce", t.EncryptedEntryDataKeyUnderUserKey.Nonce},
+		{"tuple.encryptedEntryDataKeyUnderUserKey.ciphertext", t.EncryptedEntryDataKeyUnderUserKey.Ciphertext},
+		{"tuple.encryptedEntryDataKeyUnderUserKey.associatedData", t.EncryptedEntryDataKeyUnderUserKey.AssociatedData},
+		{"tuple.encryptedEntryData.nonce", t.EncryptedEntryData.Nonce},
+		{"tuple.encryptedEntryData.ciphertext", t.EncryptedEntryData.Ciphertext},
+		{"tuple.encryptedEntryData.associatedData", t.EncryptedEntryData.AssociatedData},
+		{"tuple.encryptedAssignmentData.nonce", t.EncryptedAssignmentData.Nonce},
+		{"tuple.encryptedAssignmentData.ciphertext", t.EncryptedAssignmentData.Ciphertext},
+		{"tuple.encryptedAssignmentData.associatedData", t.EncryptedAssignmentData.AssociatedData},
+	}
+}
+
+// Compare returns every computed value of actual that differs from expected.
+// Values an implementation left empty are reported too, so an implementation
+// that does not expose an intermediate value should expect mismatches for it.
+func Compare(expected, actual Vector) []Mismatch {
+	var mismatches []Mismatch
+	actualOutputs := actual.outputs()
+	for i, e := range expected.outputs() {
+		if !bytes.Equal(e.value, actualOutputs[i].value) {
+			mismatches = append(mismatches, Mismatch{
+				Vector:   expected.Name,
+				Field:    e.name,
+				Expected: e.value,
+				Actual:   actualOutputs[i].value,
+			})
+		}
+	}
+	return mismatches
+}
+
+// CompareVectors matches actual vectors to expected ones by name and compares
+// them. Expected vectors without a counterpart are reported as missing.
+func CompareVectors(expected, actual []Vector) []Mismatch {
+	actualByName := make(map[string]Vector, len(actual))
+	for _, v := range actual {
+		actualByName[v.Name] = v
+	}
+
+	var mismatches []Mismatch
+	for _, e := range expected {
+		a, ok := actualByName[e.Name]
+		if !ok {
+			mismatches = append(mismatches, Mismatch{Vector: e.Name})
+			continue
+		}
+		mismatches = append(mismatches, Compare(e, a)...)
+	}
+	return mismatches
+}
+
+// Verify recomputes every vector of file with this implementation and returns
+// the values that differ from the file
+func Verify(file File) ([]Mismatch, error) {
+	computed, err := Compute(file)
+	if err != nil {
+		return nil, err
+	}
+	return CompareVectors(file.Vectors, computed), nil
+}